@@ -0,0 +1,252 @@
+// Package bodycomp implements the standard body composition formulas: BMI, basal metabolic rate,
+// lean body mass, and body fat from tape measurements or skinfolds. Every result names the formula
+// and the inputs it came from, so a number can always be traced back.
+package bodycomp
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Sexes the sex-specific formulas know
+const (
+	Male   = "male"
+	Female = "female"
+)
+
+// Formulas
+const (
+	FormulaBMI             = "bmi"
+	FormulaMifflinStJeor   = "mifflin_st_jeor"
+	FormulaKatchMcArdle    = "katch_mcardle"
+	FormulaLeanFromBodyFat = "weight_minus_fat"
+	FormulaBoer            = "boer"
+	FormulaUSNavy          = "us_navy"
+	FormulaJacksonPollock3 = "jackson_pollock_3"
+	FormulaJacksonPollock7 = "jackson_pollock_7"
+)
+
+// Result is a computed value with the formula and inputs behind it
+type Result struct {
+	Value   float64            `json:"value"`
+	Unit    string             `json:"unit"`
+	Formula string             `json:"formula"`
+	Inputs  map[string]float64 `json:"inputs"`
+	Note    string             `json:"note,omitempty"`
+}
+
+func round(v float64, places int) float64 {
+	p := math.Pow(10, float64(places))
+	return math.Round(v*p) / p
+}
+
+// positive checks every input was given, naming the missing ones
+func positive(inputs map[string]float64) error {
+	var missing []string
+	for name, v := range inputs {
+		if !(v > 0) {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	sort.Strings(missing)
+	return fmt.Errorf("needs %s", strings.Join(missing, ", "))
+}
+
+func knownSex(sex string) error {
+	if sex != Male && sex != Female {
+		return fmt.Errorf("needs sex")
+	}
+	return nil
+}
+
+// BMI is weight over height squared, in kg/m²
+func BMI(weightKg, heightCm float64) (Result, error) {
+	inputs := map[string]float64{"weight_kg": weightKg, "height_cm": heightCm}
+	if err := positive(inputs); err != nil {
+		return Result{}, err
+	}
+	m := heightCm / 100
+	return Result{Value: round(weightKg/(m*m), 1), Unit: "kg/m²", Formula: FormulaBMI, Inputs: inputs}, nil
+}
+
+// BMICategory is the WHO adult category for a BMI
+func BMICategory(bmi float64) string {
+	switch {
+	case bmi < 18.5:
+		return "underweight"
+	case bmi < 25:
+		return "normal"
+	case bmi < 30:
+		return "overweight"
+	}
+	return "obese"
+}
+
+// MifflinStJeor estimates basal metabolic rate in kcal/day from weight, height, age and sex.
+// Without a known sex it uses the midpoint of the male (+5) and female (-161) constants.
+func MifflinStJeor(weightKg, heightCm float64, age int, sex string) (Result, error) {
+	inputs := map[string]float64{"weight_kg": weightKg, "height_cm": heightCm, "age": float64(age)}
+	if err := positive(inputs); err != nil {
+		return Result{}, err
+	}
+	bmr := 10*weightKg + 6.25*heightCm - 5*float64(age)
+	r := Result{Unit: "kcal/day", Formula: FormulaMifflinStJeor, Inputs: inputs}
+	switch sex {
+	case Male:
+		bmr += 5
+	case Female:
+		bmr -= 161
+	default:
+		bmr -= 78
+		r.Note = "sex unknown; the midpoint of the male and female constants was used"
+	}
+	r.Value = math.Round(bmr)
+	return r, nil
+}
+
+// KatchMcArdle estimates basal metabolic rate in kcal/day from lean body mass, which makes it the
+// better estimate for people whose body fat is far from average
+func KatchMcArdle(leanMassKg float64) (Result, error) {
+	inputs := map[string]float64{"lean_body_mass_kg": leanMassKg}
+	if err := positive(inputs); err != nil {
+		return Result{}, err
+	}
+	return Result{Value: math.Round(370 + 21.6*leanMassKg), Unit: "kcal/day", Formula: FormulaKatchMcArdle, Inputs: inputs}, nil
+}
+
+// LeanMassFromBodyFat is weight less the fat a body fat percentage accounts for, in kg
+func LeanMassFromBodyFat(weightKg, bodyFatPct float64) (Result, error) {
+	inputs := map[string]float64{"weight_kg": weightKg, "body_fat_pct": bodyFatPct}
+	if err := positive(inputs); err != nil {
+		return Result{}, err
+	}
+	if bodyFatPct >= 100 {
+		return Result{}, fmt.Errorf("body_fat_pct must be below 100")
+	}
+	return Result{Value: round(weightKg*(1-bodyFatPct/100), 1), Unit: "kg", Formula: FormulaLeanFromBodyFat, Inputs: inputs}, nil
+}
+
+// Boer estimates lean body mass in kg from weight and height when body fat isn't known
+func Boer(weightKg, heightCm float64, sex string) (Result, error) {
+	inputs := map[string]float64{"weight_kg": weightKg, "height_cm": heightCm}
+	if err := positive(inputs); err != nil {
+		return Result{}, err
+	}
+	if err := knownSex(sex); err != nil {
+		return Result{}, err
+	}
+	lbm := 0.407*weightKg + 0.267*heightCm - 19.2
+	if sex == Female {
+		lbm = 0.252*weightKg + 0.473*heightCm - 48.3
+	}
+	return Result{Value: round(lbm, 1), Unit: "kg", Formula: FormulaBoer, Inputs: inputs}, nil
+}
+
+// siri converts body density in g/cm³ to body fat %
+func siri(density float64) float64 {
+	return 495/density - 450
+}
+
+// bodyFatResult checks a body fat estimate is physically possible; formulas fed measurement
+// mistakes can return anything
+func bodyFatResult(pct float64, formula string, inputs map[string]float64) (Result, error) {
+	if math.IsNaN(pct) || pct < 2 || pct > 75 {
+		return Result{}, fmt.Errorf("the measurements give an implausible result; check them")
+	}
+	return Result{Value: round(pct, 1), Unit: "%", Formula: formula, Inputs: inputs}, nil
+}
+
+// USNavy estimates body fat % from tape measurements in cm: waist and neck, plus hips for women
+func USNavy(sex string, heightCm, waistCm, neckCm, hipsCm float64) (Result, error) {
+	if err := knownSex(sex); err != nil {
+		return Result{}, err
+	}
+	inputs := map[string]float64{"height_cm": heightCm, "waist_cm": waistCm, "neck_cm": neckCm}
+	if sex == Female {
+		inputs["hips_cm"] = hipsCm
+	}
+	if err := positive(inputs); err != nil {
+		return Result{}, err
+	}
+
+	var density float64
+	if sex == Male {
+		if waistCm <= neckCm {
+			return Result{}, fmt.Errorf("waist_cm must be larger than neck_cm")
+		}
+		density = 1.0324 - 0.19077*math.Log10(waistCm-neckCm) + 0.15456*math.Log10(heightCm)
+	} else {
+		if waistCm+hipsCm <= neckCm {
+			return Result{}, fmt.Errorf("waist_cm plus hips_cm must be larger than neck_cm")
+		}
+		density = 1.29579 - 0.35004*math.Log10(waistCm+hipsCm-neckCm) + 0.22100*math.Log10(heightCm)
+	}
+	return bodyFatResult(siri(density), FormulaUSNavy, inputs)
+}
+
+// Skinfolds are caliper readings in mm; a zero is a site that wasn't measured
+type Skinfolds struct {
+	Chest, Abdominal, Thigh, Triceps, Subscapular, Suprailiac, Midaxillary float64
+}
+
+// JacksonPollock3 estimates body fat % from three skinfolds and age: chest, abdominal and thigh
+// for men; triceps, suprailiac and thigh for women. Density is converted with the Siri equation.
+func JacksonPollock3(sex string, age int, s Skinfolds) (Result, error) {
+	if err := knownSex(sex); err != nil {
+		return Result{}, err
+	}
+	inputs := map[string]float64{"age": float64(age), "thigh_skinfold_mm": s.Thigh}
+	if sex == Male {
+		inputs["chest_skinfold_mm"], inputs["abdominal_skinfold_mm"] = s.Chest, s.Abdominal
+	} else {
+		inputs["triceps_skinfold_mm"], inputs["suprailiac_skinfold_mm"] = s.Triceps, s.Suprailiac
+	}
+	if err := positive(inputs); err != nil {
+		return Result{}, err
+	}
+
+	a := float64(age)
+	var density float64
+	if sex == Male {
+		sum := s.Chest + s.Abdominal + s.Thigh
+		density = 1.10938 - 0.0008267*sum + 0.0000016*sum*sum - 0.0002574*a
+	} else {
+		sum := s.Triceps + s.Suprailiac + s.Thigh
+		density = 1.0994921 - 0.0009929*sum + 0.0000023*sum*sum - 0.0001392*a
+	}
+	return bodyFatResult(siri(density), FormulaJacksonPollock3, inputs)
+}
+
+// JacksonPollock7 estimates body fat % from seven skinfolds (chest, midaxillary, triceps,
+// subscapular, abdominal, suprailiac, thigh) and age, converting density with the Siri equation
+func JacksonPollock7(sex string, age int, s Skinfolds) (Result, error) {
+	if err := knownSex(sex); err != nil {
+		return Result{}, err
+	}
+	inputs := map[string]float64{
+		"age":                     float64(age),
+		"chest_skinfold_mm":       s.Chest,
+		"midaxillary_skinfold_mm": s.Midaxillary,
+		"triceps_skinfold_mm":     s.Triceps,
+		"subscapular_skinfold_mm": s.Subscapular,
+		"abdominal_skinfold_mm":   s.Abdominal,
+		"suprailiac_skinfold_mm":  s.Suprailiac,
+		"thigh_skinfold_mm":       s.Thigh,
+	}
+	if err := positive(inputs); err != nil {
+		return Result{}, err
+	}
+
+	a := float64(age)
+	sum := s.Chest + s.Midaxillary + s.Triceps + s.Subscapular + s.Abdominal + s.Suprailiac + s.Thigh
+	density := 1.112 - 0.00043499*sum + 0.00000055*sum*sum - 0.00028826*a
+	if sex == Female {
+		density = 1.097 - 0.00046971*sum + 0.00000056*sum*sum - 0.00012828*a
+	}
+	return bodyFatResult(siri(density), FormulaJacksonPollock7, inputs)
+}
@@ -0,0 +1,104 @@
+package bodycomp
+
+import "testing"
+
+// The expected values are the published equations worked through by hand for each case
+func TestFormulas(t *testing.T) {
+	tests := []struct {
+		name    string
+		result  func() (Result, error)
+		want    float64
+		formula string
+		note    bool
+	}{
+		{"bmi", func() (Result, error) { return BMI(70, 175) }, 22.9, FormulaBMI, false},
+		{"bmi obese", func() (Result, error) { return BMI(120, 180) }, 37.0, FormulaBMI, false},
+
+		{"mifflin male", func() (Result, error) { return MifflinStJeor(70, 175, 30, Male) }, 1649, FormulaMifflinStJeor, false},
+		{"mifflin female", func() (Result, error) { return MifflinStJeor(60, 165, 25, Female) }, 1345, FormulaMifflinStJeor, false},
+		{"mifflin unknown sex uses the midpoint", func() (Result, error) { return MifflinStJeor(70, 175, 30, "") }, 1566, FormulaMifflinStJeor, true},
+		{"katch-mcardle", func() (Result, error) { return KatchMcArdle(60) }, 1666, FormulaKatchMcArdle, false},
+
+		{"lean mass from body fat", func() (Result, error) { return LeanMassFromBodyFat(80, 20) }, 64, FormulaLeanFromBodyFat, false},
+		{"boer male", func() (Result, error) { return Boer(80, 180, Male) }, 61.4, FormulaBoer, false},
+		{"boer female", func() (Result, error) { return Boer(60, 165, Female) }, 44.9, FormulaBoer, false},
+
+		{"us navy male", func() (Result, error) { return USNavy(Male, 178, 90, 38, 0) }, 20.1, FormulaUSNavy, false},
+		{"us navy female", func() (Result, error) { return USNavy(Female, 165, 70, 32, 95) }, 24.9, FormulaUSNavy, false},
+
+		{"jackson-pollock 3 male", func() (Result, error) {
+			return JacksonPollock3(Male, 30, Skinfolds{Chest: 10, Abdominal: 20, Thigh: 15})
+		}, 13.6, FormulaJacksonPollock3, false},
+		{"jackson-pollock 3 female", func() (Result, error) {
+			return JacksonPollock3(Female, 25, Skinfolds{Triceps: 15, Suprailiac: 20, Thigh: 25})
+		}, 23.8, FormulaJacksonPollock3, false},
+		{"jackson-pollock 7 male", func() (Result, error) {
+			return JacksonPollock7(Male, 35, Skinfolds{Chest: 10, Midaxillary: 12, Triceps: 8, Subscapular: 14, Abdominal: 20, Suprailiac: 15, Thigh: 15})
+		}, 14.4, FormulaJacksonPollock7, false},
+		{"jackson-pollock 7 female", func() (Result, error) {
+			return JacksonPollock7(Female, 40, Skinfolds{Chest: 12, Midaxillary: 10, Triceps: 18, Subscapular: 14, Abdominal: 16, Suprailiac: 15, Thigh: 22})
+		}, 22.3, FormulaJacksonPollock7, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := tt.result()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if r.Value != tt.want || r.Formula != tt.formula {
+				t.Errorf("got %v by %s, want %v by %s", r.Value, r.Formula, tt.want, tt.formula)
+			}
+			if (r.Note != "") != tt.note {
+				t.Errorf("note %q", r.Note)
+			}
+			if len(r.Inputs) == 0 {
+				t.Error("inputs should be listed")
+			}
+		})
+	}
+}
+
+func TestFormulaErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		result func() (Result, error)
+		want   string
+	}{
+		{"bmi without weight", func() (Result, error) { return BMI(0, 175) }, "needs weight_kg"},
+		{"mifflin lists every missing input", func() (Result, error) { return MifflinStJeor(70, 0, 0, Male) }, "needs age, height_cm"},
+		{"katch-mcardle without lean mass", func() (Result, error) { return KatchMcArdle(0) }, "needs lean_body_mass_kg"},
+		{"lean mass from an impossible body fat", func() (Result, error) { return LeanMassFromBodyFat(80, 100) }, "body_fat_pct must be below 100"},
+		{"boer without sex", func() (Result, error) { return Boer(80, 180, "") }, "needs sex"},
+		{"us navy without sex", func() (Result, error) { return USNavy("", 178, 90, 38, 0) }, "needs sex"},
+		{"us navy female without hips", func() (Result, error) { return USNavy(Female, 165, 70, 32, 0) }, "needs hips_cm"},
+		{"us navy waist within neck", func() (Result, error) { return USNavy(Male, 178, 38, 40, 0) }, "waist_cm must be larger than neck_cm"},
+		{"us navy implausible", func() (Result, error) { return USNavy(Male, 200, 40, 38, 0) }, "the measurements give an implausible result; check them"},
+		{"jackson-pollock 3 female uses her sites", func() (Result, error) {
+			return JacksonPollock3(Female, 25, Skinfolds{Chest: 10, Abdominal: 20, Thigh: 15})
+		}, "needs suprailiac_skinfold_mm, triceps_skinfold_mm"},
+		{"jackson-pollock 7 without age", func() (Result, error) {
+			return JacksonPollock7(Male, 0, Skinfolds{Chest: 10, Midaxillary: 12, Triceps: 8, Subscapular: 14, Abdominal: 20, Suprailiac: 15})
+		}, "needs age, thigh_skinfold_mm"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.result()
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("got %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestBMICategory(t *testing.T) {
+	for bmi, want := range map[float64]string{
+		16: "underweight", 18.4: "underweight", 18.5: "normal", 24.9: "normal",
+		25: "overweight", 29.9: "overweight", 30: "obese", 45: "obese",
+	} {
+		if got := BMICategory(bmi); got != want {
+			t.Errorf("BMICategory(%v) = %s, want %s", bmi, got, want)
+		}
+	}
+}
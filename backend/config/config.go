@@ -17,7 +17,8 @@ type Config struct {
 	OpenAIAPIKey       string // Changed from Nutritionix to OpenAI
 	JWTSecret          string
 	FrontendURL        string
-	TokenExpiryHr      int // token expiry in hours
+	TokenExpiryHr      int    // token expiry in hours
+	UploadDir          string // where uploaded files such as progress photos are kept
 }
 
 var AppConfig Config
@@ -35,6 +36,7 @@ func LoadConfig() {
 		JWTSecret:          mustGetEnv("JWT_SECRET"),
 		FrontendURL:        mustGetEnv("FRONTEND_URL"),
 		TokenExpiryHr:      getEnvAsInt("TOKEN_EXPIRY_HR", 72), // default 72 hours
+		UploadDir:          getEnv("UPLOAD_DIR", "uploads"),
 	}
 }
 
@@ -0,0 +1,259 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"nutritionix/backend/config"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Domain events achievements are checked on
+const (
+	EventMealLogged       = "meal_logged"
+	EventWorkoutCompleted = "workout_completed"
+	EventPersonalRecord   = "personal_record"
+	EventGoalPeriodClosed = "goal_period_closed"
+)
+
+// achievementMetric is a number computed from a user's logged data; $1 is the user. Events lists
+// what can change it.
+type achievementMetric struct {
+	Query  string
+	Events []string
+}
+
+// bestRunSQL turns a query of distinct dates (column d) into the longest run of consecutive days
+func bestRunSQL(dates string) string {
+	return `SELECT COALESCE(MAX(n), 0) FROM (
+            SELECT COUNT(*) AS n FROM (
+                SELECT d, d - (ROW_NUMBER() OVER (ORDER BY d))::int AS run FROM (` + dates + `) dates
+            ) numbered GROUP BY run
+        ) runs`
+}
+
+var achievementMetrics = map[string]achievementMetric{
+	"meals_logged": {
+		Query:  `SELECT COUNT(*) FROM meals WHERE user_id = $1`,
+		Events: []string{EventMealLogged},
+	},
+	"meal_logging_streak": {
+		Query:  bestRunSQL(`SELECT DISTINCT date::date AS d FROM meals WHERE user_id = $1`),
+		Events: []string{EventMealLogged},
+	},
+	"workouts_completed": {
+		Query:  `SELECT COUNT(*) FROM workouts WHERE user_id = $1 AND status = 'completed'`,
+		Events: []string{EventWorkoutCompleted},
+	},
+	"personal_records": {
+		Query:  `SELECT COUNT(*) FROM personal_records WHERE user_id = $1`,
+		Events: []string{EventPersonalRecord},
+	},
+	// Days met on a daily protein goal, as recorded when each day's goal period closed
+	"protein_goal_streak": {
+		Query: bestRunSQL(`SELECT DISTINCT gp.period_start AS d
+            FROM goal_periods gp JOIN user_goals g ON g.id = gp.goal_id
+            WHERE g.user_id = $1 AND g.goal_type = 'protein' AND g.time_frame = 'daily' AND gp.status = 'met'`),
+		Events: []string{EventGoalPeriodClosed},
+	},
+}
+
+// achievement is a badge rule: it is earned once Metric reaches Target
+type achievement struct {
+	Key         string `json:"key"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Icon        string `json:"icon"`
+	Metric      string `json:"metric"`
+	Target      int    `json:"target"`
+}
+
+var achievementList = []achievement{
+	{Key: "first_meal", Name: "First Bite", Description: "Log your first meal", Icon: "🍽️", Metric: "meals_logged", Target: 1},
+	{Key: "meal_streak_7", Name: "Creature of Habit", Description: "Log meals 7 days in a row", Icon: "📅", Metric: "meal_logging_streak", Target: 7},
+	{Key: "meal_streak_30", Name: "Food Diarist", Description: "Log meals 30 days in a row", Icon: "📖", Metric: "meal_logging_streak", Target: 30},
+	{Key: "first_workout", Name: "Off the Couch", Description: "Complete your first workout", Icon: "👟", Metric: "workouts_completed", Target: 1},
+	{Key: "workouts_10", Name: "Getting Serious", Description: "Complete 10 workouts", Icon: "🏋️", Metric: "workouts_completed", Target: 10},
+	{Key: "workouts_100", Name: "Centurion", Description: "Complete 100 workouts", Icon: "💯", Metric: "workouts_completed", Target: 100},
+	{Key: "first_pr", Name: "Personal Best", Description: "Set a new personal record", Icon: "🏆", Metric: "personal_records", Target: 1},
+	{Key: "protein_streak_30", Name: "Protein Machine", Description: "Hit your daily protein goal 30 days in a row", Icon: "🥩", Metric: "protein_goal_streak", Target: 30},
+}
+
+// achievementProgress is an achievement with how far the user has got
+type achievementProgress struct {
+	achievement
+	Progress  int        `json:"progress"` // capped at the target
+	Earned    bool       `json:"earned"`
+	AwardedAt *time.Time `json:"awarded_at"`
+}
+
+// computeAchievementMetrics evaluates the named metrics for a user
+func computeAchievementMetrics(userID uuid.UUID, metrics map[string]bool) (map[string]int, error) {
+	values := make(map[string]int, len(metrics))
+	for key := range metrics {
+		var v int
+		if err := config.DB.QueryRow(achievementMetrics[key].Query, userID).Scan(&v); err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+		values[key] = v
+	}
+	return values, nil
+}
+
+// awardAchievements records every achievement whose target the values reach. The notification is
+// written in the same transaction as the award, and only when the award row is new, so each badge
+// is announced exactly once however often it is checked.
+func awardAchievements(userID uuid.UUID, values map[string]int) error {
+	tx, err := config.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, a := range achievementList {
+		value, ok := values[a.Metric]
+		if !ok || value < a.Target {
+			continue
+		}
+		var awarded bool
+		err := tx.QueryRow(
+			`INSERT INTO user_achievements (user_id, achievement_key, progress_value) VALUES ($1, $2, $3)
+             ON CONFLICT (user_id, achievement_key) DO NOTHING
+             RETURNING true`,
+			userID, a.Key, value,
+		).Scan(&awarded)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+		if !awarded {
+			continue
+		}
+		if _, err := tx.Exec(
+			`INSERT INTO notifications (user_id, message, is_read, created_at, updated_at) VALUES ($1, $2, false, NOW(), NOW())`,
+			userID, fmt.Sprintf("%s Achievement unlocked: %s! %s.", a.Icon, a.Name, a.Description),
+		); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// CheckAchievements re-evaluates the achievements an event can affect. Handlers call it after the
+// change is saved; errors are logged rather than failing the request.
+func CheckAchievements(userID uuid.UUID, event string) {
+	metrics := map[string]bool{}
+	for key, m := range achievementMetrics {
+		for _, e := range m.Events {
+			if e == event {
+				metrics[key] = true
+			}
+		}
+	}
+	if err := checkAchievementMetrics(userID, metrics); err != nil {
+		log.Println("ACHIEVEMENT CHECK ERROR (CheckAchievements):", err)
+	}
+}
+
+// checkAchievementMetrics computes the metrics and awards what they reach
+func checkAchievementMetrics(userID uuid.UUID, metrics map[string]bool) error {
+	values, err := computeAchievementMetrics(userID, metrics)
+	if err != nil {
+		return err
+	}
+	return awardAchievements(userID, values)
+}
+
+// allAchievementMetrics is every metric a rule uses
+func allAchievementMetrics() map[string]bool {
+	metrics := map[string]bool{}
+	for _, a := range achievementList {
+		metrics[a.Metric] = true
+	}
+	return metrics
+}
+
+// RunAchievementBackfill is the nightly job that evaluates every rule for every user, catching
+// awards missed by events (data imported, rules added since)
+func RunAchievementBackfill() {
+	rows, err := config.DB.Query(`SELECT id FROM users`)
+	if err != nil {
+		log.Println("DB SELECT ERROR (RunAchievementBackfill):", err)
+		return
+	}
+	var users []uuid.UUID
+	for rows.Next() {
+		var userID uuid.UUID
+		if err := rows.Scan(&userID); err != nil {
+			log.Println("DB SCAN ERROR (RunAchievementBackfill):", err)
+			continue
+		}
+		users = append(users, userID)
+	}
+	rows.Close()
+
+	metrics := allAchievementMetrics()
+	failed := 0
+	for _, userID := range users {
+		if err := checkAchievementMetrics(userID, metrics); err != nil {
+			log.Println("ACHIEVEMENT CHECK ERROR (RunAchievementBackfill):", err)
+			failed++
+		}
+	}
+	log.Printf("🏅 Achievement backfill checked %d users (%d failed)", len(users), failed)
+}
+
+// GetAchievements handles GET /user/achievements: every badge with the user's progress toward it
+func GetAchievements(c *gin.Context) {
+	userID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	values, err := computeAchievementMetrics(userID, allAchievementMetrics())
+	if err != nil {
+		log.Println("DB SELECT ERROR (GetAchievements):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch achievements"})
+		return
+	}
+	// Award anything reached but not yet recorded before listing
+	if err := awardAchievements(userID, values); err != nil {
+		log.Println("DB INSERT ERROR (GetAchievements):", err)
+	}
+
+	rows, err := config.DB.Query(`SELECT achievement_key, awarded_at FROM user_achievements WHERE user_id = $1`, userID)
+	if err != nil {
+		log.Println("DB SELECT ERROR (GetAchievements):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch achievements"})
+		return
+	}
+	defer rows.Close()
+	awarded := map[string]time.Time{}
+	for rows.Next() {
+		var key string
+		var at time.Time
+		if err := rows.Scan(&key, &at); err != nil {
+			log.Println("DB SCAN ERROR (GetAchievements):", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch achievements"})
+			return
+		}
+		awarded[key] = at
+	}
+
+	earned := 0
+	achievements := make([]achievementProgress, len(achievementList))
+	for i, a := range achievementList {
+		p := achievementProgress{achievement: a, Progress: min(values[a.Metric], a.Target)}
+		if at, ok := awarded[a.Key]; ok {
+			p.Earned, p.AwardedAt, p.Progress = true, &at, a.Target
+			earned++
+		}
+		achievements[i] = p
+	}
+	c.JSON(http.StatusOK, gin.H{"earned": earned, "total": len(achievementList), "achievements": achievements})
+}
@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"nutritionix/backend/config"
+	"nutritionix/backend/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// maxActivityDays caps the days in one upload and in one listing
+const maxActivityDays = 366
+
+// activitySample is one day in a POST /user/activity upload. Counters are the day's totals as of
+// recorded_at; fields the device doesn't track are left out.
+type activitySample struct {
+	Date          string   `json:"date"`
+	Steps         *int     `json:"steps"`
+	ActiveMinutes *int     `json:"active_minutes"`
+	DistanceM     *float64 `json:"distance_m"`
+	Floors        *int     `json:"floors"`
+	RestingHR     *int     `json:"resting_hr"`
+	RecordedAt    string   `json:"recorded_at"` // RFC 3339, defaults to now
+}
+
+// validateActivitySample checks a sample and returns its recorded_at time
+func validateActivitySample(s activitySample, latestDate string) (time.Time, error) {
+	if _, err := time.Parse("2006-01-02", s.Date); err != nil {
+		return time.Time{}, fmt.Errorf("date must be in YYYY-MM-DD format")
+	}
+	if s.Date > latestDate {
+		return time.Time{}, fmt.Errorf("%s is in the future", s.Date)
+	}
+	if s.Steps != nil && (*s.Steps < 0 || *s.Steps > 200000) {
+		return time.Time{}, fmt.Errorf("steps must be between 0 and 200000")
+	}
+	if s.ActiveMinutes != nil && (*s.ActiveMinutes < 0 || *s.ActiveMinutes > 1440) {
+		return time.Time{}, fmt.Errorf("active_minutes must be between 0 and 1440")
+	}
+	if s.DistanceM != nil && (*s.DistanceM < 0 || *s.DistanceM > 500000) {
+		return time.Time{}, fmt.Errorf("distance_m must be between 0 and 500000")
+	}
+	if s.Floors != nil && (*s.Floors < 0 || *s.Floors > 1000) {
+		return time.Time{}, fmt.Errorf("floors must be between 0 and 1000")
+	}
+	if s.RestingHR != nil && (*s.RestingHR < 25 || *s.RestingHR > 120) {
+		return time.Time{}, fmt.Errorf("resting_hr must be between 25 and 120")
+	}
+	if s.RecordedAt == "" {
+		return time.Now(), nil
+	}
+	recordedAt, err := time.Parse(time.RFC3339, s.RecordedAt)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("recorded_at must be an RFC 3339 timestamp")
+	}
+	return recordedAt, nil
+}
+
+// activityColumns is the column list scanned by scanDailyActivity
+const activityColumns = `user_id, date::text, steps, active_minutes, distance_m, floors, resting_hr, recorded_at, updated_at`
+
+func scanDailyActivity(row interface{ Scan(...interface{}) error }) (models.DailyActivity, error) {
+	var a models.DailyActivity
+	err := row.Scan(&a.UserID, &a.Date, &a.Steps, &a.ActiveMinutes, &a.DistanceM, &a.Floors, &a.RestingHR,
+		&a.RecordedAt, &a.UpdatedAt)
+	return a, err
+}
+
+// UpsertActivity handles POST /user/activity with {"days": [...]}.
+// Each day is merged into what is stored: counters only grow, so re-sent or out-of-order partial-day
+// snapshots never lower them, and resting heart rate comes from the newest sample that has one.
+func UpsertActivity(c *gin.Context) {
+	userID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	var input struct {
+		Days []activitySample `json:"days"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(input.Days) == 0 || len(input.Days) > maxActivityDays {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("days must have between 1 and %d entries", maxActivityDays)})
+		return
+	}
+
+	// Devices a timezone ahead of the profile can already be on tomorrow
+	today, _ := time.Parse("2006-01-02", localToday(userTimezone(userID)))
+	latestDate := today.AddDate(0, 0, 1).Format("2006-01-02")
+	recordedAt := make([]time.Time, len(input.Days))
+	for i, s := range input.Days {
+		if recordedAt[i], err = validateActivitySample(s, latestDate); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("days[%d]: %s", i, err.Error())})
+			return
+		}
+	}
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		log.Println("DB BEGIN ERROR (UpsertActivity):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save activity"})
+		return
+	}
+	defer tx.Rollback()
+
+	saved := map[string]models.DailyActivity{}
+	for i, s := range input.Days {
+		a, err := scanDailyActivity(tx.QueryRow(
+			`INSERT INTO daily_activity (user_id, date, steps, active_minutes, distance_m, floors, resting_hr, recorded_at)
+             VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+             ON CONFLICT (user_id, date) DO UPDATE SET
+                 steps = GREATEST(daily_activity.steps, EXCLUDED.steps),
+                 active_minutes = GREATEST(daily_activity.active_minutes, EXCLUDED.active_minutes),
+                 distance_m = GREATEST(daily_activity.distance_m, EXCLUDED.distance_m),
+                 floors = GREATEST(daily_activity.floors, EXCLUDED.floors),
+                 resting_hr = CASE WHEN EXCLUDED.recorded_at >= daily_activity.recorded_at
+                                   THEN COALESCE(EXCLUDED.resting_hr, daily_activity.resting_hr)
+                                   ELSE COALESCE(daily_activity.resting_hr, EXCLUDED.resting_hr) END,
+                 recorded_at = GREATEST(daily_activity.recorded_at, EXCLUDED.recorded_at),
+                 updated_at = NOW()
+             RETURNING `+activityColumns,
+			userID, s.Date, s.Steps, s.ActiveMinutes, s.DistanceM, s.Floors, s.RestingHR, recordedAt[i],
+		))
+		if err != nil {
+			log.Println("DB UPSERT ERROR (UpsertActivity):", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save activity"})
+			return
+		}
+		saved[a.Date] = a
+	}
+	if err := tx.Commit(); err != nil {
+		log.Println("DB COMMIT ERROR (UpsertActivity):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save activity"})
+		return
+	}
+	RecomputeGoalProgress(userID)
+
+	days := make([]models.DailyActivity, 0, len(saved))
+	for _, s := range input.Days {
+		if a, ok := saved[s.Date]; ok {
+			days = append(days, a)
+			delete(saved, s.Date)
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"days": days})
+}
+
+// GetActivity handles GET /user/activity?from=&to= (dates, inclusive; defaults to the last 30 days)
+func GetActivity(c *gin.Context) {
+	userID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	to := c.DefaultQuery("to", localToday(userTimezone(userID)))
+	toDate, err := time.Parse("2006-01-02", to)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "to must be in YYYY-MM-DD format"})
+		return
+	}
+	from := c.DefaultQuery("from", toDate.AddDate(0, 0, -29).Format("2006-01-02"))
+	fromDate, err := time.Parse("2006-01-02", from)
+	if err != nil || fromDate.After(toDate) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must be a YYYY-MM-DD date on or before to"})
+		return
+	}
+	if toDate.Sub(fromDate) >= maxActivityDays*24*time.Hour {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "the range can be at most 366 days"})
+		return
+	}
+
+	rows, err := config.DB.Query(
+		`SELECT `+activityColumns+` FROM daily_activity WHERE user_id = $1 AND date BETWEEN $2 AND $3 ORDER BY date`,
+		userID, from, to,
+	)
+	if err != nil {
+		log.Println("DB SELECT ERROR (GetActivity):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch activity"})
+		return
+	}
+	defer rows.Close()
+
+	days := []models.DailyActivity{}
+	for rows.Next() {
+		a, err := scanDailyActivity(rows)
+		if err != nil {
+			log.Println("DB SCAN ERROR (GetActivity):", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch activity"})
+			return
+		}
+		days = append(days, a)
+	}
+	c.JSON(http.StatusOK, days)
+}
@@ -0,0 +1,223 @@
+package handlers
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"time"
+
+	"nutritionix/backend/bodycomp"
+	"nutritionix/backend/config"
+	"nutritionix/backend/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// bodyFatLogged is the body fat "formula" of a percentage the user logged themselves, e.g. from a
+// DEXA scan or smart scale
+const bodyFatLogged = "logged"
+
+// measuredResult is a result computed from one body measurement, with the day it was taken
+type measuredResult struct {
+	*bodycomp.Result
+	MeasuredOn string `json:"measured_on"`
+}
+
+// bodyFatSites are the measurements a body fat method needs, which must all come from one session
+func bodyFatSites(formula, sex string) []string {
+	switch formula {
+	case bodycomp.FormulaUSNavy:
+		if sex == bodycomp.Female {
+			return []string{"waist_cm", "neck_cm", "hips_cm"}
+		}
+		return []string{"waist_cm", "neck_cm"}
+	case bodycomp.FormulaJacksonPollock3:
+		if sex == bodycomp.Female {
+			return []string{"triceps_skinfold_mm", "suprailiac_skinfold_mm", "thigh_skinfold_mm"}
+		}
+		return []string{"chest_skinfold_mm", "abdominal_skinfold_mm", "thigh_skinfold_mm"}
+	case bodycomp.FormulaJacksonPollock7:
+		return []string{"chest_skinfold_mm", "midaxillary_skinfold_mm", "triceps_skinfold_mm", "subscapular_skinfold_mm",
+			"abdominal_skinfold_mm", "suprailiac_skinfold_mm", "thigh_skinfold_mm"}
+	}
+	return []string{"body_fat_pct"}
+}
+
+// skinfoldsOf reads the caliper sites of a measurement
+func skinfoldsOf(m *models.BodyMeasurement) bodycomp.Skinfolds {
+	return bodycomp.Skinfolds{
+		Chest:       measurementValue(m, "chest_skinfold_mm"),
+		Abdominal:   measurementValue(m, "abdominal_skinfold_mm"),
+		Thigh:       measurementValue(m, "thigh_skinfold_mm"),
+		Triceps:     measurementValue(m, "triceps_skinfold_mm"),
+		Subscapular: measurementValue(m, "subscapular_skinfold_mm"),
+		Suprailiac:  measurementValue(m, "suprailiac_skinfold_mm"),
+		Midaxillary: measurementValue(m, "midaxillary_skinfold_mm"),
+	}
+}
+
+// ageOn is the age a user had on date, from the age in their profile today. Without a birth date
+// it can only take off whole years, so it may be a year out near a birthday.
+func ageOn(age int, date, today string) int {
+	d, _ := time.Parse("2006-01-02", date)
+	t, _ := time.Parse("2006-01-02", today)
+	years := t.Year() - d.Year()
+	if t.YearDay() < d.YearDay() {
+		years--
+	}
+	return max(0, age-years)
+}
+
+// GetBodyComposition handles GET /user/body-composition?date= (default today): BMI, body fat by
+// each method the logged measurements allow, lean body mass and BMR, each with the formula and
+// inputs used. Height and sex come from the profile, and age is the profile's less the years since
+// date. Weight is the newest on or before date. Each body fat method reads all its sites from the
+// newest measurement on or before date that has them, and reports that measurement's day.
+// Formulas that can't be computed are listed under unavailable with what they need.
+func GetBodyComposition(c *gin.Context) {
+	userID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	var age, heightCm sql.NullInt64
+	var profileWeight sql.NullFloat64
+	var sex, timezone string
+	err = config.DB.QueryRow(
+		`SELECT age, height, weight, COALESCE(sex, ''), timezone FROM users WHERE id = $1`, userID,
+	).Scan(&age, &heightCm, &profileWeight, &sex, &timezone)
+	if err != nil {
+		log.Println("DB SELECT ERROR (GetBodyComposition):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load profile"})
+		return
+	}
+
+	today := localToday(timezone)
+	date := c.DefaultQuery("date", today)
+	if _, err := time.Parse("2006-01-02", date); err != nil || date > today {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "date must be a YYYY-MM-DD date no later than today"})
+		return
+	}
+	values, err := measurementsAsOf(userID, date)
+	if err != nil {
+		log.Println("DB SELECT ERROR (GetBodyComposition):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute body composition"})
+		return
+	}
+
+	// The profile weight mirrors the latest weigh-in; a past date needs the weight logged by then
+	weightKg := profileWeight.Float64
+	if v := values["weight_kg"]; v != nil {
+		weightKg = v.Value
+	}
+	height := float64(heightCm.Int64)
+	years := 0
+	if age.Valid {
+		years = ageOn(int(age.Int64), date, today)
+	}
+
+	unavailable := map[string]string{}
+	keep := func(formula string, r bodycomp.Result, err error) *bodycomp.Result {
+		if err != nil {
+			unavailable[formula] = err.Error()
+			return nil
+		}
+		return &r
+	}
+
+	r, err := bodycomp.BMI(weightKg, height)
+	bmi := keep(bodycomp.FormulaBMI, r, err)
+	var bmiCategory *string
+	if bmi != nil {
+		category := bodycomp.BMICategory(bmi.Value)
+		bmiCategory = &category
+	}
+
+	// Body fat, most direct method first: a logged figure, then seven skinfolds, three, and the tape
+	methods := []string{bodyFatLogged, bodycomp.FormulaJacksonPollock7, bodycomp.FormulaJacksonPollock3, bodycomp.FormulaUSNavy}
+	bodyFat := map[string]*measuredResult{}
+	var best *measuredResult
+	var bestRow *models.BodyMeasurement
+	for _, formula := range methods {
+		row, err := measurementRowAsOf(userID, date, bodyFatSites(formula, sex)...)
+		if err != nil {
+			log.Println("DB SELECT ERROR (GetBodyComposition):", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute body composition"})
+			return
+		}
+		var result *bodycomp.Result
+		switch formula {
+		case bodyFatLogged:
+			if row != nil {
+				pct := measurementValue(row, "body_fat_pct")
+				result = &bodycomp.Result{Value: pct, Unit: "%", Formula: bodyFatLogged, Inputs: map[string]float64{"body_fat_pct": pct}}
+			}
+		case bodycomp.FormulaUSNavy:
+			r, err = bodycomp.USNavy(sex, height, measurementValue(row, "waist_cm"), measurementValue(row, "neck_cm"), measurementValue(row, "hips_cm"))
+			result = keep(formula, r, err)
+		case bodycomp.FormulaJacksonPollock3:
+			r, err = bodycomp.JacksonPollock3(sex, years, skinfoldsOf(row))
+			result = keep(formula, r, err)
+		case bodycomp.FormulaJacksonPollock7:
+			r, err = bodycomp.JacksonPollock7(sex, years, skinfoldsOf(row))
+			result = keep(formula, r, err)
+		}
+		if result == nil {
+			bodyFat[formula] = nil
+			continue
+		}
+		bodyFat[formula] = &measuredResult{Result: result, MeasuredOn: row.Date}
+		if best == nil {
+			best, bestRow = bodyFat[formula], row
+		}
+	}
+
+	// Lean mass uses the best body fat, with the weight from the same session when it was taken.
+	// Without any it falls back to an estimate from height and weight.
+	var leanMass *bodycomp.Result
+	if best != nil {
+		leanWeight := weightKg
+		if w := measurementValue(bestRow, "weight_kg"); w > 0 {
+			leanWeight = w
+		}
+		r, err = bodycomp.LeanMassFromBodyFat(leanWeight, best.Value)
+		if leanMass = keep(bodycomp.FormulaLeanFromBodyFat, r, err); leanMass != nil {
+			leanMass.Note = "body fat from " + best.Formula + " measured on " + best.MeasuredOn
+		}
+	} else {
+		r, err = bodycomp.Boer(weightKg, height, sex)
+		leanMass = keep(bodycomp.FormulaBoer, r, err)
+	}
+
+	r, err = bodycomp.MifflinStJeor(weightKg, height, years, sex)
+	mifflin := keep(bodycomp.FormulaMifflinStJeor, r, err)
+	var katch *bodycomp.Result
+	if leanMass != nil && leanMass.Formula == bodycomp.FormulaLeanFromBodyFat {
+		r, err = bodycomp.KatchMcArdle(leanMass.Value)
+		katch = keep(bodycomp.FormulaKatchMcArdle, r, err)
+	} else {
+		unavailable[bodycomp.FormulaKatchMcArdle] = "needs body fat"
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"date": date,
+		"profile": gin.H{
+			"age":       years,
+			"height_cm": heightCm.Int64,
+			"weight_kg": weightKg,
+			"sex":       sex,
+		},
+		"measurements":   values,
+		"bmi":            bmi,
+		"bmi_category":   bmiCategory,
+		"body_fat":       bodyFat,
+		"lean_body_mass": leanMass,
+		"bmr": gin.H{
+			bodycomp.FormulaMifflinStJeor: mifflin,
+			bodycomp.FormulaKatchMcArdle:  katch,
+		},
+		"unavailable": unavailable,
+	})
+}
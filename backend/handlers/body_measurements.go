@@ -0,0 +1,487 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"nutritionix/backend/config"
+	"nutritionix/backend/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// measurementField is a value a body measurement can record, with the range accepted for it
+type measurementField struct {
+	Key      string
+	Min, Max float64
+	value    func(m *models.BodyMeasurement) **float64
+}
+
+// measurementFields are the measured values, in column order
+var measurementFields = []measurementField{
+	{"weight_kg", 20, 500, func(m *models.BodyMeasurement) **float64 { return &m.WeightKg }},
+	{"body_fat_pct", 2, 75, func(m *models.BodyMeasurement) **float64 { return &m.BodyFatPct }},
+	{"waist_cm", 30, 250, func(m *models.BodyMeasurement) **float64 { return &m.WaistCm }},
+	{"hips_cm", 40, 250, func(m *models.BodyMeasurement) **float64 { return &m.HipsCm }},
+	{"chest_cm", 40, 250, func(m *models.BodyMeasurement) **float64 { return &m.ChestCm }},
+	{"neck_cm", 20, 80, func(m *models.BodyMeasurement) **float64 { return &m.NeckCm }},
+	{"arm_cm", 10, 80, func(m *models.BodyMeasurement) **float64 { return &m.ArmCm }},
+	{"thigh_cm", 20, 120, func(m *models.BodyMeasurement) **float64 { return &m.ThighCm }},
+	{"calf_cm", 15, 80, func(m *models.BodyMeasurement) **float64 { return &m.CalfCm }},
+	{"chest_skinfold_mm", 2, 80, func(m *models.BodyMeasurement) **float64 { return &m.ChestSkinfoldMm }},
+	{"abdominal_skinfold_mm", 2, 80, func(m *models.BodyMeasurement) **float64 { return &m.AbdominalSkinfoldMm }},
+	{"thigh_skinfold_mm", 2, 80, func(m *models.BodyMeasurement) **float64 { return &m.ThighSkinfoldMm }},
+	{"triceps_skinfold_mm", 2, 80, func(m *models.BodyMeasurement) **float64 { return &m.TricepsSkinfoldMm }},
+	{"subscapular_skinfold_mm", 2, 80, func(m *models.BodyMeasurement) **float64 { return &m.SubscapularSkinfoldMm }},
+	{"suprailiac_skinfold_mm", 2, 80, func(m *models.BodyMeasurement) **float64 { return &m.SuprailiacSkinfoldMm }},
+	{"midaxillary_skinfold_mm", 2, 80, func(m *models.BodyMeasurement) **float64 { return &m.MidaxillarySkinfoldMm }},
+}
+
+var measurementFieldsByKey = func() map[string]measurementField {
+	m := make(map[string]measurementField, len(measurementFields))
+	for _, f := range measurementFields {
+		m[f.Key] = f
+	}
+	return m
+}()
+
+// maxMeasurementRows caps one listing
+const maxMeasurementRows = 1000
+
+// measurementColumns is the column list scanned by scanBodyMeasurement; m is body_measurements, u the owner
+var measurementColumns = `m.id, m.user_id, m.measured_at, (m.measured_at AT TIME ZONE u.timezone)::date::text, m.` +
+	strings.Join(measurementFieldKeys(), ", m.") + `, m.note, m.created_at, m.updated_at`
+
+func scanBodyMeasurement(row interface{ Scan(...interface{}) error }) (models.BodyMeasurement, error) {
+	var m models.BodyMeasurement
+	dest := []interface{}{&m.ID, &m.UserID, &m.MeasuredAt, &m.Date}
+	for _, f := range measurementFields {
+		dest = append(dest, f.value(&m))
+	}
+	err := row.Scan(append(dest, &m.Note, &m.CreatedAt, &m.UpdatedAt)...)
+	return m, err
+}
+
+// loadBodyMeasurement loads one of the user's measurements
+func loadBodyMeasurement(id, userID uuid.UUID) (models.BodyMeasurement, error) {
+	return scanBodyMeasurement(config.DB.QueryRow(
+		`SELECT `+measurementColumns+` FROM body_measurements m JOIN users u ON u.id = m.user_id
+         WHERE m.id = $1 AND m.user_id = $2`,
+		id, userID,
+	))
+}
+
+// applyMeasurementValues sets the values present in a JSON body on m. A null clears a value. Values
+// are rounded to the precision they are stored with.
+func applyMeasurementValues(m *models.BodyMeasurement, body map[string]json.RawMessage) error {
+	for _, f := range measurementFields {
+		raw, ok := body[f.Key]
+		if !ok {
+			continue
+		}
+		var v *float64
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return fmt.Errorf("%s must be a number", f.Key)
+		}
+		if v != nil {
+			if *v < f.Min || *v > f.Max {
+				return fmt.Errorf("%s must be between %g and %g", f.Key, f.Min, f.Max)
+			}
+			rounded := math.Round(*v*10) / 10
+			if f.Key == "weight_kg" {
+				rounded = round2(*v)
+			}
+			v = &rounded
+		}
+		*f.value(m) = v
+	}
+	for _, f := range measurementFields {
+		if *f.value(m) != nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("a measurement needs at least one of %s", measurementFieldList())
+}
+
+func measurementFieldKeys() []string {
+	keys := make([]string, len(measurementFields))
+	for i, f := range measurementFields {
+		keys[i] = f.Key
+	}
+	return keys
+}
+
+func measurementFieldList() string {
+	return strings.Join(measurementFieldKeys(), ", ")
+}
+
+// parseMeasuredAt reads an RFC 3339 timestamp that can't be in the future; empty means now
+func parseMeasuredAt(field, value string) (time.Time, error) {
+	if value == "" {
+		return time.Now(), nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s must be an RFC 3339 timestamp", field)
+	}
+	if t.After(time.Now().Add(time.Hour)) {
+		return time.Time{}, fmt.Errorf("%s can't be in the future", field)
+	}
+	return t, nil
+}
+
+// saveBodyMeasurement inserts m, or updates it when it has an ID, and refreshes what depends on it
+func saveBodyMeasurement(m *models.BodyMeasurement) error {
+	// $1 is the user, then measured_at, note and the fields in order
+	columns := append([]string{"measured_at", "note"}, measurementFieldKeys()...)
+	values := []interface{}{m.UserID, m.MeasuredAt, m.Note}
+	placeholders := make([]string, len(columns))
+	assignments := make([]string, len(columns))
+	for i, col := range columns {
+		placeholders[i] = fmt.Sprintf("$%d", i+2)
+		assignments[i] = fmt.Sprintf("%s = $%d", col, i+2)
+	}
+	for _, f := range measurementFields {
+		values = append(values, *f.value(m))
+	}
+
+	var err error
+	if m.ID == uuid.Nil {
+		err = config.DB.QueryRow(
+			`INSERT INTO body_measurements (user_id, `+strings.Join(columns, ", ")+`)
+             VALUES ($1, `+strings.Join(placeholders, ", ")+`)
+             RETURNING id`,
+			values...,
+		).Scan(&m.ID)
+	} else {
+		_, err = config.DB.Exec(
+			`UPDATE body_measurements SET `+strings.Join(assignments, ", ")+`, updated_at = NOW()
+             WHERE id = $`+fmt.Sprint(len(values)+1)+` AND user_id = $1`,
+			append(values, m.ID)...,
+		)
+	}
+	if err != nil {
+		return err
+	}
+	measurementsChanged(m.UserID)
+	saved, err := loadBodyMeasurement(m.ID, m.UserID)
+	if err != nil {
+		return err
+	}
+	*m = saved
+	return nil
+}
+
+// measurementsChanged mirrors the latest values into the profile and refreshes weight goals
+func measurementsChanged(userID uuid.UUID) {
+	syncProfileMeasurements(userID)
+	RecomputeGoalProgress(userID)
+}
+
+// syncProfileMeasurements copies the latest weight and body fat into users so the profile stays
+// current. A value nobody has measured keeps what was typed into the profile.
+func syncProfileMeasurements(userID uuid.UUID) {
+	_, err := config.DB.Exec(
+		`UPDATE users SET
+             weight = COALESCE((SELECT weight_kg FROM body_measurements
+                                WHERE user_id = $1 AND weight_kg IS NOT NULL ORDER BY measured_at DESC LIMIT 1), weight),
+             body_fat_pct = COALESCE((SELECT body_fat_pct FROM body_measurements
+                                      WHERE user_id = $1 AND body_fat_pct IS NOT NULL ORDER BY measured_at DESC LIMIT 1), body_fat_pct)
+         WHERE id = $1`,
+		userID,
+	)
+	if err != nil {
+		log.Println("DB UPDATE ERROR (syncProfileMeasurements):", err)
+	}
+}
+
+// CreateMeasurement handles POST /user/measurements with measured_at (RFC 3339, defaults to now),
+// note, and any of the measurement values
+func CreateMeasurement(c *gin.Context) {
+	userID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	var input struct {
+		MeasuredAt string `json:"measured_at"`
+		Note       string `json:"note"`
+	}
+	body, ok := bindMeasurementBody(c, &input)
+	if !ok {
+		return
+	}
+
+	m := models.BodyMeasurement{UserID: userID, Note: strings.TrimSpace(input.Note)}
+	if m.MeasuredAt, err = parseMeasuredAt("measured_at", input.MeasuredAt); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := applyMeasurementValues(&m, body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := saveBodyMeasurement(&m); err != nil {
+		log.Println("DB INSERT ERROR (CreateMeasurement):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save measurement"})
+		return
+	}
+	c.JSON(http.StatusCreated, m)
+}
+
+// GetMeasurements handles GET /user/measurements?from=&to=&field= (dates inclusive), oldest first.
+// With field, only measurements that recorded it are listed, which is what a chart of it needs.
+func GetMeasurements(c *gin.Context) {
+	userID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+	from, to := c.DefaultQuery("from", "0001-01-01"), c.DefaultQuery("to", "9999-12-31")
+	for _, d := range []string{from, to} {
+		if _, err := time.Parse("2006-01-02", d); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from and to must be in YYYY-MM-DD format"})
+			return
+		}
+	}
+	query := `SELECT ` + measurementColumns + ` FROM body_measurements m JOIN users u ON u.id = m.user_id
+              WHERE m.user_id = $1 AND (m.measured_at AT TIME ZONE u.timezone)::date BETWEEN $2 AND $3`
+	if field := c.Query("field"); field != "" {
+		if _, ok := measurementFieldsByKey[field]; !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "field must be one of " + measurementFieldList()})
+			return
+		}
+		query += ` AND m.` + field + ` IS NOT NULL`
+	}
+	// The newest rows are kept when a range holds more than one listing can
+	query = `SELECT * FROM (` + query + ` ORDER BY m.measured_at DESC LIMIT $4) newest ORDER BY measured_at`
+
+	rows, err := config.DB.Query(query, userID, from, to, maxMeasurementRows)
+	if err != nil {
+		log.Println("DB SELECT ERROR (GetMeasurements):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch measurements"})
+		return
+	}
+	defer rows.Close()
+
+	measurements := []models.BodyMeasurement{}
+	for rows.Next() {
+		m, err := scanBodyMeasurement(rows)
+		if err != nil {
+			log.Println("DB SCAN ERROR (GetMeasurements):", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch measurements"})
+			return
+		}
+		measurements = append(measurements, m)
+	}
+	c.JSON(http.StatusOK, measurements)
+}
+
+// GetLatestMeasurements handles GET /user/measurements/latest: the newest value of each field,
+// each with when it was measured, since one entry rarely records everything
+func GetLatestMeasurements(c *gin.Context) {
+	userID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	latest := gin.H{}
+	for _, f := range measurementFields {
+		var value float64
+		var measuredAt time.Time
+		err := config.DB.QueryRow(
+			`SELECT `+f.Key+`, measured_at FROM body_measurements
+             WHERE user_id = $1 AND `+f.Key+` IS NOT NULL ORDER BY measured_at DESC LIMIT 1`,
+			userID,
+		).Scan(&value, &measuredAt)
+		if err == sql.ErrNoRows {
+			latest[f.Key] = nil
+			continue
+		}
+		if err != nil {
+			log.Println("DB SELECT ERROR (GetLatestMeasurements):", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch measurements"})
+			return
+		}
+		latest[f.Key] = gin.H{"value": value, "measured_at": measuredAt}
+	}
+	c.JSON(http.StatusOK, latest)
+}
+
+// measuredValue is a measurement value with the day it was measured
+type measuredValue struct {
+	Value float64 `json:"value"`
+	Date  string  `json:"date"`
+}
+
+// measurementsAsOf returns the newest value of each field measured on or before date (in the
+// user's timezone). Few days record every value, so each field is looked up on its own.
+func measurementsAsOf(userID uuid.UUID, date string) (map[string]*measuredValue, error) {
+	values := make(map[string]*measuredValue, len(measurementFields))
+	for _, f := range measurementFields {
+		var v measuredValue
+		err := config.DB.QueryRow(
+			`SELECT m.`+f.Key+`, (m.measured_at AT TIME ZONE u.timezone)::date::text
+             FROM body_measurements m JOIN users u ON u.id = m.user_id
+             WHERE m.user_id = $1 AND m.`+f.Key+` IS NOT NULL AND (m.measured_at AT TIME ZONE u.timezone)::date <= $2
+             ORDER BY m.measured_at DESC LIMIT 1`,
+			userID, date,
+		).Scan(&v.Value, &v.Date)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f.Key, err)
+		}
+		values[f.Key] = &v
+	}
+	return values, nil
+}
+
+// measurementRowAsOf returns the newest measurement on or before date (in the user's timezone)
+// that records every one of keys, or nil without one. Formulas combining several sites read them
+// all from one such row: a waist from one month and a neck from another describe no real body.
+func measurementRowAsOf(userID uuid.UUID, date string, keys ...string) (*models.BodyMeasurement, error) {
+	query := `SELECT ` + measurementColumns + `
+         FROM body_measurements m JOIN users u ON u.id = m.user_id
+         WHERE m.user_id = $1 AND (m.measured_at AT TIME ZONE u.timezone)::date <= $2`
+	for _, key := range keys {
+		query += ` AND m.` + measurementFieldsByKey[key].Key + ` IS NOT NULL`
+	}
+	m, err := scanBodyMeasurement(config.DB.QueryRow(query+` ORDER BY m.measured_at DESC LIMIT 1`, userID, date))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// measurementValue reads one value of a measurement; 0 when it or the measurement is missing
+func measurementValue(m *models.BodyMeasurement, key string) float64 {
+	if m == nil {
+		return 0
+	}
+	if v := *measurementFieldsByKey[key].value(m); v != nil {
+		return *v
+	}
+	return 0
+}
+
+// bindMeasurementBody reads a measurement request body into input, and returns it as a map too so
+// the measurement values can tell an omitted field from a null. It writes the error response on failure.
+func bindMeasurementBody(c *gin.Context, input interface{}) (map[string]json.RawMessage, bool) {
+	raw, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	var body map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "body must be a JSON object"})
+		return nil, false
+	}
+	if err := json.Unmarshal(raw, input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "measured_at and note must be strings"})
+		return nil, false
+	}
+	return body, true
+}
+
+// measurementRequestIDs parses the user and measurement IDs, writing the error response when one is invalid
+func measurementRequestIDs(c *gin.Context) (uuid.UUID, uuid.UUID, bool) {
+	userID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return uuid.Nil, uuid.Nil, false
+	}
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid measurement ID"})
+		return uuid.Nil, uuid.Nil, false
+	}
+	return userID, id, true
+}
+
+// UpdateMeasurement handles PUT /user/measurements/:id. Fields left out keep their values and a
+// null clears one, as long as something is left.
+func UpdateMeasurement(c *gin.Context) {
+	userID, id, ok := measurementRequestIDs(c)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		MeasuredAt *string `json:"measured_at"`
+		Note       *string `json:"note"`
+	}
+	body, ok := bindMeasurementBody(c, &input)
+	if !ok {
+		return
+	}
+
+	m, err := loadBodyMeasurement(id, userID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Measurement not found"})
+		return
+	}
+	if err != nil {
+		log.Println("DB SELECT ERROR (UpdateMeasurement):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update measurement"})
+		return
+	}
+	if input.MeasuredAt != nil {
+		if m.MeasuredAt, err = parseMeasuredAt("measured_at", *input.MeasuredAt); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if input.Note != nil {
+		m.Note = strings.TrimSpace(*input.Note)
+	}
+	if err := applyMeasurementValues(&m, body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := saveBodyMeasurement(&m); err != nil {
+		log.Println("DB UPDATE ERROR (UpdateMeasurement):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update measurement"})
+		return
+	}
+	c.JSON(http.StatusOK, m)
+}
+
+// DeleteMeasurement handles DELETE /user/measurements/:id
+func DeleteMeasurement(c *gin.Context) {
+	userID, id, ok := measurementRequestIDs(c)
+	if !ok {
+		return
+	}
+
+	var deleted uuid.UUID
+	err := config.DB.QueryRow(`DELETE FROM body_measurements WHERE id = $1 AND user_id = $2 RETURNING id`, id, userID).Scan(&deleted)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Measurement not found"})
+		return
+	}
+	if err != nil {
+		log.Println("DB DELETE ERROR (DeleteMeasurement):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete measurement"})
+		return
+	}
+	measurementsChanged(userID)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Measurement deleted successfully"})
+}
@@ -0,0 +1,886 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"nutritionix/backend/config"
+	"nutritionix/backend/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// What other participants see of someone on a leaderboard
+const (
+	ChallengeVisibilityPublic    = "public"    // name and score
+	ChallengeVisibilityAnonymous = "anonymous" // score without the name
+	ChallengeVisibilityRankOnly  = "rank_only" // name without the score
+)
+
+// Challenge statuses, derived from the dates
+const (
+	ChallengeUpcoming  = "upcoming"
+	ChallengeActive    = "active"
+	ChallengeEnded     = "ended" // waiting for late syncs before winners are announced
+	ChallengeFinalized = "finalized"
+)
+
+// challengeMetricUnits lists the metrics a challenge can be scored on, with the unit of a score
+var challengeMetricUnits = map[string]string{
+	"steps":        "steps",
+	"workouts":     "workouts",
+	"protein_days": "days",
+}
+
+const (
+	maxChallengeDays         = 92
+	maxChallengeParticipants = 100
+	challengeFinalizeDelay   = 1 // days after end_date before results are final, so late syncs still count
+	inviteCodeLength         = 8
+	inviteCodeAlphabet       = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789" // no 0/O or 1/I to misread
+)
+
+// generateInviteCode returns a random code that is easy to read out and type
+func generateInviteCode() (string, error) {
+	code := make([]byte, inviteCodeLength)
+	for i := range code {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(inviteCodeAlphabet))))
+		if err != nil {
+			return "", err
+		}
+		code[i] = inviteCodeAlphabet[n.Int64()]
+	}
+	return string(code), nil
+}
+
+// challengeColumns is the column list scanned by scanChallenge
+const challengeColumns = `c.id, c.owner_id, c.name, c.description, c.metric, c.protein_target_g, c.start_date::text,
+    c.end_date::text, c.invite_code, c.finalized_at, c.created_at,
+    (SELECT COUNT(*) FROM challenge_participants cp WHERE cp.challenge_id = c.id)`
+
+func scanChallenge(row interface{ Scan(...interface{}) error }) (models.Challenge, error) {
+	var ch models.Challenge
+	err := row.Scan(&ch.ID, &ch.OwnerID, &ch.Name, &ch.Description, &ch.Metric, &ch.ProteinTargetG, &ch.StartDate,
+		&ch.EndDate, &ch.InviteCode, &ch.FinalizedAt, &ch.CreatedAt, &ch.Participants)
+	return ch, err
+}
+
+// challengeStatus places a challenge relative to today
+func challengeStatus(ch models.Challenge, today string) string {
+	switch {
+	case ch.FinalizedAt != nil:
+		return ChallengeFinalized
+	case today < ch.StartDate:
+		return ChallengeUpcoming
+	case today <= ch.EndDate:
+		return ChallengeActive
+	}
+	return ChallengeEnded
+}
+
+// challengeParticipant is a participant's row with their score
+type challengeParticipant struct {
+	UserID      uuid.UUID
+	DisplayName string
+	Visibility  string
+	LastRank    sql.NullInt64
+	Score       int
+	Rank        int
+}
+
+// challengeScoreQueries score every participant over the challenge dates. $1 is the challenge, $2 and
+// $3 the first and last day, and $4 the protein target where there is one. Each participant's days
+// are their own calendar days.
+var challengeScoreQueries = map[string]string{
+	"steps": `SELECT p.user_id, COALESCE(SUM(a.steps), 0)
+        FROM challenge_participants p
+        LEFT JOIN daily_activity a ON a.user_id = p.user_id AND a.date BETWEEN $2::date AND $3::date
+        WHERE p.challenge_id = $1
+        GROUP BY p.user_id`,
+	"workouts": `SELECT p.user_id, COUNT(w.id)
+        FROM challenge_participants p
+        LEFT JOIN workouts w ON w.user_id = p.user_id AND w.status = 'completed' AND w.date BETWEEN $2::date AND $3::date
+        WHERE p.challenge_id = $1
+        GROUP BY p.user_id`,
+	"protein_days": `SELECT p.user_id, COUNT(d.day)
+        FROM challenge_participants p
+        LEFT JOIN (
+            SELECT m.user_id, m.date::date AS day
+            FROM meals m JOIN meal_foods mf ON mf.meal_id = m.id
+            WHERE m.user_id IN (SELECT user_id FROM challenge_participants WHERE challenge_id = $1)
+              AND m.date::date BETWEEN $2::date AND $3::date
+            GROUP BY m.user_id, m.date::date
+            HAVING SUM(mf.protein) >= $4
+        ) d ON d.user_id = p.user_id
+        WHERE p.challenge_id = $1
+        GROUP BY p.user_id`,
+}
+
+// challengeStandings loads the participants of a challenge with their scores, ranked. Ties share a
+// rank and are listed by name.
+func challengeStandings(ch models.Challenge) ([]challengeParticipant, error) {
+	rows, err := config.DB.Query(
+		`SELECT user_id, display_name, visibility, last_rank FROM challenge_participants WHERE challenge_id = $1`, ch.ID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	var participants []challengeParticipant
+	for rows.Next() {
+		var p challengeParticipant
+		if err := rows.Scan(&p.UserID, &p.DisplayName, &p.Visibility, &p.LastRank); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		participants = append(participants, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	args := []interface{}{ch.ID, ch.StartDate, ch.EndDate}
+	if ch.Metric == "protein_days" {
+		args = append(args, ch.ProteinTargetG)
+	}
+	rows, err = config.DB.Query(challengeScoreQueries[ch.Metric], args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	scores := map[uuid.UUID]int{}
+	for rows.Next() {
+		var userID uuid.UUID
+		var score int
+		if err := rows.Scan(&userID, &score); err != nil {
+			return nil, err
+		}
+		scores[userID] = score
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range participants {
+		participants[i].Score = scores[participants[i].UserID]
+	}
+	rankChallengeParticipants(participants)
+	return participants, nil
+}
+
+// rankChallengeParticipants sorts by score, highest first, and gives ties the same rank (1, 2, 2, 4)
+func rankChallengeParticipants(participants []challengeParticipant) {
+	sort.SliceStable(participants, func(i, j int) bool {
+		if participants[i].Score != participants[j].Score {
+			return participants[i].Score > participants[j].Score
+		}
+		return strings.ToLower(participants[i].DisplayName) < strings.ToLower(participants[j].DisplayName)
+	})
+	for i := range participants {
+		if i > 0 && participants[i].Score == participants[i-1].Score {
+			participants[i].Rank = participants[i-1].Rank
+		} else {
+			participants[i].Rank = i + 1
+		}
+	}
+}
+
+// challengeLeaderboard is the leaderboard as viewer may see it. Everyone sees their own row in full.
+func challengeLeaderboard(participants []challengeParticipant, viewer uuid.UUID) []models.ChallengeStanding {
+	board := make([]models.ChallengeStanding, len(participants))
+	for i, p := range participants {
+		s := models.ChallengeStanding{Rank: p.Rank, IsYou: p.UserID == viewer}
+		name, score, userID := p.DisplayName, p.Score, p.UserID
+		if s.IsYou || p.Visibility != ChallengeVisibilityAnonymous {
+			s.DisplayName = &name
+		}
+		if s.IsYou || p.Visibility != ChallengeVisibilityRankOnly {
+			s.Score = &score
+		}
+		if s.IsYou || p.Visibility == ChallengeVisibilityPublic {
+			s.UserID = &userID
+		}
+		board[i] = s
+	}
+	return board
+}
+
+// ordinal formats 1 as 1st, 2 as 2nd and so on
+func ordinal(n int) string {
+	suffix := "th"
+	switch {
+	case n%100 >= 11 && n%100 <= 13:
+	case n%10 == 1:
+		suffix = "st"
+	case n%10 == 2:
+		suffix = "nd"
+	case n%10 == 3:
+		suffix = "rd"
+	}
+	return fmt.Sprintf("%d%s", n, suffix)
+}
+
+// validateChallengeMember checks a display name and visibility, defaulting visibility to public
+func validateChallengeMember(displayName, visibility string) (string, string, error) {
+	displayName = strings.Join(strings.Fields(displayName), " ")
+	if len(displayName) < 2 || len(displayName) > 40 {
+		return "", "", fmt.Errorf("display_name must be 2-40 characters")
+	}
+	switch visibility {
+	case "":
+		visibility = ChallengeVisibilityPublic
+	case ChallengeVisibilityPublic, ChallengeVisibilityAnonymous, ChallengeVisibilityRankOnly:
+	default:
+		return "", "", fmt.Errorf("visibility must be public, anonymous or rank_only")
+	}
+	return displayName, visibility, nil
+}
+
+// defaultDisplayName is the first name on the profile, so full names aren't shared by default
+func defaultDisplayName(userID uuid.UUID) string {
+	var name string
+	if err := config.DB.QueryRow(`SELECT name FROM users WHERE id = $1`, userID).Scan(&name); err != nil {
+		log.Println("DB SELECT ERROR (defaultDisplayName):", err)
+	}
+	if fields := strings.Fields(name); len(fields) > 0 && len(fields[0]) >= 2 {
+		return fields[0]
+	}
+	return "Participant"
+}
+
+// loadParticipantChallenge loads a challenge the user takes part in; sql.ErrNoRows otherwise
+func loadParticipantChallenge(challengeID, userID uuid.UUID) (models.Challenge, error) {
+	return scanChallenge(config.DB.QueryRow(
+		`SELECT `+challengeColumns+`
+         FROM challenges c JOIN challenge_participants me ON me.challenge_id = c.id AND me.user_id = $2
+         WHERE c.id = $1`,
+		challengeID, userID,
+	))
+}
+
+// challengeRequestIDs parses the user and challenge IDs, writing the error response when one is invalid
+func challengeRequestIDs(c *gin.Context) (uuid.UUID, uuid.UUID, bool) {
+	userID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return uuid.Nil, uuid.Nil, false
+	}
+	challengeID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid challenge ID"})
+		return uuid.Nil, uuid.Nil, false
+	}
+	return userID, challengeID, true
+}
+
+// CreateChallenge handles POST /challenges. The creator joins it and gets the invite code to share.
+func CreateChallenge(c *gin.Context) {
+	userID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var input struct {
+		Name           string `json:"name"`
+		Description    string `json:"description"`
+		Metric         string `json:"metric"` // steps, workouts or protein_days
+		ProteinTargetG *int   `json:"protein_target_g"`
+		StartDate      string `json:"start_date"`
+		EndDate        string `json:"end_date"`
+		DisplayName    string `json:"display_name"`
+		Visibility     string `json:"visibility"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + err.Error()})
+		return
+	}
+
+	name := strings.Join(strings.Fields(input.Name), " ")
+	if len(name) < 3 || len(name) > 80 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name must be 3-80 characters"})
+		return
+	}
+	description := strings.TrimSpace(input.Description)
+	if len(description) > 500 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "description must be at most 500 characters"})
+		return
+	}
+	if _, ok := challengeMetricUnits[input.Metric]; !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "metric must be steps, workouts or protein_days"})
+		return
+	}
+	if input.Metric == "protein_days" {
+		if input.ProteinTargetG == nil || *input.ProteinTargetG < 10 || *input.ProteinTargetG > 500 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "protein_days challenges need a protein_target_g between 10 and 500"})
+			return
+		}
+	} else {
+		input.ProteinTargetG = nil
+	}
+	start, err1 := time.Parse("2006-01-02", input.StartDate)
+	end, err2 := time.Parse("2006-01-02", input.EndDate)
+	if err1 != nil || err2 != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "start_date and end_date must be in YYYY-MM-DD format"})
+		return
+	}
+	if end.Before(start) || end.Sub(start) >= maxChallengeDays*24*time.Hour {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("a challenge must run from 1 to %d days", maxChallengeDays)})
+		return
+	}
+	if input.EndDate < localToday(userTimezone(userID)) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "end_date is in the past"})
+		return
+	}
+	if input.DisplayName == "" {
+		input.DisplayName = defaultDisplayName(userID)
+	}
+	displayName, visibility, err := validateChallengeMember(input.DisplayName, input.Visibility)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		log.Println("DB BEGIN ERROR (CreateChallenge):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create challenge"})
+		return
+	}
+	defer tx.Rollback()
+
+	var challengeID uuid.UUID
+	for attempt := 0; ; attempt++ {
+		code, err := generateInviteCode()
+		if err != nil {
+			log.Println("INVITE CODE ERROR (CreateChallenge):", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create challenge"})
+			return
+		}
+		// A savepoint lets a clashing code be retried without losing the transaction
+		if _, err := tx.Exec(`SAVEPOINT invite_code`); err != nil {
+			log.Println("DB INSERT ERROR (CreateChallenge):", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create challenge"})
+			return
+		}
+		err = tx.QueryRow(
+			`INSERT INTO challenges (owner_id, name, description, metric, protein_target_g, start_date, end_date, invite_code)
+             VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+             RETURNING id`,
+			userID, name, description, input.Metric, input.ProteinTargetG, input.StartDate, input.EndDate, code,
+		).Scan(&challengeID)
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" && attempt < 5 {
+			if _, err := tx.Exec(`ROLLBACK TO SAVEPOINT invite_code`); err == nil {
+				continue
+			}
+		}
+		if err != nil {
+			log.Println("DB INSERT ERROR (CreateChallenge):", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create challenge"})
+			return
+		}
+		break
+	}
+	if _, err := tx.Exec(
+		`INSERT INTO challenge_participants (challenge_id, user_id, display_name, visibility) VALUES ($1, $2, $3, $4)`,
+		challengeID, userID, displayName, visibility,
+	); err != nil {
+		log.Println("DB INSERT ERROR (CreateChallenge):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create challenge"})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		log.Println("DB COMMIT ERROR (CreateChallenge):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create challenge"})
+		return
+	}
+
+	ch, err := loadParticipantChallenge(challengeID, userID)
+	if err != nil {
+		log.Println("DB SELECT ERROR (CreateChallenge):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load challenge"})
+		return
+	}
+	ch.Status = challengeStatus(ch, localToday(userTimezone(userID)))
+	c.JSON(http.StatusCreated, ch)
+}
+
+// JoinChallenge handles POST /challenges/join with {"invite_code", "display_name", "visibility"}
+func JoinChallenge(c *gin.Context) {
+	userID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var input struct {
+		InviteCode  string `json:"invite_code"`
+		DisplayName string `json:"display_name"`
+		Visibility  string `json:"visibility"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + err.Error()})
+		return
+	}
+	if input.DisplayName == "" {
+		input.DisplayName = defaultDisplayName(userID)
+	}
+	displayName, visibility, err := validateChallengeMember(input.DisplayName, input.Visibility)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	code := strings.ToUpper(strings.Join(strings.Fields(input.InviteCode), ""))
+	ch, err := scanChallenge(config.DB.QueryRow(`SELECT `+challengeColumns+` FROM challenges c WHERE c.invite_code = $1`, code))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No challenge has this invite code"})
+		return
+	}
+	if err != nil {
+		log.Println("DB SELECT ERROR (JoinChallenge):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to join challenge"})
+		return
+	}
+	today := localToday(userTimezone(userID))
+	if status := challengeStatus(ch, today); status == ChallengeEnded || status == ChallengeFinalized {
+		c.JSON(http.StatusConflict, gin.H{"error": "This challenge is over"})
+		return
+	}
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		log.Println("DB BEGIN ERROR (JoinChallenge):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to join challenge"})
+		return
+	}
+	defer tx.Rollback()
+
+	// Joins to one challenge queue on its row, so two people can't both take the last place:
+	// each counts the participants only once the join before it has committed
+	if _, err := tx.Exec(`SELECT 1 FROM challenges WHERE id = $1 FOR UPDATE`, ch.ID); err != nil {
+		log.Println("DB SELECT ERROR (JoinChallenge):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to join challenge"})
+		return
+	}
+	var participants int
+	var joined bool
+	err = tx.QueryRow(
+		`SELECT COUNT(*), COALESCE(BOOL_OR(user_id = $2), false) FROM challenge_participants WHERE challenge_id = $1`,
+		ch.ID, userID,
+	).Scan(&participants, &joined)
+	if err != nil {
+		log.Println("DB SELECT ERROR (JoinChallenge):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to join challenge"})
+		return
+	}
+	if joined {
+		c.JSON(http.StatusConflict, gin.H{"error": "You're already in this challenge"})
+		return
+	}
+	if participants >= maxChallengeParticipants {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("This challenge is full (%d participants)", maxChallengeParticipants)})
+		return
+	}
+
+	_, err = tx.Exec(
+		`INSERT INTO challenge_participants (challenge_id, user_id, display_name, visibility) VALUES ($1, $2, $3, $4)`,
+		ch.ID, userID, displayName, visibility,
+	)
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		log.Println("DB INSERT ERROR (JoinChallenge):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to join challenge"})
+		return
+	}
+
+	ch.Participants = participants + 1
+	ch.Status = challengeStatus(ch, today)
+	c.JSON(http.StatusCreated, ch)
+}
+
+// ListChallenges handles GET /challenges, the challenges the user takes part in, newest first
+func ListChallenges(c *gin.Context) {
+	userID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	rows, err := config.DB.Query(
+		`SELECT `+challengeColumns+`
+         FROM challenges c JOIN challenge_participants me ON me.challenge_id = c.id AND me.user_id = $1
+         ORDER BY c.end_date DESC, c.created_at DESC`,
+		userID,
+	)
+	if err != nil {
+		log.Println("DB SELECT ERROR (ListChallenges):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch challenges"})
+		return
+	}
+	defer rows.Close()
+
+	today := localToday(userTimezone(userID))
+	challenges := []models.Challenge{}
+	for rows.Next() {
+		ch, err := scanChallenge(rows)
+		if err != nil {
+			log.Println("DB SCAN ERROR (ListChallenges):", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch challenges"})
+			return
+		}
+		ch.Status = challengeStatus(ch, today)
+		challenges = append(challenges, ch)
+	}
+	c.JSON(http.StatusOK, challenges)
+}
+
+// GetChallenge handles GET /challenges/:id with the leaderboard, live until the challenge is
+// finalized and the final standings after
+func GetChallenge(c *gin.Context) {
+	userID, challengeID, ok := challengeRequestIDs(c)
+	if !ok {
+		return
+	}
+
+	ch, err := loadParticipantChallenge(challengeID, userID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Challenge not found"})
+		return
+	}
+	if err != nil {
+		log.Println("DB SELECT ERROR (GetChallenge):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch challenge"})
+		return
+	}
+	ch.Status = challengeStatus(ch, localToday(userTimezone(userID)))
+
+	var participants []challengeParticipant
+	if ch.Status == ChallengeFinalized {
+		participants, err = finalChallengeStandings(ch.ID)
+	} else {
+		participants, err = challengeStandings(ch)
+	}
+	if err != nil {
+		log.Println("DB SELECT ERROR (GetChallenge):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute leaderboard"})
+		return
+	}
+
+	var you gin.H
+	for _, p := range participants {
+		if p.UserID == userID {
+			you = gin.H{"rank": p.Rank, "score": p.Score, "display_name": p.DisplayName, "visibility": p.Visibility}
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"challenge":   ch,
+		"unit":        challengeMetricUnits[ch.Metric],
+		"you":         you,
+		"leaderboard": challengeLeaderboard(participants, userID),
+	})
+}
+
+// finalChallengeStandings reads the standings recorded when a challenge was finalized
+func finalChallengeStandings(challengeID uuid.UUID) ([]challengeParticipant, error) {
+	rows, err := config.DB.Query(
+		`SELECT user_id, display_name, visibility, last_rank, COALESCE(final_score, 0), COALESCE(final_rank, 0)
+         FROM challenge_participants WHERE challenge_id = $1
+         ORDER BY final_rank, lower(display_name)`,
+		challengeID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var participants []challengeParticipant
+	for rows.Next() {
+		var p challengeParticipant
+		if err := rows.Scan(&p.UserID, &p.DisplayName, &p.Visibility, &p.LastRank, &p.Score, &p.Rank); err != nil {
+			return nil, err
+		}
+		participants = append(participants, p)
+	}
+	return participants, rows.Err()
+}
+
+// UpdateChallengeMembership handles PUT /challenges/:id/me, changing the user's display name or visibility
+func UpdateChallengeMembership(c *gin.Context) {
+	userID, challengeID, ok := challengeRequestIDs(c)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		DisplayName *string `json:"display_name"`
+		Visibility  *string `json:"visibility"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + err.Error()})
+		return
+	}
+
+	var displayName, visibility string
+	err := config.DB.QueryRow(
+		`SELECT display_name, visibility FROM challenge_participants WHERE challenge_id = $1 AND user_id = $2`,
+		challengeID, userID,
+	).Scan(&displayName, &visibility)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Challenge not found"})
+		return
+	}
+	if err != nil {
+		log.Println("DB SELECT ERROR (UpdateChallengeMembership):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update challenge settings"})
+		return
+	}
+	if input.DisplayName != nil {
+		displayName = *input.DisplayName
+	}
+	if input.Visibility != nil {
+		visibility = *input.Visibility
+	}
+	if displayName, visibility, err = validateChallengeMember(displayName, visibility); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, err := config.DB.Exec(
+		`UPDATE challenge_participants SET display_name = $1, visibility = $2 WHERE challenge_id = $3 AND user_id = $4`,
+		displayName, visibility, challengeID, userID,
+	); err != nil {
+		log.Println("DB UPDATE ERROR (UpdateChallengeMembership):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update challenge settings"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"display_name": displayName, "visibility": visibility})
+}
+
+// LeaveChallenge handles DELETE /challenges/:id/me. The creator can't leave; they delete the challenge instead.
+func LeaveChallenge(c *gin.Context) {
+	userID, challengeID, ok := challengeRequestIDs(c)
+	if !ok {
+		return
+	}
+
+	res, err := config.DB.Exec(
+		`DELETE FROM challenge_participants p
+         USING challenges c
+         WHERE c.id = p.challenge_id AND p.challenge_id = $1 AND p.user_id = $2 AND c.owner_id <> $2`,
+		challengeID, userID,
+	)
+	if err != nil {
+		log.Println("DB DELETE ERROR (LeaveChallenge):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to leave challenge"})
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		var owner bool
+		_ = config.DB.QueryRow(`SELECT EXISTS (SELECT 1 FROM challenges WHERE id = $1 AND owner_id = $2)`, challengeID, userID).Scan(&owner)
+		if owner {
+			c.JSON(http.StatusConflict, gin.H{"error": "You created this challenge; delete it instead"})
+			return
+		}
+		c.JSON(http.StatusNotFound, gin.H{"error": "Challenge not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "You left the challenge"})
+}
+
+// DeleteChallenge handles DELETE /challenges/:id for the creator, telling the other participants
+func DeleteChallenge(c *gin.Context) {
+	userID, challengeID, ok := challengeRequestIDs(c)
+	if !ok {
+		return
+	}
+
+	var name string
+	var others []uuid.UUID
+	rows, err := config.DB.Query(
+		`SELECT c.name, p.user_id FROM challenges c JOIN challenge_participants p ON p.challenge_id = c.id
+         WHERE c.id = $1 AND c.owner_id = $2 AND p.user_id <> $2`,
+		challengeID, userID,
+	)
+	if err != nil {
+		log.Println("DB SELECT ERROR (DeleteChallenge):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete challenge"})
+		return
+	}
+	for rows.Next() {
+		var participant uuid.UUID
+		if err := rows.Scan(&name, &participant); err != nil {
+			log.Println("DB SCAN ERROR (DeleteChallenge):", err)
+			continue
+		}
+		others = append(others, participant)
+	}
+	rows.Close()
+
+	res, err := config.DB.Exec(`DELETE FROM challenges WHERE id = $1 AND owner_id = $2`, challengeID, userID)
+	if err != nil {
+		log.Println("DB DELETE ERROR (DeleteChallenge):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete challenge"})
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Challenge not found"})
+		return
+	}
+	for _, participant := range others {
+		_ = CreateNotification(participant, nil, fmt.Sprintf("🗑️ The challenge '%s' was cancelled by its creator.", name))
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Challenge deleted successfully"})
+}
+
+// RunChallengeUpdates is the daily job that tells participants of running challenges when their
+// rank has changed, and announces the winners once a challenge has ended and late syncs are in
+func RunChallengeUpdates() {
+	rows, err := config.DB.Query(
+		`SELECT ` + challengeColumns + ` FROM challenges c
+         WHERE c.finalized_at IS NULL AND c.start_date <= CURRENT_DATE`,
+	)
+	if err != nil {
+		log.Println("DB SELECT ERROR (RunChallengeUpdates):", err)
+		return
+	}
+	var challenges []models.Challenge
+	for rows.Next() {
+		ch, err := scanChallenge(rows)
+		if err != nil {
+			log.Println("DB SCAN ERROR (RunChallengeUpdates):", err)
+			continue
+		}
+		challenges = append(challenges, ch)
+	}
+	rows.Close()
+
+	finalizeBefore := time.Now().UTC().AddDate(0, 0, -challengeFinalizeDelay).Format("2006-01-02")
+	for _, ch := range challenges {
+		participants, err := challengeStandings(ch)
+		if err != nil {
+			log.Println("DB SELECT ERROR (RunChallengeUpdates):", err)
+			continue
+		}
+		if ch.EndDate < finalizeBefore {
+			finalizeChallenge(ch, participants)
+		} else if len(participants) > 1 {
+			announceChallengeStandings(ch, participants)
+		}
+	}
+}
+
+// announceChallengeStandings notifies participants whose rank moved since the last announcement
+func announceChallengeStandings(ch models.Challenge, participants []challengeParticipant) {
+	unit := challengeMetricUnits[ch.Metric]
+	for _, p := range participants {
+		if p.LastRank.Valid && int(p.LastRank.Int64) == p.Rank {
+			continue
+		}
+		if _, err := config.DB.Exec(
+			`UPDATE challenge_participants SET last_rank = $1 WHERE challenge_id = $2 AND user_id = $3`,
+			p.Rank, ch.ID, p.UserID,
+		); err != nil {
+			log.Println("DB UPDATE ERROR (announceChallengeStandings):", err)
+			continue
+		}
+		// The first announcement only records where everyone starts
+		if !p.LastRank.Valid {
+			continue
+		}
+		trend := "📈"
+		if p.Rank > int(p.LastRank.Int64) {
+			trend = "📉"
+		}
+		_ = CreateNotification(p.UserID, nil, fmt.Sprintf("%s Challenge '%s': you're now %s of %d with %d %s.",
+			trend, ch.Name, ordinal(p.Rank), len(participants), p.Score, unit))
+	}
+}
+
+// finalizeChallenge records the final standings once and announces the winners to everyone
+func finalizeChallenge(ch models.Challenge, participants []challengeParticipant) {
+	tx, err := config.DB.Begin()
+	if err != nil {
+		log.Println("DB BEGIN ERROR (finalizeChallenge):", err)
+		return
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`UPDATE challenges SET finalized_at = NOW() WHERE id = $1 AND finalized_at IS NULL`, ch.ID)
+	if err != nil {
+		log.Println("DB UPDATE ERROR (finalizeChallenge):", err)
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return
+	}
+	for _, p := range participants {
+		if _, err := tx.Exec(
+			`UPDATE challenge_participants SET final_rank = $1, final_score = $2, last_rank = $1
+             WHERE challenge_id = $3 AND user_id = $4`,
+			p.Rank, p.Score, ch.ID, p.UserID,
+		); err != nil {
+			log.Println("DB UPDATE ERROR (finalizeChallenge):", err)
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		log.Println("DB COMMIT ERROR (finalizeChallenge):", err)
+		return
+	}
+
+	unit := challengeMetricUnits[ch.Metric]
+	var winners []challengeParticipant
+	for _, p := range participants {
+		if p.Rank == 1 {
+			winners = append(winners, p)
+		}
+	}
+	for _, p := range participants {
+		var msg string
+		switch {
+		case p.Score == 0 && p.Rank == 1:
+			msg = fmt.Sprintf("🏁 Challenge '%s' is over. Nobody logged any %s, so there's no winner.", ch.Name, unit)
+		case p.Rank == 1 && len(winners) > 1:
+			msg = fmt.Sprintf("🏆 Challenge '%s' is over! You tied for 1st with %d %s.", ch.Name, p.Score, unit)
+		case p.Rank == 1:
+			msg = fmt.Sprintf("🏆 Challenge '%s' is over! You won with %d %s.", ch.Name, p.Score, unit)
+		default:
+			msg = fmt.Sprintf("🏁 Challenge '%s' is over! %s You finished %s of %d with %d %s.",
+				ch.Name, describeChallengeWinners(winners, unit), ordinal(p.Rank), len(participants), p.Score, unit)
+		}
+		_ = CreateNotification(p.UserID, nil, msg)
+	}
+}
+
+// describeChallengeWinners names the winners as far as their visibility allows
+func describeChallengeWinners(winners []challengeParticipant, unit string) string {
+	names := make([]string, len(winners))
+	for i, w := range winners {
+		names[i] = w.DisplayName
+		if w.Visibility == ChallengeVisibilityAnonymous {
+			names[i] = "an anonymous participant"
+		}
+	}
+	msg := "Winner: " + names[0]
+	if len(names) > 1 {
+		msg = "Winners: " + strings.Join(names, ", ")
+	}
+	for _, w := range winners {
+		if w.Visibility == ChallengeVisibilityRankOnly {
+			return msg + "."
+		}
+	}
+	return fmt.Sprintf("%s (%d %s).", msg, winners[0].Score, unit)
+}
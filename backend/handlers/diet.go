@@ -0,0 +1,374 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"nutritionix/backend/config"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Profile-level dietary restrictions
+var validDietaryRestrictions = map[string]bool{
+	"vegan":       true,
+	"vegetarian":  true,
+	"gluten_free": true,
+	"dairy_free":  true,
+}
+
+// Allergens tracked on both profiles and foods
+var validAllergens = map[string]bool{
+	"peanuts":   true,
+	"tree_nuts": true,
+	"milk":      true,
+	"eggs":      true,
+	"wheat":     true,
+	"gluten":    true,
+	"soy":       true,
+	"fish":      true,
+	"shellfish": true,
+	"sesame":    true,
+}
+
+// Diet tags a food can carry: claims (vegan, gluten_free, ...) plus "meat" for animal flesh
+var validFoodDietTags = map[string]bool{
+	"vegan":       true,
+	"vegetarian":  true,
+	"gluten_free": true,
+	"dairy_free":  true,
+	"meat":        true,
+}
+
+// allergenPhrases are matched before single keywords so that e.g. "peanut butter" isn't read as dairy
+var allergenPhrases = map[string][]string{
+	"peanut butter": {"peanuts"},
+	"almond milk":   {"tree_nuts"},
+	"cashew milk":   {"tree_nuts"},
+	"soy milk":      {"soy"},
+	"coconut milk":  {},
+	"oat milk":      {},
+	"rice milk":     {},
+	"cocoa butter":  {},
+}
+
+// allergenKeywords maps a food-name word to the allergens or diet tags it implies
+var allergenKeywords = map[string][]string{
+	"peanut": {"peanuts"}, "almond": {"tree_nuts"}, "cashew": {"tree_nuts"}, "walnut": {"tree_nuts"},
+	"pecan": {"tree_nuts"}, "pistachio": {"tree_nuts"}, "hazelnut": {"tree_nuts"}, "macadamia": {"tree_nuts"},
+	"milk": {"milk"}, "cheese": {"milk"}, "yogurt": {"milk"}, "yoghurt": {"milk"}, "butter": {"milk"},
+	"cream": {"milk"}, "paneer": {"milk"}, "whey": {"milk"}, "ghee": {"milk"}, "curd": {"milk"},
+	"egg": {"eggs"}, "omelette": {"eggs"}, "omelet": {"eggs"}, "mayonnaise": {"eggs"},
+	"bread": {"wheat", "gluten"}, "pasta": {"wheat", "gluten"}, "wheat": {"wheat", "gluten"},
+	"flour": {"wheat", "gluten"}, "noodle": {"wheat", "gluten"}, "cracker": {"wheat", "gluten"},
+	"biscuit": {"wheat", "gluten"}, "roti": {"wheat", "gluten"}, "chapati": {"wheat", "gluten"},
+	"naan": {"wheat", "gluten"}, "bagel": {"wheat", "gluten"}, "croissant": {"wheat", "gluten", "milk"},
+	"barley": {"gluten"}, "rye": {"gluten"}, "seitan": {"wheat", "gluten"},
+	"soy": {"soy"}, "tofu": {"soy"}, "edamame": {"soy"}, "tempeh": {"soy"},
+	"fish": {"fish"}, "salmon": {"fish"}, "tuna": {"fish"}, "cod": {"fish"}, "sardine": {"fish"},
+	"mackerel": {"fish"}, "tilapia": {"fish"}, "anchovy": {"fish"},
+	"shrimp": {"shellfish"}, "prawn": {"shellfish"}, "crab": {"shellfish"}, "lobster": {"shellfish"},
+	"oyster": {"shellfish"}, "mussel": {"shellfish"}, "clam": {"shellfish"},
+	"sesame": {"sesame"}, "tahini": {"sesame"},
+	"chicken": {"meat"}, "beef": {"meat"}, "pork": {"meat"}, "lamb": {"meat"}, "mutton": {"meat"},
+	"bacon": {"meat"}, "ham": {"meat"}, "turkey": {"meat"}, "sausage": {"meat"}, "steak": {"meat"},
+}
+
+// dietProfile holds a user's restrictions and allergens
+type dietProfile struct {
+	Restrictions []string
+	Allergens    []string
+}
+
+// normalizeTags lowercases, dedupes and validates a list of tags against an allowed set
+func normalizeTags(field string, tags []string, allowed map[string]bool) ([]string, error) {
+	seen := map[string]bool{}
+	out := []string{}
+	for _, t := range tags {
+		t = strings.ReplaceAll(strings.ToLower(strings.TrimSpace(t)), "-", "_")
+		t = strings.ReplaceAll(t, " ", "_")
+		if t == "" || seen[t] {
+			continue
+		}
+		if !allowed[t] {
+			return nil, fmt.Errorf("%s contains unknown value '%s'", field, t)
+		}
+		seen[t] = true
+		out = append(out, t)
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+// inferFoodTags guesses allergens and the "meat" tag from a food name
+func inferFoodTags(foodName string) (allergens []string, dietTags []string) {
+	name := " " + strings.ToLower(foodName) + " "
+	found := map[string]bool{}
+
+	for phrase, tags := range allergenPhrases {
+		if strings.Contains(name, " "+phrase+" ") || strings.Contains(name, " "+phrase+"s ") {
+			for _, t := range tags {
+				found[t] = true
+			}
+			name = strings.ReplaceAll(name, phrase, " ")
+		}
+	}
+
+	words := strings.FieldsFunc(name, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z')
+	})
+	for _, w := range words {
+		tags, ok := allergenKeywords[w]
+		if !ok {
+			tags, ok = allergenKeywords[strings.TrimSuffix(w, "s")]
+		}
+		if !ok {
+			tags = allergenKeywords[strings.TrimSuffix(w, "es")]
+		}
+		for _, t := range tags {
+			found[t] = true
+		}
+	}
+
+	allergens, dietTags = []string{}, []string{}
+	for t := range found {
+		if t == "meat" {
+			dietTags = append(dietTags, t)
+		} else {
+			allergens = append(allergens, t)
+		}
+	}
+	sort.Strings(allergens)
+	sort.Strings(dietTags)
+	return allergens, dietTags
+}
+
+// claimExclusions lists the allergens and tags each diet claim rules out
+var claimExclusions = map[string][]string{
+	"vegan":       {"milk", "eggs", "fish", "shellfish", "meat"},
+	"vegetarian":  {"fish", "shellfish", "meat"},
+	"gluten_free": {"gluten", "wheat"},
+	"dairy_free":  {"milk"},
+}
+
+// resolveFoodTags merges client-supplied tags with tags inferred from the name. A claim such as
+// "gluten_free" is unverified and never removes an inferred allergen: when the name contradicts
+// it, the claim is kept and also returned as a label mismatch so the user checks the label.
+func resolveFoodTags(foodName string, declaredAllergens, declaredTags []string) ([]string, []string, []string) {
+	inferredAllergens, inferredTags := inferFoodTags(foodName)
+
+	inferred := map[string]bool{}
+	for _, t := range append(append([]string{}, inferredAllergens...), inferredTags...) {
+		inferred[t] = true
+	}
+	mismatches := []string{}
+	for _, claim := range declaredTags {
+		for _, excluded := range claimExclusions[claim] {
+			if inferred[excluded] {
+				mismatches = append(mismatches, claim)
+				break
+			}
+		}
+	}
+
+	merge := func(declared, inferred []string) []string {
+		set := map[string]bool{}
+		for _, t := range append(append([]string{}, declared...), inferred...) {
+			set[t] = true
+		}
+		out := make([]string, 0, len(set))
+		for t := range set {
+			out = append(out, t)
+		}
+		sort.Strings(out)
+		return out
+	}
+
+	return merge(declaredAllergens, inferredAllergens), merge(declaredTags, inferredTags), mismatches
+}
+
+// findDietConflicts returns conflict codes ("allergen:<name>" or a violated restriction) for a food
+func findDietConflicts(profile dietProfile, foodAllergens, foodTags []string) []string {
+	allergens := map[string]bool{}
+	for _, a := range foodAllergens {
+		allergens[a] = true
+	}
+	tags := map[string]bool{}
+	for _, t := range foodTags {
+		tags[t] = true
+	}
+
+	conflicts := []string{}
+	for _, a := range profile.Allergens {
+		if allergens[a] {
+			conflicts = append(conflicts, "allergen:"+a)
+		}
+	}
+	// Claims on the food don't excuse what its allergens and tags show
+	for _, r := range profile.Restrictions {
+		for _, excluded := range claimExclusions[r] {
+			if allergens[excluded] || tags[excluded] {
+				conflicts = append(conflicts, r)
+				break
+			}
+		}
+	}
+	return conflicts
+}
+
+// describeDietConflicts turns conflict codes into user-facing warnings
+func describeDietConflicts(foodName string, conflicts []string) []string {
+	warnings := make([]string, 0, len(conflicts))
+	for _, code := range conflicts {
+		if strings.HasPrefix(code, "allergen:") {
+			allergen := strings.ReplaceAll(strings.TrimPrefix(code, "allergen:"), "_", " ")
+			warnings = append(warnings, fmt.Sprintf("⚠️ %s may contain %s, which is listed in your allergens.", foodName, allergen))
+		} else {
+			warnings = append(warnings, fmt.Sprintf("⚠️ %s does not appear to be %s.", foodName, strings.ReplaceAll(code, "_", "-")))
+		}
+	}
+	return warnings
+}
+
+// describeLabelMismatches turns a food's contradicted diet claims into user-facing warnings
+func describeLabelMismatches(foodName string, claims []string) []string {
+	warnings := make([]string, 0, len(claims))
+	for _, claim := range claims {
+		warnings = append(warnings, fmt.Sprintf("⚠️ %s is labelled %s, but its name suggests otherwise. Check the label.",
+			foodName, strings.ReplaceAll(claim, "_", "-")))
+	}
+	return warnings
+}
+
+// loadDietProfile fetches a user's dietary restrictions and allergens
+func loadDietProfile(db *sql.DB, userID string) (dietProfile, error) {
+	var p dietProfile
+	err := db.QueryRow(
+		`SELECT dietary_restrictions, allergens FROM users WHERE id = $1`,
+		userID,
+	).Scan(pq.Array(&p.Restrictions), pq.Array(&p.Allergens))
+	return p, err
+}
+
+// SearchFoods handles GET /user/foods/search?q=&exclude_conflicts=true
+// Matches the built-in food catalog and flags (or filters out) foods that conflict with the user's diet.
+func SearchFoods(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if _, err := uuid.Parse(userID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	query := strings.ToLower(strings.TrimSpace(c.Query("q")))
+	if query == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "q is required"})
+		return
+	}
+	excludeConflicts := c.Query("exclude_conflicts") == "true"
+
+	profile, err := loadDietProfile(config.DB, userID)
+	if err != nil {
+		log.Println("DB SELECT ERROR (SearchFoods):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load dietary profile"})
+		return
+	}
+
+	results := []gin.H{}
+	for _, food := range foodCatalog {
+		if !strings.Contains(strings.ToLower(food.FoodName), query) && !strings.Contains(query, food.Keyword) {
+			continue
+		}
+		allergens, tags, mismatches := resolveFoodTags(food.FoodName, food.Allergens, food.DietTags)
+		conflicts := findDietConflicts(profile, allergens, tags)
+		if excludeConflicts && len(conflicts) > 0 {
+			continue
+		}
+		result := food.toMap()
+		result["allergens"] = allergens
+		result["diet_tags"] = tags
+		result["diet_conflicts"] = conflicts
+		result["label_mismatches"] = mismatches
+		result["warnings"] = append(describeDietConflicts(food.FoodName, conflicts), describeLabelMismatches(food.FoodName, mismatches)...)
+		results = append(results, result)
+	}
+
+	c.JSON(http.StatusOK, results)
+}
+
+// GetDietExposures handles GET /user/diet/exposures?from=&to=
+// Lists logged foods that conflicted with the user's profile when they were logged.
+func GetDietExposures(c *gin.Context) {
+	userIDStr := c.GetString("user_id")
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	from := c.DefaultQuery("from", time.Now().AddDate(0, 0, -30).Format("2006-01-02"))
+	to := c.DefaultQuery("to", time.Now().Format("2006-01-02"))
+	if _, err := time.Parse("2006-01-02", from); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must be in YYYY-MM-DD format"})
+		return
+	}
+	if _, err := time.Parse("2006-01-02", to); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "to must be in YYYY-MM-DD format"})
+		return
+	}
+
+	rows, err := config.DB.Query(
+		`SELECT mf.id, mf.meal_id, m.date::text, m.meal_type, mf.food_name, mf.allergens, mf.diet_conflicts
+         FROM meal_foods mf
+         JOIN meals m ON mf.meal_id = m.id
+         WHERE m.user_id = $1
+           AND m.date::date BETWEEN $2::date AND $3::date
+           AND cardinality(mf.diet_conflicts) > 0
+         ORDER BY m.date DESC, m.created_at DESC`,
+		userID, from, to,
+	)
+	if err != nil {
+		log.Println("DB SELECT ERROR (GetDietExposures):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch exposures"})
+		return
+	}
+	defer rows.Close()
+
+	exposures := []gin.H{}
+	totals := map[string]int{}
+	for rows.Next() {
+		var id, mealID, date, mealType, foodName string
+		var allergens, conflicts []string
+		if err := rows.Scan(&id, &mealID, &date, &mealType, &foodName, pq.Array(&allergens), pq.Array(&conflicts)); err != nil {
+			log.Println("DB SCAN ERROR (GetDietExposures):", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse exposures"})
+			return
+		}
+		for _, code := range conflicts {
+			totals[code]++
+		}
+		exposures = append(exposures, gin.H{
+			"meal_food_id":   id,
+			"meal_id":        mealID,
+			"date":           date,
+			"meal_type":      mealType,
+			"food_name":      foodName,
+			"allergens":      allergens,
+			"diet_conflicts": conflicts,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"from":      from,
+		"to":        to,
+		"count":     len(exposures),
+		"by_type":   totals,
+		"exposures": exposures,
+	})
+}
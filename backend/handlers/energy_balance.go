@@ -0,0 +1,245 @@
+package handlers
+
+import (
+	"database/sql"
+	"log"
+	"math"
+	"net/http"
+	"time"
+
+	"nutritionix/backend/bodycomp"
+	"nutritionix/backend/config"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// kcalPerKg is the usual rule of thumb for the energy in a kilogram of body weight change
+const kcalPerKg = 7700
+
+// maxBalanceDays caps the energy balance report at about a year
+const maxBalanceDays = 366
+
+// energyDay is one day of GetEnergyBalance. Fields are null when the inputs for them are missing:
+// intake on days without logged meals, BMR without age and height on the profile.
+type energyDay struct {
+	Date              string   `json:"date"`
+	IntakeKcal        *int     `json:"intake_kcal"`
+	BMRKcal           *int     `json:"bmr_kcal"`
+	ActivityKcal      int      `json:"activity_kcal"` // calories_burned of completed workouts
+	ExpenditureKcal   *int     `json:"expenditure_kcal"`
+	NetKcal           *int     `json:"net_kcal"`            // intake - expenditure
+	CumulativeNetKcal int      `json:"cumulative_net_kcal"` // over the days with a net balance so far
+	ImpliedChangeKg   float64  `json:"implied_change_kg"`
+	WeightKg          *float64 `json:"weight_kg"`           // average of the day's weigh-ins
+	TrendWeightKg     *float64 `json:"trend_weight_kg"`     // smoothed weight, from the first weigh-in on
+	ProjectedWeightKg *float64 `json:"projected_weight_kg"` // baseline trend weight plus the implied change since
+}
+
+// GetEnergyBalance handles GET /user/energy-balance?from=&to=
+// It joins meal intake with BMR and workout expenditure per day, accumulates the net balance into
+// an implied weight change, and compares that projection with the weight trend.
+func GetEnergyBalance(c *gin.Context) {
+	userID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	var age, heightCm sql.NullInt64
+	var profileWeight sql.NullFloat64
+	var sex sql.NullString
+	var timezone string
+	err = config.DB.QueryRow(
+		`SELECT age, height, weight, sex, timezone FROM users WHERE id = $1`, userID,
+	).Scan(&age, &heightCm, &profileWeight, &sex, &timezone)
+	if err != nil {
+		log.Println("DB SELECT ERROR (GetEnergyBalance):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load profile"})
+		return
+	}
+
+	to := c.DefaultQuery("to", localToday(timezone))
+	toDate, err := time.Parse("2006-01-02", to)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "to must be in YYYY-MM-DD format"})
+		return
+	}
+	from := c.DefaultQuery("from", toDate.AddDate(0, 0, -29).Format("2006-01-02"))
+	fromDate, err := time.Parse("2006-01-02", from)
+	if err != nil || fromDate.After(toDate) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must be a YYYY-MM-DD date on or before to"})
+		return
+	}
+	if toDate.Sub(fromDate) >= maxBalanceDays*24*time.Hour {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "the range can be at most 366 days"})
+		return
+	}
+
+	intake, err := dailyTotals(
+		`SELECT m.date::date::text, COALESCE(SUM(mf.calories), 0)
+         FROM meals m JOIN meal_foods mf ON mf.meal_id = m.id
+         WHERE m.user_id = $1 AND m.date::date BETWEEN $2::date AND $3::date
+         GROUP BY 1`,
+		userID, from, to,
+	)
+	if err != nil {
+		log.Println("DB SELECT ERROR (GetEnergyBalance):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute energy balance"})
+		return
+	}
+	activity, err := dailyTotals(
+		`SELECT date::text, COALESCE(SUM(calories_burned), 0)
+         FROM workouts
+         WHERE user_id = $1 AND status = 'completed' AND date BETWEEN $2::date AND $3::date
+         GROUP BY 1`,
+		userID, from, to,
+	)
+	if err != nil {
+		log.Println("DB SELECT ERROR (GetEnergyBalance):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute energy balance"})
+		return
+	}
+	// Earlier weigh-ins carry the trend into the range, setting the weight used for BMR and the
+	// baseline of the projection
+	weighIns, err := loadWeighIns(userID, "0001-01-01", to)
+	if err != nil {
+		log.Println("DB SELECT ERROR (GetEnergyBalance):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute energy balance"})
+		return
+	}
+	trend := buildWeightTrend(weighIns, to)
+
+	missing := []string{}
+	if !age.Valid || age.Int64 <= 0 {
+		missing = append(missing, "age")
+	}
+	if !heightCm.Valid || heightCm.Int64 <= 0 {
+		missing = append(missing, "height")
+	}
+	if len(weighIns) == 0 && (!profileWeight.Valid || profileWeight.Float64 <= 0) {
+		missing = append(missing, "weight")
+	}
+
+	// Body weight as of each day: the trend, else the profile until the first weigh-in
+	currentWeight := profileWeight.Float64
+	if currentWeight <= 0 && len(weighIns) > 0 {
+		currentWeight = weighIns[0].WeightKg
+	}
+	var baselineWeight *float64
+	baselineCum := 0
+	if day, ok := trend.at(fromDate.AddDate(0, 0, -1).Format("2006-01-02")); ok {
+		baselineWeight = &day.TrendWeightKg
+	}
+
+	days := []energyDay{}
+	cum := 0
+	intakeTotal, activityTotal, expenditureTotal, loggedDays := 0, 0, 0, 0
+	for d := fromDate; !d.After(toDate); d = d.AddDate(0, 0, 1) {
+		date := d.Format("2006-01-02")
+		day := energyDay{Date: date, ActivityKcal: activity[date]}
+		if t, ok := trend.at(date); ok {
+			day.WeightKg, day.TrendWeightKg = t.ScaleWeightKg, &t.TrendWeightKg
+			currentWeight = t.TrendWeightKg
+		}
+		activityTotal += day.ActivityKcal
+		if kcal, ok := intake[date]; ok {
+			day.IntakeKcal = &kcal
+			intakeTotal += kcal
+			loggedDays++
+		}
+		if r, err := bodycomp.MifflinStJeor(currentWeight, float64(heightCm.Int64), int(age.Int64), sex.String); len(missing) == 0 && err == nil {
+			bmr := int(r.Value)
+			expenditure := bmr + day.ActivityKcal
+			day.BMRKcal, day.ExpenditureKcal = &bmr, &expenditure
+			expenditureTotal += expenditure
+			if day.IntakeKcal != nil {
+				net := *day.IntakeKcal - expenditure
+				day.NetKcal = &net
+				cum += net
+			}
+		}
+		day.CumulativeNetKcal = cum
+		day.ImpliedChangeKg = round2(float64(cum) / kcalPerKg)
+
+		if baselineWeight == nil && day.TrendWeightKg != nil {
+			// With no earlier weigh-in the first one in the range becomes the baseline
+			baselineWeight, baselineCum = day.TrendWeightKg, cum
+		}
+		if baselineWeight != nil {
+			projected := round2(*baselineWeight + float64(cum-baselineCum)/kcalPerKg)
+			day.ProjectedWeightKg = &projected
+		}
+		days = append(days, day)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"from":                   from,
+		"to":                     to,
+		"days":                   days,
+		"missing_profile_fields": missing,
+		"summary": gin.H{
+			"days_with_meals":     loggedDays,
+			"intake_kcal":         intakeTotal,
+			"activity_kcal":       activityTotal,
+			"expenditure_kcal":    expenditureTotal,
+			"net_kcal":            cum,
+			"implied_change_kg":   round2(float64(cum) / kcalPerKg),
+			"weigh_in_comparison": compareWithWeighIns(days),
+		},
+	})
+}
+
+// compareWithWeighIns sets the projected weight change against the change in trend weight from the
+// first to the last weighed day of the report; the trend keeps a single puffy morning from skewing
+// it. The discrepancy in kcal/day is positive when weight went up more than the logs explain,
+// which usually means intake is under-logged.
+func compareWithWeighIns(days []energyDay) gin.H {
+	var first, last *energyDay
+	for i := range days {
+		if days[i].WeightKg == nil || days[i].ProjectedWeightKg == nil {
+			continue
+		}
+		if first == nil {
+			first = &days[i]
+		}
+		last = &days[i]
+	}
+	if first == nil || first == last {
+		return nil
+	}
+
+	actual := round2(*last.TrendWeightKg - *first.TrendWeightKg)
+	projected := round2(*last.ProjectedWeightKg - *first.ProjectedWeightKg)
+	start, _ := time.Parse("2006-01-02", first.Date)
+	end, _ := time.Parse("2006-01-02", last.Date)
+	span := int(end.Sub(start).Hours() / 24)
+	return gin.H{
+		"from":                     first.Date,
+		"to":                       last.Date,
+		"actual_change_kg":         actual,
+		"projected_change_kg":      projected,
+		"difference_kg":            round2(actual - projected),
+		"discrepancy_kcal_per_day": int(math.Round((actual - projected) * kcalPerKg / float64(span))),
+	}
+}
+
+// dailyTotals runs a query returning (date, total) rows and collects them by date
+func dailyTotals(query string, args ...interface{}) (map[string]int, error) {
+	rows, err := config.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	totals := map[string]int{}
+	for rows.Next() {
+		var date string
+		var total float64
+		if err := rows.Scan(&date, &total); err != nil {
+			return nil, err
+		}
+		totals[date] = int(math.Round(total))
+	}
+	return totals, rows.Err()
+}
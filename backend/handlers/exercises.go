@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"nutritionix/backend/config"
+	"nutritionix/backend/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Where a workout's calories_burned value came from
+const (
+	CaloriesSourceManual      = "manual"
+	CaloriesSourceMETEstimate = "met_estimate"
+	CaloriesSourceDevice      = "device" // reported by the watch in an imported activity file
+	CaloriesSourceNone        = "none"
+)
+
+var errExerciseNotFound = errors.New("exercise not found in catalog")
+
+// ListExercises handles GET /user/exercises?type=&intensity=&muscle_group=
+func ListExercises(c *gin.Context) {
+	query := `SELECT id, slug, name, type, intensity, met, COALESCE(muscle_group, '') FROM exercise_catalog WHERE 1=1`
+	var args []interface{}
+
+	if t := strings.ToLower(c.Query("type")); t != "" {
+		args = append(args, t)
+		query += ` AND type = $` + strconv.Itoa(len(args))
+	}
+	if intensity := strings.ToLower(c.Query("intensity")); intensity != "" {
+		args = append(args, intensity)
+		query += ` AND intensity = $` + strconv.Itoa(len(args))
+	}
+	if group := strings.ToLower(c.Query("muscle_group")); group != "" {
+		args = append(args, group)
+		query += ` AND muscle_group = $` + strconv.Itoa(len(args))
+	}
+	query += ` ORDER BY type, name, met`
+
+	rows, err := config.DB.Query(query, args...)
+	if err != nil {
+		log.Println("DB SELECT ERROR (ListExercises):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch exercises"})
+		return
+	}
+	defer rows.Close()
+
+	exercises := []models.Exercise{}
+	for rows.Next() {
+		var e models.Exercise
+		if err := rows.Scan(&e.ID, &e.Slug, &e.Name, &e.Type, &e.Intensity, &e.MET, &e.MuscleGroup); err != nil {
+			log.Println("DB SCAN ERROR (ListExercises):", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse exercises"})
+			return
+		}
+		exercises = append(exercises, e)
+	}
+
+	c.JSON(http.StatusOK, exercises)
+}
+
+// lookupExercise finds a catalog exercise by ID, or by slug and intensity (default "moderate")
+func lookupExercise(exerciseID *int, slug, intensity string) (*models.Exercise, error) {
+	var e models.Exercise
+	var err error
+	if exerciseID != nil {
+		err = config.DB.QueryRow(
+			`SELECT id, slug, name, type, intensity, met, COALESCE(muscle_group, '') FROM exercise_catalog WHERE id = $1`,
+			*exerciseID,
+		).Scan(&e.ID, &e.Slug, &e.Name, &e.Type, &e.Intensity, &e.MET, &e.MuscleGroup)
+	} else {
+		if intensity == "" {
+			intensity = "moderate"
+		}
+		err = config.DB.QueryRow(
+			`SELECT id, slug, name, type, intensity, met, COALESCE(muscle_group, '') FROM exercise_catalog WHERE slug = $1 AND intensity = $2`,
+			strings.ToLower(strings.TrimSpace(slug)), strings.ToLower(intensity),
+		).Scan(&e.ID, &e.Slug, &e.Name, &e.Type, &e.Intensity, &e.MET, &e.MuscleGroup)
+	}
+	if err == sql.ErrNoRows {
+		return nil, errExerciseNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// bodyWeightKg returns the user's latest weigh-in, falling back to the weight on their profile
+func bodyWeightKg(userID uuid.UUID) (float64, bool) {
+	var weight sql.NullFloat64
+	err := config.DB.QueryRow(
+		`SELECT COALESCE((SELECT weight_kg FROM body_measurements WHERE user_id = $1 AND weight_kg IS NOT NULL
+                          ORDER BY measured_at DESC LIMIT 1), weight)
+         FROM users WHERE id = $1`,
+		userID,
+	).Scan(&weight)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Println("DB SELECT ERROR (bodyWeightKg):", err)
+		}
+		return 0, false
+	}
+	if !weight.Valid || weight.Float64 <= 0 {
+		return 0, false
+	}
+	return weight.Float64, true
+}
+
+// estimateCalories computes kcal = MET × body weight (kg) × duration (hours)
+func estimateCalories(met, weightKg float64, durationMin int) int {
+	return int(math.Round(met * weightKg * float64(durationMin) / 60.0))
+}
+
+// resolveWorkoutCalories decides the calories to record for a workout.
+// A positive client value always wins; otherwise a catalog exercise and a known body weight give a MET estimate.
+func resolveWorkoutCalories(userID uuid.UUID, exercise *models.Exercise, durationMin, clientCalories int) (int, string) {
+	if clientCalories > 0 {
+		return clientCalories, CaloriesSourceManual
+	}
+	if exercise != nil && durationMin > 0 {
+		if weight, ok := bodyWeightKg(userID); ok {
+			return estimateCalories(exercise.MET, weight, durationMin), CaloriesSourceMETEstimate
+		}
+	}
+	return 0, CaloriesSourceNone
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"strings"
+)
+
+// catalogFood is a built-in food entry served by the nutrition lookup and food search
+type catalogFood struct {
+	Keyword            string
+	FoodName           string
+	Calories           float64
+	Protein            float64
+	Carbs              float64
+	Fat                float64
+	ServingQty         float64
+	ServingUnit        string
+	ServingWeightGrams float64
+	Allergens          []string
+	DietTags           []string
+}
+
+// foodCatalog holds the mock foods used while the external nutrition API is unavailable
+var foodCatalog = []catalogFood{
+	{Keyword: "rice", FoodName: "Cooked White Rice", Calories: 130.0, Protein: 2.7, Carbs: 28.0, Fat: 0.3, ServingQty: 1.0, ServingUnit: "cup", ServingWeightGrams: 158.0, DietTags: []string{"vegan", "gluten_free", "dairy_free"}},
+	{Keyword: "chicken", FoodName: "Grilled Chicken Breast", Calories: 165.0, Protein: 31.0, Carbs: 0.0, Fat: 3.6, ServingQty: 100.0, ServingUnit: "grams", ServingWeightGrams: 100.0, DietTags: []string{"meat", "gluten_free", "dairy_free"}},
+	{Keyword: "apple", FoodName: "Apple", Calories: 95.0, Protein: 0.5, Carbs: 25.0, Fat: 0.3, ServingQty: 1.0, ServingUnit: "medium apple", ServingWeightGrams: 182.0, DietTags: []string{"vegan", "gluten_free", "dairy_free"}},
+	{Keyword: "banana", FoodName: "Banana", Calories: 105.0, Protein: 1.3, Carbs: 27.0, Fat: 0.4, ServingQty: 1.0, ServingUnit: "medium banana", ServingWeightGrams: 118.0, DietTags: []string{"vegan", "gluten_free", "dairy_free"}},
+	{Keyword: "egg", FoodName: "Large Egg", Calories: 70.0, Protein: 6.0, Carbs: 0.6, Fat: 5.0, ServingQty: 1.0, ServingUnit: "large egg", ServingWeightGrams: 50.0, Allergens: []string{"eggs"}, DietTags: []string{"vegetarian", "gluten_free", "dairy_free"}},
+	{Keyword: "bread", FoodName: "White Bread", Calories: 80.0, Protein: 2.3, Carbs: 15.0, Fat: 1.0, ServingQty: 1.0, ServingUnit: "slice", ServingWeightGrams: 28.0, Allergens: []string{"wheat", "gluten"}, DietTags: []string{"vegetarian"}},
+}
+
+// toMap renders a catalog food in the /api/nutrition response format
+func (f catalogFood) toMap() map[string]interface{} {
+	return map[string]interface{}{
+		"food_name":            f.FoodName,
+		"calories":             f.Calories,
+		"protein":              f.Protein,
+		"carbs":                f.Carbs,
+		"fat":                  f.Fat,
+		"serving_qty":          f.ServingQty,
+		"serving_unit":         f.ServingUnit,
+		"serving_weight_grams": f.ServingWeightGrams,
+	}
+}
+
+// LookupFood returns nutrition info for the first catalog food whose keyword appears in the query,
+// or a generic serving named after the query
+func LookupFood(query string) map[string]interface{} {
+	query = strings.ToLower(strings.TrimSpace(query))
+	for _, food := range foodCatalog {
+		if strings.Contains(query, food.Keyword) {
+			return food.toMap()
+		}
+	}
+	return catalogFood{
+		FoodName:           strings.Title(query),
+		Calories:           100.0,
+		Protein:            5.0,
+		Carbs:              15.0,
+		Fat:                3.0,
+		ServingQty:         1.0,
+		ServingUnit:        "serving",
+		ServingWeightGrams: 100.0,
+	}.toMap()
+}
@@ -0,0 +1,357 @@
+package handlers
+
+import (
+	"database/sql"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"nutritionix/backend/config"
+	"nutritionix/backend/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Outcomes of a closed goal period
+const (
+	GoalPeriodMet    = "met"
+	GoalPeriodMissed = "missed"
+)
+
+// maxGoalGapPeriods bounds how many untouched periods one rollover writes; older gaps aren't recorded
+const maxGoalGapPeriods = 366
+
+// goalMet decides whether progress meets a target. Ceiling goals need something logged under the limit.
+func goalMet(atMost bool, progress, target int) bool {
+	if atMost {
+		return progress > 0 && progress <= target
+	}
+	return progress >= target
+}
+
+// nextDay returns the calendar day after date
+func nextDay(date string) string {
+	d, _ := time.Parse("2006-01-02", date)
+	return d.AddDate(0, 0, 1).Format("2006-01-02")
+}
+
+// rolloverGoalPeriods closes the user's goal periods that ended before today, recording them as met
+// or missed, and moves each goal on to the current period with progress reset. Periods nobody
+// touched in between are recorded too so streaks see the gaps; data-bound goals get their real
+// progress for them, manual goals zero. Goals without a period_start (new, restored, or with a
+// changed time frame) just start the current period.
+func rolloverGoalPeriods(userID uuid.UUID, today string) {
+	type openGoal struct {
+		ID          uuid.UUID
+		GoalType    string
+		TimeFrame   string
+		Target      int
+		Progress    int
+		Completed   bool
+		PeriodStart sql.NullString
+	}
+
+	rows, err := config.DB.Query(
+		`SELECT id, goal_type, time_frame, target_value, progress_value, is_completed, period_start::text
+         FROM user_goals WHERE user_id = $1 AND archived = false`,
+		userID,
+	)
+	if err != nil {
+		log.Println("DB SELECT ERROR (rolloverGoalPeriods):", err)
+		return
+	}
+	var goals []openGoal
+	for rows.Next() {
+		var g openGoal
+		if err := rows.Scan(&g.ID, &g.GoalType, &g.TimeFrame, &g.Target, &g.Progress, &g.Completed, &g.PeriodStart); err != nil {
+			log.Println("DB SCAN ERROR (rolloverGoalPeriods):", err)
+			rows.Close()
+			return
+		}
+		goals = append(goals, g)
+	}
+	rows.Close()
+
+	closedAny := false
+goals:
+	for _, g := range goals {
+		t := goalTypes[g.GoalType]
+		if t.OpenEnded {
+			continue
+		}
+		current, _ := goalPeriod(g.TimeFrame, today)
+		if !g.PeriodStart.Valid {
+			if _, err := config.DB.Exec(
+				`UPDATE user_goals SET period_start = $1 WHERE id = $2 AND period_start IS NULL`, current, g.ID,
+			); err != nil {
+				log.Println("DB UPDATE ERROR (rolloverGoalPeriods):", err)
+			}
+			continue
+		}
+		if g.PeriodStart.String >= current {
+			continue
+		}
+		bound := t.Bound()
+
+		var closed []models.GoalPeriod
+		for start, _ := goalPeriod(g.TimeFrame, g.PeriodStart.String); start < current; {
+			_, end := goalPeriod(g.TimeFrame, start)
+			closed = append(closed, models.GoalPeriod{GoalID: g.ID, PeriodStart: start, PeriodEnd: end, TargetValue: g.Target})
+			start = nextDay(end)
+		}
+		if len(closed) > maxGoalGapPeriods {
+			closed = closed[len(closed)-maxGoalGapPeriods:]
+		}
+		for i := range closed {
+			p := &closed[i]
+			// Only the period the goal was in has progress on record; the gaps after it have none
+			stored := p.PeriodStart <= g.PeriodStart.String
+			switch {
+			case bound:
+				// Logs can arrive after the last recompute, so count the period again now it's over
+				value, err := t.progress(userID, p.PeriodStart, p.PeriodEnd)
+				if err != nil {
+					// Leave the goal for the next rollover rather than record a wrong outcome
+					log.Println("DB SELECT ERROR (rolloverGoalPeriods):", err)
+					continue goals
+				}
+				p.ProgressValue = int(math.Round(value))
+			case stored:
+				p.ProgressValue = g.Progress
+			}
+			p.Status = GoalPeriodMissed
+			if goalMet(t.AtMost(), p.ProgressValue, g.Target) || (!bound && stored && g.Completed) {
+				p.Status = GoalPeriodMet
+			}
+		}
+
+		if err := closeGoalPeriods(g.ID, g.PeriodStart.String, current, closed); err != nil {
+			log.Println("DB UPDATE ERROR (rolloverGoalPeriods):", err)
+			continue
+		}
+		closedAny = true
+	}
+	if closedAny {
+		CheckAchievements(userID, EventGoalPeriodClosed)
+	}
+}
+
+// closeGoalPeriods records the closed periods and starts the current one in a transaction. The
+// period_start check makes a concurrent rollover of the same goal a no-op.
+func closeGoalPeriods(goalID uuid.UUID, previousStart, current string, closed []models.GoalPeriod) error {
+	tx, err := config.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(
+		`UPDATE user_goals SET progress_value = 0, is_completed = false, period_start = $1, updated_at = NOW()
+         WHERE id = $2 AND period_start = $3`,
+		current, goalID, previousStart,
+	)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil
+	}
+	for _, p := range closed {
+		if _, err := tx.Exec(
+			`INSERT INTO goal_periods (goal_id, period_start, period_end, target_value, progress_value, status)
+             VALUES ($1, $2, $3, $4, $5, $6)
+             ON CONFLICT (goal_id, period_start) DO NOTHING`,
+			goalID, p.PeriodStart, p.PeriodEnd, p.TargetValue, p.ProgressValue, p.Status,
+		); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// RollGoalPeriods is the scheduled rollover for users who have a goal whose period has ended in
+// their timezone, so history is kept even when nobody opens the app
+func RollGoalPeriods() {
+	rows, err := config.DB.Query(
+		`SELECT DISTINCT g.user_id
+         FROM user_goals g JOIN users u ON u.id = g.user_id
+         WHERE g.archived = false AND g.period_start < CASE g.time_frame
+             WHEN 'weekly' THEN date_trunc('week', (NOW() AT TIME ZONE u.timezone)::date)::date
+             WHEN 'monthly' THEN date_trunc('month', (NOW() AT TIME ZONE u.timezone)::date)::date
+             ELSE (NOW() AT TIME ZONE u.timezone)::date
+         END`,
+	)
+	if err != nil {
+		log.Println("DB SELECT ERROR (RollGoalPeriods):", err)
+		return
+	}
+	var users []uuid.UUID
+	for rows.Next() {
+		var userID uuid.UUID
+		if err := rows.Scan(&userID); err != nil {
+			log.Println("DB SCAN ERROR (RollGoalPeriods):", err)
+			continue
+		}
+		users = append(users, userID)
+	}
+	rows.Close()
+
+	for _, userID := range users {
+		// Rolls the periods over, then counts data-bound goals for the new period
+		RecomputeGoalProgress(userID)
+	}
+	if len(users) > 0 {
+		log.Printf("🔄 Goal period rollover for %d users", len(users))
+	}
+}
+
+// goalStreak counts consecutive met periods. periods are closed periods, newest first; an open
+// period that is already met extends the streak, one still in progress doesn't break it.
+func goalStreak(periods []models.GoalPeriod, currentMet bool) (current, best int) {
+	run := 0
+	if currentMet {
+		run = 1
+	}
+	current = run
+	counting := true
+	for _, p := range periods {
+		if p.Status == GoalPeriodMet {
+			run++
+			if counting {
+				current = run
+			}
+			continue
+		}
+		counting = false
+		if run > best {
+			best = run
+		}
+		run = 0
+	}
+	if run > best {
+		best = run
+	}
+	return current, best
+}
+
+// loadGoalPeriods returns the closed periods of the given goals, newest first
+func loadGoalPeriods(goalIDs []uuid.UUID, limit int) (map[uuid.UUID][]models.GoalPeriod, error) {
+	ids := make([]string, len(goalIDs))
+	for i, id := range goalIDs {
+		ids[i] = id.String()
+	}
+	query := `SELECT id, goal_id, period_start::text, period_end::text, target_value, progress_value, status, closed_at
+              FROM goal_periods WHERE goal_id = ANY($1::uuid[]) ORDER BY goal_id, period_start DESC`
+	rows, err := config.DB.Query(query, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	periods := map[uuid.UUID][]models.GoalPeriod{}
+	for rows.Next() {
+		var p models.GoalPeriod
+		if err := rows.Scan(&p.ID, &p.GoalID, &p.PeriodStart, &p.PeriodEnd, &p.TargetValue, &p.ProgressValue,
+			&p.Status, &p.ClosedAt); err != nil {
+			return nil, err
+		}
+		if limit <= 0 || len(periods[p.GoalID]) < limit {
+			periods[p.GoalID] = append(periods[p.GoalID], p)
+		}
+	}
+	return periods, rows.Err()
+}
+
+// GetGoalHistory handles GET /goals/:id/history?limit=
+// It returns the current period, the closed ones newest first, and the streak of periods met.
+func GetGoalHistory(c *gin.Context) {
+	userID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	goalID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid goal ID"})
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "30"))
+	if err != nil || limit <= 0 || limit > maxGoalGapPeriods {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 366"})
+		return
+	}
+
+	RecomputeGoalProgress(userID)
+
+	var goalType string
+	var label *string
+	var timeFrame string
+	var target, progress int
+	var completed bool
+	var periodStart sql.NullString
+	err = config.DB.QueryRow(
+		`SELECT goal_type, label, time_frame, target_value, progress_value, is_completed, period_start::text
+         FROM user_goals WHERE id = $1 AND user_id = $2`,
+		goalID, userID,
+	).Scan(&goalType, &label, &timeFrame, &target, &progress, &completed, &periodStart)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Goal not found"})
+		return
+	}
+	if err != nil {
+		log.Println("DB SELECT ERROR (GetGoalHistory):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch goal history"})
+		return
+	}
+
+	// The streak needs every period; the response only the newest ones
+	all, err := loadGoalPeriods([]uuid.UUID{goalID}, 0)
+	if err != nil {
+		log.Println("DB SELECT ERROR (GetGoalHistory):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch goal history"})
+		return
+	}
+	periods := all[goalID]
+	currentStreak, bestStreak := goalStreak(periods, completed)
+	if len(periods) > limit {
+		periods = periods[:limit]
+	}
+	if periods == nil {
+		periods = []models.GoalPeriod{}
+	}
+
+	var current gin.H
+	if periodStart.Valid {
+		_, end := goalPeriod(timeFrame, periodStart.String)
+		current = gin.H{
+			"period_start":   periodStart.String,
+			"period_end":     end,
+			"target_value":   target,
+			"progress_value": progress,
+			"is_completed":   completed,
+		}
+	}
+	met := 0
+	for _, p := range all[goalID] {
+		if p.Status == GoalPeriodMet {
+			met++
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"goal_id":        goalID,
+		"goal_type":      goalType,
+		"name":           goalDisplayName(goalType, label),
+		"unit":           goalTypes[goalType].Unit,
+		"time_frame":     timeFrame,
+		"current_period": current,
+		"periods":        periods,
+		"streak":         gin.H{"current": currentStreak, "best": bestStreak},
+		"periods_met":    met,
+		"periods_closed": len(all[goalID]),
+	})
+}
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"nutritionix/backend/config"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// goalPeriod returns the first and last day of the daily, weekly (Monday-based) or monthly period containing today
+func goalPeriod(timeFrame, today string) (string, string) {
+	d, _ := time.Parse("2006-01-02", today)
+	switch timeFrame {
+	case "weekly":
+		d = d.AddDate(0, 0, -((int(d.Weekday()) + 6) % 7))
+		return d.Format("2006-01-02"), d.AddDate(0, 0, 6).Format("2006-01-02")
+	case "monthly":
+		d = d.AddDate(0, 0, 1-d.Day())
+		return d.Format("2006-01-02"), d.AddDate(0, 1, -1).Format("2006-01-02")
+	}
+	return today, today
+}
+
+// RecomputeGoalProgress rolls the user's goals over into the current period, refreshes the
+// data-bound ones and sends the 80% and completed notifications when progress crosses them.
+// Handlers call it after changing meals, workouts or activity; errors are logged rather than
+// failing the request that triggered it.
+func RecomputeGoalProgress(userID uuid.UUID) {
+	type storedGoal struct {
+		ID          uuid.UUID
+		GoalType    string
+		Label       *string
+		TimeFrame   string
+		Target      int
+		Progress    int
+		Completed   bool
+		PeriodStart sql.NullString
+	}
+
+	today := localToday(userTimezone(userID))
+	rolloverGoalPeriods(userID, today)
+	refreshWeightGoals(userID, today)
+
+	rows, err := config.DB.Query(
+		`SELECT id, goal_type, label, time_frame, target_value, progress_value, is_completed, period_start::text
+         FROM user_goals WHERE user_id = $1 AND archived = false AND goal_type <> ALL($2)`,
+		userID, pq.Array(ManualGoalTypes()),
+	)
+	if err != nil {
+		log.Println("DB SELECT ERROR (RecomputeGoalProgress):", err)
+		return
+	}
+	var goals []storedGoal
+	for rows.Next() {
+		var g storedGoal
+		if err := rows.Scan(&g.ID, &g.GoalType, &g.Label, &g.TimeFrame, &g.Target, &g.Progress, &g.Completed,
+			&g.PeriodStart); err != nil {
+			log.Println("DB SCAN ERROR (RecomputeGoalProgress):", err)
+			rows.Close()
+			return
+		}
+		goals = append(goals, g)
+	}
+	rows.Close()
+
+	computed := map[string]int{}
+	for _, g := range goals {
+		t, ok := goalTypes[g.GoalType]
+		if !ok || t.progress == nil {
+			continue
+		}
+		from, to := goalPeriod(g.TimeFrame, today)
+		key := g.GoalType + "/" + g.TimeFrame
+		progress, ok := computed[key]
+		if !ok {
+			value, err := t.progress(userID, from, to)
+			if err != nil {
+				log.Println("DB SELECT ERROR (RecomputeGoalProgress):", err)
+				continue
+			}
+			progress = int(math.Round(value))
+			computed[key] = progress
+		}
+
+		// A new period starts from zero, so crossing a threshold again notifies again
+		previous, wasCompleted := g.Progress, g.Completed
+		if !g.PeriodStart.Valid || g.PeriodStart.String != from {
+			previous, wasCompleted = 0, false
+		}
+		completed := goalMet(t.AtMost(), progress, g.Target)
+		if g.PeriodStart.String == from && progress == g.Progress && completed == g.Completed {
+			continue
+		}
+
+		_, err := config.DB.Exec(
+			`UPDATE user_goals SET progress_value = $1, is_completed = $2, period_start = $3, updated_at = NOW()
+             WHERE id = $4`,
+			progress, completed, from, g.ID,
+		)
+		if err != nil {
+			log.Println("DB UPDATE ERROR (RecomputeGoalProgress):", err)
+			continue
+		}
+		notifyGoalProgress(userID, g.ID, goalDisplayName(g.GoalType, g.Label), t.AtMost(), g.Target, previous, progress,
+			wasCompleted, completed)
+	}
+}
+
+// notifyGoalProgress sends the notification for a threshold the progress has just crossed
+func notifyGoalProgress(userID, goalID uuid.UUID, name string, atMost bool, target, previous, progress int, wasCompleted, completed bool) {
+	nearly := int(math.Ceil(float64(target) * 0.8))
+	var msg string
+	switch {
+	case atMost && progress > target && previous <= target:
+		msg = fmt.Sprintf("🚫 You've gone over your '%s' limit for this period.", name)
+	case atMost && progress >= nearly && previous < nearly && progress <= target:
+		msg = fmt.Sprintf("⚠️ Heads up! You've used 80%% of your '%s' limit.", name)
+	case !atMost && completed && !wasCompleted:
+		msg = fmt.Sprintf("🎯 Congratulations! Your goal '%s' is completed.", name)
+	case !atMost && !completed && progress >= nearly && previous < nearly:
+		msg = fmt.Sprintf("💪 You're close! Your goal '%s' is 80%% complete.", name)
+	}
+	if msg != "" && !HasRecentNotification(userID, goalID, msg) {
+		_ = CreateNotification(userID, &goalID, msg)
+	}
+}
@@ -0,0 +1,223 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"nutritionix/backend/config"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Directions a goal target can have
+const (
+	GoalAtLeast = "at_least"
+	GoalAtMost  = "at_most"
+)
+
+// Where a goal type's progress comes from
+const (
+	GoalSourceManual   = "manual"
+	GoalSourceMeals    = "meals"
+	GoalSourceWorkouts = "workouts"
+	GoalSourceActivity = "activity"
+	GoalSourceWeighIns = "weigh_ins"
+)
+
+// Label length limits; only custom goals need one
+const (
+	minGoalLabel = 3
+	maxGoalLabel = 50
+)
+
+// goalType is one entry in the goal registry. Goals of a type with a source other than manual have
+// their progress computed from logged data for each period.
+type goalType struct {
+	Key        string   `json:"goal_type"`
+	Name       string   `json:"name"`
+	Unit       string   `json:"unit"`
+	Direction  string   `json:"direction"`
+	TimeFrames []string `json:"time_frames"` // the first is the default
+	Source     string   `json:"source"`
+	MinTarget  int      `json:"min_target"`
+	MaxTarget  int      `json:"max_target"`
+	OpenEnded  bool     `json:"open_ended,omitempty"` // runs until reached instead of resetting each period
+	progress   func(userID uuid.UUID, from, to string) (float64, error)
+}
+
+// AtMost reports whether the target is a ceiling rather than something to reach
+func (t goalType) AtMost() bool {
+	return t.Direction == GoalAtMost
+}
+
+// Bound reports whether progress is computed rather than sent by clients
+func (t goalType) Bound() bool {
+	return t.Source != GoalSourceManual
+}
+
+var goalTypeList = []goalType{
+	{Key: "protein", Name: "Protein", Unit: "g", Direction: GoalAtLeast, TimeFrames: []string{"daily"},
+		Source: GoalSourceMeals, MinTarget: 10, MaxTarget: 500,
+		progress: func(userID uuid.UUID, from, to string) (float64, error) {
+			return goalMealTotal("protein", userID, from, to)
+		}},
+	{Key: "calories", Name: "Calories", Unit: "kcal", Direction: GoalAtMost, TimeFrames: []string{"daily"},
+		Source: GoalSourceMeals, MinTarget: 800, MaxTarget: 10000,
+		progress: func(userID uuid.UUID, from, to string) (float64, error) {
+			return goalMealTotal("calories", userID, from, to)
+		}},
+	{Key: "steps", Name: "Steps", Unit: "steps", Direction: GoalAtLeast, TimeFrames: []string{"daily", "weekly"},
+		Source: GoalSourceActivity, MinTarget: 500, MaxTarget: 700000,
+		progress: func(userID uuid.UUID, from, to string) (float64, error) {
+			var steps float64
+			err := config.DB.QueryRow(
+				`SELECT COALESCE(SUM(steps), 0) FROM daily_activity WHERE user_id = $1 AND date BETWEEN $2::date AND $3::date`,
+				userID, from, to,
+			).Scan(&steps)
+			return steps, err
+		}},
+	{Key: "workouts", Name: "Workouts", Unit: "workouts", Direction: GoalAtLeast, TimeFrames: []string{"weekly", "monthly"},
+		Source: GoalSourceWorkouts, MinTarget: 1, MaxTarget: 60,
+		progress: func(userID uuid.UUID, from, to string) (float64, error) {
+			var n float64
+			err := config.DB.QueryRow(
+				`SELECT COUNT(*) FROM workouts WHERE user_id = $1 AND status = 'completed' AND date BETWEEN $2::date AND $3::date`,
+				userID, from, to,
+			).Scan(&n)
+			return n, err
+		}},
+	{Key: "cardio_minutes", Name: "Cardio minutes", Unit: "min", Direction: GoalAtLeast, TimeFrames: []string{"monthly", "weekly"},
+		Source: GoalSourceWorkouts, MinTarget: 10, MaxTarget: 6000,
+		progress: func(userID uuid.UUID, from, to string) (float64, error) {
+			types := make([]string, 0, len(cardioWorkoutTypes))
+			for t := range cardioWorkoutTypes {
+				types = append(types, t)
+			}
+			var minutes float64
+			err := config.DB.QueryRow(
+				`SELECT COALESCE(SUM(COALESCE(actual_duration_min, duration_minutes)), 0) FROM workouts
+                 WHERE user_id = $1 AND status = 'completed' AND type = ANY($2) AND date BETWEEN $3::date AND $4::date`,
+				userID, pq.Array(types), from, to,
+			).Scan(&minutes)
+			return minutes, err
+		}},
+	// Progress is the percentage of the way from the starting weight to target_weight_kg, along the
+	// weigh-in trend; see weight_goals.go
+	{Key: "weight", Name: "Target weight", Unit: "%", Direction: GoalAtLeast, TimeFrames: []string{"weekly"},
+		Source: GoalSourceWeighIns, MinTarget: 100, MaxTarget: 100, OpenEnded: true},
+	{Key: "water", Name: "Water", Unit: "ml", Direction: GoalAtLeast, TimeFrames: []string{"daily"},
+		Source: GoalSourceManual, MinTarget: 250, MaxTarget: 10000},
+	{Key: "sleep", Name: "Sleep", Unit: "h", Direction: GoalAtLeast, TimeFrames: []string{"daily"},
+		Source: GoalSourceManual, MinTarget: 1, MaxTarget: 16},
+	{Key: "custom", Name: "Custom", Direction: GoalAtLeast, TimeFrames: []string{"daily", "weekly", "monthly"},
+		Source: GoalSourceManual, MinTarget: 1, MaxTarget: 1000000},
+}
+
+var goalTypes = func() map[string]goalType {
+	m := make(map[string]goalType, len(goalTypeList))
+	for _, t := range goalTypeList {
+		m[t.Key] = t
+	}
+	return m
+}()
+
+// ManualGoalTypes lists the goal types whose progress clients report themselves
+func ManualGoalTypes() []string {
+	var keys []string
+	for _, t := range goalTypeList {
+		if !t.Bound() {
+			keys = append(keys, t.Key)
+		}
+	}
+	return keys
+}
+
+// goalMealTotal sums a meal_foods column over the meals logged on local dates from..to
+func goalMealTotal(column string, userID uuid.UUID, from, to string) (float64, error) {
+	var total float64
+	err := config.DB.QueryRow(
+		`SELECT COALESCE(SUM(mf.`+column+`), 0)
+         FROM meals m JOIN meal_foods mf ON mf.meal_id = m.id
+         WHERE m.user_id = $1 AND m.date::date BETWEEN $2::date AND $3::date`,
+		userID, from, to,
+	).Scan(&total)
+	return total, err
+}
+
+// lookupGoalType resolves a goal_type key from a request
+func lookupGoalType(key string) (goalType, error) {
+	t, ok := goalTypes[strings.ToLower(strings.TrimSpace(key))]
+	if !ok {
+		keys := make([]string, len(goalTypeList))
+		for i, gt := range goalTypeList {
+			keys[i] = gt.Key
+		}
+		return goalType{}, fmt.Errorf("goal_type must be one of %s", strings.Join(keys, ", "))
+	}
+	return t, nil
+}
+
+// validateTimeFrame defaults an empty time_frame and checks the type allows it
+func (t goalType) validateTimeFrame(timeFrame string) (string, error) {
+	if timeFrame == "" {
+		return t.TimeFrames[0], nil
+	}
+	for _, tf := range t.TimeFrames {
+		if tf == timeFrame {
+			return timeFrame, nil
+		}
+	}
+	return "", fmt.Errorf("time_frame for %s goals must be %s", t.Key, strings.Join(t.TimeFrames, " or "))
+}
+
+// validateValues checks the target against the type's range. Progress is computed for bound
+// types, so clients can only send it for manual ones.
+func (t goalType) validateValues(target int, progress *int) (int, error) {
+	if target < t.MinTarget || target > t.MaxTarget {
+		return 0, fmt.Errorf("target_value for %s goals must be between %d and %d", t.Key, t.MinTarget, t.MaxTarget)
+	}
+	if progress == nil {
+		return 0, nil
+	}
+	if t.Bound() {
+		return 0, fmt.Errorf("progress_value is computed from your logged %s for %s goals", t.Source, t.Key)
+	}
+	if *progress < 0 || *progress > 10*t.MaxTarget {
+		return 0, fmt.Errorf("progress_value must be between 0 and %d", 10*t.MaxTarget)
+	}
+	return *progress, nil
+}
+
+// sanitizeLabel trims an optional display label; custom goals must have one
+func (t goalType) sanitizeLabel(label string) (*string, error) {
+	label = strings.Join(strings.Fields(label), " ")
+	if label == "" {
+		if t.Key == "custom" {
+			return nil, fmt.Errorf("custom goals need a label")
+		}
+		return nil, nil
+	}
+	if len(label) < minGoalLabel || len(label) > maxGoalLabel {
+		return nil, fmt.Errorf("label must be %d-%d characters", minGoalLabel, maxGoalLabel)
+	}
+	return &label, nil
+}
+
+// goalDisplayName is how notifications refer to a goal
+func goalDisplayName(key string, label *string) string {
+	if label != nil && *label != "" {
+		return *label
+	}
+	if t, ok := goalTypes[key]; ok {
+		return t.Name
+	}
+	return key
+}
+
+// GetGoalTypes handles GET /goals/types, the registry clients build goal forms from
+func GetGoalTypes(c *gin.Context) {
+	c.JSON(http.StatusOK, goalTypeList)
+}
@@ -7,7 +7,6 @@ import (
 	"net/http"
 	"nutritionix/backend/config"
 	"nutritionix/backend/models"
-	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -16,39 +15,22 @@ import (
 	"github.com/google/uuid"
 )
 
-const (
-	MAX_VALUE = 1000000
-	MIN_GOAL  = 3
-	MAX_GOAL  = 50
-)
-
-// sanitizeGoalType trims/lowercases input and ensures it’s valid
-func sanitizeGoalType(gt string) (string, error) {
-	gt = strings.TrimSpace(gt)
-	gt = strings.ToLower(gt)
-	validGoalType := regexp.MustCompile(`^[a-z0-9 _-]+$`)
-	if len(gt) < MIN_GOAL || len(gt) > MAX_GOAL || !validGoalType.MatchString(gt) {
-		return "", fmt.Errorf("goal_type must be %d-%d chars and only letters, numbers, spaces, underscores, or hyphens allowed", MIN_GOAL, MAX_GOAL)
-	}
-	return gt, nil
-}
+// notifyManualGoal sends the completed or 80% notification for a goal whose progress a client reported
+func notifyManualGoal(userID, goalID uuid.UUID, name string, target, progress int, completed bool) {
+	completedMsg := fmt.Sprintf("🎯 Congratulations! Your goal '%s' is completed.", name)
+	nearlyMsg := fmt.Sprintf("💪 You're close! Your goal '%s' is 80%% complete.", name)
 
-// validateGoalValues enforces target/progress value rules
-func validateGoalValues(target int, progress *int) (int, error) {
-	if target <= 0 || target > MAX_VALUE {
-		return 0, fmt.Errorf("target_value must be between 1 and %d", MAX_VALUE)
-	}
-	progressValue := 0
-	if progress != nil {
-		if *progress < 0 || *progress > MAX_VALUE {
-			return 0, fmt.Errorf("progress_value must be between 0 and %d", MAX_VALUE)
-		}
-		progressValue = *progress
+	if completed && !HasRecentNotification(userID, goalID, completedMsg) {
+		CreateNotification(userID, &goalID, completedMsg)
+	} else if !completed &&
+		float64(progress) >= float64(target)*0.8 &&
+		!HasRecentNotification(userID, goalID, nearlyMsg) {
+		CreateNotification(userID, &goalID, nearlyMsg)
 	}
-	return progressValue, nil
 }
 
-// CreateOrUpdateGoal creates a new goal or updates the existing one
+// CreateOrUpdateGoal creates a new goal or updates the user's existing goal of the same type.
+// Custom goals are told apart by their label.
 func CreateOrUpdateGoal(c *gin.Context) {
 	userIDStr := c.GetString("user_id")
 	userID, err := uuid.Parse(userIDStr)
@@ -58,46 +40,88 @@ func CreateOrUpdateGoal(c *gin.Context) {
 	}
 
 	var input struct {
-		GoalType      string `json:"goal_type"`
-		TargetValue   int    `json:"target_value"`
-		ProgressValue *int   `json:"progress_value"`
-		TimeFrame     string `json:"time_frame"`
-		IsCompleted   *bool  `json:"is_completed"`
+		GoalType       string   `json:"goal_type"` // a key from GET /goals/types
+		Label          string   `json:"label"`
+		TargetValue    int      `json:"target_value"`
+		ProgressValue  *int     `json:"progress_value"`
+		TimeFrame      string   `json:"time_frame"`
+		IsCompleted    *bool    `json:"is_completed"`
+		TargetWeightKg *float64 `json:"target_weight_kg"` // weight goals only
+		TargetDate     string   `json:"target_date"`      // optional deadline for weight goals
 	}
 	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + err.Error()})
 		return
 	}
 
-	goalType, err := sanitizeGoalType(input.GoalType)
+	t, err := lookupGoalType(input.GoalType)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-
-	progressValue, err := validateGoalValues(input.TargetValue, input.ProgressValue)
+	label, err := t.sanitizeLabel(input.Label)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	timeFrame, err := t.validateTimeFrame(input.TimeFrame)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	if input.TimeFrame != "daily" && input.TimeFrame != "weekly" && input.TimeFrame != "monthly" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "time_frame must be daily, weekly, or monthly"})
+	// Weight goals are set in kg and count percent of the way there from the current trend weight
+	var targetWeight, startWeight *float64
+	var targetDate *string
+	if t.Key == "weight" {
+		if input.TargetWeightKg == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "weight goals need a target_weight_kg"})
+			return
+		}
+		if targetDate, err = validateWeightTarget(*input.TargetWeightKg, input.TargetDate, userTimezone(userID)); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		start, ok := currentTrendWeight(userID)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Log a weigh-in or set your weight before setting a weight goal"})
+			return
+		}
+		target := round2(*input.TargetWeightKg)
+		start = round2(start)
+		targetWeight, startWeight = &target, &start
+		input.TargetValue = 100
+	} else if input.TargetWeightKg != nil || input.TargetDate != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "target_weight_kg and target_date are only for weight goals"})
 		return
 	}
 
-	isCompleted := false
-	if input.IsCompleted != nil {
-		isCompleted = *input.IsCompleted
+	progressValue, err := t.validateValues(input.TargetValue, input.ProgressValue)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
-	if progressValue >= input.TargetValue {
-		isCompleted = true
+
+	isCompleted := false
+	if !t.Bound() {
+		if input.IsCompleted != nil {
+			isCompleted = *input.IsCompleted
+		}
+		if goalMet(t.AtMost(), progressValue, input.TargetValue) {
+			isCompleted = true
+		}
 	}
 
+	// Record any period that ended before the goal changes
+	RecomputeGoalProgress(userID)
+
 	var existingID uuid.UUID
 	err = config.DB.QueryRow(
-		"SELECT id FROM user_goals WHERE user_id=$1 AND goal_type=$2",
-		userID, goalType,
+		`SELECT id FROM user_goals
+		 WHERE user_id=$1 AND goal_type=$2 AND ($2 <> 'custom' OR lower(label) = lower($3::text))
+		 ORDER BY archived, updated_at DESC
+		 LIMIT 1`,
+		userID, t.Key, label,
 	).Scan(&existingID)
 
 	if err != nil && err != sql.ErrNoRows {
@@ -107,58 +131,51 @@ func CreateOrUpdateGoal(c *gin.Context) {
 	}
 
 	if err == nil {
+		// The current period carries on unless the goal was archived or changed time frame
 		_, updateErr := config.DB.Exec(
 			`UPDATE user_goals 
-			 SET target_value=$1, progress_value=$2, time_frame=$3, updated_at=$4, is_completed=$5, archived=false
-			 WHERE id=$6`,
-			input.TargetValue, progressValue, input.TimeFrame, time.Now(), isCompleted, existingID,
+			 SET target_value=$1, progress_value=$2, time_frame=$3, updated_at=$4, is_completed=$5, archived=false,
+			     label=$6, period_start = CASE WHEN time_frame = $3 AND NOT archived THEN period_start END,
+			     target_weight_kg=$8, start_weight_kg=$9, target_date=$10, projected_date=NULL,
+			     reference_projected_date=NULL
+			 WHERE id=$7`,
+			input.TargetValue, progressValue, timeFrame, time.Now(), isCompleted, label, existingID,
+			targetWeight, startWeight, targetDate,
 		)
 		if updateErr != nil {
 			log.Println("DB UPDATE ERROR:", updateErr)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update goal"})
 			return
 		}
-
-		completedMsg := fmt.Sprintf("🎯 Congratulations! Your goal '%s' is completed.", goalType)
-		nearlyMsg := fmt.Sprintf("💪 You're close! Your goal '%s' is 80%% complete.", goalType)
-
-		if isCompleted && !HasRecentNotification(userID, existingID, completedMsg) {
-			CreateNotification(userID, &existingID, completedMsg)
-		} else if !isCompleted &&
-			float64(progressValue) >= float64(input.TargetValue)*0.8 &&
-			!HasRecentNotification(userID, existingID, nearlyMsg) {
-			CreateNotification(userID, &existingID, nearlyMsg)
+		RecomputeGoalProgress(userID)
+		if !t.Bound() {
+			notifyManualGoal(userID, existingID, goalDisplayName(t.Key, label), input.TargetValue, progressValue, isCompleted)
 		}
 
-		c.JSON(http.StatusOK, gin.H{"message": "Goal updated successfully"})
+		c.JSON(http.StatusOK, gin.H{"message": "Goal updated successfully", "id": existingID})
 		return
 	}
 
 	var newGoalID uuid.UUID
 	err = config.DB.QueryRow(
-		`INSERT INTO user_goals (user_id, goal_type, target_value, progress_value, time_frame, is_completed, archived, created_at, updated_at)
-		 VALUES ($1, $2, $3, $4, $5, $6, false, $7, $8)
+		`INSERT INTO user_goals (user_id, goal_type, label, target_value, progress_value, time_frame, is_completed, archived,
+		                         target_weight_kg, start_weight_kg, target_date, created_at, updated_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, false, $8, $9, $10, $11, $12)
 		 RETURNING id`,
-		userID, goalType, input.TargetValue, progressValue, input.TimeFrame, isCompleted, time.Now(), time.Now(),
+		userID, t.Key, label, input.TargetValue, progressValue, timeFrame, isCompleted,
+		targetWeight, startWeight, targetDate, time.Now(), time.Now(),
 	).Scan(&newGoalID)
 	if err != nil {
 		log.Println("DB INSERT ERROR:", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create goal"})
 		return
 	}
-
-	completedMsg := fmt.Sprintf("🎯 Congratulations! Your goal '%s' is completed.", goalType)
-	nearlyMsg := fmt.Sprintf("💪 You're close! Your goal '%s' is 80%% complete.", goalType)
-
-	if isCompleted && !HasRecentNotification(userID, newGoalID, completedMsg) {
-		CreateNotification(userID, &newGoalID, completedMsg)
-	} else if !isCompleted &&
-		float64(progressValue) >= float64(input.TargetValue)*0.8 &&
-		!HasRecentNotification(userID, newGoalID, nearlyMsg) {
-		CreateNotification(userID, &newGoalID, nearlyMsg)
+	RecomputeGoalProgress(userID)
+	if !t.Bound() {
+		notifyManualGoal(userID, newGoalID, goalDisplayName(t.Key, label), input.TargetValue, progressValue, isCompleted)
 	}
 
-	c.JSON(http.StatusCreated, gin.H{"message": "Goal created successfully"})
+	c.JSON(http.StatusCreated, gin.H{"message": "Goal created successfully", "id": newGoalID})
 }
 
 // GetGoals fetches goals for a user
@@ -170,6 +187,9 @@ func GetGoals(c *gin.Context) {
 		return
 	}
 
+	// Bring data-bound goals into the current period before listing them
+	RecomputeGoalProgress(userID)
+
 	completed := c.Query("completed")
 	includeArchived := c.Query("include_archived")
 	sortBy := c.DefaultQuery("sort_by", "created_at")
@@ -204,7 +224,8 @@ func GetGoals(c *gin.Context) {
 
 	var args []interface{}
 	query := `
-		SELECT id, user_id, goal_type, target_value, progress_value, time_frame, is_completed, archived, created_at, updated_at
+		SELECT id, user_id, goal_type, target_value, progress_value, time_frame, is_completed, label, period_start::text,
+		       target_weight_kg, start_weight_kg, target_date::text, projected_date::text, archived, created_at, updated_at
 		FROM user_goals
 		WHERE user_id = $1
 	`
@@ -237,18 +258,35 @@ func GetGoals(c *gin.Context) {
 		var g models.UserGoal
 		if err := rows.Scan(
 			&g.ID, &g.UserID, &g.GoalType, &g.TargetValue, &g.ProgressValue, &g.TimeFrame,
-			&g.IsCompleted, &g.Archived, &g.CreatedAt, &g.UpdatedAt,
+			&g.IsCompleted, &g.Label, &g.PeriodStart, &g.TargetWeightKg, &g.StartWeightKg, &g.TargetDate, &g.ProjectedDate,
+			&g.Archived, &g.CreatedAt, &g.UpdatedAt,
 		); err != nil {
 			log.Println("DB SCAN ERROR:", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse goals"})
 			return
 		}
+		t := goalTypes[g.GoalType]
+		g.Name, g.Unit, g.Direction, g.Source = goalDisplayName(g.GoalType, g.Label), t.Unit, t.Direction, t.Source
 		goals = append(goals, g)
 	}
+
+	ids := make([]uuid.UUID, len(goals))
+	for i, g := range goals {
+		ids[i] = g.ID
+	}
+	periods, err := loadGoalPeriods(ids, 0)
+	if err != nil {
+		log.Println("DB SELECT ERROR:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch goals"})
+		return
+	}
+	for i := range goals {
+		goals[i].Streak, _ = goalStreak(periods[goals[i].ID], goals[i].IsCompleted)
+	}
 	c.JSON(http.StatusOK, goals)
 }
 
-// UpdateGoal updates a goal
+// UpdateGoal updates a goal. Its type is fixed; progress can only be set on manual goals.
 func UpdateGoal(c *gin.Context) {
 	userIDStr := c.GetString("user_id")
 	userID, err := uuid.Parse(userIDStr)
@@ -265,10 +303,13 @@ func UpdateGoal(c *gin.Context) {
 	}
 
 	var input struct {
-		TargetValue   int    `json:"target_value"`
-		ProgressValue *int   `json:"progress_value"`
-		TimeFrame     string `json:"time_frame"`
-		IsCompleted   *bool  `json:"is_completed"`
+		Label          *string  `json:"label"`
+		TargetValue    int      `json:"target_value"`
+		ProgressValue  *int     `json:"progress_value"`
+		TimeFrame      string   `json:"time_frame"`
+		IsCompleted    *bool    `json:"is_completed"`
+		TargetWeightKg *float64 `json:"target_weight_kg"`
+		TargetDate     *string  `json:"target_date"` // "" clears the deadline
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -276,13 +317,87 @@ func UpdateGoal(c *gin.Context) {
 		return
 	}
 
-	progressValue, err := validateGoalValues(input.TargetValue, input.ProgressValue)
+	// Record any period that ended before the goal changes
+	RecomputeGoalProgress(userID)
+
+	var goalType string
+	var label, targetDate *string
+	var targetWeight *float64
+	err = config.DB.QueryRow(
+		`SELECT goal_type, label, target_weight_kg, target_date::text FROM user_goals WHERE id=$1 AND user_id=$2`, goalID, userID,
+	).Scan(&goalType, &label, &targetWeight, &targetDate)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Goal not found"})
+		return
+	}
+	if err != nil {
+		log.Println("DB SELECT ERROR:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	t, err := lookupGoalType(goalType)
+	if err != nil {
+		log.Println("UNKNOWN GOAL TYPE:", goalType)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if input.Label != nil {
+		if label, err = t.sanitizeLabel(*input.Label); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	timeFrame, err := t.validateTimeFrame(input.TimeFrame)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	if input.TimeFrame != "daily" && input.TimeFrame != "weekly" && input.TimeFrame != "monthly" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "time_frame must be daily, weekly, or monthly"})
+	if t.Key == "weight" {
+		// The starting weight stays; only the target and deadline move
+		if input.TargetWeightKg != nil {
+			target := round2(*input.TargetWeightKg)
+			targetWeight = &target
+		}
+		date := ""
+		if input.TargetDate != nil {
+			date = *input.TargetDate
+		}
+		newDate, err := validateWeightTarget(*targetWeight, date, userTimezone(userID))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if input.TargetDate != nil {
+			targetDate = newDate
+		}
+		input.TargetValue = 100
+	} else if input.TargetWeightKg != nil || input.TargetDate != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "target_weight_kg and target_date are only for weight goals"})
+		return
+	}
+	progressValue, err := t.validateValues(input.TargetValue, input.ProgressValue)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if t.Bound() {
+		// Progress is recomputed against the new target and time frame
+		if _, err := config.DB.Exec(
+			`UPDATE user_goals SET target_value=$1, time_frame=$2, label=$3, updated_at=$4,
+			     period_start = CASE WHEN time_frame = $2 THEN period_start END,
+			     projected_date = CASE WHEN target_weight_kg = $6::numeric THEN projected_date END,
+			     reference_projected_date = CASE WHEN target_weight_kg = $6::numeric THEN reference_projected_date END,
+			     target_weight_kg=$6, target_date=$7
+			 WHERE id=$5`,
+			input.TargetValue, timeFrame, label, time.Now(), goalID, targetWeight, targetDate,
+		); err != nil {
+			log.Println("DB UPDATE ERROR:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update goal"})
+			return
+		}
+		RecomputeGoalProgress(userID)
+		c.JSON(http.StatusOK, gin.H{"message": "Goal updated successfully"})
 		return
 	}
 
@@ -290,15 +405,16 @@ func UpdateGoal(c *gin.Context) {
 	if input.IsCompleted != nil {
 		isCompleted = *input.IsCompleted
 	}
-	if progressValue >= input.TargetValue {
+	if goalMet(t.AtMost(), progressValue, input.TargetValue) {
 		isCompleted = true
 	}
 
 	res, err := config.DB.Exec(
 		`UPDATE user_goals 
-		 SET target_value=$1, progress_value=$2, time_frame=$3, updated_at=$4, is_completed=$5
-		 WHERE id=$6 AND user_id=$7`,
-		input.TargetValue, progressValue, input.TimeFrame, time.Now(), isCompleted, goalID, userID,
+		 SET target_value=$1, progress_value=$2, time_frame=$3, updated_at=$4, is_completed=$5, label=$6,
+		     period_start = CASE WHEN time_frame = $3 THEN period_start END
+		 WHERE id=$7 AND user_id=$8`,
+		input.TargetValue, progressValue, timeFrame, time.Now(), isCompleted, label, goalID, userID,
 	)
 	if err != nil {
 		log.Println("DB UPDATE ERROR:", err)
@@ -311,17 +427,8 @@ func UpdateGoal(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Goal not found"})
 		return
 	}
-
-	completedMsg := "🎯 Congratulations! Your goal is completed."
-	nearlyMsg := "💪 You're close! Your goal is 80% complete."
-
-	if isCompleted && !HasRecentNotification(userID, goalID, completedMsg) {
-		CreateNotification(userID, &goalID, completedMsg)
-	} else if !isCompleted &&
-		float64(progressValue) >= float64(input.TargetValue)*0.8 &&
-		!HasRecentNotification(userID, goalID, nearlyMsg) {
-		CreateNotification(userID, &goalID, nearlyMsg)
-	}
+	RecomputeGoalProgress(userID)
+	notifyManualGoal(userID, goalID, goalDisplayName(goalType, label), input.TargetValue, progressValue, isCompleted)
 
 	c.JSON(http.StatusOK, gin.H{"message": "Goal updated successfully"})
 }
@@ -342,6 +449,9 @@ func DeleteGoal(c *gin.Context) {
 		return
 	}
 
+	// Periods that ended before archiving still count as history
+	RecomputeGoalProgress(userID)
+
 	res, err := config.DB.Exec(
 		`UPDATE user_goals SET archived = true, updated_at = $1
 		 WHERE id = $2 AND user_id = $3 AND archived = false`,
@@ -377,8 +487,9 @@ func RestoreGoal(c *gin.Context) {
 		return
 	}
 
+	// A restored goal starts a fresh period; the archived stretch isn't counted as missed
 	res, err := config.DB.Exec(
-		`UPDATE user_goals SET archived = false, updated_at = $1
+		`UPDATE user_goals SET archived = false, updated_at = $1, period_start = NULL, progress_value = 0, is_completed = false
 		 WHERE id = $2 AND user_id = $3 AND archived = true`,
 		time.Now(), goalID, userID,
 	)
@@ -393,5 +504,6 @@ func RestoreGoal(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Goal not found or not archived"})
 		return
 	}
+	RecomputeGoalProgress(userID)
 	c.JSON(http.StatusOK, gin.H{"message": "Goal restored successfully"})
 }
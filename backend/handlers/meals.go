@@ -10,6 +10,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 // Handler struct to hold dependencies like DB connection
@@ -66,6 +67,9 @@ func (h *Handler) CreateMeal(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not create meal"})
 		return
 	}
+	if uid, err := uuid.Parse(meal.UserID); err == nil {
+		CheckAchievements(uid, EventMealLogged)
+	}
 
 	c.JSON(http.StatusCreated, meal)
 }
@@ -123,12 +127,50 @@ func (h *Handler) CreateMealFood(c *gin.Context) {
 		Iron        float32  `json:"iron"`
 		Potassium   float32  `json:"potassium"`
 		ServingSize string   `json:"serving_size"`
+		Allergens   []string `json:"allergens"`
+		DietTags    []string `json:"diet_tags"`
 	}
 	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	declaredAllergens, err := normalizeTags("allergens", input.Allergens, validAllergens)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	declaredTags, err := normalizeTags("diet_tags", input.DietTags, validFoodDietTags)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	allergens, dietTags, mismatches := resolveFoodTags(input.FoodName, declaredAllergens, declaredTags)
+
+	profile, err := loadDietProfile(h.DB, userID.(string))
+	if err != nil {
+		log.Printf("Database error loading dietary profile: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not load dietary profile"})
+		return
+	}
+	conflicts := findDietConflicts(profile, allergens, dietTags)
+
+	// Callers can ask for conflicting foods to be refused instead of only flagged
+	if len(conflicts) > 0 && c.Query("reject_conflicts") == "true" {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error":          "food conflicts with your dietary profile",
+			"diet_conflicts": conflicts,
+			"warnings":       describeDietConflicts(input.FoodName, conflicts),
+		})
+		return
+	}
+
 	// Set default values
 	if input.Unit == "" {
 		input.Unit = "g"
@@ -138,8 +180,8 @@ func (h *Handler) CreateMealFood(c *gin.Context) {
 	}
 
 	foodID := uuid.New().String()
-	query := `INSERT INTO meal_foods (id, meal_id, food_id, food_name, quantity, unit, calories, protein, carbs, fat, fiber, sugar, sodium, calcium, iron, potassium, serving_size) 
-              VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)`
+	query := `INSERT INTO meal_foods (id, meal_id, food_id, food_name, quantity, unit, calories, protein, carbs, fat, fiber, sugar, sodium, calcium, iron, potassium, serving_size, allergens, diet_tags, diet_conflicts, label_mismatches) 
+              VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21)`
 
 	// Convert FoodID from float64 to int64 if it exists
 	var dbFoodID *int64
@@ -148,7 +190,7 @@ func (h *Handler) CreateMealFood(c *gin.Context) {
 		dbFoodID = &convertedID
 	}
 
-	if _, err := h.DB.Exec(query, foodID, input.MealID, dbFoodID, input.FoodName, input.Quantity, input.Unit, input.Calories, input.Protein, input.Carbs, input.Fat, input.Fiber, input.Sugar, input.Sodium, input.Calcium, input.Iron, input.Potassium, input.ServingSize); err != nil {
+	if _, err := h.DB.Exec(query, foodID, input.MealID, dbFoodID, input.FoodName, input.Quantity, input.Unit, input.Calories, input.Protein, input.Carbs, input.Fat, input.Fiber, input.Sugar, input.Sodium, input.Calcium, input.Iron, input.Potassium, input.ServingSize, pq.Array(allergens), pq.Array(dietTags), pq.Array(conflicts), pq.Array(mismatches)); err != nil {
 		log.Printf("Database error inserting meal food: %v", err)
 		log.Printf("Query: %s", query)
 		log.Printf("Values: foodID=%s, mealID=%s, foodID=%v, foodName=%s, quantity=%f, unit=%s, calories=%d",
@@ -157,27 +199,40 @@ func (h *Handler) CreateMealFood(c *gin.Context) {
 		return
 	}
 
+	if uid, err := uuid.Parse(userID.(string)); err == nil {
+		RecomputeGoalProgress(uid)
+		CheckAchievements(uid, EventMealLogged)
+	}
+
 	response := models.MealFood{
-		ID:          foodID,
-		MealID:      input.MealID,
-		FoodID:      dbFoodID,
-		FoodName:    input.FoodName,
-		Quantity:    input.Quantity,
-		Unit:        input.Unit,
-		Calories:    input.Calories,
-		Protein:     input.Protein,
-		Carbs:       input.Carbs,
-		Fat:         input.Fat,
-		Fiber:       input.Fiber,
-		Sugar:       input.Sugar,
-		Sodium:      input.Sodium,
-		Calcium:     input.Calcium,
-		Iron:        input.Iron,
-		Potassium:   input.Potassium,
-		ServingSize: input.ServingSize,
-	}
-
-	c.JSON(http.StatusCreated, response)
+		ID:              foodID,
+		MealID:          input.MealID,
+		FoodID:          dbFoodID,
+		FoodName:        input.FoodName,
+		Quantity:        input.Quantity,
+		Unit:            input.Unit,
+		Calories:        input.Calories,
+		Protein:         input.Protein,
+		Carbs:           input.Carbs,
+		Fat:             input.Fat,
+		Fiber:           input.Fiber,
+		Sugar:           input.Sugar,
+		Sodium:          input.Sodium,
+		Calcium:         input.Calcium,
+		Iron:            input.Iron,
+		Potassium:       input.Potassium,
+		ServingSize:     input.ServingSize,
+		Allergens:       allergens,
+		DietTags:        dietTags,
+		DietConflicts:   conflicts,
+		LabelMismatches: mismatches,
+	}
+
+	warnings := append(describeDietConflicts(input.FoodName, conflicts), describeLabelMismatches(input.FoodName, mismatches)...)
+	c.JSON(http.StatusCreated, struct {
+		models.MealFood
+		Warnings []string `json:"warnings,omitempty"`
+	}{response, warnings})
 }
 
 // ListMealFoods handles GET /mealfoods/:mealID to list all foods for a meal
@@ -188,7 +243,7 @@ func (h *Handler) ListMealFoods(c *gin.Context) {
 		return
 	}
 
-	query := `SELECT id, meal_id, COALESCE(food_id, 0) as food_id, food_name, quantity, unit, calories, protein, carbs, fat, fiber, sugar, sodium, calcium, iron, potassium, serving_size 
+	query := `SELECT id, meal_id, COALESCE(food_id, 0) as food_id, food_name, quantity, unit, calories, protein, carbs, fat, fiber, sugar, sodium, calcium, iron, potassium, serving_size, allergens, diet_tags, diet_conflicts, label_mismatches 
 			  FROM meal_foods WHERE meal_id = $1 ORDER BY id`
 	rows, err := h.DB.Query(query, mealID)
 	if err != nil {
@@ -203,7 +258,8 @@ func (h *Handler) ListMealFoods(c *gin.Context) {
 		var foodID int64
 		if err := rows.Scan(&food.ID, &food.MealID, &foodID, &food.FoodName, &food.Quantity, &food.Unit,
 			&food.Calories, &food.Protein, &food.Carbs, &food.Fat, &food.Fiber, &food.Sugar,
-			&food.Sodium, &food.Calcium, &food.Iron, &food.Potassium, &food.ServingSize); err != nil {
+			&food.Sodium, &food.Calcium, &food.Iron, &food.Potassium, &food.ServingSize,
+			pq.Array(&food.Allergens), pq.Array(&food.DietTags), pq.Array(&food.DietConflicts), pq.Array(&food.LabelMismatches)); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "scan error"})
 			return
 		}
@@ -0,0 +1,550 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"nutritionix/backend/config"
+	"nutritionix/backend/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Personal record types
+const (
+	RecordMaxWeight    = "max_weight"
+	RecordRepsAtWeight = "reps_at_weight"
+	RecordEstimated1RM = "estimated_1rm"
+	RecordMaxVolume    = "max_volume"
+)
+
+// Estimated 1RM is only trusted for sets of this many reps or fewer
+const maxRepsFor1RM = 12
+
+// exerciseKeySQL identifies the same lift across sessions: the catalog slug, or the normalized name
+const exerciseKeySQL = `COALESCE(ec.slug, LOWER(TRIM(we.name)))`
+
+// prSet is a working set considered for personal records
+type prSet struct {
+	SetID     uuid.UUID
+	WorkoutID uuid.UUID
+	Reps      int
+	WeightKg  float64
+}
+
+// liftBests summarizes the best performances in a group of sets
+type liftBests struct {
+	MaxWeight      float64
+	MaxWeightSet   *prSet
+	Best1RM        float64
+	Best1RMFormula string
+	Best1RMSet     *prSet
+	RepsAtWeight   map[float64]prSet // best set (most reps) at each load
+	MaxVolume      float64           // best single-workout volume
+}
+
+// epley1RM estimates a one-rep max as w × (1 + r/30)
+func epley1RM(weight float64, reps int) float64 {
+	return weight * (1 + float64(reps)/30.0)
+}
+
+// brzycki1RM estimates a one-rep max as w × 36 / (37 − r)
+func brzycki1RM(weight float64, reps int) float64 {
+	return weight * 36.0 / (37.0 - float64(reps))
+}
+
+// estimateOneRepMax uses Brzycki up to 10 reps, where it tracks tested maxes more closely, and Epley above that
+func estimateOneRepMax(weight float64, reps int) (float64, string) {
+	switch {
+	case reps <= 0 || weight <= 0:
+		return 0, ""
+	case reps == 1:
+		return weight, "actual"
+	case reps <= 10:
+		return brzycki1RM(weight, reps), "brzycki"
+	default:
+		return epley1RM(weight, reps), "epley"
+	}
+}
+
+// computeLiftBests finds the best weight, 1RM, reps per load and workout volume in a set of working sets
+func computeLiftBests(sets []prSet) liftBests {
+	bests := liftBests{RepsAtWeight: map[float64]prSet{}}
+	volumeByWorkout := map[uuid.UUID]float64{}
+
+	for i := range sets {
+		s := sets[i]
+		if s.Reps <= 0 {
+			continue
+		}
+		volumeByWorkout[s.WorkoutID] += float64(s.Reps) * s.WeightKg
+		if s.WeightKg <= 0 {
+			continue
+		}
+		if s.WeightKg > bests.MaxWeight {
+			bests.MaxWeight, bests.MaxWeightSet = s.WeightKg, &sets[i]
+		}
+		if s.Reps <= maxRepsFor1RM {
+			if e1rm, formula := estimateOneRepMax(s.WeightKg, s.Reps); e1rm > bests.Best1RM {
+				bests.Best1RM, bests.Best1RMFormula, bests.Best1RMSet = e1rm, formula, &sets[i]
+			}
+		}
+		if current, ok := bests.RepsAtWeight[s.WeightKg]; !ok || s.Reps > current.Reps {
+			bests.RepsAtWeight[s.WeightKg] = s
+		}
+	}
+	for _, volume := range volumeByWorkout {
+		if volume > bests.MaxVolume {
+			bests.MaxVolume = volume
+		}
+	}
+	return bests
+}
+
+// bestRepsAtOrAbove returns the most reps ever done at the given load or heavier
+func bestRepsAtOrAbove(bests liftBests, weight float64) (int, bool) {
+	best, found := 0, false
+	for w, s := range bests.RepsAtWeight {
+		if w >= weight && s.Reps > best {
+			best, found = s.Reps, true
+		}
+	}
+	return best, found
+}
+
+// findPersonalRecords compares a session's sets against history and returns the records it sets.
+// Exercises with no history get baseline records (no previous value) that aren't announced.
+func findPersonalRecords(session, history []prSet) []models.PersonalRecord {
+	current := computeLiftBests(session)
+	past := computeLiftBests(history)
+	hasHistory := len(history) > 0
+	records := []models.PersonalRecord{}
+
+	previous := func(v float64) *float64 {
+		if !hasHistory {
+			return nil
+		}
+		rounded := round2(v)
+		return &rounded
+	}
+	withSet := func(r models.PersonalRecord, s *prSet) models.PersonalRecord {
+		if s != nil {
+			setID, weight, reps := s.SetID, s.WeightKg, s.Reps
+			r.SetID, r.WeightKg, r.Reps = &setID, &weight, &reps
+		}
+		return r
+	}
+
+	if current.MaxWeightSet != nil && current.MaxWeight > past.MaxWeight {
+		records = append(records, withSet(models.PersonalRecord{
+			RecordType: RecordMaxWeight, Value: round2(current.MaxWeight), PreviousValue: previous(past.MaxWeight),
+		}, current.MaxWeightSet))
+	}
+	if current.Best1RMSet != nil && current.Best1RM > past.Best1RM {
+		records = append(records, withSet(models.PersonalRecord{
+			RecordType: RecordEstimated1RM, Value: round2(current.Best1RM), PreviousValue: previous(past.Best1RM),
+			Formula: current.Best1RMFormula,
+		}, current.Best1RMSet))
+	}
+	if current.MaxVolume > 0 && current.MaxVolume > past.MaxVolume {
+		records = append(records, models.PersonalRecord{
+			RecordType: RecordMaxVolume, Value: round2(current.MaxVolume), PreviousValue: previous(past.MaxVolume),
+		})
+	}
+
+	// Reps-at-weight only counts once a load has been lifted before; a heavier load is already a max_weight PR
+	weights := make([]float64, 0, len(current.RepsAtWeight))
+	for w := range current.RepsAtWeight {
+		weights = append(weights, w)
+	}
+	sort.Float64s(weights)
+	for _, w := range weights {
+		s := current.RepsAtWeight[w]
+		if pastReps, ok := bestRepsAtOrAbove(past, w); ok && s.Reps > pastReps {
+			prev := float64(pastReps)
+			records = append(records, withSet(models.PersonalRecord{
+				RecordType: RecordRepsAtWeight, Value: float64(s.Reps), PreviousValue: &prev,
+			}, &s))
+		}
+	}
+	return records
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// workoutOrderSQL orders a user's workouts by date, then by when they were logged
+func workoutOrderSQL(alias string) string {
+	return fmt.Sprintf(`(COALESCE(%[1]s.date, %[1]s.created_at::date), %[1]s.created_at)`, alias)
+}
+
+// loadPRSets fetches working sets for one exercise key, either from a single workout or from its
+// history: the completed workouts before it
+func loadPRSets(userID, workoutID uuid.UUID, exerciseKey string, sameWorkout bool) ([]prSet, error) {
+	workoutCond := `w.status = '` + WorkoutCompleted + `' AND ` + workoutOrderSQL("w") + ` < ` + workoutOrderSQL("cw")
+	if sameWorkout {
+		workoutCond = `we.workout_id = $2`
+	}
+	rows, err := config.DB.Query(
+		`SELECT ws.id, we.workout_id, ws.reps, ws.weight_kg
+         FROM workout_sets ws
+         JOIN workout_exercises we ON ws.workout_exercise_id = we.id
+         JOIN workouts w ON we.workout_id = w.id
+         JOIN workouts cw ON cw.id = $2
+         LEFT JOIN exercise_catalog ec ON we.exercise_id = ec.id
+         WHERE w.user_id = $1 AND `+workoutCond+`
+           AND NOT ws.is_warmup AND ws.reps > 0
+           AND `+exerciseKeySQL+` = $3`,
+		userID, workoutID, exerciseKey,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sets := []prSet{}
+	for rows.Next() {
+		var s prSet
+		if err := rows.Scan(&s.SetID, &s.WorkoutID, &s.Reps, &s.WeightKg); err != nil {
+			return nil, err
+		}
+		sets = append(sets, s)
+	}
+	return sets, rows.Err()
+}
+
+// detectPersonalRecords recomputes the records set by a workout session and stores them.
+// It is idempotent: re-saving a session replaces its records, and only newly set records are notified.
+// Later workouts that share a lift are re-evaluated too, since their history now includes this one.
+func detectPersonalRecords(userID, workoutID uuid.UUID) ([]models.PersonalRecord, error) {
+	later, err := laterPRWorkouts(userID, workoutID)
+	if err != nil {
+		return nil, err
+	}
+	found, err := storePersonalRecords(userID, workoutID, true)
+	if err != nil {
+		return nil, err
+	}
+	refreshPersonalRecords(userID, later)
+	if len(found) > 0 {
+		CheckAchievements(userID, EventPersonalRecord)
+	}
+	return found, nil
+}
+
+// laterPRWorkouts lists the completed workouts after a workout that share a lift with it, whose
+// records depend on it. Call it before deleting the workout.
+func laterPRWorkouts(userID, workoutID uuid.UUID) ([]uuid.UUID, error) {
+	rows, err := config.DB.Query(
+		`SELECT w.id FROM workouts w
+         JOIN workouts cw ON cw.id = $2 AND cw.user_id = $1
+         WHERE w.user_id = $1 AND w.id <> $2 AND w.status = '`+WorkoutCompleted+`'
+           AND `+workoutOrderSQL("w")+` > `+workoutOrderSQL("cw")+`
+           AND EXISTS (
+               SELECT 1 FROM workout_exercises we LEFT JOIN exercise_catalog ec ON we.exercise_id = ec.id
+               WHERE we.workout_id = w.id AND `+exerciseKeySQL+` IN (
+                   SELECT `+exerciseKeySQL+` FROM workout_exercises we
+                   LEFT JOIN exercise_catalog ec ON we.exercise_id = ec.id WHERE we.workout_id = $2))
+         ORDER BY `+workoutOrderSQL("w"),
+		userID, workoutID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// refreshPersonalRecords recomputes the records of workouts whose history changed. Nothing is
+// announced: these sessions happened before, and their news is old.
+func refreshPersonalRecords(userID uuid.UUID, workoutIDs []uuid.UUID) {
+	for _, id := range workoutIDs {
+		if _, err := storePersonalRecords(userID, id, false); err != nil {
+			log.Println("PR DETECTION ERROR (refreshPersonalRecords):", err)
+		}
+	}
+}
+
+// storePersonalRecords replaces the records a workout sets, notifying the new ones when notify is set.
+// A workout that isn't completed sets none.
+func storePersonalRecords(userID, workoutID uuid.UUID, notify bool) ([]models.PersonalRecord, error) {
+	var achievedAt time.Time
+	var workoutDate sql.NullTime
+	var status string
+	if err := config.DB.QueryRow(
+		`SELECT date, created_at, status FROM workouts WHERE id = $1 AND user_id = $2`,
+		workoutID, userID,
+	).Scan(&workoutDate, &achievedAt, &status); err != nil {
+		return nil, err
+	}
+	// Only a completed session's sets were lifted; a planned or running one's are targets so far
+	if status != WorkoutCompleted {
+		_, err := config.DB.Exec(`DELETE FROM personal_records WHERE workout_id = $1`, workoutID)
+		return nil, err
+	}
+	if workoutDate.Valid {
+		achievedAt = workoutDate.Time
+	}
+
+	rows, err := config.DB.Query(
+		`SELECT `+exerciseKeySQL+`, MIN(we.name)
+         FROM workout_exercises we
+         LEFT JOIN exercise_catalog ec ON we.exercise_id = ec.id
+         WHERE we.workout_id = $1
+         GROUP BY 1`,
+		workoutID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	type sessionExercise struct{ Key, Name string }
+	var exercises []sessionExercise
+	for rows.Next() {
+		var e sessionExercise
+		if err := rows.Scan(&e.Key, &e.Name); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		exercises = append(exercises, e)
+	}
+	rows.Close()
+
+	// Records already announced for this session, so re-saving doesn't notify twice
+	announced := map[string]bool{}
+	existing, err := config.DB.Query(
+		`SELECT exercise_key, record_type, value FROM personal_records WHERE workout_id = $1`,
+		workoutID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	for existing.Next() {
+		var key, recordType string
+		var value float64
+		if err := existing.Scan(&key, &recordType, &value); err != nil {
+			existing.Close()
+			return nil, err
+		}
+		announced[fmt.Sprintf("%s|%s|%.2f", key, recordType, value)] = true
+	}
+	existing.Close()
+
+	var found []models.PersonalRecord
+	for _, e := range exercises {
+		session, err := loadPRSets(userID, workoutID, e.Key, true)
+		if err != nil {
+			return nil, err
+		}
+		history, err := loadPRSets(userID, workoutID, e.Key, false)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range findPersonalRecords(session, history) {
+			r.UserID, r.WorkoutID, r.ExerciseKey, r.ExerciseName, r.AchievedAt = userID, workoutID, e.Key, e.Name, achievedAt
+			found = append(found, r)
+		}
+	}
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM personal_records WHERE workout_id = $1`, workoutID); err != nil {
+		return nil, err
+	}
+	for i := range found {
+		r := &found[i]
+		if err := tx.QueryRow(
+			`INSERT INTO personal_records (user_id, workout_id, set_id, exercise_key, exercise_name, record_type,
+                                           value, previous_value, weight_kg, reps, formula, achieved_at)
+             VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, NULLIF($11, ''), $12)
+             RETURNING id, created_at`,
+			r.UserID, r.WorkoutID, r.SetID, r.ExerciseKey, r.ExerciseName, r.RecordType,
+			r.Value, r.PreviousValue, r.WeightKg, r.Reps, r.Formula, r.AchievedAt,
+		).Scan(&r.ID, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	for _, r := range found {
+		if !notify || r.PreviousValue == nil || announced[fmt.Sprintf("%s|%s|%.2f", r.ExerciseKey, r.RecordType, r.Value)] {
+			continue
+		}
+		_ = CreateNotification(userID, &workoutID, describePersonalRecord(r))
+	}
+	return found, nil
+}
+
+// describePersonalRecord renders a PR notification message
+func describePersonalRecord(r models.PersonalRecord) string {
+	switch r.RecordType {
+	case RecordMaxWeight:
+		return fmt.Sprintf("🏆 New PR on %s: heaviest weight %.1f kg (previous %.1f kg)", r.ExerciseName, r.Value, *r.PreviousValue)
+	case RecordEstimated1RM:
+		return fmt.Sprintf("🏆 New PR on %s: estimated 1RM %.1f kg (previous %.1f kg)", r.ExerciseName, r.Value, *r.PreviousValue)
+	case RecordMaxVolume:
+		return fmt.Sprintf("🏆 New PR on %s: session volume %.0f kg (previous %.0f kg)", r.ExerciseName, r.Value, *r.PreviousValue)
+	default:
+		return fmt.Sprintf("🏆 New PR on %s: %.0f reps at %.1f kg (previous %.0f)", r.ExerciseName, r.Value, *r.WeightKg, *r.PreviousValue)
+	}
+}
+
+// SaveWorkoutSession handles POST /user/workouts/:id/save and runs PR detection for the session
+func SaveWorkoutSession(c *gin.Context) {
+	userID, workoutID, ok := sessionRequestIDs(c)
+	if !ok {
+		return
+	}
+
+	records, err := detectPersonalRecords(userID, workoutID)
+	if err != nil {
+		log.Println("PR DETECTION ERROR:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save workout session"})
+		return
+	}
+	if records == nil {
+		records = []models.PersonalRecord{}
+	}
+
+	exercises, totalVolume, err := loadWorkoutSession(config.DB, workoutID)
+	if err != nil {
+		log.Println("DB SELECT ERROR (loadWorkoutSession):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load workout session"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"workout_id":       workoutID,
+		"exercises":        exercises,
+		"total_volume_kg":  totalVolume,
+		"personal_records": records,
+	})
+}
+
+// scanPersonalRecords reads personal_records rows selected with personalRecordColumns
+func scanPersonalRecords(rows *sql.Rows) ([]models.PersonalRecord, error) {
+	records := []models.PersonalRecord{}
+	for rows.Next() {
+		var r models.PersonalRecord
+		var formula sql.NullString
+		if err := rows.Scan(
+			&r.ID, &r.UserID, &r.WorkoutID, &r.SetID, &r.ExerciseKey, &r.ExerciseName, &r.RecordType,
+			&r.Value, &r.PreviousValue, &r.WeightKg, &r.Reps, &formula, &r.AchievedAt, &r.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		r.Formula = formula.String
+		records = append(records, r)
+	}
+	return records, rows.Err()
+}
+
+const personalRecordColumns = `id, user_id, workout_id, set_id, exercise_key, exercise_name, record_type,
+       value, previous_value, weight_kg, reps, formula, achieved_at, created_at`
+
+// GetPersonalRecords handles GET /user/records and returns the current best of each type per exercise
+func GetPersonalRecords(c *gin.Context) {
+	userID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	rows, err := config.DB.Query(
+		`SELECT DISTINCT ON (exercise_key, record_type) `+personalRecordColumns+`
+         FROM personal_records
+         WHERE user_id = $1 AND record_type <> $2
+         ORDER BY exercise_key, record_type, value DESC, achieved_at DESC`,
+		userID, RecordRepsAtWeight,
+	)
+	if err != nil {
+		log.Println("DB SELECT ERROR (GetPersonalRecords):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch personal records"})
+		return
+	}
+	defer rows.Close()
+
+	records, err := scanPersonalRecords(rows)
+	if err != nil {
+		log.Println("DB SCAN ERROR (GetPersonalRecords):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse personal records"})
+		return
+	}
+
+	// Show both 1RM estimates so lifters can compare against their spreadsheets
+	response := make([]gin.H, 0, len(records))
+	for _, r := range records {
+		item := gin.H{"record": r}
+		if r.RecordType == RecordEstimated1RM && r.WeightKg != nil && r.Reps != nil {
+			item["epley_1rm"] = round2(epley1RM(*r.WeightKg, *r.Reps))
+			item["brzycki_1rm"] = round2(brzycki1RM(*r.WeightKg, *r.Reps))
+		}
+		response = append(response, item)
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// GetPersonalRecordHistory handles GET /user/records/history?exercise=&type=
+func GetPersonalRecordHistory(c *gin.Context) {
+	userID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	query := `SELECT ` + personalRecordColumns + ` FROM personal_records WHERE user_id = $1`
+	args := []interface{}{userID}
+	if exercise := strings.ToLower(strings.TrimSpace(c.Query("exercise"))); exercise != "" {
+		args = append(args, exercise)
+		query += fmt.Sprintf(` AND exercise_key = $%d`, len(args))
+	}
+	if recordType := c.Query("type"); recordType != "" {
+		switch recordType {
+		case RecordMaxWeight, RecordRepsAtWeight, RecordEstimated1RM, RecordMaxVolume:
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "type must be max_weight, reps_at_weight, estimated_1rm, or max_volume"})
+			return
+		}
+		args = append(args, recordType)
+		query += fmt.Sprintf(` AND record_type = $%d`, len(args))
+	}
+	query += ` ORDER BY achieved_at DESC, created_at DESC LIMIT 200`
+
+	rows, err := config.DB.Query(query, args...)
+	if err != nil {
+		log.Println("DB SELECT ERROR (GetPersonalRecordHistory):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch personal records"})
+		return
+	}
+	defer rows.Close()
+
+	records, err := scanPersonalRecords(rows)
+	if err != nil {
+		log.Println("DB SCAN ERROR (GetPersonalRecordHistory):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse personal records"})
+		return
+	}
+	c.JSON(http.StatusOK, records)
+}
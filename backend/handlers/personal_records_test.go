@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+
+	"nutritionix/backend/models"
+)
+
+func TestEstimateOneRepMax(t *testing.T) {
+	tests := []struct {
+		name           string
+		weight         float64
+		reps           int
+		want           float64
+		formula        string
+		epley, brzycki float64
+	}{
+		{"a single is the actual max", 100, 1, 100, "actual", 100 * 31.0 / 30, 100},
+		{"brzycki for a few reps", 100, 5, 112.5, "brzycki", 100 * 35.0 / 30, 112.5},
+		{"brzycki up to ten reps", 90, 10, 120, "brzycki", 120, 120},
+		{"epley above ten reps", 100, 12, 140, "epley", 140, 100 * 36.0 / 25},
+		{"no reps", 100, 0, 0, "", 100, 100 * 36.0 / 37},
+		{"no weight", 0, 5, 0, "", 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, formula := estimateOneRepMax(tt.weight, tt.reps)
+			if math.Abs(got-tt.want) > 1e-9 || formula != tt.formula {
+				t.Errorf("got %v by %q, want %v by %q", got, formula, tt.want, tt.formula)
+			}
+			if e := epley1RM(tt.weight, tt.reps); math.Abs(e-tt.epley) > 1e-9 {
+				t.Errorf("epley got %v, want %v", e, tt.epley)
+			}
+			if b := brzycki1RM(tt.weight, tt.reps); math.Abs(b-tt.brzycki) > 1e-9 {
+				t.Errorf("brzycki got %v, want %v", b, tt.brzycki)
+			}
+		})
+	}
+}
+
+func TestComputeLiftBests(t *testing.T) {
+	sets := []prSet{
+		{Reps: 5, WeightKg: 100},
+		{Reps: 7, WeightKg: 100},
+		{Reps: 6, WeightKg: 100},
+		{Reps: 3, WeightKg: 120},
+		{Reps: 15, WeightKg: 60}, // too many reps to estimate a max from
+		{Reps: 0, WeightKg: 140}, // a failed set counts for nothing
+		{Reps: 10, WeightKg: 0},  // bodyweight reps only add volume
+	}
+	bests := computeLiftBests(sets)
+
+	if bests.MaxWeight != 120 {
+		t.Errorf("max weight got %v, want 120", bests.MaxWeight)
+	}
+	if want := 120 * 36.0 / 34; math.Abs(bests.Best1RM-want) > 1e-9 || bests.Best1RMSet != &sets[3] {
+		t.Errorf("best 1RM got %v from %v, want %v from the 120 kg triple", bests.Best1RM, bests.Best1RMSet, want)
+	}
+	reps := map[float64]int{}
+	for w, s := range bests.RepsAtWeight {
+		reps[w] = s.Reps
+	}
+	if want := map[float64]int{100: 7, 120: 3, 60: 15}; !reflect.DeepEqual(reps, want) {
+		t.Errorf("reps at weight got %v, want %v", reps, want)
+	}
+	if bests.MaxVolume != 500+700+600+360+900 {
+		t.Errorf("max volume got %v, want 3060", bests.MaxVolume)
+	}
+
+	for weight, want := range map[float64]int{50: 15, 100: 7, 110: 3, 120: 3, 130: 0} {
+		if got, ok := bestRepsAtOrAbove(bests, weight); got != want || ok != (want > 0) {
+			t.Errorf("bestRepsAtOrAbove(%v) = %d, %v, want %d", weight, got, ok, want)
+		}
+	}
+}
+
+func TestFindPersonalRecords(t *testing.T) {
+	tests := []struct {
+		name             string
+		session, history []prSet
+		want             []string
+	}{
+		{
+			name:    "first session sets baselines",
+			session: []prSet{{Reps: 5, WeightKg: 100}},
+			want:    []string{"max_weight 100 (none)", "estimated_1rm 112.5 (none)", "max_volume 500 (none)"},
+		},
+		{
+			name:    "heavier weight beats the previous best",
+			session: []prSet{{Reps: 5, WeightKg: 110}},
+			history: []prSet{{Reps: 5, WeightKg: 100}},
+			want:    []string{"max_weight 110 (100)", "estimated_1rm 123.75 (112.5)", "max_volume 550 (500)"},
+		},
+		{
+			name:    "more reps at a load lifted before",
+			session: []prSet{{Reps: 6, WeightKg: 100}},
+			history: []prSet{{Reps: 5, WeightKg: 100}},
+			want:    []string{"estimated_1rm 116.13 (112.5)", "max_volume 600 (500)", "reps_at_weight 6 (5)"},
+		},
+		{
+			name:    "more reps at a lighter load than a heavier past set",
+			session: []prSet{{Reps: 9, WeightKg: 100}},
+			history: []prSet{{Reps: 8, WeightKg: 110}},
+			want:    []string{"max_volume 900 (880)", "reps_at_weight 9 (8)"},
+		},
+		{
+			name:    "a tie is not a record",
+			session: []prSet{{Reps: 5, WeightKg: 100}},
+			history: []prSet{{Reps: 5, WeightKg: 100}},
+		},
+		{
+			name:    "no improvement",
+			session: []prSet{{Reps: 5, WeightKg: 100}},
+			history: []prSet{{Reps: 5, WeightKg: 120}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, r := range findPersonalRecords(tt.session, tt.history) {
+				got = append(got, recordSummary(r))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// recordSummary renders a record as "type value (previous)"
+func recordSummary(r models.PersonalRecord) string {
+	previous := "none"
+	if r.PreviousValue != nil {
+		previous = fmt.Sprint(*r.PreviousValue)
+	}
+	return fmt.Sprintf("%s %v (%s)", r.RecordType, r.Value, previous)
+}
@@ -0,0 +1,962 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"nutritionix/backend/config"
+	"nutritionix/backend/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Enrollment statuses
+const (
+	EnrollmentActive    = "active"
+	EnrollmentCompleted = "completed"
+	EnrollmentCancelled = "cancelled"
+)
+
+// Results of evaluating a lift from the previous session
+const (
+	LiftSuccess      = "success"
+	LiftMiss         = "miss"
+	LiftDeload       = "deload"
+	LiftNotAttempted = "not_attempted"
+)
+
+// Defaults for lifts that progress by weight but don't say how to deload
+const (
+	defaultDeloadAfterMisses = 3
+	defaultDeloadPercent     = 10.0
+)
+
+// minutesPerSet is the rough time per working set, rest included, used to plan session duration
+const minutesPerSet = 3
+
+var programSlugPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,63}$`)
+
+// liftOutcome reports how a lift went in the previous session and what comes next
+type liftOutcome struct {
+	Key          string  `json:"key"`
+	Name         string  `json:"name"`
+	Result       string  `json:"result"` // success, miss, deload, not_attempted
+	WeightKg     float64 `json:"weight_kg"`
+	NextWeightKg float64 `json:"next_weight_kg"`
+	Misses       int     `json:"misses"`
+	Week         int     `json:"week,omitempty"`      // run/walk exercises: the week's intervals just done
+	NextWeek     int     `json:"next_week,omitempty"` // and the week's intervals next
+}
+
+// programExerciseRef is a program exercise resolved against the catalog
+type programExerciseRef struct {
+	Key      string
+	Name     string
+	Exercise *models.Exercise // nil for free-form exercises
+}
+
+// resolveProgramExercise matches a program exercise to the catalog by slug, falling back to a free-form name.
+// Keys line up with exerciseKeySQL so logged sets can be matched back to the prescription.
+func resolveProgramExercise(name string) (programExerciseRef, error) {
+	exercise, err := lookupExercise(nil, name, "")
+	if err == errExerciseNotFound {
+		trimmed := strings.TrimSpace(name)
+		return programExerciseRef{Key: strings.ToLower(trimmed), Name: trimmed}, nil
+	}
+	if err != nil {
+		return programExerciseRef{}, err
+	}
+	return programExerciseRef{Key: exercise.Slug, Name: exercise.Name, Exercise: exercise}, nil
+}
+
+// validateProgramDefinition checks an imported program and fills in defaults
+func validateProgramDefinition(def *models.ProgramDefinition) error {
+	def.Slug = strings.ToLower(strings.TrimSpace(def.Slug))
+	def.Name = strings.TrimSpace(def.Name)
+	if !programSlugPattern.MatchString(def.Slug) {
+		return fmt.Errorf("slug must be lowercase letters, digits and dashes")
+	}
+	if def.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if def.Weeks < 1 || def.Weeks > 52 {
+		return fmt.Errorf("weeks must be between 1 and 52")
+	}
+	if def.SessionsPerWeek < 1 || def.SessionsPerWeek > 7 {
+		return fmt.Errorf("sessions_per_week must be between 1 and 7")
+	}
+	if len(def.Sessions) == 0 || len(def.Sessions) > 14 {
+		return fmt.Errorf("a program needs between 1 and 14 sessions")
+	}
+
+	for i := range def.Sessions {
+		session := &def.Sessions[i]
+		session.Name = strings.TrimSpace(session.Name)
+		if session.Name == "" {
+			return fmt.Errorf("session %d: name is required", i+1)
+		}
+		sessionType, err := resolveWorkoutType(session.Type, nil, session.Name)
+		if err != nil {
+			return fmt.Errorf("session %d: %v", i+1, err)
+		}
+		session.Type = sessionType
+		if len(session.Exercises) == 0 {
+			return fmt.Errorf("session %d: at least one exercise is required", i+1)
+		}
+
+		for j := range session.Exercises {
+			ex := &session.Exercises[j]
+			where := fmt.Sprintf("session %d, exercise %d", i+1, j+1)
+			ex.Exercise = strings.TrimSpace(ex.Exercise)
+			if ex.Exercise == "" {
+				return fmt.Errorf("%s: exercise is required", where)
+			}
+			p := &ex.Progression
+
+			if ex.Intervals != nil {
+				iv := ex.Intervals
+				if iv.RunSeconds < 1 || iv.WalkSeconds < 0 || iv.TotalRunSeconds < iv.RunSeconds || iv.WarmupMin < 0 {
+					return fmt.Errorf("%s: intervals need run_seconds > 0, walk_seconds >= 0 and total_run_seconds >= run_seconds", where)
+				}
+				if p.RunIncrementSeconds < 0 || p.TotalIncrementSeconds < 0 || p.MaxRunSeconds < 0 {
+					return fmt.Errorf("%s: interval progression can't be negative", where)
+				}
+				continue
+			}
+
+			if ex.Sets < 1 || ex.Sets > 20 || ex.Reps < 1 || ex.Reps > 100 {
+				return fmt.Errorf("%s: sets must be 1-20 and reps 1-100", where)
+			}
+			if ex.StartWeightKg < 0 || ex.StartWeightKg > 999.99 {
+				return fmt.Errorf("%s: start_weight_kg must be between 0 and 999.99", where)
+			}
+			if p.IncrementKg < 0 || p.IncrementKg > 50 || p.DeloadAfterMisses < 0 || p.DeloadPercent < 0 || p.DeloadPercent >= 100 {
+				return fmt.Errorf("%s: invalid progression", where)
+			}
+			if p.IncrementKg > 0 {
+				if p.DeloadAfterMisses == 0 {
+					p.DeloadAfterMisses = defaultDeloadAfterMisses
+				}
+				if p.DeloadPercent == 0 {
+					p.DeloadPercent = defaultDeloadPercent
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// roundDownTo rounds a weight down to the nearest loadable step
+func roundDownTo(weight, step float64) float64 {
+	if step <= 0 {
+		step = 0.5
+	}
+	return round2(math.Floor(weight/step+1e-9) * step)
+}
+
+// progressLift applies a program's progression rules to a lift given its logged working sets
+func progressLift(lift *models.LiftState, target models.PrescribedExercise, rules models.ProgramProgression, sets []prSet) string {
+	if len(sets) == 0 {
+		return LiftNotAttempted
+	}
+	completed := 0
+	for _, s := range sets {
+		if s.Reps >= target.Reps && s.WeightKg >= target.WeightKg-0.01 {
+			completed++
+		}
+	}
+	if completed >= target.Sets {
+		lift.WeightKg = round2(target.WeightKg + rules.IncrementKg)
+		lift.Misses = 0
+		return LiftSuccess
+	}
+
+	lift.WeightKg = target.WeightKg
+	lift.Misses++
+	if rules.DeloadAfterMisses > 0 && lift.Misses >= rules.DeloadAfterMisses {
+		lift.WeightKg = roundDownTo(target.WeightKg*(1-rules.DeloadPercent/100), rules.IncrementKg)
+		lift.Misses = 0
+		return LiftDeload
+	}
+	return LiftMiss
+}
+
+// progressIntervals moves a run/walk exercise on a week once a week's worth of its sessions are
+// completed. A missed session repeats the week; repeated misses step back a week.
+func progressIntervals(iv *models.IntervalState, rules models.ProgramProgression, sessionsPerWeek int, completed bool) string {
+	if completed {
+		iv.Misses = 0
+		iv.Completed++
+		if iv.Completed >= sessionsPerWeek {
+			iv.Week++
+			iv.Completed = 0
+		}
+		return LiftSuccess
+	}
+
+	iv.Misses++
+	stepBackAfter := rules.DeloadAfterMisses
+	if stepBackAfter == 0 {
+		stepBackAfter = defaultDeloadAfterMisses
+	}
+	if iv.Misses >= stepBackAfter && iv.Week > 0 {
+		iv.Week--
+		iv.Completed = 0
+		iv.Misses = 0
+		return LiftDeload
+	}
+	return LiftMiss
+}
+
+// intervalsForWeek lengthens the run intervals and the total running time each program week;
+// once either reaches max_run_seconds the session becomes a single continuous run
+func intervalsForWeek(iv models.ProgramIntervals, rules models.ProgramProgression, week int) (run, walk, repeats int) {
+	run = iv.RunSeconds + week*rules.RunIncrementSeconds
+	total := iv.TotalRunSeconds + week*rules.TotalIncrementSeconds
+	if rules.MaxRunSeconds > 0 && (run >= rules.MaxRunSeconds || total >= rules.MaxRunSeconds) {
+		return rules.MaxRunSeconds, 0, 1
+	}
+	repeats = int(math.Round(float64(total) / float64(run)))
+	if repeats < 1 {
+		repeats = 1
+	}
+	return run, iv.WalkSeconds, repeats
+}
+
+// programRules finds the progression rules for a lift in a session template
+func programRules(session models.ProgramSession, key string) models.ProgramProgression {
+	for _, ex := range session.Exercises {
+		if ref, err := resolveProgramExercise(ex.Exercise); err == nil && ref.Key == key {
+			return ex.Progression
+		}
+	}
+	return models.ProgramProgression{}
+}
+
+// startingLifts sets each lift's working weight from the program, or from the user's own starting weights
+func startingLifts(def models.ProgramDefinition, overrides map[string]float64) (map[string]*models.LiftState, error) {
+	lifts := map[string]*models.LiftState{}
+	for _, session := range def.Sessions {
+		for _, ex := range session.Exercises {
+			if ex.Intervals != nil {
+				continue
+			}
+			ref, err := resolveProgramExercise(ex.Exercise)
+			if err != nil {
+				return nil, err
+			}
+			if _, seen := lifts[ref.Key]; seen {
+				continue
+			}
+			weight := ex.StartWeightKg
+			if w, ok := overrides[ref.Key]; ok {
+				weight = w
+			} else if w, ok := overrides[strings.ToLower(ex.Exercise)]; ok {
+				weight = w
+			}
+			lifts[ref.Key] = &models.LiftState{WeightKg: round2(weight)}
+		}
+	}
+	return lifts, nil
+}
+
+// loadLoggedWorkingSets returns a workout's logged working sets grouped by exercise key
+func loadLoggedWorkingSets(workoutID uuid.UUID) (map[string][]prSet, error) {
+	rows, err := config.DB.Query(
+		`SELECT `+exerciseKeySQL+`, ws.reps, ws.weight_kg
+         FROM workout_sets ws
+         JOIN workout_exercises we ON we.id = ws.workout_exercise_id
+         LEFT JOIN exercise_catalog ec ON ec.id = we.exercise_id
+         WHERE we.workout_id = $1 AND ws.is_warmup = false`,
+		workoutID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sets := map[string][]prSet{}
+	for rows.Next() {
+		var key string
+		var s prSet
+		if err := rows.Scan(&key, &s.Reps, &s.WeightKg); err != nil {
+			return nil, err
+		}
+		sets[key] = append(sets[key], s)
+	}
+	return sets, rows.Err()
+}
+
+// evaluatePendingSession closes out the last generated session once its workout is completed,
+// skipped or missed; skipped and missed sessions leave every lift not attempted and count as a
+// miss for run/walk intervals.
+// It reports done=false when that session should be offered again: its workout was deleted,
+// or it is still planned or in progress.
+func evaluatePendingSession(state *models.EnrollmentState, def models.ProgramDefinition) (bool, []liftOutcome, error) {
+	pending := state.Pending
+	var status string
+	err := config.DB.QueryRow(`SELECT status FROM workouts WHERE id = $1`, pending.WorkoutID).Scan(&status)
+	if err == sql.ErrNoRows {
+		state.Pending = nil
+		return false, nil, nil
+	}
+	if err != nil {
+		return false, nil, err
+	}
+	if status == WorkoutPlanned || status == WorkoutInProgress {
+		return false, nil, nil
+	}
+
+	logged := map[string][]prSet{}
+	if status == WorkoutCompleted {
+		if logged, err = loadLoggedWorkingSets(pending.WorkoutID); err != nil {
+			return false, nil, err
+		}
+	}
+
+	session := def.Sessions[pending.Template%len(def.Sessions)]
+	var outcomes []liftOutcome
+	for _, target := range pending.Prescribed {
+		if target.RunSeconds > 0 {
+			iv, ok := state.Intervals[target.Key]
+			if !ok {
+				iv = &models.IntervalState{Week: pending.Week}
+				state.Intervals[target.Key] = iv
+			}
+			week := iv.Week
+			result := progressIntervals(iv, programRules(session, target.Key), def.SessionsPerWeek, status == WorkoutCompleted)
+			outcomes = append(outcomes, liftOutcome{
+				Key:      target.Key,
+				Name:     target.Name,
+				Result:   result,
+				Misses:   iv.Misses,
+				Week:     week + 1,
+				NextWeek: iv.Week + 1,
+			})
+			continue
+		}
+		if target.Sets == 0 {
+			continue
+		}
+		lift, ok := state.Lifts[target.Key]
+		if !ok {
+			lift = &models.LiftState{WeightKg: target.WeightKg}
+			state.Lifts[target.Key] = lift
+		}
+		result := progressLift(lift, target, programRules(session, target.Key), logged[target.Key])
+		outcomes = append(outcomes, liftOutcome{
+			Key:          target.Key,
+			Name:         target.Name,
+			Result:       result,
+			WeightKg:     target.WeightKg,
+			NextWeightKg: lift.WeightKg,
+			Misses:       lift.Misses,
+		})
+	}
+
+	state.SessionsDone++
+	state.Pending = nil
+	return true, outcomes, nil
+}
+
+// buildProgramSession prescribes the next session from the enrollment's current state
+func buildProgramSession(def models.ProgramDefinition, state *models.EnrollmentState) (int, int, models.ProgramSession, []models.PrescribedExercise, []exerciseInput, error) {
+	template := state.SessionsDone % len(def.Sessions)
+	week := state.SessionsDone / def.SessionsPerWeek
+	session := def.Sessions[template]
+
+	var prescribed []models.PrescribedExercise
+	var exercises []exerciseInput
+	for _, ex := range session.Exercises {
+		ref, err := resolveProgramExercise(ex.Exercise)
+		if err != nil {
+			return 0, 0, session, nil, nil, err
+		}
+		p := models.PrescribedExercise{Key: ref.Key, Name: ref.Name}
+		var notes string
+		if ex.Intervals != nil {
+			// The intervals follow the sessions actually run, not the calendar; enrollments from
+			// before that was tracked start from the program week they're in
+			iv, ok := state.Intervals[ref.Key]
+			if !ok {
+				iv = &models.IntervalState{Week: week}
+				state.Intervals[ref.Key] = iv
+			}
+			p.RunSeconds, p.WalkSeconds, p.Repeats = intervalsForWeek(*ex.Intervals, ex.Progression, iv.Week)
+			if p.Repeats == 1 && p.WalkSeconds == 0 {
+				notes = fmt.Sprintf("Run %d min continuously", p.RunSeconds/60)
+			} else {
+				notes = fmt.Sprintf("%d × run %ds / walk %ds", p.Repeats, p.RunSeconds, p.WalkSeconds)
+			}
+			if ex.Intervals.WarmupMin > 0 {
+				notes += fmt.Sprintf(" after a %d min warm-up walk", ex.Intervals.WarmupMin)
+			}
+		} else {
+			lift, ok := state.Lifts[ref.Key]
+			if !ok {
+				lift = &models.LiftState{WeightKg: ex.StartWeightKg}
+				state.Lifts[ref.Key] = lift
+			}
+			p.Sets, p.Reps, p.WeightKg = ex.Sets, ex.Reps, lift.WeightKg
+			notes = fmt.Sprintf("%d × %d", p.Sets, p.Reps)
+			if p.WeightKg > 0 {
+				notes += " @ " + strconv.FormatFloat(p.WeightKg, 'f', -1, 64) + " kg"
+			}
+		}
+		prescribed = append(prescribed, p)
+
+		in := exerciseInput{Name: ref.Name, Notes: notes}
+		if ref.Exercise != nil {
+			in.ExerciseID = &ref.Exercise.ID
+		}
+		exercises = append(exercises, in)
+	}
+	return template, week, session, prescribed, exercises, nil
+}
+
+// plannedDurationMin estimates how long a generated session takes
+func plannedDurationMin(def models.ProgramSession, prescribed []models.PrescribedExercise) int {
+	seconds := 0
+	for i, p := range prescribed {
+		if p.Repeats > 0 {
+			seconds += p.Repeats*p.RunSeconds + (p.Repeats-1)*p.WalkSeconds
+			if iv := def.Exercises[i].Intervals; iv != nil {
+				seconds += iv.WarmupMin * 60
+			}
+		} else {
+			seconds += p.Sets * minutesPerSet * 60
+		}
+	}
+	return int(math.Ceil(float64(seconds) / 60))
+}
+
+func scanProgram(row interface{ Scan(...interface{}) error }) (models.Program, error) {
+	var p models.Program
+	var ownerID uuid.NullUUID
+	var definition []byte
+	if err := row.Scan(&p.ID, &ownerID, &p.Slug, &p.Name, &p.Description, &definition, &p.CreatedAt); err != nil {
+		return p, err
+	}
+	if ownerID.Valid {
+		p.OwnerID = &ownerID.UUID
+	}
+	return p, json.Unmarshal(definition, &p.Definition)
+}
+
+const programColumns = `id, owner_id, slug, name, description, definition, created_at`
+
+// loadVisibleProgram loads a built-in program or one the user imported
+func loadVisibleProgram(programID, userID uuid.UUID) (models.Program, error) {
+	return scanProgram(config.DB.QueryRow(
+		`SELECT `+programColumns+` FROM programs WHERE id = $1 AND (owner_id IS NULL OR owner_id = $2)`,
+		programID, userID,
+	))
+}
+
+func scanEnrollment(row interface{ Scan(...interface{}) error }) (models.ProgramEnrollment, error) {
+	var e models.ProgramEnrollment
+	var state []byte
+	if err := row.Scan(&e.ID, &e.UserID, &e.ProgramID, &e.StartDate, &e.Status, &state, &e.CreatedAt, &e.UpdatedAt); err != nil {
+		return e, err
+	}
+	if err := json.Unmarshal(state, &e.State); err != nil {
+		return e, err
+	}
+	if e.State.Lifts == nil {
+		e.State.Lifts = map[string]*models.LiftState{}
+	}
+	if e.State.Intervals == nil {
+		e.State.Intervals = map[string]*models.IntervalState{}
+	}
+	return e, nil
+}
+
+const enrollmentColumns = `id, user_id, program_id, start_date::text, status, state, created_at, updated_at`
+
+// programRequestIDs parses the user ID and the :id path parameter.
+// It writes the error response itself and returns false when the request should stop.
+func programRequestIDs(c *gin.Context, what string) (uuid.UUID, uuid.UUID, bool) {
+	userID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return uuid.Nil, uuid.Nil, false
+	}
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid " + what + " ID"})
+		return uuid.Nil, uuid.Nil, false
+	}
+	return userID, id, true
+}
+
+// ListPrograms handles GET /user/programs: built-in programs plus the user's imports
+func ListPrograms(c *gin.Context) {
+	userID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	rows, err := config.DB.Query(
+		`SELECT `+programColumns+` FROM programs
+         WHERE owner_id IS NULL OR owner_id = $1
+         ORDER BY owner_id NULLS FIRST, name`,
+		userID,
+	)
+	if err != nil {
+		log.Println("DB SELECT ERROR (ListPrograms):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get programs"})
+		return
+	}
+	defer rows.Close()
+
+	programs := []models.Program{}
+	for rows.Next() {
+		p, err := scanProgram(rows)
+		if err != nil {
+			log.Println("DB SCAN ERROR (ListPrograms):", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse programs"})
+			return
+		}
+		programs = append(programs, p)
+	}
+
+	c.JSON(http.StatusOK, programs)
+}
+
+// ImportProgram handles POST /user/programs/import with a program definition as exported
+func ImportProgram(c *gin.Context) {
+	userID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	var def models.ProgramDefinition
+	if err := c.ShouldBindJSON(&def); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validateProgramDefinition(&def); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	definition, err := json.Marshal(def)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import program"})
+		return
+	}
+
+	program, err := scanProgram(config.DB.QueryRow(
+		`INSERT INTO programs (owner_id, slug, name, description, definition)
+         VALUES ($1, $2, $3, $4, $5::jsonb)
+         RETURNING `+programColumns,
+		userID, def.Slug, def.Name, def.Description, string(definition),
+	))
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			c.JSON(http.StatusConflict, gin.H{"error": "You already have a program with this slug"})
+			return
+		}
+		log.Println("DB INSERT ERROR (ImportProgram):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import program"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, program)
+}
+
+// ExportProgram handles GET /user/programs/:id/export, returning JSON that ImportProgram accepts
+func ExportProgram(c *gin.Context) {
+	userID, programID, ok := programRequestIDs(c, "program")
+	if !ok {
+		return
+	}
+
+	program, err := loadVisibleProgram(programID, userID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Program not found"})
+		return
+	}
+	if err != nil {
+		log.Println("DB SELECT ERROR (ExportProgram):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export program"})
+		return
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="`+program.Slug+`.json"`)
+	c.JSON(http.StatusOK, program.Definition)
+}
+
+// DeleteProgram handles DELETE /user/programs/:id for programs the user imported
+func DeleteProgram(c *gin.Context) {
+	userID, programID, ok := programRequestIDs(c, "program")
+	if !ok {
+		return
+	}
+
+	res, err := config.DB.Exec(`DELETE FROM programs WHERE id = $1 AND owner_id = $2`, programID, userID)
+	if err != nil {
+		log.Println("DB DELETE ERROR (DeleteProgram):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete program"})
+		return
+	}
+	if count, _ := res.RowsAffected(); count == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Program not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Program deleted successfully"})
+}
+
+// EnrollInProgram handles POST /user/programs/:id/enroll.
+// Starting weights default to the program's and can be overridden per lift, e.g. {"weights_kg": {"squat": 60}}.
+func EnrollInProgram(c *gin.Context) {
+	userID, programID, ok := programRequestIDs(c, "program")
+	if !ok {
+		return
+	}
+
+	var input struct {
+		StartDate string             `json:"start_date"`
+		WeightsKg map[string]float64 `json:"weights_kg"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil && err.Error() != "EOF" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.StartDate == "" {
+		input.StartDate = time.Now().Format("2006-01-02")
+	}
+	if _, err := time.Parse("2006-01-02", input.StartDate); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "start_date must be in YYYY-MM-DD format"})
+		return
+	}
+	for lift, w := range input.WeightsKg {
+		if w < 0 || w > 999.99 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "weight for " + lift + " must be between 0 and 999.99"})
+			return
+		}
+	}
+
+	program, err := loadVisibleProgram(programID, userID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Program not found"})
+		return
+	}
+	if err != nil {
+		log.Println("DB SELECT ERROR (EnrollInProgram):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enroll in program"})
+		return
+	}
+
+	lifts, err := startingLifts(program.Definition, input.WeightsKg)
+	if err != nil {
+		log.Println("DB SELECT ERROR (startingLifts):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enroll in program"})
+		return
+	}
+	state, _ := json.Marshal(models.EnrollmentState{Lifts: lifts})
+
+	enrollment, err := scanEnrollment(config.DB.QueryRow(
+		`INSERT INTO program_enrollments (user_id, program_id, start_date, status, state)
+         VALUES ($1, $2, $3, $4, $5::jsonb)
+         RETURNING `+enrollmentColumns,
+		userID, programID, input.StartDate, EnrollmentActive, string(state),
+	))
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			c.JSON(http.StatusConflict, gin.H{"error": "You are already enrolled in this program"})
+			return
+		}
+		log.Println("DB INSERT ERROR (EnrollInProgram):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enroll in program"})
+		return
+	}
+
+	_ = CreateNotification(userID, &enrollment.ID, "📋 You enrolled in "+program.Name)
+
+	c.JSON(http.StatusCreated, enrollment)
+}
+
+// ListEnrollments handles GET /user/program-enrollments
+func ListEnrollments(c *gin.Context) {
+	userID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	rows, err := config.DB.Query(
+		`SELECT `+enrollmentColumns+` FROM program_enrollments WHERE user_id = $1 ORDER BY created_at DESC`,
+		userID,
+	)
+	if err != nil {
+		log.Println("DB SELECT ERROR (ListEnrollments):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get enrollments"})
+		return
+	}
+	defer rows.Close()
+
+	enrollments := []models.ProgramEnrollment{}
+	for rows.Next() {
+		e, err := scanEnrollment(rows)
+		if err != nil {
+			log.Println("DB SCAN ERROR (ListEnrollments):", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse enrollments"})
+			return
+		}
+		enrollments = append(enrollments, e)
+	}
+
+	c.JSON(http.StatusOK, enrollments)
+}
+
+// GetEnrollment handles GET /user/program-enrollments/:id
+func GetEnrollment(c *gin.Context) {
+	userID, enrollmentID, ok := programRequestIDs(c, "enrollment")
+	if !ok {
+		return
+	}
+
+	enrollment, err := scanEnrollment(config.DB.QueryRow(
+		`SELECT `+enrollmentColumns+` FROM program_enrollments WHERE id = $1 AND user_id = $2`,
+		enrollmentID, userID,
+	))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Enrollment not found"})
+		return
+	}
+	if err != nil {
+		log.Println("DB SELECT ERROR (GetEnrollment):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get enrollment"})
+		return
+	}
+
+	c.JSON(http.StatusOK, enrollment)
+}
+
+// CancelEnrollment handles DELETE /user/program-enrollments/:id; generated workouts are kept
+func CancelEnrollment(c *gin.Context) {
+	userID, enrollmentID, ok := programRequestIDs(c, "enrollment")
+	if !ok {
+		return
+	}
+
+	res, err := config.DB.Exec(
+		`UPDATE program_enrollments SET status = $1, updated_at = NOW()
+         WHERE id = $2 AND user_id = $3 AND status = $4`,
+		EnrollmentCancelled, enrollmentID, userID, EnrollmentActive,
+	)
+	if err != nil {
+		log.Println("DB UPDATE ERROR (CancelEnrollment):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel enrollment"})
+		return
+	}
+	if count, _ := res.RowsAffected(); count == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Active enrollment not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Enrollment cancelled"})
+}
+
+// NextProgramSession handles POST /user/program-enrollments/:id/next.
+// It grades the previous session against what was logged, applies the progression rules,
+// and creates the next session as a workout with its exercises prescribed in the notes.
+func NextProgramSession(c *gin.Context) {
+	userID, enrollmentID, ok := programRequestIDs(c, "enrollment")
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Date string `json:"date"` // YYYY-MM-DD, defaults to today
+	}
+	if err := c.ShouldBindJSON(&input); err != nil && err.Error() != "EOF" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.Date == "" {
+		input.Date = localToday(userTimezone(userID))
+	}
+	if _, err := time.Parse("2006-01-02", input.Date); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "date must be in YYYY-MM-DD format"})
+		return
+	}
+
+	enrollment, err := scanEnrollment(config.DB.QueryRow(
+		`SELECT `+enrollmentColumns+` FROM program_enrollments WHERE id = $1 AND user_id = $2`,
+		enrollmentID, userID,
+	))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Enrollment not found"})
+		return
+	}
+	if err != nil {
+		log.Println("DB SELECT ERROR (NextProgramSession):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate session"})
+		return
+	}
+	if enrollment.Status != EnrollmentActive {
+		c.JSON(http.StatusConflict, gin.H{"error": "Enrollment is " + enrollment.Status})
+		return
+	}
+	program, err := loadVisibleProgram(enrollment.ProgramID, userID)
+	if err != nil {
+		log.Println("DB SELECT ERROR (NextProgramSession):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate session"})
+		return
+	}
+	def := program.Definition
+	state := enrollment.State
+
+	var outcomes []liftOutcome
+	if state.Pending != nil {
+		done, results, err := evaluatePendingSession(&state, def)
+		if err != nil {
+			log.Println("DB SELECT ERROR (evaluatePendingSession):", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate session"})
+			return
+		}
+		if !done && state.Pending != nil {
+			// The session hasn't been completed, skipped or missed yet, so it's still the next one
+			respondWithSession(c, http.StatusOK, state.Pending.WorkoutID)
+			return
+		}
+		outcomes = results
+	}
+
+	if state.SessionsDone >= def.Weeks*def.SessionsPerWeek {
+		if err := saveEnrollmentState(config.DB, enrollmentID, EnrollmentCompleted, state); err != nil {
+			log.Println("DB UPDATE ERROR (NextProgramSession):", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update enrollment"})
+			return
+		}
+		_ = CreateNotification(userID, &enrollmentID, "🏁 You completed "+program.Name+"!")
+		c.JSON(http.StatusOK, gin.H{"message": "Program completed", "lift_results": outcomes})
+		return
+	}
+
+	template, week, session, prescribed, exercises, err := buildProgramSession(def, &state)
+	if err != nil {
+		log.Println("DB SELECT ERROR (buildProgramSession):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate session"})
+		return
+	}
+
+	var workoutExercise *models.Exercise
+	if len(exercises) > 0 && exercises[0].ExerciseID != nil {
+		workoutExercise, _ = lookupExercise(exercises[0].ExerciseID, "", "")
+	}
+	var exerciseID *int
+	if workoutExercise != nil {
+		exerciseID = &workoutExercise.ID
+	}
+	durationMin := plannedDurationMin(session, prescribed)
+	caloriesBurned, caloriesSource := resolveWorkoutCalories(userID, workoutExercise, durationMin, 0)
+	name := program.Name + " – " + session.Name
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		log.Printf("Failed to begin program session transaction: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate session"})
+		return
+	}
+	defer tx.Rollback()
+
+	var workoutID uuid.UUID
+	err = tx.QueryRow(
+		`INSERT INTO workouts (user_id, name, duration_minutes, calories_burned, date, created_at, exercise_id,
+                               calories_source, type, enrollment_id, timezone)
+         VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+         RETURNING id`,
+		userID, name, durationMin, caloriesBurned, input.Date, time.Now(), exerciseID, caloriesSource,
+		session.Type, enrollmentID, userTimezone(userID),
+	).Scan(&workoutID)
+	if err != nil {
+		log.Printf("Failed to create program workout: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate session"})
+		return
+	}
+	for _, exercise := range exercises {
+		if _, err := insertWorkoutExercise(tx, workoutID, exercise); err != nil {
+			log.Printf("Failed to add program exercise: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate session"})
+			return
+		}
+	}
+
+	state.Pending = &models.PendingSession{
+		WorkoutID:  workoutID,
+		Template:   template,
+		Week:       week,
+		Date:       input.Date,
+		Prescribed: prescribed,
+	}
+	if err := saveEnrollmentState(tx, enrollmentID, EnrollmentActive, state); err != nil {
+		log.Printf("Failed to save enrollment state: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate session"})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("Failed to commit program session: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate session"})
+		return
+	}
+
+	for _, o := range outcomes {
+		if o.Result != LiftDeload {
+			continue
+		}
+		msg := fmt.Sprintf("📉 Deload: %s drops to %s kg after repeated missed sessions.",
+			o.Name, strconv.FormatFloat(o.NextWeightKg, 'f', -1, 64))
+		if o.NextWeek > 0 {
+			msg = fmt.Sprintf("📉 %s steps back to week %d intervals after repeated missed sessions.", o.Name, o.NextWeek)
+		}
+		_ = CreateNotification(userID, &workoutID, msg)
+	}
+	_ = CreateNotification(userID, &workoutID, "💪 New workout scheduled: "+name)
+
+	sessionExercises, totalVolume, err := loadWorkoutSession(config.DB, workoutID)
+	if err != nil {
+		log.Println("DB SELECT ERROR (loadWorkoutSession):", err)
+	}
+	c.JSON(http.StatusCreated, gin.H{
+		"workout_id":      workoutID,
+		"name":            name,
+		"date":            input.Date,
+		"week":            week + 1,
+		"duration_min":    durationMin,
+		"calories_burned": caloriesBurned,
+		"calories_source": caloriesSource,
+		"prescribed":      prescribed,
+		"exercises":       sessionExercises,
+		"total_volume_kg": totalVolume,
+		"lift_results":    outcomes,
+	})
+}
+
+// saveEnrollmentState stores the enrollment's progression state and status
+func saveEnrollmentState(db dbRunner, enrollmentID uuid.UUID, status string, state models.EnrollmentState) error {
+	encoded, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(
+		`UPDATE program_enrollments SET state = $1::jsonb, status = $2, updated_at = NOW() WHERE id = $3`,
+		string(encoded), status, enrollmentID,
+	)
+	return err
+}
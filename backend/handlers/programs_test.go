@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"testing"
+
+	"nutritionix/backend/models"
+)
+
+func TestProgressLift(t *testing.T) {
+	linear := models.ProgramProgression{IncrementKg: 2.5, DeloadAfterMisses: 3, DeloadPercent: 10}
+	fives := func(n int, weight float64) []prSet {
+		sets := make([]prSet, n)
+		for i := range sets {
+			sets[i] = prSet{Reps: 5, WeightKg: weight}
+		}
+		return sets
+	}
+	tests := []struct {
+		name   string
+		lift   models.LiftState
+		target models.PrescribedExercise
+		rules  models.ProgramProgression
+		sets   []prSet
+		want   models.LiftState
+		result string
+	}{
+		{
+			name: "every set done adds the increment and clears misses",
+			lift: models.LiftState{WeightKg: 100, Misses: 2}, target: models.PrescribedExercise{Sets: 3, Reps: 5, WeightKg: 100},
+			rules: linear, sets: fives(3, 100),
+			want: models.LiftState{WeightKg: 102.5}, result: LiftSuccess,
+		},
+		{
+			name: "a hair under the target weight still counts",
+			lift: models.LiftState{WeightKg: 100}, target: models.PrescribedExercise{Sets: 3, Reps: 5, WeightKg: 100},
+			rules: linear, sets: fives(3, 99.995),
+			want: models.LiftState{WeightKg: 102.5}, result: LiftSuccess,
+		},
+		{
+			name: "a short set is a miss at the same weight",
+			lift: models.LiftState{WeightKg: 100}, target: models.PrescribedExercise{Sets: 3, Reps: 5, WeightKg: 100},
+			rules: linear, sets: append(fives(2, 100), prSet{Reps: 4, WeightKg: 100}),
+			want: models.LiftState{WeightKg: 100, Misses: 1}, result: LiftMiss,
+		},
+		{
+			name: "the third miss deloads, rounded down to the increment",
+			lift: models.LiftState{WeightKg: 102.5, Misses: 2}, target: models.PrescribedExercise{Sets: 3, Reps: 5, WeightKg: 102.5},
+			rules: linear, sets: fives(1, 102.5),
+			want: models.LiftState{WeightKg: 90}, result: LiftDeload,
+		},
+		{
+			name: "without an increment the deload rounds down to half a kilo",
+			lift: models.LiftState{WeightKg: 61, Misses: 1}, target: models.PrescribedExercise{Sets: 3, Reps: 5, WeightKg: 61},
+			rules: models.ProgramProgression{DeloadAfterMisses: 2, DeloadPercent: 10}, sets: fives(1, 61),
+			want: models.LiftState{WeightKg: 54.5}, result: LiftDeload,
+		},
+		{
+			name: "without a deload rule misses keep counting",
+			lift: models.LiftState{WeightKg: 100, Misses: 5}, target: models.PrescribedExercise{Sets: 3, Reps: 5, WeightKg: 100},
+			rules: models.ProgramProgression{IncrementKg: 2.5}, sets: fives(1, 100),
+			want: models.LiftState{WeightKg: 100, Misses: 6}, result: LiftMiss,
+		},
+		{
+			name: "no sets leave the lift alone",
+			lift: models.LiftState{WeightKg: 100, Misses: 1}, target: models.PrescribedExercise{Sets: 3, Reps: 5, WeightKg: 100},
+			rules: linear,
+			want:  models.LiftState{WeightKg: 100, Misses: 1}, result: LiftNotAttempted,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lift := tt.lift
+			if result := progressLift(&lift, tt.target, tt.rules, tt.sets); result != tt.result || lift != tt.want {
+				t.Errorf("got %s %+v, want %s %+v", result, lift, tt.result, tt.want)
+			}
+		})
+	}
+}
+
+func TestProgressIntervals(t *testing.T) {
+	tests := []struct {
+		name      string
+		state     models.IntervalState
+		rules     models.ProgramProgression
+		completed bool
+		want      models.IntervalState
+		result    string
+	}{
+		{"a session short of the week counts toward it", models.IntervalState{Week: 1, Completed: 1, Misses: 1}, models.ProgramProgression{}, true,
+			models.IntervalState{Week: 1, Completed: 2}, LiftSuccess},
+		{"the week's last session moves on a week", models.IntervalState{Week: 1, Completed: 2}, models.ProgramProgression{}, true,
+			models.IntervalState{Week: 2}, LiftSuccess},
+		{"a miss repeats the week", models.IntervalState{Week: 2, Completed: 1}, models.ProgramProgression{}, false,
+			models.IntervalState{Week: 2, Completed: 1, Misses: 1}, LiftMiss},
+		{"the third miss steps back a week by default", models.IntervalState{Week: 2, Completed: 1, Misses: 2}, models.ProgramProgression{}, false,
+			models.IntervalState{Week: 1}, LiftDeload},
+		{"the rules can step back sooner", models.IntervalState{Week: 2, Completed: 1, Misses: 1}, models.ProgramProgression{DeloadAfterMisses: 2}, false,
+			models.IntervalState{Week: 1}, LiftDeload},
+		{"the first week has nowhere to step back to", models.IntervalState{Completed: 1, Misses: 2}, models.ProgramProgression{}, false,
+			models.IntervalState{Completed: 1, Misses: 3}, LiftMiss},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := tt.state
+			if result := progressIntervals(&state, tt.rules, 3, tt.completed); result != tt.result || state != tt.want {
+				t.Errorf("got %s %+v, want %s %+v", result, state, tt.result, tt.want)
+			}
+		})
+	}
+}
+
+func TestIntervalsForWeek(t *testing.T) {
+	intervals := models.ProgramIntervals{RunSeconds: 60, WalkSeconds: 90, TotalRunSeconds: 480}
+	rules := models.ProgramProgression{RunIncrementSeconds: 30, TotalIncrementSeconds: 60, MaxRunSeconds: 1800}
+	tests := []struct {
+		week               int
+		run, walk, repeats int
+	}{
+		{0, 60, 90, 8},
+		{2, 120, 90, 5},
+		{10, 360, 90, 3},
+		{21, 690, 90, 3},
+		{22, 1800, 0, 1}, // 1800 s of running in total: one continuous run
+	}
+	for _, tt := range tests {
+		run, walk, repeats := intervalsForWeek(intervals, rules, tt.week)
+		if run != tt.run || walk != tt.walk || repeats != tt.repeats {
+			t.Errorf("week %d got %d/%d x%d, want %d/%d x%d", tt.week, run, walk, repeats, tt.run, tt.walk, tt.repeats)
+		}
+	}
+}
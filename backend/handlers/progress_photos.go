@@ -0,0 +1,539 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	_ "image/png" // registers the PNG decoder for uploads
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"nutritionix/backend/config"
+	"nutritionix/backend/models"
+	"nutritionix/backend/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// maxPhotoFileBytes caps uploaded photos; a full-resolution phone JPEG is 3-8 MB
+const maxPhotoFileBytes = 15 << 20
+
+// maxPhotoPixels rejects images too large to decode safely, whatever their file size
+const maxPhotoPixels = 50_000_000
+
+// Stored sizes: the longer side of the kept image and of its thumbnail
+const (
+	photoMaxSide   = 2048
+	photoThumbSide = 320
+	photoQuality   = 88
+)
+
+// progressPoses are the pose tags, in the order a day's photos are shown
+var progressPoses = []string{"front", "side", "back"}
+
+func validPose(pose string) bool {
+	for _, p := range progressPoses {
+		if p == pose {
+			return true
+		}
+	}
+	return false
+}
+
+// progressPhotoColumns is the column list scanned by scanProgressPhoto. The weigh-in columns come
+// from the lateral join in progressPhotoFrom: the newest weigh-in on the photo's day.
+const progressPhotoColumns = `p.id, p.user_id, p.taken_on::text, p.pose, p.note, p.width, p.height, p.size_bytes, p.created_at,
+    w.id, w.measured_at, w.weight_kg, w.note`
+
+const progressPhotoFrom = ` FROM progress_photos p JOIN users u ON u.id = p.user_id
+    LEFT JOIN LATERAL (
+        SELECT m.id, m.measured_at, m.weight_kg, m.note FROM body_measurements m
+        WHERE m.user_id = p.user_id AND m.weight_kg IS NOT NULL
+          AND (m.measured_at AT TIME ZONE u.timezone)::date = p.taken_on
+        ORDER BY m.measured_at DESC LIMIT 1
+    ) w ON true`
+
+func scanProgressPhoto(row interface{ Scan(...interface{}) error }) (models.ProgressPhoto, error) {
+	var p models.ProgressPhoto
+	var weighInID uuid.NullUUID
+	var weighedAt sql.NullTime
+	var weightKg sql.NullFloat64
+	var weighInNote sql.NullString
+	err := row.Scan(&p.ID, &p.UserID, &p.TakenOn, &p.Pose, &p.Note, &p.Width, &p.Height, &p.SizeBytes, &p.CreatedAt,
+		&weighInID, &weighedAt, &weightKg, &weighInNote)
+	if err != nil {
+		return p, err
+	}
+	p.ImageURL = fmt.Sprintf("/user/progress-photos/%s/image", p.ID)
+	p.ThumbURL = p.ImageURL + "?size=thumbnail"
+	if weighInID.Valid {
+		p.WeighIn = &models.WeighIn{ID: weighInID.UUID, UserID: p.UserID, WeighedAt: weighedAt.Time, Date: p.TakenOn,
+			WeightKg: weightKg.Float64, Note: weighInNote.String}
+	}
+	return p, nil
+}
+
+// loadProgressPhoto loads one of the user's photos
+func loadProgressPhoto(id, userID uuid.UUID) (models.ProgressPhoto, error) {
+	return scanProgressPhoto(config.DB.QueryRow(
+		`SELECT `+progressPhotoColumns+progressPhotoFrom+` WHERE p.id = $1 AND p.user_id = $2`,
+		id, userID,
+	))
+}
+
+// progressPhotoPath is where a photo's image, or its thumbnail, is kept. Each user has their own
+// directory and files are named by photo ID, never by anything the client sent.
+func progressPhotoPath(userID, id uuid.UUID, thumbnail bool) string {
+	name := id.String() + ".jpg"
+	if thumbnail {
+		name = id.String() + "_thumb.jpg"
+	}
+	return filepath.Join(config.AppConfig.UploadDir, "progress_photos", userID.String(), name)
+}
+
+// removeProgressPhotoFiles deletes a photo's files; a file already gone is not an error
+func removeProgressPhotoFiles(userID, id uuid.UUID) {
+	for _, thumbnail := range []bool{false, true} {
+		if err := os.Remove(progressPhotoPath(userID, id, thumbnail)); err != nil && !os.IsNotExist(err) {
+			log.Println("FILE DELETE ERROR (removeProgressPhotoFiles):", err)
+		}
+	}
+}
+
+// decodePhoto reads an uploaded JPEG or PNG and turns it upright. The pixels are all that is kept:
+// re-encoding drops EXIF metadata such as the camera's GPS position.
+func decodePhoto(data []byte) (image.Image, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || (format != "jpeg" && format != "png") {
+		return nil, fmt.Errorf("photo must be a JPEG or PNG image")
+	}
+	if cfg.Width*cfg.Height > maxPhotoPixels {
+		return nil, fmt.Errorf("photo must be at most %d megapixels", maxPhotoPixels/1_000_000)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("photo could not be read")
+	}
+	if format == "jpeg" {
+		img = utils.ApplyOrientation(img, utils.JPEGOrientation(data))
+	}
+	return img, nil
+}
+
+// encodeJPEG encodes img as a JPEG, putting transparent areas of a PNG on white
+func encodeJPEG(img image.Image) ([]byte, error) {
+	flat := image.NewRGBA(img.Bounds())
+	draw.Draw(flat, flat.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(flat, flat.Bounds(), img, img.Bounds().Min, draw.Over)
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, flat, &jpeg.Options{Quality: photoQuality}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeProgressPhotoFiles stores the image, scaled down to photoMaxSide, and its thumbnail.
+// It returns the stored image's size in bytes.
+func writeProgressPhotoFiles(userID, id uuid.UUID, img image.Image) (int, error) {
+	full, err := encodeJPEG(img)
+	if err != nil {
+		return 0, err
+	}
+	thumb, err := encodeJPEG(utils.Thumbnail(img, photoThumbSide))
+	if err != nil {
+		return 0, err
+	}
+	if err := os.MkdirAll(filepath.Dir(progressPhotoPath(userID, id, false)), 0o700); err != nil {
+		return 0, err
+	}
+	if err := os.WriteFile(progressPhotoPath(userID, id, false), full, 0o600); err != nil {
+		return 0, err
+	}
+	if err := os.WriteFile(progressPhotoPath(userID, id, true), thumb, 0o600); err != nil {
+		removeProgressPhotoFiles(userID, id)
+		return 0, err
+	}
+	return len(full), nil
+}
+
+// parsePhotoDate reads a YYYY-MM-DD day that isn't after today in the user's timezone; empty means today
+func parsePhotoDate(value, timezone string) (string, error) {
+	today := localToday(timezone)
+	if value == "" {
+		return today, nil
+	}
+	if _, err := time.Parse("2006-01-02", value); err != nil {
+		return "", fmt.Errorf("taken_on must be in YYYY-MM-DD format")
+	}
+	if value > today {
+		return "", fmt.Errorf("taken_on can't be in the future")
+	}
+	return value, nil
+}
+
+// UploadProgressPhoto handles POST /user/progress-photos (multipart: file, pose, optional taken_on
+// and note). The photo is stored upright with a thumbnail and linked to the same day's weigh-in.
+func UploadProgressPhoto(c *gin.Context) {
+	userID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	pose := c.PostForm("pose")
+	if !validPose(pose) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "pose must be one of " + strings.Join(progressPoses, ", ")})
+		return
+	}
+	takenOn, err := parsePhotoDate(c.PostForm("taken_on"), userTimezone(userID))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+		return
+	}
+	if header.Size > maxPhotoFileBytes {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "photo must be 15 MB or smaller"})
+		return
+	}
+	file, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "could not read file"})
+		return
+	}
+	data, err := io.ReadAll(io.LimitReader(file, maxPhotoFileBytes+1))
+	file.Close()
+	if err != nil || len(data) > maxPhotoFileBytes {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "could not read file"})
+		return
+	}
+
+	img, err := decodePhoto(data)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	img = utils.Thumbnail(img, photoMaxSide)
+
+	id := uuid.New()
+	size, err := writeProgressPhotoFiles(userID, id, img)
+	if err != nil {
+		log.Println("FILE WRITE ERROR (UploadProgressPhoto):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save photo"})
+		return
+	}
+	_, err = config.DB.Exec(
+		`INSERT INTO progress_photos (id, user_id, taken_on, pose, note, width, height, size_bytes)
+         VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		id, userID, takenOn, pose, strings.TrimSpace(c.PostForm("note")), img.Bounds().Dx(), img.Bounds().Dy(), size,
+	)
+	if err != nil {
+		removeProgressPhotoFiles(userID, id)
+		log.Println("DB INSERT ERROR (UploadProgressPhoto):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save photo"})
+		return
+	}
+
+	photo, err := loadProgressPhoto(id, userID)
+	if err != nil {
+		log.Println("DB SELECT ERROR (UploadProgressPhoto):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save photo"})
+		return
+	}
+	c.JSON(http.StatusCreated, photo)
+}
+
+// maxProgressPhotoRows caps one gallery listing
+const maxProgressPhotoRows = 500
+
+// poseOrderSQL sorts photos front, side, back
+const poseOrderSQL = `array_position(ARRAY['front', 'side', 'back'], p.pose)`
+
+// GetProgressPhotos handles GET /user/progress-photos?from=&to=&pose= (dates inclusive), newest day first
+func GetProgressPhotos(c *gin.Context) {
+	userID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+	from, to := c.DefaultQuery("from", "0001-01-01"), c.DefaultQuery("to", "9999-12-31")
+	for _, d := range []string{from, to} {
+		if _, err := time.Parse("2006-01-02", d); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from and to must be in YYYY-MM-DD format"})
+			return
+		}
+	}
+	query := `SELECT ` + progressPhotoColumns + progressPhotoFrom + ` WHERE p.user_id = $1 AND p.taken_on BETWEEN $2 AND $3`
+	args := []interface{}{userID, from, to}
+	if pose := c.Query("pose"); pose != "" {
+		if !validPose(pose) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "pose must be one of " + strings.Join(progressPoses, ", ")})
+			return
+		}
+		query += ` AND p.pose = $4`
+		args = append(args, pose)
+	}
+	query += fmt.Sprintf(` ORDER BY p.taken_on DESC, %s, p.created_at DESC LIMIT %d`, poseOrderSQL, maxProgressPhotoRows)
+
+	rows, err := config.DB.Query(query, args...)
+	if err != nil {
+		log.Println("DB SELECT ERROR (GetProgressPhotos):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch photos"})
+		return
+	}
+	defer rows.Close()
+
+	photos := []models.ProgressPhoto{}
+	for rows.Next() {
+		p, err := scanProgressPhoto(rows)
+		if err != nil {
+			log.Println("DB SCAN ERROR (GetProgressPhotos):", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch photos"})
+			return
+		}
+		photos = append(photos, p)
+	}
+	c.JSON(http.StatusOK, photos)
+}
+
+// progressPhotoRequestIDs parses the user and photo IDs, writing the error response when one is invalid
+func progressPhotoRequestIDs(c *gin.Context) (uuid.UUID, uuid.UUID, bool) {
+	userID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return uuid.Nil, uuid.Nil, false
+	}
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid photo ID"})
+		return uuid.Nil, uuid.Nil, false
+	}
+	return userID, id, true
+}
+
+// GetProgressPhotoImage handles GET /user/progress-photos/:id/image?size=thumbnail. Photos are only
+// ever served through here, to their owner.
+func GetProgressPhotoImage(c *gin.Context) {
+	userID, id, ok := progressPhotoRequestIDs(c)
+	if !ok {
+		return
+	}
+	size := c.DefaultQuery("size", "full")
+	if size != "full" && size != "thumbnail" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "size must be full or thumbnail"})
+		return
+	}
+
+	var exists bool
+	err := config.DB.QueryRow(
+		`SELECT EXISTS (SELECT 1 FROM progress_photos WHERE id = $1 AND user_id = $2)`, id, userID,
+	).Scan(&exists)
+	if err != nil {
+		log.Println("DB SELECT ERROR (GetProgressPhotoImage):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch photo"})
+		return
+	}
+	path := progressPhotoPath(userID, id, size == "thumbnail")
+	if _, err := os.Stat(path); !exists || err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Photo not found"})
+		return
+	}
+
+	c.Header("Cache-Control", "private, max-age=86400")
+	c.File(path)
+}
+
+// UpdateProgressPhoto handles PUT /user/progress-photos/:id to correct the pose, taken_on or note
+func UpdateProgressPhoto(c *gin.Context) {
+	userID, id, ok := progressPhotoRequestIDs(c)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Pose    *string `json:"pose"`
+		TakenOn *string `json:"taken_on"`
+		Note    *string `json:"note"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	photo, err := loadProgressPhoto(id, userID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Photo not found"})
+		return
+	}
+	if err != nil {
+		log.Println("DB SELECT ERROR (UpdateProgressPhoto):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update photo"})
+		return
+	}
+	if input.Pose != nil {
+		if !validPose(*input.Pose) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "pose must be one of " + strings.Join(progressPoses, ", ")})
+			return
+		}
+		photo.Pose = *input.Pose
+	}
+	if input.TakenOn != nil {
+		if photo.TakenOn, err = parsePhotoDate(*input.TakenOn, userTimezone(userID)); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if input.Note != nil {
+		photo.Note = strings.TrimSpace(*input.Note)
+	}
+
+	_, err = config.DB.Exec(
+		`UPDATE progress_photos SET pose = $1, taken_on = $2, note = $3 WHERE id = $4 AND user_id = $5`,
+		photo.Pose, photo.TakenOn, photo.Note, id, userID,
+	)
+	if err == nil {
+		photo, err = loadProgressPhoto(id, userID)
+	}
+	if err != nil {
+		log.Println("DB UPDATE ERROR (UpdateProgressPhoto):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update photo"})
+		return
+	}
+	c.JSON(http.StatusOK, photo)
+}
+
+// DeleteProgressPhoto handles DELETE /user/progress-photos/:id, removing the files with the row
+func DeleteProgressPhoto(c *gin.Context) {
+	userID, id, ok := progressPhotoRequestIDs(c)
+	if !ok {
+		return
+	}
+
+	var deleted uuid.UUID
+	err := config.DB.QueryRow(`DELETE FROM progress_photos WHERE id = $1 AND user_id = $2 RETURNING id`, id, userID).Scan(&deleted)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Photo not found"})
+		return
+	}
+	if err != nil {
+		log.Println("DB DELETE ERROR (DeleteProgressPhoto):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete photo"})
+		return
+	}
+	removeProgressPhotoFiles(userID, id)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Photo deleted successfully"})
+}
+
+// measurementDelta compares one measured value between the two days of a comparison
+type measurementDelta struct {
+	Field  string         `json:"field"`
+	From   *measuredValue `json:"from"`
+	To     *measuredValue `json:"to"`
+	Change *float64       `json:"change"` // to minus from, when both are known
+}
+
+// photosOnDay returns the user's newest photo of each pose taken on date
+func photosOnDay(userID uuid.UUID, date, pose string) (map[string]*models.ProgressPhoto, error) {
+	rows, err := config.DB.Query(
+		`SELECT DISTINCT ON (p.pose) `+progressPhotoColumns+progressPhotoFrom+`
+         WHERE p.user_id = $1 AND p.taken_on = $2 AND ($3 = '' OR p.pose = $3)
+         ORDER BY p.pose, p.created_at DESC`,
+		userID, date, pose,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	photos := map[string]*models.ProgressPhoto{}
+	for rows.Next() {
+		p, err := scanProgressPhoto(rows)
+		if err != nil {
+			return nil, err
+		}
+		photos[p.Pose] = &p
+	}
+	return photos, rows.Err()
+}
+
+// CompareProgressPhotos handles GET /user/progress-photos/compare?from=&to=&pose= for a side-by-side
+// view: each pose's photo on the two days, and how every measurement changed between them. A
+// measurement not taken on a day uses the newest value before it, with the day it was measured.
+func CompareProgressPhotos(c *gin.Context) {
+	userID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+	from, to, pose := c.Query("from"), c.Query("to"), c.Query("pose")
+	for _, d := range []string{from, to} {
+		if _, err := time.Parse("2006-01-02", d); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from and to are required in YYYY-MM-DD format"})
+			return
+		}
+	}
+	if pose != "" && !validPose(pose) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "pose must be one of " + strings.Join(progressPoses, ", ")})
+		return
+	}
+
+	var fromPhotos, toPhotos map[string]*models.ProgressPhoto
+	var fromValues, toValues map[string]*measuredValue
+	if fromPhotos, err = photosOnDay(userID, from, pose); err == nil {
+		if toPhotos, err = photosOnDay(userID, to, pose); err == nil {
+			if fromValues, err = measurementsAsOf(userID, from); err == nil {
+				toValues, err = measurementsAsOf(userID, to)
+			}
+		}
+	}
+	if err != nil {
+		log.Println("DB SELECT ERROR (CompareProgressPhotos):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compare photos"})
+		return
+	}
+
+	pairs := []gin.H{}
+	for _, p := range progressPoses {
+		if fromPhotos[p] != nil || toPhotos[p] != nil {
+			pairs = append(pairs, gin.H{"pose": p, "from": fromPhotos[p], "to": toPhotos[p]})
+		}
+	}
+
+	deltas := []measurementDelta{}
+	for _, f := range measurementFields {
+		d := measurementDelta{Field: f.Key, From: fromValues[f.Key], To: toValues[f.Key]}
+		if d.From == nil && d.To == nil {
+			continue
+		}
+		if d.From != nil && d.To != nil {
+			change := round2(d.To.Value - d.From.Value)
+			d.Change = &change
+		}
+		deltas = append(deltas, d)
+	}
+
+	fromDay, _ := time.Parse("2006-01-02", from)
+	toDay, _ := time.Parse("2006-01-02", to)
+	c.JSON(http.StatusOK, gin.H{
+		"from":         from,
+		"to":           to,
+		"days_between": int(toDay.Sub(fromDay).Hours() / 24),
+		"photos":       pairs,
+		"measurements": deltas,
+	})
+}
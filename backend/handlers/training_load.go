@@ -0,0 +1,381 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"nutritionix/backend/config"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Training load thresholds. An acute:chronic workload ratio above 1.5 is the "danger zone" in
+// Gabbett's work on load spikes; Foster flags a weekly monotony above 2.0.
+const (
+	acwrSpikeThreshold   = 1.5
+	monotonyThreshold    = 2.0
+	chronicLoadWeeks     = 4
+	defaultTrainingWeeks = 12
+)
+
+// intensityRPE estimates a session RPE from the catalog intensity when the user didn't rate the session
+var intensityRPE = map[string]int{"light": 3, "moderate": 5, "vigorous": 7}
+
+// trainingSession is a completed workout as seen by the load calculations
+type trainingSession struct {
+	Date        string
+	Type        string
+	Minutes     int
+	RPE         int  // 0 when the session couldn't be rated
+	RPEEstimate bool // RPE came from logged sets or the catalog intensity, not the user's session rating
+}
+
+// Load is Foster's session RPE load: RPE × minutes, in arbitrary units
+func (s trainingSession) Load() float64 {
+	return float64(s.RPE * s.Minutes)
+}
+
+// loadTrainingSessions reads the user's completed workouts dated from..to.
+// Unrated sessions fall back to the average RPE of their working sets, then to the catalog intensity.
+func loadTrainingSessions(userID uuid.UUID, from, to string) ([]trainingSession, error) {
+	rows, err := config.DB.Query(
+		`SELECT w.date::text, COALESCE(w.type, ''), COALESCE(w.actual_duration_min, w.duration_minutes),
+                w.session_rpe,
+                (SELECT AVG(ws.rpe) FROM workout_exercises we JOIN workout_sets ws ON ws.workout_exercise_id = we.id
+                 WHERE we.workout_id = w.id AND NOT ws.is_warmup AND ws.rpe IS NOT NULL),
+                COALESCE(ec.intensity, '')
+         FROM workouts w
+         LEFT JOIN exercise_catalog ec ON ec.id = w.exercise_id
+         WHERE w.user_id = $1 AND w.status = 'completed' AND w.date BETWEEN $2 AND $3
+         ORDER BY w.date`,
+		userID, from, to,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []trainingSession
+	for rows.Next() {
+		var s trainingSession
+		var sessionRPE sql.NullInt64
+		var setRPE sql.NullFloat64
+		var intensity string
+		if err := rows.Scan(&s.Date, &s.Type, &s.Minutes, &sessionRPE, &setRPE, &intensity); err != nil {
+			return nil, err
+		}
+		switch {
+		case sessionRPE.Valid:
+			s.RPE = int(sessionRPE.Int64)
+		case setRPE.Valid:
+			s.RPE, s.RPEEstimate = int(math.Round(setRPE.Float64)), true
+		default:
+			s.RPE, s.RPEEstimate = intensityRPE[intensity], true
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions, rows.Err()
+}
+
+// dailyLoads sums session loads per date
+func dailyLoads(sessions []trainingSession) map[string]float64 {
+	loads := map[string]float64{}
+	for _, s := range sessions {
+		loads[s.Date] += s.Load()
+	}
+	return loads
+}
+
+// windowLoad is the total load of the days days ending on end
+func windowLoad(loads map[string]float64, end time.Time, days int) float64 {
+	total := 0.0
+	for i := 0; i < days; i++ {
+		total += loads[end.AddDate(0, 0, -i).Format("2006-01-02")]
+	}
+	return total
+}
+
+// weekMonotony is Foster's monotony for the seven days ending on end: mean daily load over its
+// standard deviation. It is undefined for a week without load or with identical loads every day.
+func weekMonotony(loads map[string]float64, end time.Time) *float64 {
+	var daily [7]float64
+	mean := 0.0
+	for i := range daily {
+		daily[i] = loads[end.AddDate(0, 0, -i).Format("2006-01-02")]
+		mean += daily[i] / 7
+	}
+	variance := 0.0
+	for _, d := range daily {
+		variance += (d - mean) * (d - mean) / 7
+	}
+	if mean == 0 || variance == 0 {
+		return nil
+	}
+	m := round2(mean / math.Sqrt(variance))
+	return &m
+}
+
+// acuteChronicRatio compares the load of the week ending on end with the average weekly load of
+// the four weeks ending on end. It needs four weeks of history to mean anything.
+func acuteChronicRatio(loads map[string]float64, end time.Time, firstSession string) *float64 {
+	windowStart := end.AddDate(0, 0, -7*chronicLoadWeeks+1).Format("2006-01-02")
+	if firstSession == "" || firstSession > windowStart {
+		return nil
+	}
+	chronic := windowLoad(loads, end, 7*chronicLoadWeeks) / chronicLoadWeeks
+	if chronic == 0 {
+		return nil
+	}
+	ratio := round2(windowLoad(loads, end, 7) / chronic)
+	return &ratio
+}
+
+// firstCompletedWorkout is the date of the user's earliest completed workout, "" when there is none
+func firstCompletedWorkout(userID uuid.UUID) (string, error) {
+	var first sql.NullString
+	err := config.DB.QueryRow(
+		`SELECT MIN(date)::text FROM workouts WHERE user_id = $1 AND status = 'completed'`, userID,
+	).Scan(&first)
+	return first.String, err
+}
+
+// weekLoad is one calendar week (Monday to Sunday) of GetTrainingLoad
+type weekLoad struct {
+	WeekStart         string                   `json:"week_start"`
+	Sessions          int                      `json:"sessions"`
+	Minutes           int                      `json:"minutes"`
+	Load              float64                  `json:"load"` // session RPE × minutes, summed
+	EstimatedSessions int                      `json:"estimated_rpe_sessions"`
+	UnratedSessions   int                      `json:"unrated_sessions"` // sessions left out of the load
+	ACWR              *float64                 `json:"acwr"`
+	Monotony          *float64                 `json:"monotony"`
+	Strain            *float64                 `json:"strain"` // load × monotony
+	Flags             []string                 `json:"flags"`
+	ByType            map[string]*typeVolume   `json:"by_type"`
+	ByMuscleGroup     map[string]*muscleVolume `json:"by_muscle_group"`
+}
+
+type typeVolume struct {
+	Sessions int     `json:"sessions"`
+	Minutes  int     `json:"minutes"`
+	Load     float64 `json:"load"`
+}
+
+type muscleVolume struct {
+	Sets     int     `json:"sets"`
+	Reps     int     `json:"reps"`
+	VolumeKg float64 `json:"volume_kg"`
+}
+
+// GetTrainingLoad handles GET /user/training-load?weeks=
+// Per calendar week it reports volume by workout type and muscle group, session RPE load,
+// the acute:chronic workload ratio, and Foster's monotony and strain, flagging risky weeks.
+func GetTrainingLoad(c *gin.Context) {
+	userID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+	weeks := defaultTrainingWeeks
+	if w := c.Query("weeks"); w != "" {
+		weeks, err = strconv.Atoi(w)
+		if err != nil || weeks < 1 || weeks > 52 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "weeks must be between 1 and 52"})
+			return
+		}
+	}
+
+	today, _ := time.Parse("2006-01-02", localToday(userTimezone(userID)))
+	thisMonday := today.AddDate(0, 0, -((int(today.Weekday()) + 6) % 7))
+	firstMonday := thisMonday.AddDate(0, 0, -7*(weeks-1))
+	// The chronic load of the first reported week reaches back three more weeks
+	loadFrom := firstMonday.AddDate(0, 0, -7*(chronicLoadWeeks-1)).Format("2006-01-02")
+	to := thisMonday.AddDate(0, 0, 6).Format("2006-01-02")
+
+	sessions, err := loadTrainingSessions(userID, loadFrom, to)
+	if err != nil {
+		log.Println("DB SELECT ERROR (GetTrainingLoad):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute training load"})
+		return
+	}
+	firstSession, err := firstCompletedWorkout(userID)
+	if err != nil {
+		log.Println("DB SELECT ERROR (GetTrainingLoad):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute training load"})
+		return
+	}
+	loads := dailyLoads(sessions)
+
+	result := make([]*weekLoad, weeks)
+	byStart := map[string]*weekLoad{}
+	for i := range result {
+		start := firstMonday.AddDate(0, 0, 7*i)
+		end := start.AddDate(0, 0, 6)
+		wk := &weekLoad{
+			WeekStart:     start.Format("2006-01-02"),
+			Flags:         []string{},
+			ByType:        map[string]*typeVolume{},
+			ByMuscleGroup: map[string]*muscleVolume{},
+			Load:          windowLoad(loads, end, 7),
+			Monotony:      weekMonotony(loads, end),
+		}
+		// The current week isn't over, so its ratio would understate the load
+		if !end.After(today) {
+			wk.ACWR = acuteChronicRatio(loads, end, firstSession)
+		}
+		if wk.Monotony != nil {
+			strain := round2(wk.Load * *wk.Monotony)
+			wk.Strain = &strain
+		}
+		if wk.ACWR != nil && *wk.ACWR > acwrSpikeThreshold {
+			wk.Flags = append(wk.Flags, "load_spike")
+		}
+		if wk.Monotony != nil && *wk.Monotony > monotonyThreshold {
+			wk.Flags = append(wk.Flags, "high_monotony")
+		}
+		result[i] = wk
+		byStart[wk.WeekStart] = wk
+	}
+
+	for _, s := range sessions {
+		d, _ := time.Parse("2006-01-02", s.Date)
+		wk := byStart[d.AddDate(0, 0, -((int(d.Weekday())+6)%7)).Format("2006-01-02")]
+		if wk == nil {
+			continue // warm-up weeks for the chronic load
+		}
+		wk.Sessions++
+		wk.Minutes += s.Minutes
+		switch {
+		case s.RPE == 0:
+			wk.UnratedSessions++
+		case s.RPEEstimate:
+			wk.EstimatedSessions++
+		}
+		t := wk.ByType[s.Type]
+		if t == nil {
+			t = &typeVolume{}
+			wk.ByType[s.Type] = t
+		}
+		t.Sessions++
+		t.Minutes += s.Minutes
+		t.Load += s.Load()
+	}
+
+	rows, err := config.DB.Query(
+		`SELECT date_trunc('week', w.date)::date::text, COALESCE(ec.muscle_group, 'other'),
+                COUNT(*), SUM(ws.reps), COALESCE(SUM(ws.reps * ws.weight_kg), 0)
+         FROM workouts w
+         JOIN workout_exercises we ON we.workout_id = w.id
+         JOIN workout_sets ws ON ws.workout_exercise_id = we.id
+         LEFT JOIN exercise_catalog ec ON ec.id = we.exercise_id
+         WHERE w.user_id = $1 AND w.status = 'completed' AND NOT ws.is_warmup
+           AND w.date BETWEEN $2 AND $3
+         GROUP BY 1, 2`,
+		userID, firstMonday.Format("2006-01-02"), to,
+	)
+	if err != nil {
+		log.Println("DB SELECT ERROR (GetTrainingLoad):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute training load"})
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var weekStart, group string
+		var v muscleVolume
+		if err := rows.Scan(&weekStart, &group, &v.Sets, &v.Reps, &v.VolumeKg); err != nil {
+			log.Println("DB SCAN ERROR (GetTrainingLoad):", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute training load"})
+			return
+		}
+		if wk := byStart[weekStart]; wk != nil {
+			v.VolumeKg = round2(v.VolumeKg)
+			wk.ByMuscleGroup[group] = &v
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"weeks": result,
+		"thresholds": gin.H{
+			"acwr_spike": acwrSpikeThreshold,
+			"monotony":   monotonyThreshold,
+		},
+	})
+}
+
+// RunTrainingLoadAlerts warns users whose last seven days of training load spiked against their
+// four-week average, or were unusually monotonous. Each user gets at most one warning a week.
+func RunTrainingLoadAlerts() {
+	log.Println("📢 Running training load alerts job...")
+	rows, err := config.DB.Query(
+		`SELECT DISTINCT w.user_id, u.timezone
+         FROM workouts w JOIN users u ON u.id = w.user_id
+         WHERE w.status = 'completed' AND w.date > (NOW() AT TIME ZONE u.timezone)::date - 7`,
+	)
+	if err != nil {
+		log.Println("DB SELECT ERROR (RunTrainingLoadAlerts):", err)
+		return
+	}
+	type candidate struct {
+		UserID   uuid.UUID
+		Timezone string
+	}
+	var users []candidate
+	for rows.Next() {
+		var u candidate
+		if err := rows.Scan(&u.UserID, &u.Timezone); err != nil {
+			log.Println("DB SCAN ERROR (RunTrainingLoadAlerts):", err)
+			continue
+		}
+		users = append(users, u)
+	}
+	rows.Close()
+
+	for _, u := range users {
+		today, _ := time.Parse("2006-01-02", localToday(u.Timezone))
+		from := today.AddDate(0, 0, -7*chronicLoadWeeks+1).Format("2006-01-02")
+		sessions, err := loadTrainingSessions(u.UserID, from, today.Format("2006-01-02"))
+		if err != nil {
+			log.Println("DB SELECT ERROR (RunTrainingLoadAlerts):", err)
+			continue
+		}
+		firstSession, err := firstCompletedWorkout(u.UserID)
+		if err != nil {
+			log.Println("DB SELECT ERROR (RunTrainingLoadAlerts):", err)
+			continue
+		}
+		loads := dailyLoads(sessions)
+
+		var msg string
+		if ratio := acuteChronicRatio(loads, today, firstSession); ratio != nil && *ratio > acwrSpikeThreshold {
+			msg = fmt.Sprintf("⚠️ Training load spike: your last 7 days were %.1f× your 4-week average. Ease off for a day or two to lower your injury risk.", *ratio)
+		} else if m := weekMonotony(loads, today); m != nil && *m > monotonyThreshold {
+			msg = "⚠️ Training load: your sessions this week have been very similar in load. Mixing in lighter and rest days helps you recover."
+		}
+		if msg == "" || hasRecentTrainingLoadAlert(u.UserID) {
+			continue
+		}
+		_ = CreateNotification(u.UserID, nil, msg)
+	}
+}
+
+// hasRecentTrainingLoadAlert reports whether the user was warned about training load in the last week
+func hasRecentTrainingLoadAlert(userID uuid.UUID) bool {
+	var exists bool
+	err := config.DB.QueryRow(
+		`SELECT EXISTS (SELECT 1 FROM notifications
+                        WHERE user_id = $1 AND goal_id IS NULL AND message LIKE '⚠️ Training load%'
+                          AND created_at >= NOW() - INTERVAL '6 days')`,
+		userID,
+	).Scan(&exists)
+	if err != nil {
+		log.Println("DB SELECT ERROR (hasRecentTrainingLoadAlert):", err)
+		return true
+	}
+	return exists
+}
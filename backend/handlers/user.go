@@ -2,14 +2,17 @@ package handlers
 
 import (
 	"database/sql"
+	"log"
 	"net/http"
 	"time"
 
 	"nutritionix/backend/config"
+	"nutritionix/backend/models"
 	"nutritionix/backend/utils"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 // GetProfile returns the current user's profile
@@ -22,22 +25,32 @@ func GetProfile(c *gin.Context) {
 	}
 
 	var user struct {
-		ID        uuid.UUID     `json:"id"`
-		Email     string        `json:"email"`
-		Name      string        `json:"name"`
-		Role      string        `json:"role"`
-		Age       sql.NullInt64 `json:"age"`
-		Height    sql.NullInt64 `json:"height"`
-		Weight    sql.NullInt64 `json:"weight"`
-		CreatedAt time.Time     `json:"-"`
+		ID        uuid.UUID       `json:"id"`
+		Email     string          `json:"email"`
+		Name      string          `json:"name"`
+		Role      string          `json:"role"`
+		Age       sql.NullInt64   `json:"age"`
+		Height    sql.NullInt64   `json:"height"`
+		Weight    sql.NullFloat64 `json:"weight"`
+		CreatedAt time.Time       `json:"-"`
+
+		DietaryRestrictions []string        `json:"dietary_restrictions"`
+		Allergens           []string        `json:"allergens"`
+		Timezone            string          `json:"timezone"`
+		Sex                 string          `json:"sex"`
+		MaxHR               sql.NullInt64   `json:"max_hr"`
+		RestingHR           sql.NullInt64   `json:"resting_hr"`
+		BodyFatPct          sql.NullFloat64 `json:"body_fat_pct"`
 	}
 
 	err = config.DB.QueryRow(
-		`SELECT id, email, name, role, age, height, weight, created_at 
+		`SELECT id, email, name, role, age, height, weight, created_at, dietary_restrictions, allergens, timezone, COALESCE(sex, ''), max_hr, resting_hr, body_fat_pct
          FROM users 
          WHERE id=$1`,
 		userID,
-	).Scan(&user.ID, &user.Email, &user.Name, &user.Role, &user.Age, &user.Height, &user.Weight, &user.CreatedAt)
+	).Scan(&user.ID, &user.Email, &user.Name, &user.Role, &user.Age, &user.Height, &user.Weight, &user.CreatedAt,
+		pq.Array(&user.DietaryRestrictions), pq.Array(&user.Allergens), &user.Timezone, &user.Sex,
+		&user.MaxHR, &user.RestingHR, &user.BodyFatPct)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -54,6 +67,21 @@ func GetProfile(c *gin.Context) {
 		"name":       user.Name,
 		"role":       user.Role,
 		"created_at": user.CreatedAt.Format(time.RFC3339),
+
+		"dietary_restrictions": user.DietaryRestrictions,
+		"allergens":            user.Allergens,
+		"timezone":             user.Timezone,
+		"sex":                  nil,
+	}
+	if user.Sex != "" {
+		resp["sex"] = user.Sex
+	}
+	resp["max_hr"], resp["resting_hr"] = nil, nil
+	if user.MaxHR.Valid {
+		resp["max_hr"] = user.MaxHR.Int64
+	}
+	if user.RestingHR.Valid {
+		resp["resting_hr"] = user.RestingHR.Int64
 	}
 
 	// Handle nullable int64 fields for JSON response
@@ -68,10 +96,15 @@ func GetProfile(c *gin.Context) {
 		resp["height"] = nil
 	}
 	if user.Weight.Valid {
-		resp["weight"] = user.Weight.Int64
+		resp["weight"] = user.Weight.Float64
 	} else {
 		resp["weight"] = nil
 	}
+	if user.BodyFatPct.Valid {
+		resp["body_fat_pct"] = user.BodyFatPct.Float64
+	} else {
+		resp["body_fat_pct"] = nil
+	}
 
 	utils.JSONResponse(c, http.StatusOK, resp)
 }
@@ -86,10 +119,17 @@ func UpdateProfile(c *gin.Context) {
 	}
 
 	var req struct {
-		Name   string `json:"name"`
-		Age    *int64 `json:"age"`
-		Height *int64 `json:"height"`
-		Weight *int64 `json:"weight"`
+		Name   string   `json:"name"`
+		Age    *int64   `json:"age"`
+		Height *int64   `json:"height"`
+		Weight *float64 `json:"weight"` // kg; a change is also logged as a body measurement
+
+		DietaryRestrictions *[]string `json:"dietary_restrictions"`
+		Allergens           *[]string `json:"allergens"`
+		Timezone            *string   `json:"timezone"`   // IANA name, e.g. Europe/Berlin
+		Sex                 *string   `json:"sex"`        // male or female, used for BMR; "" clears it
+		MaxHR               *int64    `json:"max_hr"`     // bpm for heart rate zones; 0 clears it
+		RestingHR           *int64    `json:"resting_hr"` // bpm for heart rate zones; 0 clears it
 	}
 	if !utils.BindJSON(c, &req) {
 		return
@@ -100,9 +140,68 @@ func UpdateProfile(c *gin.Context) {
 		return
 	}
 
+	// Omitted restriction lists keep their stored values; an empty list clears them
+	var restrictions, allergens interface{}
+	if req.DietaryRestrictions != nil {
+		normalized, err := normalizeTags("dietary_restrictions", *req.DietaryRestrictions, validDietaryRestrictions)
+		if err != nil {
+			utils.JSONError(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		restrictions = pq.Array(normalized)
+	}
+	if req.Allergens != nil {
+		normalized, err := normalizeTags("allergens", *req.Allergens, validAllergens)
+		if err != nil {
+			utils.JSONError(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		allergens = pq.Array(normalized)
+	}
+
+	if req.Timezone != nil {
+		if _, err := loadTimezone(*req.Timezone); err != nil {
+			utils.JSONError(c, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	if req.Sex != nil && *req.Sex != "" && *req.Sex != "male" && *req.Sex != "female" {
+		utils.JSONError(c, http.StatusBadRequest, "sex must be male or female")
+		return
+	}
+
+	if req.Weight != nil {
+		if *req.Weight < 20 || *req.Weight > 500 {
+			utils.JSONError(c, http.StatusBadRequest, "weight must be between 20 and 500")
+			return
+		}
+		rounded := round2(*req.Weight)
+		req.Weight = &rounded
+	}
+
+	if req.MaxHR != nil && *req.MaxHR != 0 && (*req.MaxHR < 100 || *req.MaxHR > 230) {
+		utils.JSONError(c, http.StatusBadRequest, "max_hr must be between 100 and 230")
+		return
+	}
+	if req.RestingHR != nil && *req.RestingHR != 0 && (*req.RestingHR < 25 || *req.RestingHR > 120) {
+		utils.JSONError(c, http.StatusBadRequest, "resting_hr must be between 25 and 120")
+		return
+	}
+
+	var previousWeight sql.NullFloat64
+	_ = config.DB.QueryRow(`SELECT weight FROM users WHERE id=$1`, userID).Scan(&previousWeight)
+
 	res, err := config.DB.Exec(
-		`UPDATE users SET name=$1, age=$2, height=$3, weight=$4 WHERE id=$5`,
-		req.Name, req.Age, req.Height, req.Weight, userID,
+		`UPDATE users SET name=$1, age=$2, height=$3, weight=COALESCE($4, weight),
+		 dietary_restrictions=COALESCE($5, dietary_restrictions), allergens=COALESCE($6, allergens),
+		 timezone=COALESCE($7, timezone),
+		 sex=CASE WHEN $8::text IS NULL THEN sex ELSE NULLIF($8, '') END,
+		 max_hr=CASE WHEN $9::smallint IS NULL THEN max_hr ELSE NULLIF($9, 0) END,
+		 resting_hr=CASE WHEN $10::smallint IS NULL THEN resting_hr ELSE NULLIF($10, 0) END
+		 WHERE id=$11`,
+		req.Name, req.Age, req.Height, req.Weight, restrictions, allergens, req.Timezone, req.Sex,
+		req.MaxHR, req.RestingHR, userID,
 	)
 	if err != nil {
 		utils.JSONError(c, http.StatusInternalServerError, err.Error())
@@ -115,22 +214,40 @@ func UpdateProfile(c *gin.Context) {
 		return
 	}
 
+	// A weight typed into the profile goes into the measurement log, so it has a history
+	if req.Weight != nil && (!previousWeight.Valid || previousWeight.Float64 != *req.Weight) {
+		m := models.BodyMeasurement{UserID: userID, MeasuredAt: time.Now(), WeightKg: req.Weight, Note: "Profile update"}
+		if err := saveBodyMeasurement(&m); err != nil {
+			log.Println("DB INSERT ERROR (UpdateProfile):", err)
+		}
+	}
+
 	// Fetch updated user details after update
 	var user struct {
-		ID        uuid.UUID     `json:"id"`
-		Email     string        `json:"email"`
-		Name      string        `json:"name"`
-		Role      string        `json:"role"`
-		Age       sql.NullInt64 `json:"age"`
-		Height    sql.NullInt64 `json:"height"`
-		Weight    sql.NullInt64 `json:"weight"`
-		CreatedAt time.Time     `json:"-"`
+		ID        uuid.UUID       `json:"id"`
+		Email     string          `json:"email"`
+		Name      string          `json:"name"`
+		Role      string          `json:"role"`
+		Age       sql.NullInt64   `json:"age"`
+		Height    sql.NullInt64   `json:"height"`
+		Weight    sql.NullFloat64 `json:"weight"`
+		CreatedAt time.Time       `json:"-"`
+
+		DietaryRestrictions []string        `json:"dietary_restrictions"`
+		Allergens           []string        `json:"allergens"`
+		Timezone            string          `json:"timezone"`
+		Sex                 string          `json:"sex"`
+		MaxHR               sql.NullInt64   `json:"max_hr"`
+		RestingHR           sql.NullInt64   `json:"resting_hr"`
+		BodyFatPct          sql.NullFloat64 `json:"body_fat_pct"`
 	}
 
 	err = config.DB.QueryRow(
-		`SELECT id, email, name, role, age, height, weight, created_at FROM users WHERE id=$1`,
+		`SELECT id, email, name, role, age, height, weight, created_at, dietary_restrictions, allergens, timezone, COALESCE(sex, ''), max_hr, resting_hr, body_fat_pct FROM users WHERE id=$1`,
 		userID,
-	).Scan(&user.ID, &user.Email, &user.Name, &user.Role, &user.Age, &user.Height, &user.Weight, &user.CreatedAt)
+	).Scan(&user.ID, &user.Email, &user.Name, &user.Role, &user.Age, &user.Height, &user.Weight, &user.CreatedAt,
+		pq.Array(&user.DietaryRestrictions), pq.Array(&user.Allergens), &user.Timezone, &user.Sex,
+		&user.MaxHR, &user.RestingHR, &user.BodyFatPct)
 
 	if err != nil {
 		utils.JSONError(c, http.StatusInternalServerError, err.Error())
@@ -143,6 +260,21 @@ func UpdateProfile(c *gin.Context) {
 		"name":       user.Name,
 		"role":       user.Role,
 		"created_at": user.CreatedAt.Format(time.RFC3339),
+
+		"dietary_restrictions": user.DietaryRestrictions,
+		"allergens":            user.Allergens,
+		"timezone":             user.Timezone,
+		"sex":                  nil,
+	}
+	if user.Sex != "" {
+		resp["sex"] = user.Sex
+	}
+	resp["max_hr"], resp["resting_hr"] = nil, nil
+	if user.MaxHR.Valid {
+		resp["max_hr"] = user.MaxHR.Int64
+	}
+	if user.RestingHR.Valid {
+		resp["resting_hr"] = user.RestingHR.Int64
 	}
 
 	if user.Age.Valid {
@@ -156,10 +288,15 @@ func UpdateProfile(c *gin.Context) {
 		resp["height"] = nil
 	}
 	if user.Weight.Valid {
-		resp["weight"] = user.Weight.Int64
+		resp["weight"] = user.Weight.Float64
 	} else {
 		resp["weight"] = nil
 	}
+	if user.BodyFatPct.Valid {
+		resp["body_fat_pct"] = user.BodyFatPct.Float64
+	} else {
+		resp["body_fat_pct"] = nil
+	}
 
 	utils.JSONResponse(c, http.StatusOK, resp)
 }
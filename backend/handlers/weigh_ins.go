@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"nutritionix/backend/config"
+	"nutritionix/backend/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// CreateWeighIn handles POST /user/weigh-ins, a body measurement of just the weight
+func CreateWeighIn(c *gin.Context) {
+	userID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	var input struct {
+		WeightKg  float64 `json:"weight_kg"`
+		WeighedAt string  `json:"weighed_at"` // RFC 3339, defaults to now
+		Note      string  `json:"note"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.WeightKg < 20 || input.WeightKg > 500 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "weight_kg must be between 20 and 500"})
+		return
+	}
+	weighedAt, err := parseMeasuredAt("weighed_at", input.WeighedAt)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	weight := round2(input.WeightKg)
+	m := models.BodyMeasurement{UserID: userID, MeasuredAt: weighedAt, WeightKg: &weight, Note: strings.TrimSpace(input.Note)}
+	if err := saveBodyMeasurement(&m); err != nil {
+		log.Println("DB INSERT ERROR (CreateWeighIn):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save weigh-in"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.WeighIn{
+		ID: m.ID, UserID: userID, WeighedAt: m.MeasuredAt, Date: m.Date, WeightKg: weight, Note: m.Note, CreatedAt: m.CreatedAt,
+	})
+}
+
+// GetWeighIns handles GET /user/weigh-ins?from=&to= (dates, inclusive)
+func GetWeighIns(c *gin.Context) {
+	userID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+	from, to := c.DefaultQuery("from", "0001-01-01"), c.DefaultQuery("to", "9999-12-31")
+	for _, d := range []string{from, to} {
+		if _, err := time.Parse("2006-01-02", d); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from and to must be in YYYY-MM-DD format"})
+			return
+		}
+	}
+
+	weighIns, err := loadWeighIns(userID, from, to)
+	if err != nil {
+		log.Println("DB SELECT ERROR (GetWeighIns):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch weigh-ins"})
+		return
+	}
+	c.JSON(http.StatusOK, weighIns)
+}
+
+// loadWeighIns returns the measurements with a weight on local dates from..to, oldest first
+func loadWeighIns(userID uuid.UUID, from, to string) ([]models.WeighIn, error) {
+	rows, err := config.DB.Query(
+		`SELECT m.id, m.user_id, m.measured_at, (m.measured_at AT TIME ZONE u.timezone)::date::text, m.weight_kg, m.note, m.created_at
+         FROM body_measurements m JOIN users u ON u.id = m.user_id
+         WHERE m.user_id = $1 AND m.weight_kg IS NOT NULL AND (m.measured_at AT TIME ZONE u.timezone)::date BETWEEN $2 AND $3
+         ORDER BY m.measured_at`,
+		userID, from, to,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	weighIns := []models.WeighIn{}
+	for rows.Next() {
+		var w models.WeighIn
+		if err := rows.Scan(&w.ID, &w.UserID, &w.WeighedAt, &w.Date, &w.WeightKg, &w.Note, &w.CreatedAt); err != nil {
+			return nil, err
+		}
+		weighIns = append(weighIns, w)
+	}
+	return weighIns, rows.Err()
+}
+
+// DeleteWeighIn handles DELETE /user/weigh-ins/:id
+func DeleteWeighIn(c *gin.Context) {
+	userID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+	weighInID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid weigh-in ID"})
+		return
+	}
+
+	// A weigh-in taken with other measurements only loses its weight; weight_kg is the first field
+	var id uuid.UUID
+	err = config.DB.QueryRow(
+		`WITH cleared AS (
+             UPDATE body_measurements SET weight_kg = NULL, updated_at = NOW()
+             WHERE id = $1 AND user_id = $2 AND weight_kg IS NOT NULL
+               AND num_nonnulls(`+strings.Join(measurementFieldKeys()[1:], ", ")+`) > 0
+             RETURNING id
+         ), deleted AS (
+             DELETE FROM body_measurements
+             WHERE id = $1 AND user_id = $2 AND weight_kg IS NOT NULL AND id NOT IN (SELECT id FROM cleared)
+             RETURNING id
+         )
+         SELECT id FROM cleared UNION ALL SELECT id FROM deleted`,
+		weighInID, userID,
+	).Scan(&id)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Weigh-in not found"})
+		return
+	}
+	if err != nil {
+		log.Println("DB DELETE ERROR (DeleteWeighIn):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete weigh-in"})
+		return
+	}
+	measurementsChanged(userID)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Weigh-in deleted successfully"})
+}
@@ -0,0 +1,294 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"time"
+
+	"nutritionix/backend/config"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	maxSafeWeeklyRate  = 0.01 // of body weight per week, the usual ceiling for safe loss or gain
+	projectionSlipDays = 14   // a projection this much later than the reference one is worth telling about
+	maxProjectionDays  = 3650
+)
+
+// Weight goal warnings
+const (
+	WeightWarnNotEnoughData  = "not_enough_data"
+	WeightWarnTrendAway      = "trend_away_from_target"
+	WeightWarnRequiredUnsafe = "required_rate_unsafe"
+	WeightWarnCurrentUnsafe  = "current_rate_unsafe"
+	WeightWarnDeadlinePassed = "target_date_passed"
+	WeightWarnStale          = "no_recent_weigh_ins"
+)
+
+// weightProjection is where a weight goal stands according to the weigh-in trend
+type weightProjection struct {
+	StartWeightKg         float64  `json:"start_weight_kg"`
+	TargetWeightKg        float64  `json:"target_weight_kg"`
+	TrendWeightKg         *float64 `json:"trend_weight_kg"` // the smoothed weight today, null without weigh-ins
+	RateKgPerWeek         *float64 `json:"rate_kg_per_week"`
+	ProjectedDate         *string  `json:"projected_date"` // when the trend reaches the target
+	TargetDate            *string  `json:"target_date"`
+	RequiredRateKgPerWeek *float64 `json:"required_rate_kg_per_week"` // to make target_date from here
+	ProgressPercent       int      `json:"progress_percent"`
+	Reached               bool     `json:"reached"`
+	LastWeighIn           *string  `json:"last_weigh_in"`
+	WeighInsUsed          int      `json:"weigh_ins_used"` // days weighed in the window the rate was measured over
+	Warnings              []string `json:"warnings"`
+}
+
+// projectWeightGoal reads the weight trend and projects when the target is reached at its current rate
+func projectWeightGoal(userID uuid.UUID, start, target float64, targetDate *string, today string) (weightProjection, error) {
+	p := weightProjection{StartWeightKg: start, TargetWeightKg: target, TargetDate: targetDate, Warnings: []string{}}
+
+	todayDate, _ := time.Parse("2006-01-02", today)
+	trend, err := loadWeightTrend(userID, today)
+	if err != nil {
+		return p, err
+	}
+	day, ok := trend.at(today)
+	if !ok {
+		p.Warnings = append(p.Warnings, WeightWarnNotEnoughData)
+		return p, nil
+	}
+	current := day.TrendWeightKg
+	p.TrendWeightKg = &current
+	last, _ := trend.lastWeighIn(today)
+	p.LastWeighIn = &last
+
+	if start == target {
+		p.ProgressPercent, p.Reached = 100, true
+	} else {
+		p.ProgressPercent = int(math.Round(math.Max(0, math.Min(100, (start-current)/(start-target)*100))))
+		p.Reached = (target < start && current <= target) || (target > start && current >= target)
+	}
+	remaining := target - current
+
+	rate, used := trend.weeklyRate(today)
+	p.WeighInsUsed = used
+	lastDay, _ := epochDay(last)
+	todayDay, _ := epochDay(today)
+	switch {
+	case todayDay-lastDay > weightTrendMaxGap:
+		// The trend holds still after the last weigh-in, so there's nothing to project from
+		p.Warnings = append(p.Warnings, WeightWarnStale)
+	case rate == nil:
+		p.Warnings = append(p.Warnings, WeightWarnNotEnoughData)
+	default:
+		p.RateKgPerWeek = rate
+		if math.Abs(*rate) > maxSafeWeeklyRate*current {
+			p.Warnings = append(p.Warnings, WeightWarnCurrentUnsafe)
+		}
+		if !p.Reached {
+			if slope := *rate / 7; slope != 0 && (remaining > 0) == (slope > 0) {
+				if days := remaining / slope; days <= maxProjectionDays {
+					projected := todayDate.AddDate(0, 0, int(math.Ceil(days))).Format("2006-01-02")
+					p.ProjectedDate = &projected
+				}
+			} else {
+				p.Warnings = append(p.Warnings, WeightWarnTrendAway)
+			}
+		}
+	}
+
+	if targetDate != nil && !p.Reached {
+		deadline, _ := time.Parse("2006-01-02", *targetDate)
+		if weeks := deadline.Sub(todayDate).Hours() / 24 / 7; weeks > 0 {
+			required := round2(remaining / weeks)
+			p.RequiredRateKgPerWeek = &required
+			if math.Abs(required) > maxSafeWeeklyRate*current {
+				p.Warnings = append(p.Warnings, WeightWarnRequiredUnsafe)
+			}
+		} else {
+			p.Warnings = append(p.Warnings, WeightWarnDeadlinePassed)
+		}
+	}
+	return p, nil
+}
+
+// currentTrendWeight is the starting point for a new weight goal: the weight trend, else the profile weight
+func currentTrendWeight(userID uuid.UUID) (float64, bool) {
+	today := localToday(userTimezone(userID))
+	trend, err := loadWeightTrend(userID, today)
+	if err != nil {
+		log.Println("DB SELECT ERROR (currentTrendWeight):", err)
+	}
+	if day, ok := trend.at(today); ok {
+		return day.TrendWeightKg, true
+	}
+	var weight sql.NullFloat64
+	if err := config.DB.QueryRow(`SELECT weight FROM users WHERE id = $1`, userID).Scan(&weight); err != nil {
+		log.Println("DB SELECT ERROR (currentTrendWeight):", err)
+	}
+	return weight.Float64, weight.Valid && weight.Float64 > 0
+}
+
+// validateWeightTarget checks the fields a weight goal needs
+func validateWeightTarget(targetKg float64, targetDate string, timezone string) (*string, error) {
+	if targetKg < 20 || targetKg > 500 {
+		return nil, fmt.Errorf("target_weight_kg must be between 20 and 500")
+	}
+	if targetDate == "" {
+		return nil, nil
+	}
+	if _, err := time.Parse("2006-01-02", targetDate); err != nil {
+		return nil, fmt.Errorf("target_date must be in YYYY-MM-DD format")
+	}
+	if targetDate <= localToday(timezone) {
+		return nil, fmt.Errorf("target_date must be in the future")
+	}
+	return &targetDate, nil
+}
+
+// refreshWeightGoals updates the progress and projection of the user's weight goals and notifies
+// when the goal is reached, gets close, or the projected date slips
+func refreshWeightGoals(userID uuid.UUID, today string) {
+	type weightGoal struct {
+		ID            uuid.UUID
+		Label         *string
+		Start, Target float64
+		TargetDate    *string
+		ProjectedDate sql.NullString
+		Reference     sql.NullString // projection slips are measured from
+		Progress      int
+		Completed     bool
+	}
+
+	rows, err := config.DB.Query(
+		`SELECT id, label, start_weight_kg, target_weight_kg, target_date::text, projected_date::text,
+                reference_projected_date::text, progress_value, is_completed
+         FROM user_goals WHERE user_id = $1 AND goal_type = 'weight' AND archived = false`,
+		userID,
+	)
+	if err != nil {
+		log.Println("DB SELECT ERROR (refreshWeightGoals):", err)
+		return
+	}
+	var goals []weightGoal
+	for rows.Next() {
+		var g weightGoal
+		if err := rows.Scan(&g.ID, &g.Label, &g.Start, &g.Target, &g.TargetDate, &g.ProjectedDate, &g.Reference, &g.Progress, &g.Completed); err != nil {
+			log.Println("DB SCAN ERROR (refreshWeightGoals):", err)
+			rows.Close()
+			return
+		}
+		goals = append(goals, g)
+	}
+	rows.Close()
+	if len(goals) == 0 {
+		return
+	}
+
+	for _, g := range goals {
+		p, err := projectWeightGoal(userID, g.Start, g.Target, g.TargetDate, today)
+		if err != nil {
+			log.Println("DB SELECT ERROR (refreshWeightGoals):", err)
+			continue
+		}
+		if p.TrendWeightKg == nil {
+			continue
+		}
+		_, err = config.DB.Exec(
+			`UPDATE user_goals SET progress_value = $1, is_completed = $2, projected_date = $3,
+                 reference_projected_date = COALESCE(reference_projected_date, $3), updated_at = NOW()
+             WHERE id = $4`,
+			p.ProgressPercent, p.Reached, p.ProjectedDate, g.ID,
+		)
+		if err != nil {
+			log.Println("DB UPDATE ERROR (refreshWeightGoals):", err)
+			continue
+		}
+
+		name := goalDisplayName("weight", g.Label)
+		notifyGoalProgress(userID, g.ID, name, false, 100, g.Progress, p.ProgressPercent, g.Completed, p.Reached)
+		if p.Reached {
+			continue
+		}
+		// Slips are measured from the reference projection rather than yesterday's, so small daily
+		// drifts add up; once one is announced the new date becomes the reference
+		var msg string
+		if p.ProjectedDate == nil {
+			if g.ProjectedDate.Valid && hasWarning(p.Warnings, WeightWarnTrendAway) {
+				msg = fmt.Sprintf("📉 Your goal '%s' is off track: your weight trend is moving away from the target.", name)
+			}
+		} else if g.Reference.Valid && slipped(g.Reference.String, *p.ProjectedDate) {
+			msg = fmt.Sprintf("📉 Your goal '%s' has slipped: it's now projected for %s instead of %s.", name, *p.ProjectedDate, g.Reference.String)
+			if _, err := config.DB.Exec(
+				`UPDATE user_goals SET reference_projected_date = $1 WHERE id = $2`, *p.ProjectedDate, g.ID,
+			); err != nil {
+				log.Println("DB UPDATE ERROR (refreshWeightGoals):", err)
+			}
+		}
+		if msg != "" && !HasRecentNotification(userID, g.ID, msg) {
+			_ = CreateNotification(userID, &g.ID, msg)
+		}
+	}
+}
+
+// slipped reports whether a projected date moved out by at least projectionSlipDays
+func slipped(before, after string) bool {
+	b, err1 := time.Parse("2006-01-02", before)
+	a, err2 := time.Parse("2006-01-02", after)
+	return err1 == nil && err2 == nil && a.Sub(b) >= projectionSlipDays*24*time.Hour
+}
+
+func hasWarning(warnings []string, warning string) bool {
+	for _, w := range warnings {
+		if w == warning {
+			return true
+		}
+	}
+	return false
+}
+
+// GetWeightGoalProjection handles GET /goals/:id/projection for weight goals
+func GetWeightGoalProjection(c *gin.Context) {
+	userID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	goalID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid goal ID"})
+		return
+	}
+
+	var goalType string
+	var start, target sql.NullFloat64
+	var targetDate *string
+	err = config.DB.QueryRow(
+		`SELECT goal_type, start_weight_kg, target_weight_kg, target_date::text FROM user_goals WHERE id = $1 AND user_id = $2`,
+		goalID, userID,
+	).Scan(&goalType, &start, &target, &targetDate)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Goal not found"})
+		return
+	}
+	if err != nil {
+		log.Println("DB SELECT ERROR (GetWeightGoalProjection):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to project goal"})
+		return
+	}
+	if goalType != "weight" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only weight goals have a projection"})
+		return
+	}
+
+	p, err := projectWeightGoal(userID, start.Float64, target.Float64, targetDate, localToday(userTimezone(userID)))
+	if err != nil {
+		log.Println("DB SELECT ERROR (GetWeightGoalProjection):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to project goal"})
+		return
+	}
+	c.JSON(http.StatusOK, p)
+}
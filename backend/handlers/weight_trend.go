@@ -0,0 +1,263 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"nutritionix/backend/models"
+	"nutritionix/backend/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	weightTrendAlpha        = 0.1 // weight of a daily weigh-in in the trend; about a ten-day memory
+	weightTrendMaxGap       = 28  // a month without weighing restarts the trend at the next weigh-in
+	weightRateWindow        = 14  // the weekly rate is measured over the trend's last two weeks
+	weightRateMinSpan       = 7   // and needs at least a week of it
+	weightRateMinReadings   = 4   // with this many weigh-ins
+	maxWeightTrendRangeDays = 731
+)
+
+// weightTrendDay is one day of the smoothed weight trend
+type weightTrendDay struct {
+	Date          string   `json:"date"`
+	ScaleWeightKg *float64 `json:"scale_weight_kg"` // average of the day's weigh-ins, null without any
+	TrendWeightKg float64  `json:"trend_weight_kg"` // the "true" weight with day-to-day water swings smoothed out
+	Interpolated  bool     `json:"interpolated"`    // no weigh-in that day
+}
+
+// weightTrend is the smoothed trend through a user's weigh-ins, one day per entry from the first
+// weigh-in. Goals, the energy balance report and calorie targets should read body weight from
+// here rather than from single scale readings.
+type weightTrend struct {
+	days []weightTrendDay
+}
+
+// epochDay numbers YYYY-MM-DD dates consecutively
+func epochDay(date string) (int, error) {
+	t, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return 0, err
+	}
+	return int(t.Unix() / 86400), nil
+}
+
+// buildWeightTrend smooths weigh-ins (oldest first) through the date through; nil without weigh-ins.
+// Several weigh-ins on one day count as their average.
+func buildWeightTrend(weighIns []models.WeighIn, through string) *weightTrend {
+	var readings []utils.DayValue
+	var counts []int
+	for _, w := range weighIns {
+		day, err := epochDay(w.Date)
+		if err != nil || w.Date > through {
+			continue
+		}
+		if n := len(readings); n > 0 && readings[n-1].Day == day {
+			readings[n-1].Value += w.WeightKg
+			counts[n-1]++
+			continue
+		}
+		readings = append(readings, utils.DayValue{Day: day, Value: w.WeightKg})
+		counts = append(counts, 1)
+	}
+	if len(readings) == 0 {
+		return nil
+	}
+	for i := range readings {
+		readings[i].Value /= float64(counts[i])
+	}
+
+	last, _ := epochDay(through)
+	points := utils.DailyEMA(readings, weightTrendAlpha, weightTrendMaxGap, last)
+	t := &weightTrend{days: make([]weightTrendDay, len(points))}
+	next := 0
+	for i, p := range points {
+		day := weightTrendDay{
+			Date:          time.Unix(int64(p.Day)*86400, 0).UTC().Format("2006-01-02"),
+			TrendWeightKg: round2(p.Trend),
+			Interpolated:  !p.Observed,
+		}
+		if p.Observed {
+			scale := round2(readings[next].Value)
+			day.ScaleWeightKg = &scale
+			next++
+		}
+		t.days[i] = day
+	}
+	return t
+}
+
+// loadWeightTrend builds the user's trend from all their weigh-ins through the date through
+func loadWeightTrend(userID uuid.UUID, through string) (*weightTrend, error) {
+	weighIns, err := loadWeighIns(userID, "0001-01-01", through)
+	if err != nil {
+		return nil, err
+	}
+	return buildWeightTrend(weighIns, through), nil
+}
+
+// index finds a date's entry; ok is false before the first weigh-in and after the trend's end
+func (t *weightTrend) index(date string) (int, bool) {
+	if t == nil {
+		return 0, false
+	}
+	day, err := epochDay(date)
+	if err != nil {
+		return 0, false
+	}
+	first, _ := epochDay(t.days[0].Date)
+	i := day - first
+	return i, i >= 0 && i < len(t.days)
+}
+
+// at returns the trend on a date
+func (t *weightTrend) at(date string) (weightTrendDay, bool) {
+	i, ok := t.index(date)
+	if !ok {
+		return weightTrendDay{}, false
+	}
+	return t.days[i], true
+}
+
+// weeklyRate is the trend's change in kg/week up to a date, measured over the weightRateWindow
+// days before the last weigh-in on or before it, and the number of days weighed in that window.
+// The rate is nil while the trend rests on too few weigh-ins to mean anything, or when the last
+// of them is so old (over weightTrendMaxGap days) that it says nothing about the date.
+func (t *weightTrend) weeklyRate(date string) (*float64, int) {
+	at, ok := t.index(date)
+	if !ok {
+		return nil, 0
+	}
+	end := at
+	for end > 0 && t.days[end].Interpolated {
+		end--
+	}
+	// The window starts at a weigh-in, not partway along a line interpolated across a gap
+	start := max(0, end-weightRateWindow)
+	for start < end && t.days[start].Interpolated {
+		start++
+	}
+	readings := 0
+	for _, d := range t.days[start : end+1] {
+		if !d.Interpolated {
+			readings++
+		}
+	}
+	if at-end > weightTrendMaxGap || end-start < weightRateMinSpan || readings < weightRateMinReadings {
+		return nil, readings
+	}
+	rate := round2((t.days[end].TrendWeightKg - t.days[start].TrendWeightKg) * 7 / float64(end-start))
+	return &rate, readings
+}
+
+// lastWeighIn is the date of the latest weigh-in on or before date
+func (t *weightTrend) lastWeighIn(date string) (string, bool) {
+	i, ok := t.index(date)
+	if !ok {
+		return "", false
+	}
+	for ; i >= 0; i-- {
+		if !t.days[i].Interpolated {
+			return t.days[i].Date, true
+		}
+	}
+	return "", false
+}
+
+// GetWeightTrend handles GET /user/weight-trend?from=&to= (dates inclusive, default the last 90
+// days): the smoothed trend line with the scale readings it came from, and the trend weight and
+// weekly rate as of to
+func GetWeightTrend(c *gin.Context) {
+	userID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+	today := localToday(userTimezone(userID))
+	to := c.DefaultQuery("to", today)
+	toDate, err := time.Parse("2006-01-02", to)
+	if err != nil || to > today {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "to must be a YYYY-MM-DD date no later than today"})
+		return
+	}
+	from := c.DefaultQuery("from", toDate.AddDate(0, 0, -89).Format("2006-01-02"))
+	fromDate, err := time.Parse("2006-01-02", from)
+	if err != nil || fromDate.After(toDate) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must be a YYYY-MM-DD date on or before to"})
+		return
+	}
+	if toDate.Sub(fromDate) >= maxWeightTrendRangeDays*24*time.Hour {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "the range can be at most 731 days"})
+		return
+	}
+
+	// The whole history is smoothed so the line already has its memory where the range starts
+	trend, err := loadWeightTrend(userID, to)
+	if err != nil {
+		log.Println("DB SELECT ERROR (GetWeightTrend):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute weight trend"})
+		return
+	}
+
+	days := []weightTrendDay{}
+	for d := fromDate; !d.After(toDate); d = d.AddDate(0, 0, 1) {
+		if day, ok := trend.at(d.Format("2006-01-02")); ok {
+			days = append(days, day)
+		}
+	}
+	var trendWeight *float64
+	if day, ok := trend.at(to); ok {
+		trendWeight = &day.TrendWeightKg
+	}
+	rate, _ := trend.weeklyRate(to)
+	c.JSON(http.StatusOK, gin.H{
+		"from":             from,
+		"to":               to,
+		"days":             days,
+		"trend_weight_kg":  trendWeight,
+		"rate_kg_per_week": rate,
+	})
+}
+
+// GetWeightTrendOn handles GET /user/weight-trend/:date: the trend ("true") weight on one day,
+// including days without a weigh-in, and the weekly rate then
+func GetWeightTrendOn(c *gin.Context) {
+	userID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+	date := c.Param("date")
+	if _, err := time.Parse("2006-01-02", date); err != nil || date > localToday(userTimezone(userID)) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "date must be a YYYY-MM-DD date no later than today"})
+		return
+	}
+
+	trend, err := loadWeightTrend(userID, date)
+	if err != nil {
+		log.Println("DB SELECT ERROR (GetWeightTrendOn):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute weight trend"})
+		return
+	}
+	day, ok := trend.at(date)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No weigh-ins on or before this date"})
+		return
+	}
+	last, _ := trend.lastWeighIn(date)
+	rate, _ := trend.weeklyRate(date)
+	lastDay, _ := epochDay(last)
+	dateDay, _ := epochDay(date)
+	c.JSON(http.StatusOK, gin.H{
+		"date":                date,
+		"trend_weight_kg":     day.TrendWeightKg,
+		"scale_weight_kg":     day.ScaleWeightKg,
+		"interpolated":        day.Interpolated,
+		"rate_kg_per_week":    rate,
+		"last_weigh_in":       last,
+		"days_since_weigh_in": dateDay - lastDay,
+	})
+}
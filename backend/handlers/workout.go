@@ -1,8 +1,12 @@
 package handlers
 
 import (
+	"database/sql"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"slices"
 	"strings"
 	"time"
 
@@ -13,6 +17,146 @@ import (
 	"github.com/google/uuid"
 )
 
+// Allowed values for workouts.type
+var validWorkoutTypes = map[string]bool{
+	"cardio":      true,
+	"strength":    true,
+	"flexibility": true,
+	"sports":      true,
+}
+
+// workoutTypeKeywords mirrors the name patterns migration 003 used to backfill types
+var workoutTypeKeywords = []struct {
+	Type     string
+	Keywords []string
+}{
+	{"cardio", []string{"cardio", "run", "jog", "walk", "bike", "cycle", "swim"}},
+	{"strength", []string{"strength", "bench", "squat", "deadlift", "press", "curl", "lift", "weight"}},
+	{"flexibility", []string{"flexibility", "yoga", "stretch", "pilate"}},
+	{"sports", []string{"sports", "basketball", "tennis", "soccer", "football"}},
+}
+
+// resolveWorkoutType validates an explicit type, or falls back to the catalog exercise's type,
+// then to the workout name, then to cardio like migration 003 does
+func resolveWorkoutType(workoutType string, exercise *models.Exercise, name string) (string, error) {
+	workoutType = strings.ToLower(strings.TrimSpace(workoutType))
+	if workoutType != "" {
+		if !validWorkoutTypes[workoutType] {
+			return "", fmt.Errorf("type must be cardio, strength, flexibility, or sports")
+		}
+		return workoutType, nil
+	}
+	if exercise != nil {
+		return exercise.Type, nil
+	}
+	lowerName := strings.ToLower(name)
+	for _, candidate := range workoutTypeKeywords {
+		for _, kw := range candidate.Keywords {
+			if strings.Contains(lowerName, kw) {
+				return candidate.Type, nil
+			}
+		}
+	}
+	return "cardio", nil
+}
+
+// validateStrengthFields checks weight and reps against the workouts column limits
+func validateStrengthFields(weight *float64, reps *int) error {
+	if weight != nil && (*weight < 0 || *weight > 999.99) {
+		return fmt.Errorf("weight must be between 0 and 999.99")
+	}
+	if reps != nil && (*reps < 0 || *reps > 10000) {
+		return fmt.Errorf("reps must be between 0 and 10000")
+	}
+	return nil
+}
+
+// validateSessionRPE checks a session RPE rating (Foster's 1-10 scale)
+func validateSessionRPE(rpe *int) error {
+	if rpe != nil && (*rpe < 1 || *rpe > 10) {
+		return fmt.Errorf("session_rpe must be between 1 and 10")
+	}
+	return nil
+}
+
+// userTimezone returns the user's IANA timezone, UTC when unset
+func userTimezone(userID uuid.UUID) string {
+	var tz string
+	if err := config.DB.QueryRow(`SELECT timezone FROM users WHERE id = $1`, userID).Scan(&tz); err != nil || tz == "" {
+		return "UTC"
+	}
+	return tz
+}
+
+var errInvalidTimezone = errors.New("timezone must be an IANA name such as Europe/Berlin")
+
+// loadTimezone checks a timezone name before it is stored. Dates are worked out both here and in
+// SQL (AT TIME ZONE), so the name must be one Postgres knows too; Go alone also accepts "Local" and
+// zones the database's tz data may lack. If the database can't be asked, Go's answer stands.
+func loadTimezone(name string) (*time.Location, error) {
+	if name == "" || name == "Local" {
+		return nil, errInvalidTimezone
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, errInvalidTimezone
+	}
+	var known bool
+	err = config.DB.QueryRow(`SELECT EXISTS (SELECT 1 FROM pg_timezone_names WHERE name = $1)`, name).Scan(&known)
+	if err != nil {
+		log.Println("DB SELECT ERROR (loadTimezone):", err)
+		return loc, nil
+	}
+	if !known {
+		return nil, errInvalidTimezone
+	}
+	return loc, nil
+}
+
+// resolveWorkoutSchedule works out a workout's calendar date, optional start timestamp and timezone.
+// A full scheduled_at timestamp wins; otherwise start_time ("HH:MM") is read on date in the timezone,
+// which defaults to the user's.
+func resolveWorkoutSchedule(userID uuid.UUID, date, startTime, scheduledAt, timezone string) (string, *time.Time, string, error) {
+	if timezone == "" {
+		timezone = userTimezone(userID)
+	}
+	loc, err := loadTimezone(timezone)
+	if err != nil {
+		return "", nil, "", err
+	}
+
+	if scheduledAt != "" {
+		t, err := time.Parse(time.RFC3339, scheduledAt)
+		if err != nil {
+			return "", nil, "", fmt.Errorf("scheduled_at must be an RFC 3339 timestamp")
+		}
+		return t.In(loc).Format("2006-01-02"), &t, timezone, nil
+	}
+
+	// Validate date format "YYYY-MM-DD"
+	day, err := time.ParseInLocation("2006-01-02", date, loc)
+	if err != nil {
+		return "", nil, "", fmt.Errorf("date must be in YYYY-MM-DD format")
+	}
+	if startTime == "" {
+		return date, nil, timezone, nil
+	}
+	clock, err := time.Parse("15:04", startTime)
+	if err != nil {
+		return "", nil, "", fmt.Errorf("start_time must be in HH:MM format")
+	}
+	t := time.Date(day.Year(), day.Month(), day.Day(), clock.Hour(), clock.Minute(), 0, 0, loc)
+	return date, &t, timezone, nil
+}
+
+// createdWorkoutResponse is a new workout plus the session posted with it
+type createdWorkoutResponse struct {
+	workoutResponse
+	Exercises       []models.WorkoutExercise `json:"exercises,omitempty"`
+	TotalVolumeKg   *float64                 `json:"total_volume_kg,omitempty"`
+	PersonalRecords []models.PersonalRecord  `json:"personal_records,omitempty"`
+}
+
 // CreateWorkout creates a new workout for the logged-in user
 func CreateWorkout(c *gin.Context) {
 	userIDStr := c.GetString("user_id")
@@ -23,118 +167,162 @@ func CreateWorkout(c *gin.Context) {
 	}
 
 	var input struct {
-		Name           string `json:"name"`
-		DurationMin    int    `json:"duration_min"`
-		CaloriesBurned int    `json:"calories_burned"`
-		Date           string `json:"date"`
+		Name           string   `json:"name"`
+		DurationMin    int      `json:"duration_min"`
+		CaloriesBurned int      `json:"calories_burned"`
+		Date           string   `json:"date"`
+		StartTime      string   `json:"start_time"`   // optional "HH:MM" on date
+		ScheduledAt    string   `json:"scheduled_at"` // optional RFC 3339 start, alternative to date + start_time
+		Timezone       string   `json:"timezone"`     // IANA name, defaults to the user's
+		ExerciseID     *int     `json:"exercise_id"`
+		Exercise       string   `json:"exercise"`  // catalog slug, alternative to exercise_id
+		Intensity      string   `json:"intensity"` // light, moderate, vigorous; used with exercise
+		Type           string   `json:"type"`      // cardio, strength, flexibility, sports
+		Weight         *float64 `json:"weight"`    // load for strength training
+		Reps           *int     `json:"reps"`
+		SessionRPE     *int     `json:"session_rpe"` // how hard the session felt, 1-10
+		Status         string   `json:"status"`      // planned or completed; inferred from the date when omitted
+
+		Exercises []exerciseInput `json:"exercises"` // optional structured session
 	}
 	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	if strings.TrimSpace(input.Name) == "" || input.DurationMin < 0 || input.CaloriesBurned < 0 || input.Date == "" {
+	if strings.TrimSpace(input.Name) == "" || input.DurationMin < 0 || input.CaloriesBurned < 0 || (input.Date == "" && input.ScheduledAt == "") {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid workout data"})
 		return
 	}
 
-	// Validate date format "YYYY-MM-DD"
-	if _, err := time.Parse("2006-01-02", input.Date); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "date must be in YYYY-MM-DD format"})
+	date, scheduledAt, timezone, err := resolveWorkoutSchedule(userID, input.Date, input.StartTime, input.ScheduledAt, input.Timezone)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	exercise, ok := workoutExerciseFromInput(c, input.ExerciseID, input.Exercise, input.Intensity)
+	if !ok {
+		return
+	}
+	caloriesBurned, caloriesSource := resolveWorkoutCalories(userID, exercise, input.DurationMin, input.CaloriesBurned)
+	var exerciseID *int
+	if exercise != nil {
+		exerciseID = &exercise.ID
+	}
+
+	workoutType, err := resolveWorkoutType(input.Type, exercise, input.Name)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validateStrengthFields(input.Weight, input.Reps); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validateSessionRPE(input.SessionRPE); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.Status != "" && input.Status != WorkoutPlanned && input.Status != WorkoutCompleted {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be planned or completed"})
+		return
+	}
+	loggedSets := false
+	for i := range input.Exercises {
+		prepared, status, err := prepareExerciseInput(input.Exercises[i])
+		if err != nil {
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+		input.Exercises[i] = prepared
+		loggedSets = loggedSets || len(prepared.Sets) > 0
+	}
+	status := initialWorkoutStatus(input.Status, date, timezone, loggedSets)
+	var completedAt *time.Time
+	var actualDuration *int
+	if status == WorkoutCompleted {
+		now := time.Now()
+		completedAt, actualDuration = &now, &input.DurationMin
+	}
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		log.Printf("Failed to begin workout transaction: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create workout"})
 		return
 	}
+	defer tx.Rollback()
 
 	var workoutID uuid.UUID
-	err = config.DB.QueryRow(
-		`INSERT INTO workouts (user_id, name, duration_minutes, calories_burned, date, created_at) 
-         VALUES ($1, $2, $3, $4, $5, $6)
+	err = tx.QueryRow(
+		`INSERT INTO workouts (user_id, name, duration_minutes, calories_burned, date, created_at, exercise_id, calories_source, type, weight, reps,
+                               scheduled_at, timezone, status, completed_at, actual_duration_min, was_planned, session_rpe) 
+         VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18)
          RETURNING id`,
-		userID, input.Name, input.DurationMin, input.CaloriesBurned, input.Date, time.Now(),
+		userID, input.Name, input.DurationMin, caloriesBurned, date, time.Now(), exerciseID, caloriesSource,
+		workoutType, input.Weight, input.Reps, scheduledAt, timezone, status, completedAt, actualDuration, status == WorkoutPlanned,
+		input.SessionRPE,
 	).Scan(&workoutID)
 	if err != nil {
 		log.Printf("Failed to create workout: %v", err)
 		log.Printf("Values: userID=%s, name=%s, duration=%d, calories=%d, date=%s",
-			userID, input.Name, input.DurationMin, input.CaloriesBurned, input.Date)
+			userID, input.Name, input.DurationMin, caloriesBurned, date)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create workout"})
 		return
 	}
 
-	_ = CreateNotification(userID, &workoutID, "💪 New workout scheduled: "+input.Name)
-
-	// Return the workout object in frontend-compatible format
-	response := map[string]interface{}{
-		"id":              workoutID,
-		"user_id":         userID,
-		"name":            input.Name,
-		"duration_min":    input.DurationMin,
-		"calories_burned": input.CaloriesBurned,
-		"date":            input.Date,
-		"created_at":      time.Now(),
+	for _, exercise := range input.Exercises {
+		if _, err := insertWorkoutExercise(tx, workoutID, exercise); err != nil {
+			log.Printf("Failed to add workout exercise: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create workout"})
+			return
+		}
 	}
-
-	c.JSON(http.StatusCreated, response)
-}
-
-// GetWorkouts lists the workouts for the logged-in user
-func GetWorkouts(c *gin.Context) {
-	userIDStr := c.GetString("user_id")
-	userID, err := uuid.Parse(userIDStr)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+	if err := tx.Commit(); err != nil {
+		log.Printf("Failed to commit workout: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create workout"})
 		return
 	}
 
-	rows, err := config.DB.Query(
-		`SELECT id, user_id, name, duration_minutes, calories_burned, date, created_at
-         FROM workouts 
-         WHERE user_id=$1
-         ORDER BY date DESC, created_at DESC`,
-		userID,
-	)
+	if status == WorkoutPlanned {
+		_ = CreateNotification(userID, &workoutID, "💪 New workout scheduled: "+input.Name)
+	} else {
+		_ = CreateNotification(userID, &workoutID, "✅ Workout logged: "+input.Name)
+	}
+	RecomputeGoalProgress(userID)
+	CheckAchievements(userID, EventWorkoutCompleted)
+
+	workout, err := loadWorkoutResponse(workoutID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get workouts"})
+		log.Printf("Failed to load created workout: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load workout"})
 		return
 	}
-	defer rows.Close()
+	response := createdWorkoutResponse{workoutResponse: workout}
 
-	var workouts []models.Workout
-	for rows.Next() {
-		var w models.Workout
-		if err := rows.Scan(
-			&w.ID, &w.UserID, &w.Name, &w.DurationMin, &w.CaloriesBurned, &w.Date, &w.CreatedAt,
-		); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse workouts"})
-			return
-		}
-		// Convert Date sql.NullTime to string DateString for frontend
-		if w.Date.Valid {
-			w.DateString = w.Date.Time.Format("2006-01-02")
+	if len(input.Exercises) > 0 {
+		exercises, totalVolume, err := loadWorkoutSession(config.DB, workoutID)
+		if err != nil {
+			log.Printf("Failed to load workout session: %v", err)
 		} else {
-			w.DateString = ""
+			response.Exercises = exercises
+			response.TotalVolumeKg = &totalVolume
 		}
-		workouts = append(workouts, w)
-	}
 
-	// Convert workouts to a format suitable for frontend
-	var responseWorkouts []map[string]interface{}
-	for _, w := range workouts {
-		caloriesBurned := 0
-		if w.CaloriesBurned.Valid {
-			caloriesBurned = int(w.CaloriesBurned.Int32)
+	}
+	// A finished session posted in one go counts as saved, so check it for PRs right away;
+	// a planned one's sets are targets, not lifts
+	if len(input.Exercises) > 0 && status == WorkoutCompleted {
+		records, err := detectPersonalRecords(userID, workoutID)
+		if err != nil {
+			log.Printf("Failed to detect personal records: %v", err)
+		} else {
+			response.PersonalRecords = records
 		}
-
-		responseWorkouts = append(responseWorkouts, map[string]interface{}{
-			"id":              w.ID,
-			"user_id":         w.UserID,
-			"name":            w.Name,
-			"duration_min":    w.DurationMin,
-			"calories_burned": caloriesBurned,
-			"date":            w.DateString,
-			"created_at":      w.CreatedAt,
-		})
 	}
 
-	c.JSON(http.StatusOK, responseWorkouts)
+	c.JSON(http.StatusCreated, response)
 }
 
 // UpdateWorkout updates an existing workout
@@ -154,39 +342,102 @@ func UpdateWorkout(c *gin.Context) {
 	}
 
 	var input struct {
-		Name           string `json:"name"`
-		DurationMin    int    `json:"duration_min"`
-		CaloriesBurned int    `json:"calories_burned"`
-		Date           string `json:"date"`
+		Name           string   `json:"name"`
+		DurationMin    int      `json:"duration_min"`
+		CaloriesBurned int      `json:"calories_burned"`
+		Date           string   `json:"date"`
+		StartTime      string   `json:"start_time"`   // optional "HH:MM" on date
+		ScheduledAt    string   `json:"scheduled_at"` // optional RFC 3339 start, alternative to date + start_time
+		Timezone       string   `json:"timezone"`     // IANA name, defaults to the user's
+		ExerciseID     *int     `json:"exercise_id"`
+		Exercise       string   `json:"exercise"`  // catalog slug, alternative to exercise_id
+		Intensity      string   `json:"intensity"` // light, moderate, vigorous; used with exercise
+		Type           string   `json:"type"`      // cardio, strength, flexibility, sports
+		Weight         *float64 `json:"weight"`    // load for strength training
+		Reps           *int     `json:"reps"`
+		SessionRPE     *int     `json:"session_rpe"` // how hard the session felt, 1-10
 	}
 	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	if strings.TrimSpace(input.Name) == "" || input.DurationMin < 0 || input.CaloriesBurned < 0 || input.Date == "" {
+	if strings.TrimSpace(input.Name) == "" || input.DurationMin < 0 || input.CaloriesBurned < 0 || (input.Date == "" && input.ScheduledAt == "") {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid workout data"})
 		return
 	}
 
-	// Validate date format "YYYY-MM-DD"
-	if _, err := time.Parse("2006-01-02", input.Date); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "date must be in YYYY-MM-DD format"})
+	date, scheduledAt, timezone, err := resolveWorkoutSchedule(userID, input.Date, input.StartTime, input.ScheduledAt, input.Timezone)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	exercise, ok := workoutExerciseFromInput(c, input.ExerciseID, input.Exercise, input.Intensity)
+	if !ok {
+		return
+	}
+	caloriesBurned, caloriesSource := resolveWorkoutCalories(userID, exercise, input.DurationMin, input.CaloriesBurned)
+	var exerciseID *int
+	if exercise != nil {
+		exerciseID = &exercise.ID
+	}
+
+	workoutType, err := resolveWorkoutType(input.Type, exercise, input.Name)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validateStrengthFields(input.Weight, input.Reps); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validateSessionRPE(input.SessionRPE); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Moving a session reorders its history, so the records around both its old and new place are redone
+	var oldDate sql.NullString
+	if err := config.DB.QueryRow(
+		`SELECT date::text FROM workouts WHERE id=$1 AND user_id=$2`, workoutID, userID,
+	).Scan(&oldDate); err != nil && err != sql.ErrNoRows {
+		log.Println("DB SELECT ERROR (UpdateWorkout):", err)
+	}
+	laterWorkouts, err := laterPRWorkouts(userID, workoutID)
+	if err != nil {
+		log.Printf("Failed to find workouts to re-check for personal records: %v", err)
+	}
+
 	_, err = config.DB.Exec(
 		`UPDATE workouts 
-         SET name=$1, duration_minutes=$2, calories_burned=$3, date=$4
-         WHERE id=$5 AND user_id=$6`,
-		input.Name, input.DurationMin, input.CaloriesBurned, input.Date, workoutID, userID,
+         SET name=$1, duration_minutes=$2, calories_burned=$3, date=$4, exercise_id=$5, calories_source=$6,
+             type=$7, weight=$8, reps=$9, scheduled_at=$10, timezone=$11, session_rpe=$12, detached=(plan_id IS NOT NULL)
+         WHERE id=$13 AND user_id=$14`,
+		input.Name, input.DurationMin, caloriesBurned, date, exerciseID, caloriesSource,
+		workoutType, input.Weight, input.Reps, scheduledAt, timezone, input.SessionRPE, workoutID, userID,
 	)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update workout"})
 		return
 	}
 
+	if oldDate.Valid && oldDate.String != date {
+		nowLater, err := laterPRWorkouts(userID, workoutID)
+		if err != nil {
+			log.Printf("Failed to find workouts to re-check for personal records: %v", err)
+		}
+		for _, id := range nowLater {
+			if !slices.Contains(laterWorkouts, id) {
+				laterWorkouts = append(laterWorkouts, id)
+			}
+		}
+		refreshPersonalRecords(userID, append([]uuid.UUID{workoutID}, laterWorkouts...))
+	}
+
 	_ = CreateNotification(userID, &workoutID, "✏️ Your workout '"+input.Name+"' was updated.")
+	RecomputeGoalProgress(userID)
+	CheckAchievements(userID, EventWorkoutCompleted)
 
 	c.JSON(http.StatusOK, gin.H{"message": "Workout updated successfully"})
 }
@@ -207,20 +458,57 @@ func DeleteWorkout(c *gin.Context) {
 		return
 	}
 
-	res, err := config.DB.Exec(
-		`DELETE FROM workouts WHERE id=$1 AND user_id=$2`,
+	// Records on later sessions were measured against this one, so they are redone once it's gone
+	laterWorkouts, err := laterPRWorkouts(userID, workoutID)
+	if err != nil {
+		log.Printf("Failed to find workouts to re-check for personal records: %v", err)
+	}
+
+	var planID uuid.NullUUID
+	var occurrenceDate sql.NullString
+	err = config.DB.QueryRow(
+		`DELETE FROM workouts WHERE id=$1 AND user_id=$2 RETURNING plan_id, occurrence_date::text`,
 		workoutID, userID,
-	)
+	).Scan(&planID, &occurrenceDate)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Workout not found"})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete workout"})
 		return
 	}
 
-	count, _ := res.RowsAffected()
-	if count == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Workout not found"})
-		return
+	// Deleting one occurrence of a plan skips that date rather than letting the plan recreate it
+	if planID.Valid && occurrenceDate.Valid {
+		if _, err := config.DB.Exec(
+			`INSERT INTO workout_plan_exceptions (plan_id, occurrence_date) VALUES ($1, $2) ON CONFLICT DO NOTHING`,
+			planID.UUID, occurrenceDate.String,
+		); err != nil {
+			log.Printf("Failed to record workout plan exception: %v", err)
+		}
 	}
+	refreshPersonalRecords(userID, laterWorkouts)
+	RecomputeGoalProgress(userID)
 
 	c.JSON(http.StatusOK, gin.H{"message": "Workout deleted successfully"})
 }
+
+// workoutExerciseFromInput resolves the optional catalog exercise referenced by a workout request.
+// It writes the error response itself and returns false when the request should stop.
+func workoutExerciseFromInput(c *gin.Context, exerciseID *int, slug, intensity string) (*models.Exercise, bool) {
+	if exerciseID == nil && strings.TrimSpace(slug) == "" {
+		return nil, true
+	}
+	exercise, err := lookupExercise(exerciseID, slug, intensity)
+	if err == errExerciseNotFound {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	if err != nil {
+		log.Printf("Failed to look up exercise: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to look up exercise"})
+		return nil, false
+	}
+	return exercise, true
+}
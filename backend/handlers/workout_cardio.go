@@ -0,0 +1,456 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"nutritionix/backend/config"
+	"nutritionix/backend/models"
+	"nutritionix/backend/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Cardio metric sources
+const (
+	CardioSourceManual = "manual"
+	CardioSourceTrack  = "track"
+)
+
+// Unit conversions for cardio input
+var (
+	distanceUnits  = map[string]float64{"m": 1, "km": 1000, "mi": 1609.344}
+	elevationUnits = map[string]float64{"m": 1, "ft": 0.3048}
+)
+
+// cardioWorkoutTypes are the workout types that can carry cardio metrics
+var cardioWorkoutTypes = map[string]bool{"cardio": true, "sports": true}
+
+// heartRateSettings are the values behind a user's heart rate zones
+type heartRateSettings struct {
+	MaxHR            int  `json:"max_hr"`
+	RestingHR        int  `json:"resting_hr"`       // 0 when unset; zones are then % of max HR
+	MaxHRAgeEstimate bool `json:"max_hr_estimated"` // max HR is 220 - age, not configured
+}
+
+// userHeartRateZones loads the user's Karvonen zones. ok is false when neither a max heart rate
+// nor an age is on the profile.
+func userHeartRateZones(userID uuid.UUID) (zones [utils.HRZoneCount]utils.HRZone, settings heartRateSettings, ok bool, err error) {
+	var maxHR, restingHR, age sql.NullInt64
+	err = config.DB.QueryRow(`SELECT max_hr, resting_hr, age FROM users WHERE id = $1`, userID).Scan(&maxHR, &restingHR, &age)
+	if err != nil {
+		return zones, settings, false, err
+	}
+	switch {
+	case maxHR.Valid:
+		settings.MaxHR = int(maxHR.Int64)
+	case age.Valid && age.Int64 > 0:
+		settings.MaxHR, settings.MaxHRAgeEstimate = 220-int(age.Int64), true
+	default:
+		return zones, settings, false, nil
+	}
+	if restingHR.Valid && int(restingHR.Int64) < settings.MaxHR {
+		settings.RestingHR = int(restingHR.Int64)
+	}
+	return utils.KarvonenZones(settings.MaxHR, settings.RestingHR), settings, true, nil
+}
+
+// paceSecPerKm is the average pace, 0 without distance
+func paceSecPerKm(durationSec int, distanceM float64) int {
+	if distanceM <= 0 {
+		return 0
+	}
+	return int(math.Round(float64(durationSec) / (distanceM / 1000)))
+}
+
+// UpsertWorkoutCardio handles PUT /user/workouts/:id/cardio.
+// Distance defaults to km and elevation to m; zone_minutes gives the time in zones 1-5.
+func UpsertWorkoutCardio(c *gin.Context) {
+	_, workoutID, ok := sessionRequestIDs(c)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Distance      *float64  `json:"distance"`
+		DistanceUnit  string    `json:"distance_unit"` // m, km, mi
+		ElevationGain *float64  `json:"elevation_gain"`
+		ElevationUnit string    `json:"elevation_unit"` // m, ft
+		AvgHeartRate  *int      `json:"avg_heart_rate"`
+		MaxHeartRate  *int      `json:"max_heart_rate"`
+		ZoneMinutes   []float64 `json:"zone_minutes"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var workoutType string
+	var durationMin int
+	err := config.DB.QueryRow(
+		`SELECT COALESCE(type, ''), COALESCE(actual_duration_min, duration_minutes) FROM workouts WHERE id = $1`, workoutID,
+	).Scan(&workoutType, &durationMin)
+	if err != nil {
+		log.Println("DB SELECT ERROR (UpsertWorkoutCardio):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load workout"})
+		return
+	}
+	if !cardioWorkoutTypes[workoutType] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cardio metrics can only be added to cardio and sports workouts"})
+		return
+	}
+
+	metrics, err := cardioMetricsFromInput(input.Distance, input.DistanceUnit, input.ElevationGain, input.ElevationUnit,
+		input.AvgHeartRate, input.MaxHeartRate, input.ZoneMinutes, durationMin)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var zoneSeconds interface{}
+	if metrics.ZoneSeconds != nil {
+		zoneSeconds = pq.Array(metrics.ZoneSeconds)
+	}
+	_, err = config.DB.Exec(
+		`INSERT INTO workout_cardio (workout_id, distance_m, elevation_gain_m, avg_heart_rate, max_heart_rate, zone_seconds, source, updated_at)
+         VALUES ($1, $2, $3, $4, $5, $6, $7, NOW())
+         ON CONFLICT (workout_id) DO UPDATE
+         SET distance_m = EXCLUDED.distance_m, elevation_gain_m = EXCLUDED.elevation_gain_m,
+             avg_heart_rate = EXCLUDED.avg_heart_rate, max_heart_rate = EXCLUDED.max_heart_rate,
+             zone_seconds = EXCLUDED.zone_seconds, source = EXCLUDED.source, updated_at = NOW()`,
+		workoutID, metrics.DistanceM, metrics.ElevationGainM, metrics.AvgHeartRate, metrics.MaxHeartRate, zoneSeconds, CardioSourceManual,
+	)
+	if err != nil {
+		log.Println("DB INSERT ERROR (UpsertWorkoutCardio):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save cardio metrics"})
+		return
+	}
+
+	respondWithCardio(c, workoutID)
+}
+
+// cardioMetricsFromInput validates cardio input and converts it to metres and seconds
+func cardioMetricsFromInput(distance *float64, distanceUnit string, elevation *float64, elevationUnit string,
+	avgHR, maxHR *int, zoneMinutes []float64, durationMin int) (models.WorkoutCardio, error) {
+	var m models.WorkoutCardio
+
+	if distance != nil {
+		unit := strings.ToLower(strings.TrimSpace(distanceUnit))
+		if unit == "" {
+			unit = "km"
+		}
+		factor, ok := distanceUnits[unit]
+		if !ok {
+			return m, fmt.Errorf("distance_unit must be m, km, or mi")
+		}
+		meters := math.Round(*distance*factor*10) / 10
+		if meters < 0 || meters > 1000000 {
+			return m, fmt.Errorf("distance must be between 0 and 1000 km")
+		}
+		m.DistanceM = &meters
+	}
+	if elevation != nil {
+		unit := strings.ToLower(strings.TrimSpace(elevationUnit))
+		if unit == "" {
+			unit = "m"
+		}
+		factor, ok := elevationUnits[unit]
+		if !ok {
+			return m, fmt.Errorf("elevation_unit must be m or ft")
+		}
+		meters := math.Round(*elevation*factor*10) / 10
+		if meters < 0 || meters > 20000 {
+			return m, fmt.Errorf("elevation_gain must be between 0 and 20000 m")
+		}
+		m.ElevationGainM = &meters
+	}
+	for _, hr := range []*int{avgHR, maxHR} {
+		if hr != nil && (*hr < 30 || *hr > 250) {
+			return m, fmt.Errorf("heart rates must be between 30 and 250 bpm")
+		}
+	}
+	if avgHR != nil && maxHR != nil && *avgHR > *maxHR {
+		return m, fmt.Errorf("avg_heart_rate can't be above max_heart_rate")
+	}
+	m.AvgHeartRate, m.MaxHeartRate = avgHR, maxHR
+
+	if zoneMinutes != nil {
+		if len(zoneMinutes) != utils.HRZoneCount {
+			return m, fmt.Errorf("zone_minutes must have 5 entries, for zones 1 to 5")
+		}
+		total := 0.0
+		m.ZoneSeconds = make([]int64, utils.HRZoneCount)
+		for i, min := range zoneMinutes {
+			if min < 0 {
+				return m, fmt.Errorf("zone_minutes can't be negative")
+			}
+			total += min
+			m.ZoneSeconds[i] = int64(math.Round(min * 60))
+		}
+		if durationMin > 0 && total > float64(durationMin)+1 {
+			return m, fmt.Errorf("zone_minutes add up to more than the workout's duration")
+		}
+	}
+	return m, nil
+}
+
+// GetWorkoutCardio handles GET /user/workouts/:id/cardio
+func GetWorkoutCardio(c *gin.Context) {
+	_, workoutID, ok := sessionRequestIDs(c)
+	if !ok {
+		return
+	}
+	respondWithCardio(c, workoutID)
+}
+
+// respondWithCardio writes a workout's cardio metrics with pace and heart rate zones. Tracked
+// workouts get their time in zones from the recorded points, so it follows profile changes.
+func respondWithCardio(c *gin.Context, workoutID uuid.UUID) {
+	var m models.WorkoutCardio
+	var userID uuid.UUID
+	var durationSec int
+	var avgHR, maxHR sql.NullInt64
+	var points []byte
+	err := config.DB.QueryRow(
+		`SELECT wc.workout_id, wc.distance_m, wc.elevation_gain_m, wc.avg_heart_rate, wc.max_heart_rate,
+                wc.zone_seconds, wc.source, wc.updated_at, w.user_id,
+                COALESCE(t.duration_sec, COALESCE(w.actual_duration_min, w.duration_minutes) * 60), t.points
+         FROM workout_cardio wc
+         JOIN workouts w ON w.id = wc.workout_id
+         LEFT JOIN workout_tracks t ON t.workout_id = wc.workout_id
+         WHERE wc.workout_id = $1`,
+		workoutID,
+	).Scan(&m.WorkoutID, &m.DistanceM, &m.ElevationGainM, &avgHR, &maxHR, pq.Array(&m.ZoneSeconds), &m.Source,
+		&m.UpdatedAt, &userID, &durationSec, &points)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "This workout has no cardio metrics"})
+		return
+	}
+	if err != nil {
+		log.Println("DB SELECT ERROR (respondWithCardio):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load cardio metrics"})
+		return
+	}
+	if avgHR.Valid {
+		v := int(avgHR.Int64)
+		m.AvgHeartRate = &v
+	}
+	if maxHR.Valid {
+		v := int(maxHR.Int64)
+		m.MaxHeartRate = &v
+	}
+
+	resp := gin.H{
+		"workout_id":       m.WorkoutID,
+		"source":           m.Source,
+		"duration_sec":     durationSec,
+		"distance_m":       m.DistanceM,
+		"distance_km":      nil,
+		"distance_mi":      nil,
+		"elevation_gain_m": m.ElevationGainM,
+		"avg_heart_rate":   m.AvgHeartRate,
+		"max_heart_rate":   m.MaxHeartRate,
+		"pace_sec_per_km":  nil,
+		"pace_sec_per_mi":  nil,
+		"avg_speed_kmh":    nil,
+		"heart_rate_zones": nil,
+		"updated_at":       m.UpdatedAt,
+	}
+	if m.DistanceM != nil && *m.DistanceM > 0 {
+		resp["distance_km"] = round2(*m.DistanceM / 1000)
+		resp["distance_mi"] = round2(*m.DistanceM / distanceUnits["mi"])
+		if durationSec > 0 {
+			resp["pace_sec_per_km"] = paceSecPerKm(durationSec, *m.DistanceM)
+			resp["pace_sec_per_mi"] = int(math.Round(float64(durationSec) / (*m.DistanceM / distanceUnits["mi"])))
+			resp["avg_speed_kmh"] = round2(*m.DistanceM / float64(durationSec) * 3.6)
+		}
+	}
+
+	zones, settings, ok, err := userHeartRateZones(userID)
+	if err != nil {
+		log.Println("DB SELECT ERROR (userHeartRateZones):", err)
+	}
+	if ok {
+		seconds := make([]int64, utils.HRZoneCount)
+		copy(seconds, m.ZoneSeconds)
+		if m.Source == CardioSourceTrack && len(points) > 0 {
+			track := &utils.Track{}
+			if err := json.Unmarshal(points, &track.Points); err == nil {
+				for i, s := range track.TimeInZones(zones) {
+					seconds[i] = int64(s)
+				}
+			}
+		}
+		type zoneTime struct {
+			utils.HRZone
+			Seconds int64 `json:"seconds"`
+		}
+		zoneTimes := make([]zoneTime, utils.HRZoneCount)
+		for i, z := range zones {
+			zoneTimes[i] = zoneTime{HRZone: z, Seconds: seconds[i]}
+		}
+		resp["heart_rate_zones"] = gin.H{"method": "karvonen", "settings": settings, "zones": zoneTimes}
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// cardioWeek is one week of one activity in GetCardioSummary
+type cardioWeek struct {
+	WeekStart      string  `json:"week_start"`
+	Sessions       int     `json:"sessions"`
+	DistanceKm     float64 `json:"distance_km"`
+	DurationMin    int     `json:"duration_min"`
+	ElevationGainM float64 `json:"elevation_gain_m"`
+	AvgPaceSecKm   *int    `json:"avg_pace_sec_per_km"` // total time over total distance
+	AvgHeartRate   *int    `json:"avg_heart_rate"`      // duration-weighted
+	durationSec    int
+}
+
+// GetCardioSummary handles GET /user/cardio/summary?weeks=
+// Per activity (catalog exercise, else the track's sport, else the workout type) it reports weekly
+// distance, time, elevation, pace and heart rate, and the trend of the weekly pace.
+func GetCardioSummary(c *gin.Context) {
+	userID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+	weeks := defaultTrainingWeeks
+	if w := c.Query("weeks"); w != "" {
+		weeks, err = strconv.Atoi(w)
+		if err != nil || weeks < 1 || weeks > 52 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "weeks must be between 1 and 52"})
+			return
+		}
+	}
+	today, _ := time.Parse("2006-01-02", localToday(userTimezone(userID)))
+	thisMonday := today.AddDate(0, 0, -((int(today.Weekday()) + 6) % 7))
+	from := thisMonday.AddDate(0, 0, -7*(weeks-1)).Format("2006-01-02")
+
+	rows, err := config.DB.Query(
+		`SELECT COALESCE(ec.slug, NULLIF(t.sport, ''), w.type, 'other'),
+                date_trunc('week', w.date)::date::text,
+                COUNT(*),
+                COALESCE(SUM(wc.distance_m), 0),
+                SUM(COALESCE(t.duration_sec, COALESCE(w.actual_duration_min, w.duration_minutes) * 60)),
+                COALESCE(SUM(COALESCE(t.duration_sec, COALESCE(w.actual_duration_min, w.duration_minutes) * 60))
+                         FILTER (WHERE wc.distance_m > 0), 0),
+                COALESCE(SUM(wc.elevation_gain_m), 0),
+                SUM(wc.avg_heart_rate * COALESCE(t.duration_sec, COALESCE(w.actual_duration_min, w.duration_minutes) * 60))
+                    / NULLIF(SUM(COALESCE(t.duration_sec, COALESCE(w.actual_duration_min, w.duration_minutes) * 60))
+                             FILTER (WHERE wc.avg_heart_rate IS NOT NULL), 0)
+         FROM workouts w
+         JOIN workout_cardio wc ON wc.workout_id = w.id
+         LEFT JOIN workout_tracks t ON t.workout_id = w.id
+         LEFT JOIN exercise_catalog ec ON ec.id = w.exercise_id
+         WHERE w.user_id = $1 AND w.status = 'completed' AND w.date >= $2::date
+         GROUP BY 1, 2
+         ORDER BY 1, 2`,
+		userID, from,
+	)
+	if err != nil {
+		log.Println("DB SELECT ERROR (GetCardioSummary):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute cardio summary"})
+		return
+	}
+	defer rows.Close()
+
+	byActivity := map[string][]*cardioWeek{}
+	var order []string
+	for rows.Next() {
+		var activity string
+		var distanceM float64
+		var pacedSec int
+		var avgHR sql.NullFloat64
+		wk := &cardioWeek{}
+		if err := rows.Scan(&activity, &wk.WeekStart, &wk.Sessions, &distanceM, &wk.durationSec, &pacedSec,
+			&wk.ElevationGainM, &avgHR); err != nil {
+			log.Println("DB SCAN ERROR (GetCardioSummary):", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute cardio summary"})
+			return
+		}
+		wk.DistanceKm = round2(distanceM / 1000)
+		wk.DurationMin = int(math.Round(float64(wk.durationSec) / 60))
+		wk.ElevationGainM = math.Round(wk.ElevationGainM*10) / 10
+		if pace := paceSecPerKm(pacedSec, distanceM); pace > 0 {
+			wk.AvgPaceSecKm = &pace
+		}
+		if avgHR.Valid {
+			hr := int(math.Round(avgHR.Float64))
+			wk.AvgHeartRate = &hr
+		}
+		if _, seen := byActivity[activity]; !seen {
+			order = append(order, activity)
+		}
+		byActivity[activity] = append(byActivity[activity], wk)
+	}
+
+	activities := []gin.H{}
+	for _, activity := range order {
+		weeksData := byActivity[activity]
+		totalKm, sessions := 0.0, 0
+		for _, wk := range weeksData {
+			totalKm += wk.DistanceKm
+			sessions += wk.Sessions
+		}
+		activities = append(activities, gin.H{
+			"activity":    activity,
+			"sessions":    sessions,
+			"distance_km": round2(totalKm),
+			"weeks":       weeksData,
+			"pace_trend":  paceTrend(weeksData),
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"from": from, "activities": activities})
+}
+
+// paceTrend fits a least-squares line through the weekly average paces. A negative slope means
+// the user is getting faster. It needs paces from at least three weeks.
+func paceTrend(weeks []*cardioWeek) gin.H {
+	var xs, ys []float64
+	var first time.Time
+	for _, wk := range weeks {
+		if wk.AvgPaceSecKm == nil {
+			continue
+		}
+		start, _ := time.Parse("2006-01-02", wk.WeekStart)
+		if first.IsZero() {
+			first = start
+		}
+		xs = append(xs, start.Sub(first).Hours()/24/7)
+		ys = append(ys, float64(*wk.AvgPaceSecKm))
+	}
+	if len(xs) < 3 {
+		return nil
+	}
+	var meanX, meanY float64
+	for i := range xs {
+		meanX += xs[i] / float64(len(xs))
+		meanY += ys[i] / float64(len(ys))
+	}
+	var cov, varX float64
+	for i := range xs {
+		cov += (xs[i] - meanX) * (ys[i] - meanY)
+		varX += (xs[i] - meanX) * (xs[i] - meanX)
+	}
+	if varX == 0 {
+		return nil
+	}
+	slope := cov / varX
+	return gin.H{
+		"weeks_with_pace":            len(xs),
+		"change_sec_per_km_per_week": round2(slope),
+		"improving":                  slope < 0,
+	}
+}
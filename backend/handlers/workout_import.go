@@ -0,0 +1,307 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"nutritionix/backend/config"
+	"nutritionix/backend/models"
+	"nutritionix/backend/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// maxTrackFileBytes caps uploaded activity files; a multi-hour FIT file is a few MB
+const maxTrackFileBytes = 20 << 20
+
+// duplicateStartWindow treats tracks starting this close together as the same activity,
+// e.g. the GPX and FIT export of one run
+const duplicateStartWindow = 60 * time.Second
+
+// sportIntensities gives the average speeds (km/h) above which a sport counts as moderate and vigorous
+var sportIntensities = map[string][2]float64{
+	"running":  {8, 11},
+	"cycling":  {16, 22},
+	"walking":  {4.5, 6},
+	"hiking":   {3, 5},
+	"swimming": {1.5, 2.5},
+}
+
+// trackIntensity picks a catalog intensity from the track's average speed
+func trackIntensity(sport string, speedKmh float64) string {
+	limits, ok := sportIntensities[sport]
+	switch {
+	case !ok:
+		return "moderate"
+	case speedKmh >= limits[1]:
+		return "vigorous"
+	case speedKmh >= limits[0]:
+		return "moderate"
+	}
+	return "light"
+}
+
+// trackExercise finds the catalog exercise for a sport, falling back to moderate intensity
+// when the catalog has no entry for the intensity the speed suggests
+func trackExercise(sport string, speedKmh float64) *models.Exercise {
+	if sport == "" {
+		return nil
+	}
+	exercise, err := lookupExercise(nil, sport, trackIntensity(sport, speedKmh))
+	if err == errExerciseNotFound {
+		exercise, err = lookupExercise(nil, sport, "moderate")
+	}
+	if err != nil {
+		if err != errExerciseNotFound {
+			log.Printf("Failed to look up exercise: %v", err)
+		}
+		return nil
+	}
+	return exercise
+}
+
+// ImportWorkout handles POST /user/workouts/import (multipart: file, optional name and sport).
+// It parses a GPX, TCX or FIT file, creates a workout from the derived figures and keeps the track.
+func ImportWorkout(c *gin.Context) {
+	userID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+		return
+	}
+	if header.Size > maxTrackFileBytes {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "file must be 20 MB or smaller"})
+		return
+	}
+	file, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "could not read file"})
+		return
+	}
+	data, err := io.ReadAll(io.LimitReader(file, maxTrackFileBytes+1))
+	file.Close()
+	if err != nil || len(data) > maxTrackFileBytes {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "could not read file"})
+		return
+	}
+
+	sum := sha256.Sum256(data)
+	fileHash := hex.EncodeToString(sum[:])
+	var existingID uuid.UUID
+	err = config.DB.QueryRow(
+		`SELECT workout_id FROM workout_tracks WHERE user_id = $1 AND file_hash = $2`,
+		userID, fileHash,
+	).Scan(&existingID)
+	if err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "This file was already imported", "workout_id": existingID})
+		return
+	}
+	if err != sql.ErrNoRows {
+		log.Println("DB SELECT ERROR (ImportWorkout):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import workout"})
+		return
+	}
+
+	track, err := utils.ParseTrack(data)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	summary := track.Summarize()
+
+	err = config.DB.QueryRow(
+		`SELECT workout_id FROM workout_tracks
+         WHERE user_id = $1 AND start_time BETWEEN $2 AND $3
+         LIMIT 1`,
+		userID, summary.StartTime.Add(-duplicateStartWindow), summary.StartTime.Add(duplicateStartWindow),
+	).Scan(&existingID)
+	if err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "An activity starting at the same time was already imported", "workout_id": existingID})
+		return
+	}
+	if err != sql.ErrNoRows {
+		log.Println("DB SELECT ERROR (ImportWorkout):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import workout"})
+		return
+	}
+
+	sport := track.Sport
+	if s := strings.ToLower(strings.TrimSpace(c.PostForm("sport"))); s != "" {
+		if _, ok := sportIntensities[s]; !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "sport must be running, cycling, walking, hiking, or swimming"})
+			return
+		}
+		sport = s
+	}
+
+	exercise := trackExercise(sport, summary.AvgSpeedKmh)
+	var exerciseID *int
+	if exercise != nil {
+		exerciseID = &exercise.ID
+	}
+	durationMin := int(math.Round(float64(summary.DurationSec) / 60))
+	caloriesBurned, caloriesSource := resolveWorkoutCalories(userID, exercise, durationMin, 0)
+	if track.DeviceCalories > 0 {
+		caloriesBurned, caloriesSource = track.DeviceCalories, CaloriesSourceDevice
+	}
+
+	name := strings.TrimSpace(c.PostForm("name"))
+	if name == "" {
+		label := "Workout"
+		if exercise != nil {
+			label = exercise.Name
+		}
+		name = fmt.Sprintf("%s %.1f km", label, summary.DistanceM/1000)
+	}
+	workoutType, err := resolveWorkoutType("", exercise, name)
+	if err != nil {
+		workoutType = "cardio"
+	}
+	timezone := userTimezone(userID)
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	date := summary.StartTime.In(loc).Format("2006-01-02")
+
+	splits, _ := json.Marshal(summary.Splits)
+	points, err := json.Marshal(track.Points)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import workout"})
+		return
+	}
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		log.Printf("Failed to begin import transaction: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import workout"})
+		return
+	}
+	defer tx.Rollback()
+
+	var workoutID uuid.UUID
+	err = tx.QueryRow(
+		`INSERT INTO workouts (user_id, name, duration_minutes, calories_burned, date, created_at, exercise_id, calories_source, type,
+                               scheduled_at, timezone, status, started_at, completed_at, actual_duration_min, was_planned)
+         VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, false)
+         RETURNING id`,
+		userID, name, durationMin, caloriesBurned, date, time.Now(), exerciseID, caloriesSource, workoutType,
+		summary.StartTime, timezone, WorkoutCompleted, summary.StartTime, summary.EndTime, durationMin,
+	).Scan(&workoutID)
+	if err != nil {
+		log.Printf("Failed to create imported workout: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import workout"})
+		return
+	}
+
+	var avgHR, maxHR, deviceCalories *int
+	if summary.AvgHeartRate > 0 {
+		avgHR, maxHR = &summary.AvgHeartRate, &summary.MaxHeartRate
+	}
+	if track.DeviceCalories > 0 {
+		deviceCalories = &track.DeviceCalories
+	}
+	_, err = tx.Exec(
+		`INSERT INTO workout_tracks (workout_id, user_id, format, sport, file_hash, start_time, end_time, duration_sec,
+                                     distance_m, elevation_gain_m, avg_heart_rate, max_heart_rate, device_calories, splits, points)
+         VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14::jsonb, $15::jsonb)`,
+		workoutID, userID, track.Format, sport, fileHash, summary.StartTime, summary.EndTime, summary.DurationSec,
+		summary.DistanceM, summary.ElevationGainM, avgHR, maxHR, deviceCalories, string(splits), string(points),
+	)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			c.JSON(http.StatusConflict, gin.H{"error": "This file was already imported"})
+			return
+		}
+		log.Printf("Failed to store workout track: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import workout"})
+		return
+	}
+	var distanceM, elevationGainM *float64
+	if summary.DistanceM > 0 {
+		distanceM, elevationGainM = &summary.DistanceM, &summary.ElevationGainM
+	}
+	_, err = tx.Exec(
+		`INSERT INTO workout_cardio (workout_id, distance_m, elevation_gain_m, avg_heart_rate, max_heart_rate, source)
+         VALUES ($1, $2, $3, $4, $5, $6)`,
+		workoutID, distanceM, elevationGainM, avgHR, maxHR, CardioSourceTrack,
+	)
+	if err != nil {
+		log.Printf("Failed to store cardio metrics: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import workout"})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("Failed to commit imported workout: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import workout"})
+		return
+	}
+
+	_ = CreateNotification(userID, &workoutID, "📥 Imported workout: "+name)
+	RecomputeGoalProgress(userID)
+	CheckAchievements(userID, EventWorkoutCompleted)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"id":              workoutID,
+		"user_id":         userID,
+		"name":            name,
+		"type":            workoutType,
+		"duration_min":    durationMin,
+		"calories_burned": caloriesBurned,
+		"calories_source": caloriesSource,
+		"exercise_id":     exerciseID,
+		"date":            date,
+		"scheduled_at":    summary.StartTime,
+		"timezone":        timezone,
+		"status":          WorkoutCompleted,
+		"format":          track.Format,
+		"sport":           sport,
+		"track":           summary,
+	})
+}
+
+// GetWorkoutTrack handles GET /user/workouts/:id/track
+func GetWorkoutTrack(c *gin.Context) {
+	_, workoutID, ok := sessionRequestIDs(c)
+	if !ok {
+		return
+	}
+
+	var t models.WorkoutTrack
+	var splits, points []byte
+	err := config.DB.QueryRow(
+		`SELECT id, workout_id, user_id, format, sport, file_hash, start_time, end_time, duration_sec,
+                distance_m, elevation_gain_m, avg_heart_rate, max_heart_rate, device_calories, splits, points, created_at
+         FROM workout_tracks WHERE workout_id = $1`,
+		workoutID,
+	).Scan(&t.ID, &t.WorkoutID, &t.UserID, &t.Format, &t.Sport, &t.FileHash, &t.StartTime, &t.EndTime, &t.DurationSec,
+		&t.DistanceM, &t.ElevationGainM, &t.AvgHeartRate, &t.MaxHeartRate, &t.DeviceCalories, &splits, &points, &t.CreatedAt)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "This workout has no recorded track"})
+		return
+	}
+	if err != nil {
+		log.Println("DB SELECT ERROR (GetWorkoutTrack):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load track"})
+		return
+	}
+	t.Splits, t.Points = splits, points
+
+	c.JSON(http.StatusOK, t)
+}
@@ -0,0 +1,430 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"nutritionix/backend/config"
+	"nutritionix/backend/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Page sizes for GetWorkouts
+const (
+	defaultWorkoutPageSize = 50
+	maxWorkoutPageSize     = 200
+)
+
+// nextCursorHeader carries the cursor for the next page of GetWorkouts
+const nextCursorHeader = "X-Next-Cursor"
+
+var validWorkoutStatuses = map[string]bool{
+	WorkoutPlanned:    true,
+	WorkoutInProgress: true,
+	WorkoutCompleted:  true,
+	WorkoutSkipped:    true,
+	WorkoutMissed:     true,
+}
+
+// workoutColumns matches scanWorkout
+const workoutColumns = `id, user_id, name, COALESCE(type, ''), duration_minutes, weight, reps, calories_burned, date, created_at,
+    exercise_id, calories_source, plan_id, detached, enrollment_id, scheduled_at, timezone,
+    status, started_at, completed_at, actual_duration_min, was_planned, session_rpe`
+
+func scanWorkout(row interface{ Scan(...interface{}) error }) (models.Workout, error) {
+	var w models.Workout
+	err := row.Scan(
+		&w.ID, &w.UserID, &w.Name, &w.Type, &w.DurationMin, &w.Weight, &w.Reps, &w.CaloriesBurned, &w.Date, &w.CreatedAt,
+		&w.ExerciseID, &w.CaloriesSource, &w.PlanID, &w.Detached, &w.EnrollmentID, &w.ScheduledAt, &w.Timezone,
+		&w.Status, &w.StartedAt, &w.CompletedAt, &w.ActualDurationMin, &w.WasPlanned, &w.SessionRPE,
+	)
+	if w.Date.Valid {
+		w.DateString = w.Date.Time.Format("2006-01-02")
+	}
+	return w, err
+}
+
+// workoutResponse is a workout as the frontend consumes it: nullable columns become pointers
+// and the start time is also given as local "HH:MM"
+type workoutResponse struct {
+	ID                uuid.UUID  `json:"id"`
+	UserID            uuid.UUID  `json:"user_id"`
+	Name              string     `json:"name"`
+	Type              string     `json:"type"`
+	Weight            *float64   `json:"weight"`
+	Reps              *int32     `json:"reps"`
+	DurationMin       int        `json:"duration_min"`
+	CaloriesBurned    int        `json:"calories_burned"`
+	CaloriesSource    string     `json:"calories_source"`
+	ExerciseID        *int64     `json:"exercise_id"`
+	PlanID            *uuid.UUID `json:"plan_id"`
+	Detached          bool       `json:"detached"`
+	EnrollmentID      *uuid.UUID `json:"enrollment_id"`
+	Date              string     `json:"date"`
+	StartTime         string     `json:"start_time"`
+	ScheduledAt       *time.Time `json:"scheduled_at"`
+	Timezone          string     `json:"timezone"`
+	Status            string     `json:"status"`
+	StartedAt         *time.Time `json:"started_at"`
+	CompletedAt       *time.Time `json:"completed_at"`
+	ActualDurationMin *int32     `json:"actual_duration_min"`
+	WasPlanned        bool       `json:"was_planned"`
+	SessionRPE        *int32     `json:"session_rpe"`
+	CreatedAt         time.Time  `json:"created_at"`
+}
+
+func newWorkoutResponse(w models.Workout) workoutResponse {
+	r := workoutResponse{
+		ID:             w.ID,
+		UserID:         w.UserID,
+		Name:           w.Name,
+		Type:           w.Type,
+		DurationMin:    w.DurationMin,
+		CaloriesSource: w.CaloriesSource,
+		Detached:       w.Detached,
+		Date:           w.DateString,
+		Timezone:       w.Timezone,
+		Status:         w.Status,
+		WasPlanned:     w.WasPlanned,
+		CreatedAt:      w.CreatedAt,
+	}
+	if w.Weight.Valid {
+		r.Weight = &w.Weight.Float64
+	}
+	if w.Reps.Valid {
+		r.Reps = &w.Reps.Int32
+	}
+	if w.CaloriesBurned.Valid {
+		r.CaloriesBurned = int(w.CaloriesBurned.Int32)
+	}
+	if w.ExerciseID.Valid {
+		r.ExerciseID = &w.ExerciseID.Int64
+	}
+	if w.PlanID.Valid {
+		r.PlanID = &w.PlanID.UUID
+	}
+	if w.EnrollmentID.Valid {
+		r.EnrollmentID = &w.EnrollmentID.UUID
+	}
+	if w.ScheduledAt.Valid {
+		r.ScheduledAt = &w.ScheduledAt.Time
+		if loc, err := time.LoadLocation(w.Timezone); err == nil {
+			r.StartTime = w.ScheduledAt.Time.In(loc).Format("15:04")
+		}
+	}
+	if w.StartedAt.Valid {
+		r.StartedAt = &w.StartedAt.Time
+	}
+	if w.CompletedAt.Valid {
+		r.CompletedAt = &w.CompletedAt.Time
+	}
+	if w.ActualDurationMin.Valid {
+		r.ActualDurationMin = &w.ActualDurationMin.Int32
+	}
+	if w.SessionRPE.Valid {
+		r.SessionRPE = &w.SessionRPE.Int32
+	}
+	return r
+}
+
+// loadWorkoutResponse reads one workout in its response shape
+func loadWorkoutResponse(workoutID uuid.UUID) (workoutResponse, error) {
+	w, err := scanWorkout(config.DB.QueryRow(`SELECT `+workoutColumns+` FROM workouts WHERE id = $1`, workoutID))
+	if err != nil {
+		return workoutResponse{}, err
+	}
+	return newWorkoutResponse(w), nil
+}
+
+// workoutFilter turns the from, to, type and status query parameters into SQL conditions on top
+// of the user's workouts. It writes the error response itself and returns false when they're invalid.
+func workoutFilter(c *gin.Context, userID uuid.UUID) (string, []interface{}, bool) {
+	where := `user_id = $1`
+	args := []interface{}{userID}
+
+	for _, param := range []string{"from", "to"} {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		if _, err := time.Parse("2006-01-02", value); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": param + " must be in YYYY-MM-DD format"})
+			return "", nil, false
+		}
+		args = append(args, value)
+		if param == "from" {
+			where += ` AND date >= $` + strconv.Itoa(len(args))
+		} else {
+			where += ` AND date <= $` + strconv.Itoa(len(args))
+		}
+	}
+
+	if t := strings.ToLower(strings.TrimSpace(c.Query("type"))); t != "" {
+		if !validWorkoutTypes[t] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "type must be cardio, strength, flexibility, or sports"})
+			return "", nil, false
+		}
+		args = append(args, t)
+		where += ` AND type = $` + strconv.Itoa(len(args))
+	}
+
+	// status takes a comma-separated list, e.g. status=skipped,missed
+	if s := strings.ToLower(strings.TrimSpace(c.Query("status"))); s != "" {
+		var placeholders []string
+		for _, status := range strings.Split(s, ",") {
+			status = strings.TrimSpace(status)
+			if !validWorkoutStatuses[status] {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "status must be planned, in_progress, completed, skipped, or missed"})
+				return "", nil, false
+			}
+			args = append(args, status)
+			placeholders = append(placeholders, "$"+strconv.Itoa(len(args)))
+		}
+		where += ` AND status IN (` + strings.Join(placeholders, ", ") + `)`
+	}
+	return where, args, true
+}
+
+// workoutCursor is the position after the last workout of a page, in the listing order
+type workoutCursor struct {
+	Date      string
+	CreatedAt time.Time
+	ID        uuid.UUID
+}
+
+func (cur workoutCursor) encode() string {
+	raw := cur.Date + "|" + cur.CreatedAt.Format(time.RFC3339Nano) + "|" + cur.ID.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeWorkoutCursor(s string) (workoutCursor, error) {
+	var cur workoutCursor
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return cur, err
+	}
+	parts := strings.Split(string(raw), "|")
+	if len(parts) != 3 {
+		return cur, fmt.Errorf("malformed cursor")
+	}
+	if _, err := time.Parse("2006-01-02", parts[0]); err != nil {
+		return cur, err
+	}
+	cur.Date = parts[0]
+	if cur.CreatedAt, err = time.Parse(time.RFC3339Nano, parts[1]); err != nil {
+		return cur, err
+	}
+	cur.ID, err = uuid.Parse(parts[2])
+	return cur, err
+}
+
+// GetWorkouts handles GET /user/workouts?from=&to=&type=&status=&group_by=&limit=&cursor=
+// Workouts are listed newest first. Passing limit or cursor pages the results: while more remain,
+// the X-Next-Cursor response header holds the cursor for the next page. Without either the full
+// list is returned, as before pagination existed.
+func GetWorkouts(c *gin.Context) {
+	userID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	where, args, ok := workoutFilter(c, userID)
+	if !ok {
+		return
+	}
+	groupBy := c.Query("group_by")
+	if groupBy != "" && groupBy != "type" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "group_by only supports 'type'"})
+		return
+	}
+
+	paged := c.Query("limit") != "" || c.Query("cursor") != ""
+	limit := defaultWorkoutPageSize
+	if l := c.Query("limit"); l != "" {
+		limit, err = strconv.Atoi(l)
+		if err != nil || limit < 1 || limit > maxWorkoutPageSize {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit must be between 1 and %d", maxWorkoutPageSize)})
+			return
+		}
+	}
+	if cursor := c.Query("cursor"); cursor != "" {
+		cur, err := decodeWorkoutCursor(cursor)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cursor"})
+			return
+		}
+		args = append(args, cur.Date, cur.CreatedAt, cur.ID)
+		n := len(args)
+		where += fmt.Sprintf(` AND (date, created_at, id) < ($%d::date, $%d, $%d)`, n-2, n-1, n)
+	}
+
+	query := `SELECT ` + workoutColumns + ` FROM workouts WHERE ` + where + ` ORDER BY date DESC, created_at DESC, id DESC`
+	if paged {
+		// One extra row tells whether there is a next page
+		query += ` LIMIT ` + strconv.Itoa(limit+1)
+	}
+
+	rows, err := config.DB.Query(query, args...)
+	if err != nil {
+		log.Println("DB SELECT ERROR (GetWorkouts):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get workouts"})
+		return
+	}
+	defer rows.Close()
+
+	workouts := []workoutResponse{}
+	var last models.Workout
+	for rows.Next() {
+		w, err := scanWorkout(rows)
+		if err != nil {
+			log.Println("DB SCAN ERROR (GetWorkouts):", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse workouts"})
+			return
+		}
+		if paged && len(workouts) == limit {
+			c.Header(nextCursorHeader, workoutCursor{Date: last.DateString, CreatedAt: last.CreatedAt, ID: last.ID}.encode())
+			break
+		}
+		workouts = append(workouts, newWorkoutResponse(w))
+		last = w
+	}
+
+	if groupBy == "type" {
+		grouped := map[string][]workoutResponse{}
+		for t := range validWorkoutTypes {
+			grouped[t] = []workoutResponse{}
+		}
+		for _, w := range workouts {
+			grouped[w.Type] = append(grouped[w.Type], w)
+		}
+		c.JSON(http.StatusOK, grouped)
+		return
+	}
+
+	c.JSON(http.StatusOK, workouts)
+}
+
+// workoutStreak is a run of consecutive days with at least one completed workout
+type workoutStreak struct {
+	Days  int    `json:"days"`
+	Start string `json:"start,omitempty"`
+	End   string `json:"end,omitempty"`
+}
+
+// workoutSummary is the response of GetWorkoutSummary
+type workoutSummary struct {
+	From          string         `json:"from,omitempty"`
+	To            string         `json:"to,omitempty"`
+	Total         int            `json:"total"`
+	ByStatus      map[string]int `json:"by_status"`
+	ByType        map[string]int `json:"by_type"`
+	TotalMinutes  int            `json:"total_minutes"`  // completed workouts, actual duration where recorded
+	TotalCalories int            `json:"total_calories"` // completed workouts
+	ActiveDays    int            `json:"active_days"`
+	LongestStreak workoutStreak  `json:"longest_streak"`
+}
+
+// GetWorkoutSummary handles GET /user/workouts/summary?from=&to=&type=&status=
+// It takes the same filters as GetWorkouts and totals the matching workouts.
+func GetWorkoutSummary(c *gin.Context) {
+	userID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+	where, args, ok := workoutFilter(c, userID)
+	if !ok {
+		return
+	}
+
+	summary := workoutSummary{
+		From:     c.Query("from"),
+		To:       c.Query("to"),
+		ByStatus: map[string]int{},
+		ByType:   map[string]int{},
+	}
+	rows, err := config.DB.Query(
+		`SELECT status, COALESCE(type, ''), COUNT(*),
+                COALESCE(SUM(COALESCE(actual_duration_min, duration_minutes)) FILTER (WHERE status = 'completed'), 0),
+                COALESCE(SUM(calories_burned) FILTER (WHERE status = 'completed'), 0)
+         FROM workouts WHERE `+where+`
+         GROUP BY 1, 2`,
+		args...,
+	)
+	if err != nil {
+		log.Println("DB SELECT ERROR (GetWorkoutSummary):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to summarize workouts"})
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var status, workoutType string
+		var count, minutes, calories int
+		if err := rows.Scan(&status, &workoutType, &count, &minutes, &calories); err != nil {
+			log.Println("DB SCAN ERROR (GetWorkoutSummary):", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to summarize workouts"})
+			return
+		}
+		summary.Total += count
+		summary.ByStatus[status] += count
+		summary.ByType[workoutType] += count
+		summary.TotalMinutes += minutes
+		summary.TotalCalories += calories
+	}
+
+	dayRows, err := config.DB.Query(
+		`SELECT DISTINCT date::text FROM workouts WHERE `+where+` AND status = 'completed' ORDER BY 1`,
+		args...,
+	)
+	if err != nil {
+		log.Println("DB SELECT ERROR (GetWorkoutSummary):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to summarize workouts"})
+		return
+	}
+	defer dayRows.Close()
+	var days []string
+	for dayRows.Next() {
+		var day string
+		if err := dayRows.Scan(&day); err != nil {
+			log.Println("DB SCAN ERROR (GetWorkoutSummary):", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to summarize workouts"})
+			return
+		}
+		days = append(days, day)
+	}
+	summary.ActiveDays = len(days)
+	summary.LongestStreak = longestStreak(days)
+
+	c.JSON(http.StatusOK, summary)
+}
+
+// longestStreak finds the longest run of consecutive dates in a sorted list of YYYY-MM-DD days
+func longestStreak(days []string) workoutStreak {
+	var best, current workoutStreak
+	var prev time.Time
+	for _, day := range days {
+		d, err := time.Parse("2006-01-02", day)
+		if err != nil {
+			continue
+		}
+		if current.Days > 0 && d.Sub(prev) == 24*time.Hour {
+			current.Days++
+			current.End = day
+		} else {
+			current = workoutStreak{Days: 1, Start: day, End: day}
+		}
+		if current.Days > best.Days {
+			best = current
+		}
+		prev = d
+	}
+	return best
+}
@@ -0,0 +1,550 @@
+package handlers
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"nutritionix/backend/config"
+	"nutritionix/backend/models"
+	"nutritionix/backend/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// planHorizonDays is how far ahead plan occurrences are materialized as workouts
+const planHorizonDays = 28
+
+// planInput is the request body for creating or editing a workout plan
+type planInput struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"` // cardio, strength, flexibility, sports
+	ExerciseID  *int   `json:"exercise_id"`
+	Exercise    string `json:"exercise"`  // catalog slug, alternative to exercise_id
+	Intensity   string `json:"intensity"` // light, moderate, vigorous; used with exercise
+	DurationMin int    `json:"duration_min"`
+	RRule       string `json:"rrule"`      // e.g. FREQ=WEEKLY;BYDAY=MO,WE,FR
+	StartDate   string `json:"start_date"` // YYYY-MM-DD, defaults to today
+	StartTime   string `json:"start_time"` // optional "HH:MM" for every occurrence
+	Timezone    string `json:"timezone"`   // IANA name, defaults to the user's
+	Active      *bool  `json:"active"`
+}
+
+// planOccurrence is a materialized workout as listed under its plan
+type planOccurrence struct {
+	WorkoutID      uuid.UUID `json:"workout_id"`
+	OccurrenceDate string    `json:"occurrence_date"`
+	Date           string    `json:"date"`
+	Detached       bool      `json:"detached"`
+}
+
+const workoutPlanColumns = `id, user_id, name, type, exercise_id, duration_min, rrule, start_date::text,
+    to_char(start_time, 'HH24:MI'), timezone, active, created_at, updated_at`
+
+func scanWorkoutPlan(row interface{ Scan(...interface{}) error }) (models.WorkoutPlan, error) {
+	var p models.WorkoutPlan
+	var exerciseID sql.NullInt64
+	var startTime sql.NullString
+	err := row.Scan(&p.ID, &p.UserID, &p.Name, &p.Type, &exerciseID, &p.DurationMin, &p.RRule, &p.StartDate,
+		&startTime, &p.Timezone, &p.Active, &p.CreatedAt, &p.UpdatedAt)
+	if exerciseID.Valid {
+		id := int(exerciseID.Int64)
+		p.ExerciseID = &id
+	}
+	if startTime.Valid {
+		p.StartTime = &startTime.String
+	}
+	return p, err
+}
+
+// preparePlan validates a plan request and fills in the plan's fields.
+// It writes the error response itself and returns false when the request should stop.
+func preparePlan(c *gin.Context, in planInput, p *models.WorkoutPlan) bool {
+	if strings.TrimSpace(in.Name) == "" || in.DurationMin < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid workout plan data"})
+		return false
+	}
+	if _, err := utils.ParseRRule(in.RRule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid rrule: " + err.Error()})
+		return false
+	}
+	if in.StartDate == "" {
+		in.StartDate = time.Now().Format("2006-01-02")
+	}
+	if _, err := time.Parse("2006-01-02", in.StartDate); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "start_date must be in YYYY-MM-DD format"})
+		return false
+	}
+	if in.StartTime != "" {
+		if _, err := time.Parse("15:04", in.StartTime); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "start_time must be in HH:MM format"})
+			return false
+		}
+	}
+	if in.Timezone == "" {
+		in.Timezone = p.Timezone
+	}
+	if in.Timezone == "" {
+		in.Timezone = userTimezone(p.UserID)
+	}
+	if _, err := loadTimezone(in.Timezone); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+
+	exercise, ok := workoutExerciseFromInput(c, in.ExerciseID, in.Exercise, in.Intensity)
+	if !ok {
+		return false
+	}
+	workoutType, err := resolveWorkoutType(in.Type, exercise, in.Name)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+
+	p.Name = strings.TrimSpace(in.Name)
+	p.Type = workoutType
+	p.ExerciseID = nil
+	if exercise != nil {
+		p.ExerciseID = &exercise.ID
+	}
+	p.DurationMin = in.DurationMin
+	p.RRule = strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(in.RRule)), "RRULE:")
+	p.StartDate = in.StartDate
+	p.StartTime = nil
+	if in.StartTime != "" {
+		p.StartTime = &in.StartTime
+	}
+	p.Timezone = in.Timezone
+	if in.Active != nil {
+		p.Active = *in.Active
+	}
+	return true
+}
+
+// planOccurrenceDates expands the plan over [today, today+horizon], leaving out deleted occurrences
+func planOccurrenceDates(db dbRunner, p models.WorkoutPlan, today time.Time) ([]string, error) {
+	if !p.Active {
+		return []string{}, nil
+	}
+	rule, err := utils.ParseRRule(p.RRule)
+	if err != nil {
+		return nil, err
+	}
+	start, err := time.Parse("2006-01-02", p.StartDate)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(`SELECT occurrence_date::text FROM workout_plan_exceptions WHERE plan_id = $1`, p.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	skipped := map[string]bool{}
+	for rows.Next() {
+		var d string
+		if err := rows.Scan(&d); err != nil {
+			return nil, err
+		}
+		skipped[d] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	dates := []string{}
+	for _, d := range rule.Between(start, today, today.AddDate(0, 0, planHorizonDays)) {
+		if s := d.Format("2006-01-02"); !skipped[s] {
+			dates = append(dates, s)
+		}
+	}
+	return dates, nil
+}
+
+// syncPlanOccurrences brings the plan's upcoming workouts in line with the plan.
+// Occurrences edited on their own (detached) and past ones are left alone; upcoming ones that
+// still fall on the schedule take the plan's current fields, the rest are removed unless they
+// already have logged exercises, and missing dates inside the horizon are created.
+// It returns the number of workouts created.
+func syncPlanOccurrences(db dbRunner, p models.WorkoutPlan, today time.Time) (int, error) {
+	dates, err := planOccurrenceDates(db, p, today)
+	if err != nil {
+		return 0, err
+	}
+	todayStr := today.Format("2006-01-02")
+
+	var exercise *models.Exercise
+	if p.ExerciseID != nil {
+		if exercise, err = lookupExercise(p.ExerciseID, "", ""); err != nil {
+			return 0, err
+		}
+	}
+	calories, caloriesSource := resolveWorkoutCalories(p.UserID, exercise, p.DurationMin, 0)
+
+	if _, err := db.Exec(
+		`UPDATE workouts
+         SET name = $1, type = $2, exercise_id = $3, duration_minutes = $4, calories_burned = $5, calories_source = $6,
+             scheduled_at = (occurrence_date + $10::time) AT TIME ZONE $11, timezone = $11
+         WHERE plan_id = $7 AND detached = false AND status = 'planned' AND occurrence_date >= $8::date
+           AND occurrence_date = ANY($9::date[])`,
+		p.Name, p.Type, p.ExerciseID, p.DurationMin, calories, caloriesSource, p.ID, todayStr, pq.Array(dates),
+		p.StartTime, p.Timezone,
+	); err != nil {
+		return 0, err
+	}
+
+	if _, err := db.Exec(
+		`DELETE FROM workouts w
+         WHERE w.plan_id = $1 AND w.detached = false AND w.occurrence_date >= $2::date
+           AND NOT (w.occurrence_date = ANY($3::date[])) AND w.status = 'planned'
+           AND NOT EXISTS (SELECT 1 FROM workout_exercises we WHERE we.workout_id = w.id)`,
+		p.ID, todayStr, pq.Array(dates),
+	); err != nil {
+		return 0, err
+	}
+
+	created := 0
+	for _, d := range dates {
+		res, err := db.Exec(
+			`INSERT INTO workouts (user_id, name, duration_minutes, calories_burned, date, created_at, exercise_id,
+                                   calories_source, type, plan_id, occurrence_date, scheduled_at, timezone)
+             VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $5, ($5::date + $11::time) AT TIME ZONE $12, $12)
+             ON CONFLICT (plan_id, occurrence_date) WHERE plan_id IS NOT NULL DO NOTHING`,
+			p.UserID, p.Name, p.DurationMin, calories, d, time.Now(), p.ExerciseID, caloriesSource, p.Type, p.ID,
+			p.StartTime, p.Timezone,
+		)
+		if err != nil {
+			return created, err
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			created++
+		}
+	}
+	return created, nil
+}
+
+// loadPlanOccurrences lists the plan's workouts from today on
+func loadPlanOccurrences(planID uuid.UUID, today time.Time) ([]planOccurrence, error) {
+	rows, err := config.DB.Query(
+		`SELECT id, occurrence_date::text, date::text, detached
+         FROM workouts
+         WHERE plan_id = $1 AND occurrence_date >= $2::date
+         ORDER BY occurrence_date`,
+		planID, today.Format("2006-01-02"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	occurrences := []planOccurrence{}
+	for rows.Next() {
+		var o planOccurrence
+		if err := rows.Scan(&o.WorkoutID, &o.OccurrenceDate, &o.Date, &o.Detached); err != nil {
+			return nil, err
+		}
+		occurrences = append(occurrences, o)
+	}
+	return occurrences, rows.Err()
+}
+
+// planToday is the calendar day occurrences are materialized from: today in the plan's timezone,
+// as a UTC midnight like the dates the recurrence rule yields
+func planToday(timezone string) time.Time {
+	today, _ := time.Parse("2006-01-02", localToday(timezone))
+	return today
+}
+
+// planRequestIDs parses the user and plan IDs from the request.
+// It writes the error response itself and returns false when the request should stop.
+func planRequestIDs(c *gin.Context) (uuid.UUID, uuid.UUID, bool) {
+	userID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return uuid.Nil, uuid.Nil, false
+	}
+	planID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid plan ID"})
+		return uuid.Nil, uuid.Nil, false
+	}
+	return userID, planID, true
+}
+
+// respondWithPlan writes the plan together with its upcoming occurrences
+func respondWithPlan(c *gin.Context, status int, p models.WorkoutPlan) {
+	occurrences, err := loadPlanOccurrences(p.ID, planToday(p.Timezone))
+	if err != nil {
+		log.Println("DB SELECT ERROR (loadPlanOccurrences):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load plan occurrences"})
+		return
+	}
+	c.JSON(status, gin.H{
+		"plan":        p,
+		"occurrences": occurrences,
+	})
+}
+
+// CreateWorkoutPlan handles POST /user/workout-plans
+func CreateWorkoutPlan(c *gin.Context) {
+	userID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	var input planInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	plan := models.WorkoutPlan{UserID: userID, Active: true}
+	if !preparePlan(c, input, &plan) {
+		return
+	}
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		log.Printf("Failed to begin workout plan transaction: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create workout plan"})
+		return
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(
+		`INSERT INTO workout_plans (user_id, name, type, exercise_id, duration_min, rrule, start_date, start_time, timezone, active)
+         VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+         RETURNING id, created_at, updated_at`,
+		userID, plan.Name, plan.Type, plan.ExerciseID, plan.DurationMin, plan.RRule, plan.StartDate, plan.StartTime,
+		plan.Timezone, plan.Active,
+	).Scan(&plan.ID, &plan.CreatedAt, &plan.UpdatedAt)
+	if err != nil {
+		log.Printf("Failed to create workout plan: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create workout plan"})
+		return
+	}
+	if _, err := syncPlanOccurrences(tx, plan, planToday(plan.Timezone)); err != nil {
+		log.Printf("Failed to materialize workout plan: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create workout plan"})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("Failed to commit workout plan: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create workout plan"})
+		return
+	}
+
+	_ = CreateNotification(userID, &plan.ID, "🔁 New recurring workout: "+plan.Name)
+
+	respondWithPlan(c, http.StatusCreated, plan)
+}
+
+// GetWorkoutPlans handles GET /user/workout-plans
+func GetWorkoutPlans(c *gin.Context) {
+	userID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	rows, err := config.DB.Query(
+		`SELECT `+workoutPlanColumns+` FROM workout_plans WHERE user_id = $1 ORDER BY created_at DESC`,
+		userID,
+	)
+	if err != nil {
+		log.Println("DB SELECT ERROR (GetWorkoutPlans):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get workout plans"})
+		return
+	}
+	defer rows.Close()
+
+	plans := []models.WorkoutPlan{}
+	for rows.Next() {
+		p, err := scanWorkoutPlan(rows)
+		if err != nil {
+			log.Println("DB SCAN ERROR (GetWorkoutPlans):", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse workout plans"})
+			return
+		}
+		plans = append(plans, p)
+	}
+
+	c.JSON(http.StatusOK, plans)
+}
+
+// GetWorkoutPlan handles GET /user/workout-plans/:id
+func GetWorkoutPlan(c *gin.Context) {
+	userID, planID, ok := planRequestIDs(c)
+	if !ok {
+		return
+	}
+
+	plan, err := scanWorkoutPlan(config.DB.QueryRow(
+		`SELECT `+workoutPlanColumns+` FROM workout_plans WHERE id = $1 AND user_id = $2`,
+		planID, userID,
+	))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Workout plan not found"})
+		return
+	}
+	if err != nil {
+		log.Println("DB SELECT ERROR (GetWorkoutPlan):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get workout plan"})
+		return
+	}
+
+	respondWithPlan(c, http.StatusOK, plan)
+}
+
+// UpdateWorkoutPlan handles PUT /user/workout-plans/:id.
+// It edits the whole series: upcoming occurrences follow the new plan, except ones edited on their own.
+func UpdateWorkoutPlan(c *gin.Context) {
+	userID, planID, ok := planRequestIDs(c)
+	if !ok {
+		return
+	}
+
+	plan, err := scanWorkoutPlan(config.DB.QueryRow(
+		`SELECT `+workoutPlanColumns+` FROM workout_plans WHERE id = $1 AND user_id = $2`,
+		planID, userID,
+	))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Workout plan not found"})
+		return
+	}
+	if err != nil {
+		log.Println("DB SELECT ERROR (UpdateWorkoutPlan):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update workout plan"})
+		return
+	}
+
+	var input planInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.StartDate == "" {
+		input.StartDate = plan.StartDate
+	}
+	if !preparePlan(c, input, &plan) {
+		return
+	}
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		log.Printf("Failed to begin workout plan transaction: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update workout plan"})
+		return
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(
+		`UPDATE workout_plans
+         SET name = $1, type = $2, exercise_id = $3, duration_min = $4, rrule = $5, start_date = $6, active = $7,
+             start_time = $8, timezone = $9, updated_at = NOW()
+         WHERE id = $10 AND user_id = $11
+         RETURNING updated_at`,
+		plan.Name, plan.Type, plan.ExerciseID, plan.DurationMin, plan.RRule, plan.StartDate, plan.Active,
+		plan.StartTime, plan.Timezone, planID, userID,
+	).Scan(&plan.UpdatedAt)
+	if err != nil {
+		log.Printf("Failed to update workout plan: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update workout plan"})
+		return
+	}
+	if _, err := syncPlanOccurrences(tx, plan, planToday(plan.Timezone)); err != nil {
+		log.Printf("Failed to materialize workout plan: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update workout plan"})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("Failed to commit workout plan: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update workout plan"})
+		return
+	}
+
+	respondWithPlan(c, http.StatusOK, plan)
+}
+
+// DeleteWorkoutPlan handles DELETE /user/workout-plans/:id.
+// Upcoming occurrences that weren't edited or logged go with it; past workouts are kept as history.
+func DeleteWorkoutPlan(c *gin.Context) {
+	userID, planID, ok := planRequestIDs(c)
+	if !ok {
+		return
+	}
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		log.Printf("Failed to begin workout plan transaction: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete workout plan"})
+		return
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(
+		`DELETE FROM workouts w
+         USING workout_plans p
+         WHERE w.plan_id = p.id AND p.id = $1 AND p.user_id = $2
+           AND w.detached = false AND w.occurrence_date >= (now() AT TIME ZONE p.timezone)::date
+           AND NOT EXISTS (SELECT 1 FROM workout_exercises we WHERE we.workout_id = w.id)`,
+		planID, userID,
+	)
+	if err != nil {
+		log.Printf("Failed to delete plan occurrences: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete workout plan"})
+		return
+	}
+
+	res, err := tx.Exec(`DELETE FROM workout_plans WHERE id = $1 AND user_id = $2`, planID, userID)
+	if err != nil {
+		log.Printf("Failed to delete workout plan: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete workout plan"})
+		return
+	}
+	if count, _ := res.RowsAffected(); count == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Workout plan not found"})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("Failed to commit workout plan delete: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete workout plan"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Workout plan deleted successfully"})
+}
+
+// MaterializeWorkoutPlans extends every active plan's occurrences to the rolling horizon
+func MaterializeWorkoutPlans() {
+	rows, err := config.DB.Query(`SELECT ` + workoutPlanColumns + ` FROM workout_plans WHERE active = true`)
+	if err != nil {
+		log.Println("DB SELECT ERROR (MaterializeWorkoutPlans):", err)
+		return
+	}
+	var plans []models.WorkoutPlan
+	for rows.Next() {
+		p, err := scanWorkoutPlan(rows)
+		if err != nil {
+			log.Println("DB SCAN ERROR (MaterializeWorkoutPlans):", err)
+			continue
+		}
+		plans = append(plans, p)
+	}
+	rows.Close()
+
+	created := 0
+	for _, p := range plans {
+		n, err := syncPlanOccurrences(config.DB, p, planToday(p.Timezone))
+		if err != nil {
+			log.Printf("Failed to materialize workout plan %s: %v", p.ID, err)
+		}
+		created += n
+	}
+	log.Printf("🔁 Materialized %d workout plan occurrences", created)
+}
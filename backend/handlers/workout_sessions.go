@@ -0,0 +1,589 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strings"
+
+	"nutritionix/backend/config"
+	"nutritionix/backend/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// dbRunner is satisfied by both *sql.DB and *sql.Tx
+type dbRunner interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// setInput is the request body for a logged set
+type setInput struct {
+	Reps        int      `json:"reps"`
+	WeightKg    float64  `json:"weight_kg"`
+	RPE         *float64 `json:"rpe"`
+	RestSeconds *int     `json:"rest_seconds"`
+	IsWarmup    bool     `json:"is_warmup"`
+}
+
+// exerciseInput is the request body for an exercise within a workout, optionally with its sets
+type exerciseInput struct {
+	ExerciseID *int       `json:"exercise_id"`
+	Exercise   string     `json:"exercise"`  // catalog slug, alternative to exercise_id
+	Intensity  string     `json:"intensity"` // used with exercise
+	Name       string     `json:"name"`      // defaults to the catalog name
+	Notes      string     `json:"notes"`
+	Sets       []setInput `json:"sets"`
+}
+
+// validateSetInput enforces ranges for reps, load, RPE and rest
+func validateSetInput(s setInput) error {
+	if s.Reps < 0 || s.Reps > 1000 {
+		return fmt.Errorf("reps must be between 0 and 1000")
+	}
+	if s.WeightKg < 0 || s.WeightKg > 2000 {
+		return fmt.Errorf("weight_kg must be between 0 and 2000")
+	}
+	if s.RPE != nil && (*s.RPE < 1 || *s.RPE > 10) {
+		return fmt.Errorf("rpe must be between 1 and 10")
+	}
+	if s.RestSeconds != nil && (*s.RestSeconds < 0 || *s.RestSeconds > 3600) {
+		return fmt.Errorf("rest_seconds must be between 0 and 3600")
+	}
+	return nil
+}
+
+// setVolume is reps × load; warm-up sets don't count toward volume
+func setVolume(s models.WorkoutSet) float64 {
+	if s.IsWarmup {
+		return 0
+	}
+	return float64(s.Reps) * s.WeightKg
+}
+
+// prepareExerciseInput validates an exercise and its sets, resolving the catalog reference and default name.
+// The returned status is the HTTP status to use when err is not nil.
+func prepareExerciseInput(in exerciseInput) (exerciseInput, int, error) {
+	if in.ExerciseID != nil || strings.TrimSpace(in.Exercise) != "" {
+		exercise, err := lookupExercise(in.ExerciseID, in.Exercise, in.Intensity)
+		if err == errExerciseNotFound {
+			return in, http.StatusBadRequest, err
+		}
+		if err != nil {
+			log.Printf("Failed to look up exercise: %v", err)
+			return in, http.StatusInternalServerError, fmt.Errorf("failed to look up exercise")
+		}
+		in.ExerciseID = &exercise.ID
+		if strings.TrimSpace(in.Name) == "" {
+			in.Name = exercise.Name
+		}
+	}
+	in.Name = strings.TrimSpace(in.Name)
+	if in.Name == "" {
+		return in, http.StatusBadRequest, fmt.Errorf("exercise name or catalog exercise is required")
+	}
+	for _, s := range in.Sets {
+		if err := validateSetInput(s); err != nil {
+			return in, http.StatusBadRequest, err
+		}
+	}
+	return in, http.StatusOK, nil
+}
+
+// insertWorkoutExercise appends a prepared exercise (and its sets) to the end of a workout
+func insertWorkoutExercise(db dbRunner, workoutID uuid.UUID, in exerciseInput) (uuid.UUID, error) {
+	var exerciseRowID uuid.UUID
+	err := db.QueryRow(
+		`INSERT INTO workout_exercises (workout_id, exercise_id, name, position, notes)
+         VALUES ($1, $2, $3, (SELECT COALESCE(MAX(position), 0) + 1 FROM workout_exercises WHERE workout_id = $1), $4)
+         RETURNING id`,
+		workoutID, in.ExerciseID, in.Name, in.Notes,
+	).Scan(&exerciseRowID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	for _, s := range in.Sets {
+		if _, err := insertWorkoutSet(db, exerciseRowID, s); err != nil {
+			return uuid.Nil, err
+		}
+	}
+	return exerciseRowID, nil
+}
+
+// insertWorkoutSet appends a set to a workout exercise, numbering it after the existing sets.
+// Adding to an exercise others may be adding to needs its row locked first, in the same transaction.
+func insertWorkoutSet(db dbRunner, workoutExerciseID uuid.UUID, s setInput) (uuid.UUID, error) {
+	var setID uuid.UUID
+	err := db.QueryRow(
+		`INSERT INTO workout_sets (workout_exercise_id, set_number, reps, weight_kg, rpe, rest_seconds, is_warmup)
+         VALUES ($1, (SELECT COALESCE(MAX(set_number), 0) + 1 FROM workout_sets WHERE workout_exercise_id = $1), $2, $3, $4, $5, $6)
+         RETURNING id`,
+		workoutExerciseID, s.Reps, s.WeightKg, s.RPE, s.RestSeconds, s.IsWarmup,
+	).Scan(&setID)
+	return setID, err
+}
+
+// loadWorkoutSession returns a workout's exercises in order with their sets, plus total volume
+func loadWorkoutSession(db dbRunner, workoutID uuid.UUID) ([]models.WorkoutExercise, float64, error) {
+	rows, err := db.Query(
+		`SELECT we.id, we.workout_id, we.exercise_id, we.name, we.position, we.notes, we.created_at,
+                ws.id, ws.set_number, ws.reps, ws.weight_kg, ws.rpe, ws.rest_seconds, ws.is_warmup, ws.completed_at
+         FROM workout_exercises we
+         LEFT JOIN workout_sets ws ON ws.workout_exercise_id = we.id
+         WHERE we.workout_id = $1
+         ORDER BY we.position, ws.set_number`,
+		workoutID,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	exercises := []models.WorkoutExercise{}
+	totalVolume := 0.0
+	for rows.Next() {
+		var e models.WorkoutExercise
+		var exerciseID sql.NullInt64
+		var setID uuid.NullUUID
+		var setNumber, reps, restSeconds sql.NullInt64
+		var weight, rpe sql.NullFloat64
+		var isWarmup sql.NullBool
+		var completedAt sql.NullTime
+		if err := rows.Scan(
+			&e.ID, &e.WorkoutID, &exerciseID, &e.Name, &e.Position, &e.Notes, &e.CreatedAt,
+			&setID, &setNumber, &reps, &weight, &rpe, &restSeconds, &isWarmup, &completedAt,
+		); err != nil {
+			return nil, 0, err
+		}
+
+		if len(exercises) == 0 || exercises[len(exercises)-1].ID != e.ID {
+			if exerciseID.Valid {
+				id := int(exerciseID.Int64)
+				e.ExerciseID = &id
+			}
+			e.Sets = []models.WorkoutSet{}
+			exercises = append(exercises, e)
+		}
+		if !setID.Valid {
+			continue
+		}
+
+		current := &exercises[len(exercises)-1]
+		s := models.WorkoutSet{
+			ID:                setID.UUID,
+			WorkoutExerciseID: current.ID,
+			SetNumber:         int(setNumber.Int64),
+			Reps:              int(reps.Int64),
+			WeightKg:          weight.Float64,
+			IsWarmup:          isWarmup.Bool,
+			CompletedAt:       completedAt.Time,
+		}
+		if rpe.Valid {
+			s.RPE = &rpe.Float64
+		}
+		if restSeconds.Valid {
+			rest := int(restSeconds.Int64)
+			s.RestSeconds = &rest
+		}
+		current.Sets = append(current.Sets, s)
+		current.VolumeKg += setVolume(s)
+		totalVolume += setVolume(s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	for i := range exercises {
+		exercises[i].VolumeKg = math.Round(exercises[i].VolumeKg*100) / 100
+	}
+	return exercises, math.Round(totalVolume*100) / 100, nil
+}
+
+// workoutBelongsToUser reports whether the workout exists and is owned by the user
+func workoutBelongsToUser(workoutID, userID uuid.UUID) (bool, error) {
+	var exists bool
+	err := config.DB.QueryRow(
+		`SELECT EXISTS (SELECT 1 FROM workouts WHERE id = $1 AND user_id = $2)`,
+		workoutID, userID,
+	).Scan(&exists)
+	return exists, err
+}
+
+// sessionRequestIDs parses the user and workout IDs and checks ownership.
+// It writes the error response itself and returns false when the request should stop.
+func sessionRequestIDs(c *gin.Context) (uuid.UUID, uuid.UUID, bool) {
+	userID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return uuid.Nil, uuid.Nil, false
+	}
+	workoutID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid workout ID"})
+		return uuid.Nil, uuid.Nil, false
+	}
+	owned, err := workoutBelongsToUser(workoutID, userID)
+	if err != nil {
+		log.Println("DB SELECT ERROR (workoutBelongsToUser):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load workout"})
+		return uuid.Nil, uuid.Nil, false
+	}
+	if !owned {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Workout not found"})
+		return uuid.Nil, uuid.Nil, false
+	}
+	return userID, workoutID, true
+}
+
+// respondWithSession writes the workout's current exercises, sets and volume
+func respondWithSession(c *gin.Context, status int, workoutID uuid.UUID) {
+	exercises, totalVolume, err := loadWorkoutSession(config.DB, workoutID)
+	if err != nil {
+		log.Println("DB SELECT ERROR (loadWorkoutSession):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load workout session"})
+		return
+	}
+	c.JSON(status, gin.H{
+		"workout_id":      workoutID,
+		"exercises":       exercises,
+		"total_volume_kg": totalVolume,
+	})
+}
+
+// GetWorkoutSession handles GET /user/workouts/:id/exercises
+func GetWorkoutSession(c *gin.Context) {
+	_, workoutID, ok := sessionRequestIDs(c)
+	if !ok {
+		return
+	}
+	respondWithSession(c, http.StatusOK, workoutID)
+}
+
+// AddWorkoutExercise handles POST /user/workouts/:id/exercises
+func AddWorkoutExercise(c *gin.Context) {
+	_, workoutID, ok := sessionRequestIDs(c)
+	if !ok {
+		return
+	}
+
+	var input exerciseInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	input, status, err := prepareExerciseInput(input)
+	if err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		log.Println("DB TX ERROR (AddWorkoutExercise):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add exercise"})
+		return
+	}
+	defer tx.Rollback()
+
+	if _, err := insertWorkoutExercise(tx, workoutID, input); err != nil {
+		log.Println("DB INSERT ERROR (AddWorkoutExercise):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add exercise"})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		log.Println("DB COMMIT ERROR (AddWorkoutExercise):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add exercise"})
+		return
+	}
+
+	respondWithSession(c, http.StatusCreated, workoutID)
+}
+
+// UpdateWorkoutExercise handles PUT /user/workouts/:id/exercises/:exerciseId (rename, notes, reorder)
+func UpdateWorkoutExercise(c *gin.Context) {
+	_, workoutID, ok := sessionRequestIDs(c)
+	if !ok {
+		return
+	}
+	exerciseRowID, err := uuid.Parse(c.Param("exerciseId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid exercise ID"})
+		return
+	}
+
+	var input struct {
+		Name     *string `json:"name"`
+		Notes    *string `json:"notes"`
+		Position *int    `json:"position"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.Name != nil && strings.TrimSpace(*input.Name) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name cannot be empty"})
+		return
+	}
+	if input.Position != nil && *input.Position < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "position must be at least 1"})
+		return
+	}
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		log.Println("DB TX ERROR (UpdateWorkoutExercise):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update exercise"})
+		return
+	}
+	defer tx.Rollback()
+
+	var currentPosition int
+	err = tx.QueryRow(
+		`SELECT position FROM workout_exercises WHERE id = $1 AND workout_id = $2 FOR UPDATE`,
+		exerciseRowID, workoutID,
+	).Scan(&currentPosition)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Exercise not found"})
+		return
+	}
+	if err != nil {
+		log.Println("DB SELECT ERROR (UpdateWorkoutExercise):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update exercise"})
+		return
+	}
+
+	// Moving an exercise shifts the ones in between by one place
+	if input.Position != nil && *input.Position != currentPosition {
+		var count int
+		if err := tx.QueryRow(`SELECT COUNT(*) FROM workout_exercises WHERE workout_id = $1`, workoutID).Scan(&count); err != nil {
+			log.Println("DB SELECT ERROR (UpdateWorkoutExercise):", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update exercise"})
+			return
+		}
+		newPosition := *input.Position
+		if newPosition > count {
+			newPosition = count
+		}
+		if newPosition < currentPosition {
+			_, err = tx.Exec(
+				`UPDATE workout_exercises SET position = position + 1
+                 WHERE workout_id = $1 AND position >= $2 AND position < $3`,
+				workoutID, newPosition, currentPosition,
+			)
+		} else {
+			_, err = tx.Exec(
+				`UPDATE workout_exercises SET position = position - 1
+                 WHERE workout_id = $1 AND position > $2 AND position <= $3`,
+				workoutID, currentPosition, newPosition,
+			)
+		}
+		if err == nil {
+			_, err = tx.Exec(`UPDATE workout_exercises SET position = $1 WHERE id = $2`, newPosition, exerciseRowID)
+		}
+		if err != nil {
+			log.Println("DB UPDATE ERROR (UpdateWorkoutExercise):", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reorder exercise"})
+			return
+		}
+	}
+
+	if _, err := tx.Exec(
+		`UPDATE workout_exercises SET name = COALESCE($1, name), notes = COALESCE($2, notes) WHERE id = $3`,
+		input.Name, input.Notes, exerciseRowID,
+	); err != nil {
+		log.Println("DB UPDATE ERROR (UpdateWorkoutExercise):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update exercise"})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		log.Println("DB COMMIT ERROR (UpdateWorkoutExercise):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update exercise"})
+		return
+	}
+
+	respondWithSession(c, http.StatusOK, workoutID)
+}
+
+// DeleteWorkoutExercise handles DELETE /user/workouts/:id/exercises/:exerciseId
+func DeleteWorkoutExercise(c *gin.Context) {
+	_, workoutID, ok := sessionRequestIDs(c)
+	if !ok {
+		return
+	}
+	exerciseRowID, err := uuid.Parse(c.Param("exerciseId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid exercise ID"})
+		return
+	}
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		log.Println("DB TX ERROR (DeleteWorkoutExercise):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete exercise"})
+		return
+	}
+	defer tx.Rollback()
+
+	var position int
+	err = tx.QueryRow(
+		`DELETE FROM workout_exercises WHERE id = $1 AND workout_id = $2 RETURNING position`,
+		exerciseRowID, workoutID,
+	).Scan(&position)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Exercise not found"})
+		return
+	}
+	if err == nil {
+		_, err = tx.Exec(
+			`UPDATE workout_exercises SET position = position - 1 WHERE workout_id = $1 AND position > $2`,
+			workoutID, position,
+		)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		log.Println("DB DELETE ERROR (DeleteWorkoutExercise):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete exercise"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Exercise removed from workout"})
+}
+
+// AddWorkoutSet handles POST /user/workouts/:id/exercises/:exerciseId/sets during a live session
+func AddWorkoutSet(c *gin.Context) {
+	_, workoutID, ok := sessionRequestIDs(c)
+	if !ok {
+		return
+	}
+	exerciseRowID, err := uuid.Parse(c.Param("exerciseId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid exercise ID"})
+		return
+	}
+
+	var input setInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validateSetInput(input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tx, err := config.DB.Begin()
+	if err != nil {
+		log.Println("DB BEGIN ERROR (AddWorkoutSet):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add set"})
+		return
+	}
+	defer tx.Rollback()
+
+	// Locking the exercise queues concurrent adds, so each numbers its set after the one before it
+	var locked uuid.UUID
+	err = tx.QueryRow(
+		`SELECT id FROM workout_exercises WHERE id = $1 AND workout_id = $2 FOR UPDATE`,
+		exerciseRowID, workoutID,
+	).Scan(&locked)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Exercise not found"})
+		return
+	}
+	if err != nil {
+		log.Println("DB SELECT ERROR (AddWorkoutSet):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add set"})
+		return
+	}
+
+	_, err = insertWorkoutSet(tx, exerciseRowID, input)
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		log.Println("DB INSERT ERROR (AddWorkoutSet):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add set"})
+		return
+	}
+
+	respondWithSession(c, http.StatusCreated, workoutID)
+}
+
+// UpdateWorkoutSet handles PUT /user/workouts/:id/sets/:setId
+func UpdateWorkoutSet(c *gin.Context) {
+	_, workoutID, ok := sessionRequestIDs(c)
+	if !ok {
+		return
+	}
+	setID, err := uuid.Parse(c.Param("setId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid set ID"})
+		return
+	}
+
+	var input setInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validateSetInput(input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	res, err := config.DB.Exec(
+		`UPDATE workout_sets ws
+         SET reps = $1, weight_kg = $2, rpe = $3, rest_seconds = $4, is_warmup = $5
+         FROM workout_exercises we
+         WHERE ws.workout_exercise_id = we.id AND ws.id = $6 AND we.workout_id = $7`,
+		input.Reps, input.WeightKg, input.RPE, input.RestSeconds, input.IsWarmup, setID, workoutID,
+	)
+	if err != nil {
+		log.Println("DB UPDATE ERROR (UpdateWorkoutSet):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update set"})
+		return
+	}
+	if count, _ := res.RowsAffected(); count == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Set not found"})
+		return
+	}
+
+	respondWithSession(c, http.StatusOK, workoutID)
+}
+
+// DeleteWorkoutSet handles DELETE /user/workouts/:id/sets/:setId
+func DeleteWorkoutSet(c *gin.Context) {
+	_, workoutID, ok := sessionRequestIDs(c)
+	if !ok {
+		return
+	}
+	setID, err := uuid.Parse(c.Param("setId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid set ID"})
+		return
+	}
+
+	res, err := config.DB.Exec(
+		`DELETE FROM workout_sets ws
+         USING workout_exercises we
+         WHERE ws.workout_exercise_id = we.id AND ws.id = $1 AND we.workout_id = $2`,
+		setID, workoutID,
+	)
+	if err != nil {
+		log.Println("DB DELETE ERROR (DeleteWorkoutSet):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete set"})
+		return
+	}
+	if count, _ := res.RowsAffected(); count == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Set not found"})
+		return
+	}
+
+	respondWithSession(c, http.StatusOK, workoutID)
+}
@@ -0,0 +1,465 @@
+package handlers
+
+import (
+	"database/sql"
+	"log"
+	"math"
+	"net/http"
+	"time"
+
+	"nutritionix/backend/config"
+	"nutritionix/backend/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Workout statuses
+const (
+	WorkoutPlanned    = "planned"
+	WorkoutInProgress = "in_progress"
+	WorkoutCompleted  = "completed"
+	WorkoutSkipped    = "skipped"
+	WorkoutMissed     = "missed"
+)
+
+// adherenceWeeks is the default reporting window for GetWorkoutAdherence
+const adherenceWeeks = 12
+
+// localToday is the current calendar day in the given IANA timezone
+func localToday(timezone string) string {
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	return time.Now().In(loc).Format("2006-01-02")
+}
+
+// initialWorkoutStatus decides whether a new workout is a plan or a log of something already done.
+// Workouts dated before today, or posted with logged sets, are logs.
+func initialWorkoutStatus(requested, date, timezone string, loggedSets bool) string {
+	if requested == WorkoutPlanned || requested == WorkoutCompleted {
+		return requested
+	}
+	if loggedSets || date < localToday(timezone) {
+		return WorkoutCompleted
+	}
+	return WorkoutPlanned
+}
+
+// workoutStatusRow is what the lifecycle endpoints need to know about a workout
+type workoutStatusRow struct {
+	Name           string
+	Status         string
+	DurationMin    int
+	StartedAt      sql.NullTime
+	ExerciseID     sql.NullInt64
+	CaloriesSource string
+}
+
+func loadWorkoutStatus(workoutID uuid.UUID) (workoutStatusRow, error) {
+	var w workoutStatusRow
+	err := config.DB.QueryRow(
+		`SELECT name, status, duration_minutes, started_at, exercise_id, calories_source FROM workouts WHERE id = $1`,
+		workoutID,
+	).Scan(&w.Name, &w.Status, &w.DurationMin, &w.StartedAt, &w.ExerciseID, &w.CaloriesSource)
+	return w, err
+}
+
+// respondWithStatus writes the workout's lifecycle fields
+func respondWithStatus(c *gin.Context, workoutID uuid.UUID, extra gin.H) {
+	var status string
+	var startedAt, completedAt sql.NullTime
+	var plannedMin int
+	var actualMin sql.NullInt64
+	err := config.DB.QueryRow(
+		`SELECT status, started_at, completed_at, duration_minutes, actual_duration_min FROM workouts WHERE id = $1`,
+		workoutID,
+	).Scan(&status, &startedAt, &completedAt, &plannedMin, &actualMin)
+	if err != nil {
+		log.Println("DB SELECT ERROR (respondWithStatus):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load workout"})
+		return
+	}
+
+	resp := gin.H{
+		"workout_id":           workoutID,
+		"status":               status,
+		"started_at":           nil,
+		"completed_at":         nil,
+		"planned_duration_min": plannedMin,
+		"actual_duration_min":  nil,
+	}
+	if startedAt.Valid {
+		resp["started_at"] = startedAt.Time
+	}
+	if completedAt.Valid {
+		resp["completed_at"] = completedAt.Time
+	}
+	if actualMin.Valid {
+		resp["actual_duration_min"] = actualMin.Int64
+	}
+	for k, v := range extra {
+		resp[k] = v
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// StartWorkout handles POST /user/workouts/:id/start
+func StartWorkout(c *gin.Context) {
+	userID, workoutID, ok := sessionRequestIDs(c)
+	if !ok {
+		return
+	}
+
+	res, err := config.DB.Exec(
+		`UPDATE workouts SET status = $1, started_at = NOW()
+         WHERE id = $2 AND user_id = $3 AND status IN ($4, $5)`,
+		WorkoutInProgress, workoutID, userID, WorkoutPlanned, WorkoutMissed,
+	)
+	if err != nil {
+		log.Println("DB UPDATE ERROR (StartWorkout):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start workout"})
+		return
+	}
+	if count, _ := res.RowsAffected(); count == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Only planned or missed workouts can be started"})
+		return
+	}
+
+	respondWithStatus(c, workoutID, nil)
+}
+
+// CompleteWorkout handles POST /user/workouts/:id/complete.
+// The actual duration comes from the body, or from the time since /start; calories estimated
+// from the catalog are recomputed for it, and the session is checked for personal records.
+func CompleteWorkout(c *gin.Context) {
+	userID, workoutID, ok := sessionRequestIDs(c)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		ActualDurationMin *int `json:"actual_duration_min"`
+		SessionRPE        *int `json:"session_rpe"` // how hard the session felt, 1-10
+	}
+	if err := c.ShouldBindJSON(&input); err != nil && err.Error() != "EOF" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.ActualDurationMin != nil && (*input.ActualDurationMin < 0 || *input.ActualDurationMin > 1440) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "actual_duration_min must be between 0 and 1440"})
+		return
+	}
+	if err := validateSessionRPE(input.SessionRPE); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	w, err := loadWorkoutStatus(workoutID)
+	if err != nil {
+		log.Println("DB SELECT ERROR (CompleteWorkout):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to complete workout"})
+		return
+	}
+	if w.Status == WorkoutCompleted || w.Status == WorkoutSkipped {
+		c.JSON(http.StatusConflict, gin.H{"error": "Workout is already " + w.Status})
+		return
+	}
+
+	actual := w.DurationMin
+	switch {
+	case input.ActualDurationMin != nil:
+		actual = *input.ActualDurationMin
+	case w.StartedAt.Valid:
+		// A session left running overnight shouldn't claim more than a day
+		actual = min(int(math.Round(time.Since(w.StartedAt.Time).Minutes())), 1440)
+	}
+
+	var calories interface{}
+	if w.CaloriesSource == CaloriesSourceMETEstimate && w.ExerciseID.Valid {
+		id := int(w.ExerciseID.Int64)
+		if exercise, err := lookupExercise(&id, "", ""); err == nil {
+			calories, _ = resolveWorkoutCalories(userID, exercise, actual, 0)
+		}
+	}
+
+	// The status check is repeated here so two concurrent requests can't both complete the workout
+	res, err := config.DB.Exec(
+		`UPDATE workouts
+         SET status = $1, completed_at = NOW(), actual_duration_min = $2,
+             calories_burned = COALESCE($3, calories_burned), session_rpe = COALESCE($4, session_rpe)
+         WHERE id = $5 AND user_id = $6 AND status NOT IN ($1, $7)`,
+		WorkoutCompleted, actual, calories, input.SessionRPE, workoutID, userID, WorkoutSkipped,
+	)
+	if err != nil {
+		log.Println("DB UPDATE ERROR (CompleteWorkout):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to complete workout"})
+		return
+	}
+	if count, _ := res.RowsAffected(); count == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Completed or skipped workouts can't be completed"})
+		return
+	}
+
+	records, err := detectPersonalRecords(userID, workoutID)
+	if err != nil {
+		log.Println("PR DETECTION ERROR:", err)
+	}
+	if records == nil {
+		records = []models.PersonalRecord{}
+	}
+
+	_ = CreateNotification(userID, &workoutID, "✅ Workout completed: "+w.Name)
+	RecomputeGoalProgress(userID)
+	CheckAchievements(userID, EventWorkoutCompleted)
+
+	respondWithStatus(c, workoutID, gin.H{"personal_records": records})
+}
+
+// SkipWorkout handles POST /user/workouts/:id/skip
+func SkipWorkout(c *gin.Context) {
+	userID, workoutID, ok := sessionRequestIDs(c)
+	if !ok {
+		return
+	}
+
+	res, err := config.DB.Exec(
+		`UPDATE workouts SET status = $1
+         WHERE id = $2 AND user_id = $3 AND status IN ($4, $5, $6)`,
+		WorkoutSkipped, workoutID, userID, WorkoutPlanned, WorkoutInProgress, WorkoutMissed,
+	)
+	if err != nil {
+		log.Println("DB UPDATE ERROR (SkipWorkout):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to skip workout"})
+		return
+	}
+	if count, _ := res.RowsAffected(); count == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Completed or skipped workouts can't be skipped"})
+		return
+	}
+
+	respondWithStatus(c, workoutID, nil)
+}
+
+// MarkMissedWorkouts closes out workouts whose day has passed in their own timezone.
+// Sessions with logged sets are counted as completed; anything else still planned or in progress is missed.
+func MarkMissedWorkouts() {
+	rows, err := config.DB.Query(
+		`UPDATE workouts w
+         SET status = $1,
+             completed_at = (SELECT MAX(ws.completed_at) FROM workout_exercises we
+                             JOIN workout_sets ws ON ws.workout_exercise_id = we.id WHERE we.workout_id = w.id)
+         WHERE w.status IN ($2, $3)
+           AND w.date < (NOW() AT TIME ZONE w.timezone)::date
+           AND EXISTS (SELECT 1 FROM workout_exercises we JOIN workout_sets ws ON ws.workout_exercise_id = we.id
+                       WHERE we.workout_id = w.id)
+         RETURNING w.id, w.user_id`,
+		WorkoutCompleted, WorkoutPlanned, WorkoutInProgress,
+	)
+	if err != nil {
+		log.Println("DB UPDATE ERROR (MarkMissedWorkouts):", err)
+		return
+	}
+	completed := map[uuid.UUID]uuid.UUID{} // workout → user
+	users := map[uuid.UUID]bool{}
+	for rows.Next() {
+		var workoutID, userID uuid.UUID
+		if err := rows.Scan(&workoutID, &userID); err != nil {
+			log.Println("DB SCAN ERROR (MarkMissedWorkouts):", err)
+			continue
+		}
+		completed[workoutID] = userID
+		users[userID] = true
+	}
+	rows.Close()
+	// Workouts completed from their logged sets are checked for PRs and count towards goals
+	for workoutID, userID := range completed {
+		if _, err := detectPersonalRecords(userID, workoutID); err != nil {
+			log.Println("PR DETECTION ERROR (MarkMissedWorkouts):", err)
+		}
+	}
+	for userID := range users {
+		RecomputeGoalProgress(userID)
+		CheckAchievements(userID, EventWorkoutCompleted)
+	}
+
+	res, err := config.DB.Exec(
+		`UPDATE workouts SET status = $1
+         WHERE status IN ($2, $3) AND date < (NOW() AT TIME ZONE timezone)::date`,
+		WorkoutMissed, WorkoutPlanned, WorkoutInProgress,
+	)
+	if err != nil {
+		log.Println("DB UPDATE ERROR (MarkMissedWorkouts):", err)
+		return
+	}
+	missed, _ := res.RowsAffected()
+	log.Printf("📋 Workout status job: %d completed from logged sets, %d missed", len(completed), missed)
+}
+
+// adherenceStats counts how planned workouts turned out
+type adherenceStats struct {
+	Completed      int      `json:"completed"`
+	Skipped        int      `json:"skipped"`
+	Missed         int      `json:"missed"`
+	Upcoming       int      `json:"upcoming"`        // still planned or in progress
+	AdherenceRate  *float64 `json:"adherence_rate"`  // completed / (completed + skipped + missed), null when nothing was due
+	PlannedMinutes int      `json:"planned_minutes"` // planned duration of the completed workouts
+	ActualMinutes  int      `json:"actual_minutes"`
+}
+
+// adherenceSelect aggregates workouts into adherenceStats columns
+const adherenceSelect = `
+    COUNT(*) FILTER (WHERE w.status = 'completed'),
+    COUNT(*) FILTER (WHERE w.status = 'skipped'),
+    COUNT(*) FILTER (WHERE w.status = 'missed'),
+    COUNT(*) FILTER (WHERE w.status IN ('planned', 'in_progress')),
+    COALESCE(SUM(w.duration_minutes) FILTER (WHERE w.status = 'completed'), 0),
+    COALESCE(SUM(COALESCE(w.actual_duration_min, w.duration_minutes)) FILTER (WHERE w.status = 'completed'), 0)`
+
+func (s *adherenceStats) scanArgs() []interface{} {
+	return []interface{}{&s.Completed, &s.Skipped, &s.Missed, &s.Upcoming, &s.PlannedMinutes, &s.ActualMinutes}
+}
+
+func (s *adherenceStats) computeRate() {
+	if due := s.Completed + s.Skipped + s.Missed; due > 0 {
+		rate := math.Round(float64(s.Completed)/float64(due)*1000) / 1000
+		s.AdherenceRate = &rate
+	}
+}
+
+// GetWorkoutAdherence handles GET /user/workouts/adherence?from=&to=
+// Only workouts that were planned ahead count; logs of unplanned workouts are left out.
+func GetWorkoutAdherence(c *gin.Context) {
+	userID, err := uuid.Parse(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	to := c.DefaultQuery("to", localToday(userTimezone(userID)))
+	toDate, err := time.Parse("2006-01-02", to)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "to must be in YYYY-MM-DD format"})
+		return
+	}
+	from := c.DefaultQuery("from", toDate.AddDate(0, 0, -7*adherenceWeeks+1).Format("2006-01-02"))
+	if _, err := time.Parse("2006-01-02", from); err != nil || from > to {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must be a YYYY-MM-DD date on or before to"})
+		return
+	}
+
+	var overall adherenceStats
+	err = config.DB.QueryRow(
+		`SELECT `+adherenceSelect+` FROM workouts w
+         WHERE w.user_id = $1 AND w.was_planned AND w.date BETWEEN $2 AND $3`,
+		userID, from, to,
+	).Scan(overall.scanArgs()...)
+	if err != nil {
+		log.Println("DB SELECT ERROR (GetWorkoutAdherence):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute adherence"})
+		return
+	}
+	overall.computeRate()
+
+	type weekAdherence struct {
+		WeekStart string `json:"week_start"`
+		adherenceStats
+	}
+	weeks := []weekAdherence{}
+	rows, err := config.DB.Query(
+		`SELECT date_trunc('week', w.date)::date::text, `+adherenceSelect+` FROM workouts w
+         WHERE w.user_id = $1 AND w.was_planned AND w.date BETWEEN $2 AND $3
+         GROUP BY 1 ORDER BY 1`,
+		userID, from, to,
+	)
+	if err != nil {
+		log.Println("DB SELECT ERROR (GetWorkoutAdherence):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute adherence"})
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var wk weekAdherence
+		if err := rows.Scan(append([]interface{}{&wk.WeekStart}, wk.scanArgs()...)...); err != nil {
+			log.Println("DB SCAN ERROR (GetWorkoutAdherence):", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute adherence"})
+			return
+		}
+		wk.computeRate()
+		weeks = append(weeks, wk)
+	}
+
+	type programAdherence struct {
+		EnrollmentID uuid.UUID `json:"enrollment_id"`
+		ProgramID    uuid.UUID `json:"program_id"`
+		ProgramName  string    `json:"program_name"`
+		adherenceStats
+	}
+	programs := []programAdherence{}
+	programRows, err := config.DB.Query(
+		`SELECT e.id, p.id, p.name, `+adherenceSelect+`
+         FROM workouts w
+         JOIN program_enrollments e ON e.id = w.enrollment_id
+         JOIN programs p ON p.id = e.program_id
+         WHERE w.user_id = $1 AND w.date BETWEEN $2 AND $3
+         GROUP BY e.id, p.id, p.name ORDER BY p.name`,
+		userID, from, to,
+	)
+	if err != nil {
+		log.Println("DB SELECT ERROR (GetWorkoutAdherence):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute adherence"})
+		return
+	}
+	defer programRows.Close()
+	for programRows.Next() {
+		var pa programAdherence
+		if err := programRows.Scan(append([]interface{}{&pa.EnrollmentID, &pa.ProgramID, &pa.ProgramName}, pa.scanArgs()...)...); err != nil {
+			log.Println("DB SCAN ERROR (GetWorkoutAdherence):", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute adherence"})
+			return
+		}
+		pa.computeRate()
+		programs = append(programs, pa)
+	}
+
+	type planAdherence struct {
+		PlanID   uuid.UUID `json:"plan_id"`
+		PlanName string    `json:"plan_name"`
+		adherenceStats
+	}
+	plans := []planAdherence{}
+	planRows, err := config.DB.Query(
+		`SELECT p.id, p.name, `+adherenceSelect+`
+         FROM workouts w
+         JOIN workout_plans p ON p.id = w.plan_id
+         WHERE w.user_id = $1 AND w.date BETWEEN $2 AND $3
+         GROUP BY p.id, p.name ORDER BY p.name`,
+		userID, from, to,
+	)
+	if err != nil {
+		log.Println("DB SELECT ERROR (GetWorkoutAdherence):", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute adherence"})
+		return
+	}
+	defer planRows.Close()
+	for planRows.Next() {
+		var pa planAdherence
+		if err := planRows.Scan(append([]interface{}{&pa.PlanID, &pa.PlanName}, pa.scanArgs()...)...); err != nil {
+			log.Println("DB SCAN ERROR (GetWorkoutAdherence):", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute adherence"})
+			return
+		}
+		pa.computeRate()
+		plans = append(plans, pa)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"from":     from,
+		"to":       to,
+		"overall":  overall,
+		"weeks":    weeks,
+		"programs": programs,
+		"plans":    plans,
+	})
+}
@@ -10,6 +10,7 @@ import (
 	"regexp"
 	"strings"
 	"time"
+	_ "time/tzdata" // users' timezones must resolve even on images without zoneinfo
 
 	"nutritionix/backend/config"
 	"nutritionix/backend/handlers"
@@ -18,6 +19,7 @@ import (
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 
 	"github.com/joho/godotenv"
 
@@ -54,7 +56,7 @@ func main() {
 		AllowOrigins:     []string{"http://localhost:5173", "http://127.0.0.1:5173"},
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
 		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "Accept"},
-		ExposeHeaders:    []string{"Content-Length"},
+		ExposeHeaders:    []string{"Content-Length", "X-Next-Cursor"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}))
@@ -119,6 +121,9 @@ func main() {
 			}
 
 			log.Printf("Successfully deleted meal %s", mealID)
+			if uid, err := uuid.Parse(userIDStr.(string)); err == nil {
+				handlers.RecomputeGoalProgress(uid)
+			}
 			c.JSON(http.StatusOK, gin.H{"message": "meal deleted successfully"})
 		})
 
@@ -126,6 +131,44 @@ func main() {
 		user.POST("/mealfoods", mealHandler.CreateMealFood)
 		user.GET("/mealfoods/:mealID", mealHandler.ListMealFoods)
 
+		// Food search and dietary exposure report
+		user.GET("/foods/search", handlers.SearchFoods)
+		user.GET("/diet/exposures", handlers.GetDietExposures)
+
+		// Exercise catalog
+		user.GET("/exercises", handlers.ListExercises)
+
+		// Personal records
+		user.GET("/records", handlers.GetPersonalRecords)
+		user.GET("/records/history", handlers.GetPersonalRecordHistory)
+
+		// Training load: weekly volume, session RPE load, ACWR, monotony and strain
+		user.GET("/training-load", handlers.GetTrainingLoad)
+		user.GET("/cardio/summary", handlers.GetCardioSummary)
+
+		// Weigh-ins, their smoothed trend and the daily energy balance checked against it
+		user.POST("/weigh-ins", handlers.CreateWeighIn)
+		user.GET("/weigh-ins", handlers.GetWeighIns)
+		user.DELETE("/weigh-ins/:id", handlers.DeleteWeighIn)
+		user.GET("/energy-balance", handlers.GetEnergyBalance)
+		user.GET("/weight-trend", handlers.GetWeightTrend)
+		user.GET("/weight-trend/:date", handlers.GetWeightTrendOn)
+
+		// Body measurement log: weight, body fat and circumferences; weigh-ins above are its weight-only view
+		user.POST("/measurements", handlers.CreateMeasurement)
+		user.GET("/measurements", handlers.GetMeasurements)
+		user.GET("/measurements/latest", handlers.GetLatestMeasurements)
+		user.PUT("/measurements/:id", handlers.UpdateMeasurement)
+		user.DELETE("/measurements/:id", handlers.DeleteMeasurement)
+		user.GET("/body-composition", handlers.GetBodyComposition)
+
+		// Daily activity synced from phones and watches
+		user.POST("/activity", handlers.UpsertActivity)
+		user.GET("/activity", handlers.GetActivity)
+
+		// Badges and progress toward them
+		user.GET("/achievements", handlers.GetAchievements)
+
 		// ADD MISSING ROUTES - Get foods for a meal (alternative endpoint)
 		user.GET("/meals/:mealId/foods", func(c *gin.Context) {
 			mealID := c.Param("mealId")
@@ -216,6 +259,9 @@ func main() {
 			}
 
 			log.Printf("Successfully deleted food %s", foodID)
+			if uid, err := uuid.Parse(userIDStr.(string)); err == nil {
+				handlers.RecomputeGoalProgress(uid)
+			}
 			c.JSON(http.StatusOK, gin.H{"message": "food deleted successfully"})
 		})
 	}
@@ -231,87 +277,7 @@ func main() {
 		}
 
 		// Mock responses for different foods
-		query := strings.ToLower(strings.TrimSpace(req.Query))
-		var mockResponse map[string]interface{}
-
-		if strings.Contains(query, "rice") {
-			mockResponse = map[string]interface{}{
-				"food_name":            "Cooked White Rice",
-				"calories":             130.0,
-				"protein":              2.7,
-				"carbs":                28.0,
-				"fat":                  0.3,
-				"serving_qty":          1.0,
-				"serving_unit":         "cup",
-				"serving_weight_grams": 158.0,
-			}
-		} else if strings.Contains(query, "chicken") {
-			mockResponse = map[string]interface{}{
-				"food_name":            "Grilled Chicken Breast",
-				"calories":             165.0,
-				"protein":              31.0,
-				"carbs":                0.0,
-				"fat":                  3.6,
-				"serving_qty":          100.0,
-				"serving_unit":         "grams",
-				"serving_weight_grams": 100.0,
-			}
-		} else if strings.Contains(query, "apple") {
-			mockResponse = map[string]interface{}{
-				"food_name":            "Apple",
-				"calories":             95.0,
-				"protein":              0.5,
-				"carbs":                25.0,
-				"fat":                  0.3,
-				"serving_qty":          1.0,
-				"serving_unit":         "medium apple",
-				"serving_weight_grams": 182.0,
-			}
-		} else if strings.Contains(query, "banana") {
-			mockResponse = map[string]interface{}{
-				"food_name":            "Banana",
-				"calories":             105.0,
-				"protein":              1.3,
-				"carbs":                27.0,
-				"fat":                  0.4,
-				"serving_qty":          1.0,
-				"serving_unit":         "medium banana",
-				"serving_weight_grams": 118.0,
-			}
-		} else if strings.Contains(query, "egg") {
-			mockResponse = map[string]interface{}{
-				"food_name":            "Large Egg",
-				"calories":             70.0,
-				"protein":              6.0,
-				"carbs":                0.6,
-				"fat":                  5.0,
-				"serving_qty":          1.0,
-				"serving_unit":         "large egg",
-				"serving_weight_grams": 50.0,
-			}
-		} else if strings.Contains(query, "bread") {
-			mockResponse = map[string]interface{}{
-				"food_name":            "White Bread",
-				"calories":             80.0,
-				"protein":              2.3,
-				"carbs":                15.0,
-				"fat":                  1.0,
-				"serving_qty":          1.0,
-				"serving_unit":         "slice",
-				"serving_weight_grams": 28.0,
-			}
-		} else {
-			mockResponse = map[string]interface{}{
-				"food_name":            strings.Title(query),
-				"calories":             100.0,
-				"protein":              5.0,
-				"carbs":                15.0,
-				"fat":                  3.0,
-				"serving_qty":          1.0,
-				"serving_unit":         "serving",
-				"serving_weight_grams": 100.0,
-			}
-		}
+		mockResponse := handlers.LookupFood(req.Query)
 
 		c.JSON(http.StatusOK, mockResponse)
 	})
@@ -324,18 +290,102 @@ func main() {
 		workouts.GET("", handlers.GetWorkouts)
 		workouts.PUT("/:id", handlers.UpdateWorkout)
 		workouts.DELETE("/:id", handlers.DeleteWorkout)
+
+		// Status lifecycle: planned -> in_progress -> completed, or skipped; the hourly job marks missed
+		workouts.POST("/:id/start", handlers.StartWorkout)
+		workouts.POST("/:id/complete", handlers.CompleteWorkout)
+		workouts.POST("/:id/skip", handlers.SkipWorkout)
+		workouts.GET("/adherence", handlers.GetWorkoutAdherence)
+		workouts.GET("/summary", handlers.GetWorkoutSummary)
+
+		// Activity files from watches and bike computers
+		workouts.POST("/import", handlers.ImportWorkout)
+		workouts.GET("/:id/track", handlers.GetWorkoutTrack)
+
+		// Cardio metrics: distance, elevation, heart rate and time in zones
+		workouts.PUT("/:id/cardio", handlers.UpsertWorkoutCardio)
+		workouts.GET("/:id/cardio", handlers.GetWorkoutCardio)
+
+		// Structured sessions: ordered exercises with logged sets
+		workouts.GET("/:id/exercises", handlers.GetWorkoutSession)
+		workouts.POST("/:id/exercises", handlers.AddWorkoutExercise)
+		workouts.PUT("/:id/exercises/:exerciseId", handlers.UpdateWorkoutExercise)
+		workouts.DELETE("/:id/exercises/:exerciseId", handlers.DeleteWorkoutExercise)
+		workouts.POST("/:id/exercises/:exerciseId/sets", handlers.AddWorkoutSet)
+		workouts.PUT("/:id/sets/:setId", handlers.UpdateWorkoutSet)
+		workouts.DELETE("/:id/sets/:setId", handlers.DeleteWorkoutSet)
+		workouts.POST("/:id/save", handlers.SaveWorkoutSession)
+	}
+
+	// Recurring workout plans; occurrences are materialized into /user/workouts
+	plans := r.Group("/user/workout-plans")
+	plans.Use(utils.AuthMiddleware())
+	{
+		plans.POST("", handlers.CreateWorkoutPlan)
+		plans.GET("", handlers.GetWorkoutPlans)
+		plans.GET("/:id", handlers.GetWorkoutPlan)
+		plans.PUT("/:id", handlers.UpdateWorkoutPlan)
+		plans.DELETE("/:id", handlers.DeleteWorkoutPlan)
 	}
 
-	// Goal routes with auth middleware - DISABLED (requires mobile step counter)
-	// goals := r.Group("/goals")
-	// goals.Use(middleware.AuthMiddleware())
-	// {
-	// 	goals.POST("/", handlers.CreateOrUpdateGoal)
-	// 	goals.GET("/", handlers.GetGoals)
-	// 	goals.PUT("/:id", handlers.UpdateGoal)
-	// 	goals.DELETE("/:id", handlers.DeleteGoal)
-	// 	goals.PUT("/:id/restore", handlers.RestoreGoal)
-	// }
+	// Training programs: shareable templates with progression rules
+	programs := r.Group("/user/programs")
+	programs.Use(utils.AuthMiddleware())
+	{
+		programs.GET("", handlers.ListPrograms)
+		programs.POST("/import", handlers.ImportProgram)
+		programs.GET("/:id/export", handlers.ExportProgram)
+		programs.DELETE("/:id", handlers.DeleteProgram)
+		programs.POST("/:id/enroll", handlers.EnrollInProgram)
+	}
+
+	enrollments := r.Group("/user/program-enrollments")
+	enrollments.Use(utils.AuthMiddleware())
+	{
+		enrollments.GET("", handlers.ListEnrollments)
+		enrollments.GET("/:id", handlers.GetEnrollment)
+		enrollments.DELETE("/:id", handlers.CancelEnrollment)
+		enrollments.POST("/:id/next", handlers.NextProgramSession)
+	}
+
+	// Goal routes with auth middleware
+	goals := r.Group("/goals")
+	goals.Use(utils.AuthMiddleware())
+	{
+		goals.POST("/", handlers.CreateOrUpdateGoal)
+		goals.GET("/", handlers.GetGoals)
+		goals.GET("/types", handlers.GetGoalTypes)
+		goals.PUT("/:id", handlers.UpdateGoal)
+		goals.DELETE("/:id", handlers.DeleteGoal)
+		goals.PUT("/:id/restore", handlers.RestoreGoal)
+		goals.GET("/:id/history", handlers.GetGoalHistory)
+		goals.GET("/:id/projection", handlers.GetWeightGoalProjection)
+	}
+
+	// Progress photos, kept private and only served through these routes
+	photos := r.Group("/user/progress-photos")
+	photos.Use(utils.AuthMiddleware())
+	{
+		photos.POST("", handlers.UploadProgressPhoto)
+		photos.GET("", handlers.GetProgressPhotos)
+		photos.GET("/compare", handlers.CompareProgressPhotos)
+		photos.GET("/:id/image", handlers.GetProgressPhotoImage)
+		photos.PUT("/:id", handlers.UpdateProgressPhoto)
+		photos.DELETE("/:id", handlers.DeleteProgressPhoto)
+	}
+
+	// Group challenges, joined with an invite code
+	challenges := r.Group("/challenges")
+	challenges.Use(utils.AuthMiddleware())
+	{
+		challenges.POST("", handlers.CreateChallenge)
+		challenges.GET("", handlers.ListChallenges)
+		challenges.POST("/join", handlers.JoinChallenge)
+		challenges.GET("/:id", handlers.GetChallenge)
+		challenges.DELETE("/:id", handlers.DeleteChallenge)
+		challenges.PUT("/:id/me", handlers.UpdateChallengeMembership)
+		challenges.DELETE("/:id/me", handlers.LeaveChallenge)
+	}
 
 	// Notification routes with auth middleware
 	notifications := r.Group("/notifications")
@@ -349,14 +399,35 @@ func main() {
 	}
 
 	// Background scheduled jobs
-	// go scheduleDaily(9, 0, runOverdueGoalNotifications) // DISABLED - Goals feature removed
+	go scheduleDaily(9, 0, runOverdueGoalNotifications)
+	// Goal periods end at midnight in each user's timezone, so roll them over hourly
+	go func() {
+		handlers.RollGoalPeriods()
+		ticker := time.NewTicker(time.Hour)
+		for range ticker.C {
+			handlers.RollGoalPeriods()
+		}
+	}()
+	// Materialize plan occurrences before the reminder jobs look for them
+	go handlers.MaterializeWorkoutPlans()
+	go scheduleDaily(7, 30, handlers.MaterializeWorkoutPlans)
 	go scheduleDaily(8, 0, runTomorrowWorkoutReminders)
+	go scheduleDaily(9, 30, handlers.RunTrainingLoadAlerts)
+	go scheduleDaily(20, 0, handlers.RunChallengeUpdates)
+	go scheduleDaily(3, 0, handlers.RunAchievementBackfill)
 	go func() {
 		ticker := time.NewTicker(30 * time.Minute)
 		for range ticker.C {
 			runSameDayWorkoutReminders()
 		}
 	}()
+	go func() {
+		handlers.MarkMissedWorkouts()
+		ticker := time.NewTicker(time.Hour)
+		for range ticker.C {
+			handlers.MarkMissedWorkouts()
+		}
+	}()
 
 	log.Printf("🚀 Server running on port %s", config.AppConfig.Port)
 	if err := r.Run(":" + config.AppConfig.Port); err != nil {
@@ -467,17 +538,18 @@ func scheduleDaily(hour, min int, job func()) {
 	}
 }
 
-// runOverdueGoalNotifications sends reminders for goals not updated in 3 days
+// runOverdueGoalNotifications sends reminders for manually tracked goals not updated in 3 days
 func runOverdueGoalNotifications() {
 	log.Println("📢 Running overdue goal notifications job...")
 	query := `
-        SELECT id, user_id, goal_type
+        SELECT id, user_id, COALESCE(label, goal_type)
         FROM user_goals
         WHERE is_completed = false
           AND archived = false
+          AND goal_type = ANY($1)
           AND updated_at <= NOW() - INTERVAL '3 days'
     `
-	rows, err := config.DB.Query(query)
+	rows, err := config.DB.Query(query, pq.Array(handlers.ManualGoalTypes()))
 	if err != nil {
 		log.Println("DB SELECT ERROR (Overdue goals):", err)
 		return
@@ -498,13 +570,14 @@ func runOverdueGoalNotifications() {
 	}
 }
 
-// runTomorrowWorkoutReminders sends reminders for workouts scheduled tomorrow
+// runTomorrowWorkoutReminders sends reminders for workouts scheduled tomorrow in the workout's own timezone
 func runTomorrowWorkoutReminders() {
 	log.Println("📢 Running tomorrow's workout reminders job...")
 	query := `
         SELECT id, user_id, name
         FROM workouts
-        WHERE workout_date::date = (CURRENT_DATE + INTERVAL '1 day')
+        WHERE date = (NOW() AT TIME ZONE timezone)::date + 1
+          AND status = 'planned'
     `
 	rows, err := config.DB.Query(query)
 	if err != nil {
@@ -527,15 +600,15 @@ func runTomorrowWorkoutReminders() {
 	}
 }
 
-// runSameDayWorkoutReminders sends reminders for workouts scheduled for today in next 3 hours
+// runSameDayWorkoutReminders sends reminders for workouts starting in the next 3 hours
 func runSameDayWorkoutReminders() {
 	log.Println("📢 Running same-day workout reminders job...")
 	query := `
-        SELECT id, user_id, name, workout_date
+        SELECT id, user_id, name, scheduled_at, timezone
         FROM workouts
-        WHERE DATE(workout_date) = CURRENT_DATE
-          AND workout_date > NOW()
-          AND workout_date <= NOW() + INTERVAL '3 hours'
+        WHERE scheduled_at > NOW()
+          AND scheduled_at <= NOW() + INTERVAL '3 hours'
+          AND status = 'planned'
     `
 	rows, err := config.DB.Query(query)
 	if err != nil {
@@ -546,13 +619,17 @@ func runSameDayWorkoutReminders() {
 
 	for rows.Next() {
 		var workoutID, userID uuid.UUID
-		var name string
-		var workoutDate time.Time
-		if err := rows.Scan(&workoutID, &userID, &name, &workoutDate); err != nil {
+		var name, timezone string
+		var scheduledAt time.Time
+		if err := rows.Scan(&workoutID, &userID, &name, &scheduledAt, &timezone); err != nil {
 			log.Println("DB SCAN ERROR (Same-day workouts):", err)
 			continue
 		}
-		msg := "⏰ Get ready! Your workout '" + name + "' starts at " + workoutDate.Format("15:04")
+		// Show the start time as the user sees it, not in server time
+		if loc, err := time.LoadLocation(timezone); err == nil {
+			scheduledAt = scheduledAt.In(loc)
+		}
+		msg := "⏰ Get ready! Your workout '" + name + "' starts at " + scheduledAt.Format("15:04")
 		if !handlers.HasRecentNotification(userID, workoutID, msg) {
 			handlers.CreateNotification(userID, &workoutID, msg)
 		}
@@ -0,0 +1,16 @@
+-- Add dietary restrictions and allergens to user profiles, and allergen/diet tags to logged foods
+-- Migration: 004_dietary_restrictions.sql
+
+-- Profile restrictions: vegan, vegetarian, gluten_free, dairy_free
+ALTER TABLE users ADD COLUMN IF NOT EXISTS dietary_restrictions TEXT[] NOT NULL DEFAULT '{}';
+-- Profile allergens: peanuts, tree_nuts, milk, eggs, wheat, gluten, soy, fish, shellfish, sesame
+ALTER TABLE users ADD COLUMN IF NOT EXISTS allergens TEXT[] NOT NULL DEFAULT '{}';
+
+-- Food tags, either sent by the client or inferred from the food name
+ALTER TABLE meal_foods ADD COLUMN IF NOT EXISTS allergens TEXT[] NOT NULL DEFAULT '{}';
+ALTER TABLE meal_foods ADD COLUMN IF NOT EXISTS diet_tags TEXT[] NOT NULL DEFAULT '{}';
+
+-- Conflicts with the user's profile at the time the food was logged (exposure history)
+ALTER TABLE meal_foods ADD COLUMN IF NOT EXISTS diet_conflicts TEXT[] NOT NULL DEFAULT '{}';
+
+CREATE INDEX IF NOT EXISTS idx_meal_foods_diet_conflicts ON meal_foods(meal_id) WHERE cardinality(diet_conflicts) > 0;
@@ -0,0 +1,72 @@
+-- Exercise catalog with MET values, and calorie source tracking on workouts
+-- Migration: 005_exercise_catalog.sql
+
+CREATE TABLE IF NOT EXISTS exercise_catalog (
+    id SERIAL PRIMARY KEY,
+    slug TEXT NOT NULL,
+    name TEXT NOT NULL,
+    type TEXT NOT NULL,                          -- cardio, strength, flexibility, sports
+    intensity TEXT NOT NULL DEFAULT 'moderate',  -- light, moderate, vigorous
+    met NUMERIC(4,1) NOT NULL,                   -- metabolic equivalent (2011 Compendium of Physical Activities)
+    UNIQUE (slug, intensity)
+);
+
+INSERT INTO exercise_catalog (slug, name, type, intensity, met) VALUES
+    ('walking', 'Walking', 'cardio', 'light', 2.8),
+    ('walking', 'Walking', 'cardio', 'moderate', 3.5),
+    ('walking', 'Walking', 'cardio', 'vigorous', 5.0),
+    ('running', 'Running', 'cardio', 'light', 6.0),
+    ('running', 'Running', 'cardio', 'moderate', 9.8),
+    ('running', 'Running', 'cardio', 'vigorous', 11.5),
+    ('cycling', 'Cycling', 'cardio', 'light', 4.0),
+    ('cycling', 'Cycling', 'cardio', 'moderate', 8.0),
+    ('cycling', 'Cycling', 'cardio', 'vigorous', 10.0),
+    ('swimming', 'Swimming', 'cardio', 'light', 5.8),
+    ('swimming', 'Swimming', 'cardio', 'moderate', 8.3),
+    ('swimming', 'Swimming', 'cardio', 'vigorous', 9.8),
+    ('rowing', 'Rowing Machine', 'cardio', 'light', 4.8),
+    ('rowing', 'Rowing Machine', 'cardio', 'moderate', 7.0),
+    ('rowing', 'Rowing Machine', 'cardio', 'vigorous', 8.5),
+    ('elliptical', 'Elliptical Trainer', 'cardio', 'moderate', 5.0),
+    ('hiking', 'Hiking', 'cardio', 'moderate', 6.0),
+    ('jump-rope', 'Jump Rope', 'cardio', 'light', 8.8),
+    ('jump-rope', 'Jump Rope', 'cardio', 'moderate', 11.8),
+    ('jump-rope', 'Jump Rope', 'cardio', 'vigorous', 12.3),
+    ('hiit', 'HIIT', 'cardio', 'vigorous', 8.0),
+    ('weight-training', 'Weight Training', 'strength', 'light', 3.5),
+    ('weight-training', 'Weight Training', 'strength', 'moderate', 5.0),
+    ('weight-training', 'Weight Training', 'strength', 'vigorous', 6.0),
+    ('bench-press', 'Bench Press', 'strength', 'moderate', 5.0),
+    ('squat', 'Squat', 'strength', 'moderate', 5.0),
+    ('deadlift', 'Deadlift', 'strength', 'moderate', 6.0),
+    ('overhead-press', 'Overhead Press', 'strength', 'moderate', 5.0),
+    ('barbell-row', 'Barbell Row', 'strength', 'moderate', 5.0),
+    ('pull-up', 'Pull-up', 'strength', 'moderate', 3.8),
+    ('calisthenics', 'Calisthenics', 'strength', 'light', 2.8),
+    ('calisthenics', 'Calisthenics', 'strength', 'moderate', 3.8),
+    ('calisthenics', 'Calisthenics', 'strength', 'vigorous', 8.0),
+    ('circuit-training', 'Circuit Training', 'strength', 'vigorous', 8.0),
+    ('yoga', 'Yoga', 'flexibility', 'light', 2.5),
+    ('yoga', 'Yoga', 'flexibility', 'vigorous', 4.0),
+    ('stretching', 'Stretching', 'flexibility', 'light', 2.3),
+    ('pilates', 'Pilates', 'flexibility', 'moderate', 3.0),
+    ('basketball', 'Basketball', 'sports', 'moderate', 6.5),
+    ('basketball', 'Basketball', 'sports', 'vigorous', 8.0),
+    ('soccer', 'Soccer', 'sports', 'moderate', 7.0),
+    ('soccer', 'Soccer', 'sports', 'vigorous', 10.0),
+    ('tennis', 'Tennis', 'sports', 'light', 6.0),
+    ('tennis', 'Tennis', 'sports', 'moderate', 7.3),
+    ('tennis', 'Tennis', 'sports', 'vigorous', 8.0),
+    ('football', 'Football', 'sports', 'vigorous', 8.0),
+    ('badminton', 'Badminton', 'sports', 'moderate', 5.5),
+    ('cricket', 'Cricket', 'sports', 'moderate', 4.8)
+ON CONFLICT (slug, intensity) DO NOTHING;
+
+-- Workouts may reference a catalog exercise; calories_source records where calories_burned came from
+ALTER TABLE workouts ADD COLUMN IF NOT EXISTS exercise_id INTEGER REFERENCES exercise_catalog(id);
+ALTER TABLE workouts ADD COLUMN IF NOT EXISTS calories_source TEXT NOT NULL DEFAULT 'manual'; -- manual, met_estimate, none
+
+-- Existing rows with no calories were never estimated
+UPDATE workouts SET calories_source = 'none' WHERE calories_burned IS NULL OR calories_burned = 0;
+
+CREATE INDEX IF NOT EXISTS idx_workouts_exercise_id ON workouts(exercise_id);
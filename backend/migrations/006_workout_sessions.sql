@@ -0,0 +1,28 @@
+-- Structured strength sessions: ordered exercises per workout, each with logged sets
+-- Migration: 006_workout_sessions.sql
+
+CREATE TABLE IF NOT EXISTS workout_exercises (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    workout_id UUID NOT NULL REFERENCES workouts(id) ON DELETE CASCADE,
+    exercise_id INTEGER REFERENCES exercise_catalog(id),  -- optional catalog reference
+    name TEXT NOT NULL,
+    position INTEGER NOT NULL,                            -- order within the workout, starting at 1
+    notes TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS workout_sets (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    workout_exercise_id UUID NOT NULL REFERENCES workout_exercises(id) ON DELETE CASCADE,
+    set_number INTEGER NOT NULL,
+    reps INTEGER NOT NULL,
+    weight_kg NUMERIC(6,2) NOT NULL DEFAULT 0,
+    rpe NUMERIC(3,1),                                     -- rate of perceived exertion, 1-10
+    rest_seconds INTEGER,
+    is_warmup BOOLEAN NOT NULL DEFAULT false,
+    completed_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (workout_exercise_id, set_number)
+);
+
+CREATE INDEX IF NOT EXISTS idx_workout_exercises_workout_id ON workout_exercises(workout_id, position);
+CREATE INDEX IF NOT EXISTS idx_workout_sets_exercise_id ON workout_sets(workout_exercise_id);
@@ -0,0 +1,22 @@
+-- Personal records per exercise, detected when a workout session is saved
+-- Migration: 007_personal_records.sql
+
+CREATE TABLE IF NOT EXISTS personal_records (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    workout_id UUID NOT NULL REFERENCES workouts(id) ON DELETE CASCADE,
+    set_id UUID REFERENCES workout_sets(id) ON DELETE SET NULL,
+    exercise_key TEXT NOT NULL,        -- catalog slug, or the lowercased exercise name
+    exercise_name TEXT NOT NULL,
+    record_type TEXT NOT NULL,         -- max_weight, reps_at_weight, estimated_1rm, max_volume
+    value NUMERIC(9,2) NOT NULL,       -- kg for weight/1RM/volume, reps for reps_at_weight
+    previous_value NUMERIC(9,2),       -- NULL when this is the first record for the exercise
+    weight_kg NUMERIC(6,2),
+    reps INTEGER,
+    formula TEXT,                      -- epley or brzycki, for estimated_1rm
+    achieved_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_personal_records_user_exercise ON personal_records(user_id, exercise_key, record_type, achieved_at DESC);
+CREATE INDEX IF NOT EXISTS idx_personal_records_workout ON personal_records(workout_id);
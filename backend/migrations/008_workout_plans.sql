@@ -0,0 +1,31 @@
+-- Recurring workout plans, materialized into workouts over a rolling horizon
+-- Migration: 008_workout_plans.sql
+
+CREATE TABLE IF NOT EXISTS workout_plans (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    type TEXT NOT NULL DEFAULT 'cardio',
+    exercise_id INTEGER REFERENCES exercise_catalog(id),
+    duration_min INTEGER NOT NULL DEFAULT 0,
+    rrule TEXT NOT NULL,                 -- RFC 5545 subset, e.g. FREQ=WEEKLY;BYDAY=MO,WE,FR
+    start_date DATE NOT NULL,
+    active BOOLEAN NOT NULL DEFAULT true,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Occurrences deleted individually, so the materializer doesn't recreate them
+CREATE TABLE IF NOT EXISTS workout_plan_exceptions (
+    plan_id UUID NOT NULL REFERENCES workout_plans(id) ON DELETE CASCADE,
+    occurrence_date DATE NOT NULL,
+    PRIMARY KEY (plan_id, occurrence_date)
+);
+
+ALTER TABLE workouts ADD COLUMN IF NOT EXISTS plan_id UUID REFERENCES workout_plans(id) ON DELETE SET NULL;
+ALTER TABLE workouts ADD COLUMN IF NOT EXISTS occurrence_date DATE;
+-- Set when a single occurrence is edited; series edits leave detached occurrences alone
+ALTER TABLE workouts ADD COLUMN IF NOT EXISTS detached BOOLEAN NOT NULL DEFAULT false;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_workouts_plan_occurrence ON workouts(plan_id, occurrence_date) WHERE plan_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_workout_plans_user_id ON workout_plans(user_id);
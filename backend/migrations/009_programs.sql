@@ -0,0 +1,88 @@
+-- Multi-week training programs with progression rules, and user enrollments
+-- Migration: 009_programs.sql
+
+CREATE TABLE IF NOT EXISTS programs (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    owner_id UUID REFERENCES users(id) ON DELETE CASCADE,  -- NULL for built-in programs
+    slug TEXT NOT NULL,
+    name TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    definition JSONB NOT NULL,                             -- sessions, exercises and progression rules
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_programs_builtin_slug ON programs(slug) WHERE owner_id IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_programs_owner_slug ON programs(owner_id, slug) WHERE owner_id IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS program_enrollments (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    program_id UUID NOT NULL REFERENCES programs(id) ON DELETE CASCADE,
+    start_date DATE NOT NULL,
+    status TEXT NOT NULL DEFAULT 'active',   -- active, completed, cancelled
+    state JSONB NOT NULL DEFAULT '{}',       -- working weights, miss counts, pending session
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_program_enrollments_active ON program_enrollments(user_id, program_id) WHERE status = 'active';
+
+-- Sessions generated from an enrollment
+ALTER TABLE workouts ADD COLUMN IF NOT EXISTS enrollment_id UUID REFERENCES program_enrollments(id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS idx_workouts_enrollment_id ON workouts(enrollment_id);
+
+INSERT INTO programs (slug, name, description, definition) VALUES
+('stronglifts-5x5', 'StrongLifts 5x5', 'Alternate workouts A and B three times a week, adding weight every successful session.', '{
+  "slug": "stronglifts-5x5",
+  "name": "StrongLifts 5x5",
+  "description": "Alternate workouts A and B three times a week, adding weight every successful session.",
+  "weeks": 12,
+  "sessions_per_week": 3,
+  "sessions": [
+    {"name": "Workout A", "type": "strength", "exercises": [
+      {"exercise": "squat", "sets": 5, "reps": 5, "start_weight_kg": 20, "progression": {"increment_kg": 2.5, "deload_after_misses": 3, "deload_percent": 10}},
+      {"exercise": "bench-press", "sets": 5, "reps": 5, "start_weight_kg": 20, "progression": {"increment_kg": 2.5, "deload_after_misses": 3, "deload_percent": 10}},
+      {"exercise": "barbell-row", "sets": 5, "reps": 5, "start_weight_kg": 30, "progression": {"increment_kg": 2.5, "deload_after_misses": 3, "deload_percent": 10}}
+    ]},
+    {"name": "Workout B", "type": "strength", "exercises": [
+      {"exercise": "squat", "sets": 5, "reps": 5, "start_weight_kg": 20, "progression": {"increment_kg": 2.5, "deload_after_misses": 3, "deload_percent": 10}},
+      {"exercise": "overhead-press", "sets": 5, "reps": 5, "start_weight_kg": 20, "progression": {"increment_kg": 2.5, "deload_after_misses": 3, "deload_percent": 10}},
+      {"exercise": "deadlift", "sets": 1, "reps": 5, "start_weight_kg": 40, "progression": {"increment_kg": 5, "deload_after_misses": 3, "deload_percent": 10}}
+    ]}
+  ]
+}'),
+('push-pull-legs', 'Push Pull Legs', 'Six sessions a week rotating push, pull and leg days with linear progression.', '{
+  "slug": "push-pull-legs",
+  "name": "Push Pull Legs",
+  "description": "Six sessions a week rotating push, pull and leg days with linear progression.",
+  "weeks": 8,
+  "sessions_per_week": 6,
+  "sessions": [
+    {"name": "Push", "type": "strength", "exercises": [
+      {"exercise": "bench-press", "sets": 4, "reps": 8, "start_weight_kg": 40, "progression": {"increment_kg": 2.5, "deload_after_misses": 3, "deload_percent": 10}},
+      {"exercise": "overhead-press", "sets": 3, "reps": 10, "start_weight_kg": 25, "progression": {"increment_kg": 2.5, "deload_after_misses": 3, "deload_percent": 10}}
+    ]},
+    {"name": "Pull", "type": "strength", "exercises": [
+      {"exercise": "deadlift", "sets": 1, "reps": 5, "start_weight_kg": 60, "progression": {"increment_kg": 5, "deload_after_misses": 3, "deload_percent": 10}},
+      {"exercise": "barbell-row", "sets": 4, "reps": 8, "start_weight_kg": 40, "progression": {"increment_kg": 2.5, "deload_after_misses": 3, "deload_percent": 10}},
+      {"exercise": "pull-up", "sets": 3, "reps": 8}
+    ]},
+    {"name": "Legs", "type": "strength", "exercises": [
+      {"exercise": "squat", "sets": 4, "reps": 8, "start_weight_kg": 50, "progression": {"increment_kg": 2.5, "deload_after_misses": 3, "deload_percent": 10}}
+    ]}
+  ]
+}'),
+('couch-to-5k', 'Couch to 5K', 'Three run/walk sessions a week, running a little longer each week until you can run 30 minutes.', '{
+  "slug": "couch-to-5k",
+  "name": "Couch to 5K",
+  "description": "Three run/walk sessions a week, running a little longer each week until you can run 30 minutes.",
+  "weeks": 9,
+  "sessions_per_week": 3,
+  "sessions": [
+    {"name": "Run/Walk", "type": "cardio", "exercises": [
+      {"exercise": "running", "intervals": {"run_seconds": 60, "walk_seconds": 90, "total_run_seconds": 480, "warmup_min": 5},
+       "progression": {"run_increment_seconds": 60, "total_increment_seconds": 165, "max_run_seconds": 1800}}
+    ]}
+  ]
+}')
+ON CONFLICT (slug) WHERE owner_id IS NULL DO NOTHING;
@@ -0,0 +1,26 @@
+-- GPS/heart-rate tracks imported from GPX, TCX and FIT files
+-- Migration: 010_workout_tracks.sql
+
+CREATE TABLE IF NOT EXISTS workout_tracks (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    workout_id UUID NOT NULL UNIQUE REFERENCES workouts(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    format TEXT NOT NULL,                 -- gpx, tcx, fit
+    sport TEXT NOT NULL DEFAULT '',
+    file_hash TEXT NOT NULL,              -- SHA-256 of the uploaded file
+    start_time TIMESTAMPTZ NOT NULL,
+    end_time TIMESTAMPTZ NOT NULL,
+    duration_sec INTEGER NOT NULL,
+    distance_m NUMERIC(9,1) NOT NULL DEFAULT 0,
+    elevation_gain_m NUMERIC(7,1) NOT NULL DEFAULT 0,
+    avg_heart_rate INTEGER,
+    max_heart_rate INTEGER,
+    device_calories INTEGER,
+    splits JSONB NOT NULL DEFAULT '[]',   -- per-km splits
+    points JSONB NOT NULL,                -- the raw track, for map and chart views
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- The same file can't be imported twice
+CREATE UNIQUE INDEX IF NOT EXISTS idx_workout_tracks_user_hash ON workout_tracks(user_id, file_hash);
+CREATE INDEX IF NOT EXISTS idx_workout_tracks_user_start ON workout_tracks(user_id, start_time);
@@ -0,0 +1,31 @@
+-- Optional start time and timezone for workouts, so same-day reminders have something to compare against
+-- Migration: 011_workout_schedule.sql
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS timezone TEXT NOT NULL DEFAULT 'UTC';  -- IANA name, e.g. Europe/Berlin
+
+-- date stays the calendar day the workout belongs to (in its timezone); scheduled_at is set when a start time is known
+ALTER TABLE workouts ADD COLUMN IF NOT EXISTS scheduled_at TIMESTAMPTZ;
+ALTER TABLE workouts ADD COLUMN IF NOT EXISTS timezone TEXT NOT NULL DEFAULT 'UTC';
+
+ALTER TABLE workout_plans ADD COLUMN IF NOT EXISTS start_time TIME;
+ALTER TABLE workout_plans ADD COLUMN IF NOT EXISTS timezone TEXT NOT NULL DEFAULT 'UTC';
+
+-- Existing rows take their owner's timezone
+UPDATE workouts w SET timezone = u.timezone FROM users u WHERE u.id = w.user_id;
+UPDATE workout_plans p SET timezone = u.timezone FROM users u WHERE u.id = p.user_id;
+
+-- Some databases were created with the workout_date column the reminder jobs used to query.
+-- Carry its time of day over to scheduled_at and drop it, so there's one schema.
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM information_schema.columns
+               WHERE table_name = 'workouts' AND column_name = 'workout_date') THEN
+        UPDATE workouts SET date = workout_date::date WHERE date IS NULL AND workout_date IS NOT NULL;
+        UPDATE workouts SET scheduled_at = workout_date
+         WHERE scheduled_at IS NULL AND workout_date IS NOT NULL AND workout_date::time <> '00:00';
+        ALTER TABLE workouts DROP COLUMN workout_date;
+    END IF;
+END $$;
+
+CREATE INDEX IF NOT EXISTS idx_workouts_scheduled_at ON workouts(scheduled_at) WHERE scheduled_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_workouts_date ON workouts(date);
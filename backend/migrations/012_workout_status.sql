@@ -0,0 +1,32 @@
+-- Status lifecycle for workouts: planned -> in_progress -> completed, or skipped / missed
+-- Migration: 012_workout_status.sql
+
+ALTER TABLE workouts ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'planned';
+ALTER TABLE workouts ADD COLUMN IF NOT EXISTS started_at TIMESTAMPTZ;
+ALTER TABLE workouts ADD COLUMN IF NOT EXISTS completed_at TIMESTAMPTZ;
+ALTER TABLE workouts ADD COLUMN IF NOT EXISTS actual_duration_min INTEGER;  -- duration_minutes stays the planned duration
+-- false for workouts logged after the fact, which don't count toward adherence
+ALTER TABLE workouts ADD COLUMN IF NOT EXISTS was_planned BOOLEAN NOT NULL DEFAULT true;
+
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'workouts_status_check') THEN
+        ALTER TABLE workouts ADD CONSTRAINT workouts_status_check
+            CHECK (status IN ('planned', 'in_progress', 'completed', 'skipped', 'missed'));
+    END IF;
+END $$;
+
+-- Existing rows: past workouts and anything with logged sets or a recorded track were done.
+-- Only plan and program occurrences were scheduled ahead of time.
+UPDATE workouts w
+SET status = 'completed',
+    completed_at = COALESCE(w.scheduled_at, w.date::timestamp AT TIME ZONE w.timezone),
+    actual_duration_min = w.duration_minutes,
+    was_planned = (w.plan_id IS NOT NULL OR w.enrollment_id IS NOT NULL)
+WHERE w.status = 'planned'
+  AND (w.date < (NOW() AT TIME ZONE w.timezone)::date
+       OR EXISTS (SELECT 1 FROM workout_exercises we JOIN workout_sets ws ON ws.workout_exercise_id = we.id
+                  WHERE we.workout_id = w.id)
+       OR EXISTS (SELECT 1 FROM workout_tracks t WHERE t.workout_id = w.id));
+
+CREATE INDEX IF NOT EXISTS idx_workouts_status_date ON workouts(status, date);
@@ -0,0 +1,23 @@
+-- Training load analytics: muscle groups for strength exercises, and session RPE on workouts
+-- Migration: 013_training_load.sql
+
+ALTER TABLE exercise_catalog ADD COLUMN IF NOT EXISTS muscle_group TEXT;  -- chest, back, legs, shoulders, arms, core, full_body
+
+UPDATE exercise_catalog SET muscle_group = 'chest'     WHERE slug = 'bench-press';
+UPDATE exercise_catalog SET muscle_group = 'legs'      WHERE slug = 'squat';
+UPDATE exercise_catalog SET muscle_group = 'back'      WHERE slug IN ('deadlift', 'barbell-row', 'pull-up', 'rowing');
+UPDATE exercise_catalog SET muscle_group = 'shoulders' WHERE slug = 'overhead-press';
+UPDATE exercise_catalog SET muscle_group = 'core'      WHERE slug = 'pilates';
+UPDATE exercise_catalog SET muscle_group = 'full_body' WHERE slug IN ('weight-training', 'calisthenics', 'circuit-training');
+
+-- Foster's session RPE: how hard the whole session felt, 1-10, asked for after the workout
+ALTER TABLE workouts ADD COLUMN IF NOT EXISTS session_rpe SMALLINT;
+
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'workouts_session_rpe_check') THEN
+        ALTER TABLE workouts ADD CONSTRAINT workouts_session_rpe_check CHECK (session_rpe BETWEEN 1 AND 10);
+    END IF;
+END $$;
+
+CREATE INDEX IF NOT EXISTS idx_workouts_user_date ON workouts(user_id, date);
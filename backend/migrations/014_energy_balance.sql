@@ -0,0 +1,22 @@
+-- Energy balance: sex for the BMR formula, and a weigh-in log to check the projection against
+-- Migration: 014_energy_balance.sql
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS sex TEXT;
+
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'users_sex_check') THEN
+        ALTER TABLE users ADD CONSTRAINT users_sex_check CHECK (sex IN ('male', 'female'));
+    END IF;
+END $$;
+
+CREATE TABLE IF NOT EXISTS weigh_ins (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    weighed_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    weight_kg NUMERIC(5,2) NOT NULL CHECK (weight_kg > 0),
+    note TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_weigh_ins_user_time ON weigh_ins(user_id, weighed_at);
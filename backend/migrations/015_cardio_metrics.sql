@@ -0,0 +1,32 @@
+-- Cardio metrics per workout, and the heart rate settings used for Karvonen zones
+-- Migration: 015_cardio_metrics.sql
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS max_hr SMALLINT;      -- bpm; 220 - age when unset
+ALTER TABLE users ADD COLUMN IF NOT EXISTS resting_hr SMALLINT;  -- bpm; zones fall back to % of max HR when unset
+
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'users_heart_rate_check') THEN
+        ALTER TABLE users ADD CONSTRAINT users_heart_rate_check
+            CHECK ((max_hr IS NULL OR max_hr BETWEEN 100 AND 230)
+               AND (resting_hr IS NULL OR resting_hr BETWEEN 25 AND 120)
+               AND (max_hr IS NULL OR resting_hr IS NULL OR resting_hr < max_hr));
+    END IF;
+END $$;
+
+CREATE TABLE IF NOT EXISTS workout_cardio (
+    workout_id UUID PRIMARY KEY REFERENCES workouts(id) ON DELETE CASCADE,
+    distance_m NUMERIC(9,1),
+    elevation_gain_m NUMERIC(7,1),
+    avg_heart_rate SMALLINT,
+    max_heart_rate SMALLINT,
+    zone_seconds INTEGER[],               -- time in zones 1-5 as entered; tracked workouts derive it from the points
+    source TEXT NOT NULL DEFAULT 'manual', -- manual or track
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Imported workouts already have these figures on their track
+INSERT INTO workout_cardio (workout_id, distance_m, elevation_gain_m, avg_heart_rate, max_heart_rate, source)
+SELECT workout_id, distance_m, elevation_gain_m, avg_heart_rate, max_heart_rate, 'track'
+FROM workout_tracks
+ON CONFLICT (workout_id) DO NOTHING;
@@ -0,0 +1,17 @@
+-- Goals bound to logged data: progress is computed from meals and workouts instead of sent by clients
+-- Migration: 016_goal_progress.sql
+
+-- NULL kind keeps the old manually tracked goals
+ALTER TABLE user_goals ADD COLUMN IF NOT EXISTS kind TEXT;
+-- First day of the period progress_value was computed for, in the user's timezone
+ALTER TABLE user_goals ADD COLUMN IF NOT EXISTS period_start DATE;
+
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'user_goals_kind_check') THEN
+        ALTER TABLE user_goals ADD CONSTRAINT user_goals_kind_check
+            CHECK (kind IN ('daily_protein_min', 'daily_calories_max', 'weekly_workouts', 'monthly_cardio_minutes'));
+    END IF;
+END $$;
+
+CREATE INDEX IF NOT EXISTS idx_user_goals_user_kind ON user_goals(user_id) WHERE kind IS NOT NULL AND archived = false;
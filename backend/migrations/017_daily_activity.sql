@@ -0,0 +1,20 @@
+-- Daily activity synced from phones and watches: one row per user and calendar day
+-- Migration: 017_daily_activity.sql
+
+CREATE TABLE IF NOT EXISTS daily_activity (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    date DATE NOT NULL,
+    steps INTEGER CHECK (steps >= 0),
+    active_minutes INTEGER CHECK (active_minutes BETWEEN 0 AND 1440),
+    distance_m NUMERIC(9,1) CHECK (distance_m >= 0),
+    floors INTEGER CHECK (floors >= 0),
+    resting_hr SMALLINT CHECK (resting_hr BETWEEN 25 AND 120),
+    recorded_at TIMESTAMPTZ NOT NULL,  -- time of the newest sample merged into the row
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, date)
+);
+
+-- Step goals are backed by this table
+ALTER TABLE user_goals DROP CONSTRAINT IF EXISTS user_goals_kind_check;
+ALTER TABLE user_goals ADD CONSTRAINT user_goals_kind_check
+    CHECK (kind IN ('daily_protein_min', 'daily_calories_max', 'weekly_workouts', 'monthly_cardio_minutes', 'daily_steps'));
@@ -0,0 +1,25 @@
+-- Goal periods: progress resets at each daily/weekly/monthly boundary in the user's timezone,
+-- and every closed period is kept as history
+-- Migration: 018_goal_periods.sql
+
+CREATE TABLE IF NOT EXISTS goal_periods (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    goal_id UUID NOT NULL REFERENCES user_goals(id) ON DELETE CASCADE,
+    period_start DATE NOT NULL,
+    period_end DATE NOT NULL,
+    target_value INTEGER NOT NULL,
+    progress_value INTEGER NOT NULL DEFAULT 0,
+    status TEXT NOT NULL CHECK (status IN ('met', 'missed')),
+    closed_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (goal_id, period_start)
+);
+
+-- Manual goals start their first period now; data-bound goals already track period_start
+UPDATE user_goals g
+SET period_start = CASE g.time_frame
+        WHEN 'weekly' THEN date_trunc('week', (NOW() AT TIME ZONE u.timezone)::date)::date
+        WHEN 'monthly' THEN date_trunc('month', (NOW() AT TIME ZONE u.timezone)::date)::date
+        ELSE (NOW() AT TIME ZONE u.timezone)::date
+    END
+FROM users u
+WHERE u.id = g.user_id AND g.period_start IS NULL AND g.kind IS NULL;
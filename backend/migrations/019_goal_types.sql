@@ -0,0 +1,79 @@
+-- Goal type registry: goal_type becomes a key from the registry in handlers/goal_types.go instead of
+-- free text, with a label for display. This replaces the kind column from 016.
+-- Migration: 019_goal_types.sql
+
+ALTER TABLE user_goals ADD COLUMN IF NOT EXISTS label TEXT;
+
+-- Map the data-bound kinds and known strings onto registry keys. Rows whose time frame or target
+-- doesn't fit the type they map to become custom goals, labelled with what the user typed.
+WITH registry (key, time_frames, min_target, max_target) AS (VALUES
+    ('protein',        ARRAY['daily'],                      10,   500),
+    ('calories',       ARRAY['daily'],                      800,  10000),
+    ('steps',          ARRAY['daily', 'weekly'],            500,  700000),
+    ('workouts',       ARRAY['weekly', 'monthly'],          1,    60),
+    ('cardio_minutes', ARRAY['monthly', 'weekly'],          10,   6000),
+    ('water',          ARRAY['daily'],                      250,  10000),
+    ('sleep',          ARRAY['daily'],                      1,    16)
+), mapped AS (
+    SELECT g.id, g.goal_type AS original, g.time_frame, g.target_value,
+        CASE
+            WHEN g.kind = 'daily_protein_min' THEN 'protein'
+            WHEN g.kind = 'daily_calories_max' THEN 'calories'
+            WHEN g.kind = 'daily_steps' THEN 'steps'
+            WHEN g.kind = 'weekly_workouts' THEN 'workouts'
+            WHEN g.kind = 'monthly_cardio_minutes' THEN 'cardio_minutes'
+            WHEN lower(g.goal_type) ~ '^\s*prot' THEN 'protein'
+            WHEN lower(g.goal_type) ~ '(calorie|kcal)' AND lower(g.goal_type) !~ '(burn|active)' THEN 'calories'
+            WHEN lower(g.goal_type) ~ 'step' THEN 'steps'
+            WHEN lower(g.goal_type) ~ 'cardio' AND lower(g.goal_type) ~ 'min' THEN 'cardio_minutes'
+            WHEN lower(g.goal_type) ~ '(workout|exercise|gym|training)' THEN 'workouts'
+            WHEN lower(g.goal_type) ~ '(water|hydrat)' THEN 'water'
+            WHEN lower(g.goal_type) ~ 'sleep' THEN 'sleep'
+        END AS key,
+        g.kind IS NOT NULL AS was_bound
+    FROM user_goals g
+), resolved AS (
+    SELECT m.id, m.original, m.was_bound, COALESCE(r.key, 'custom') AS key
+    FROM mapped m
+    LEFT JOIN registry r ON r.key = m.key
+        AND m.time_frame = ANY(r.time_frames)
+        AND m.target_value BETWEEN r.min_target AND r.max_target
+)
+UPDATE user_goals g
+SET goal_type = r.key,
+    label = CASE WHEN r.key = 'custom' THEN left(r.original, 50) END,
+    -- Goals whose progress is now computed start their period fresh
+    period_start = CASE WHEN r.was_bound OR r.key NOT IN ('protein', 'calories', 'steps', 'workouts', 'cardio_minutes')
+                        THEN g.period_start END,
+    progress_value = CASE WHEN r.was_bound OR r.key NOT IN ('protein', 'calories', 'steps', 'workouts', 'cardio_minutes')
+                          THEN g.progress_value ELSE 0 END
+FROM resolved r
+WHERE g.id = r.id;
+
+-- "Protein", "protein goal" and "prot" are one goal now: keep the active, most recently updated one
+WITH ranked AS (
+    SELECT id, ROW_NUMBER() OVER (
+        PARTITION BY user_id, goal_type, lower(COALESCE(label, ''))
+        ORDER BY archived, updated_at DESC
+    ) AS n
+    FROM user_goals
+)
+UPDATE user_goals g SET archived = true, updated_at = NOW()
+FROM ranked r
+WHERE g.id = r.id AND r.n > 1 AND NOT g.archived;
+
+ALTER TABLE user_goals DROP CONSTRAINT IF EXISTS user_goals_kind_check;
+DROP INDEX IF EXISTS idx_user_goals_user_kind;
+ALTER TABLE user_goals DROP COLUMN IF EXISTS kind;
+
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'user_goals_goal_type_check') THEN
+        ALTER TABLE user_goals ADD CONSTRAINT user_goals_goal_type_check CHECK (
+            goal_type IN ('protein', 'calories', 'steps', 'workouts', 'cardio_minutes', 'water', 'sleep', 'custom')
+            AND (goal_type <> 'custom' OR label IS NOT NULL)
+        );
+    END IF;
+END $$;
+
+CREATE INDEX IF NOT EXISTS idx_user_goals_user_type ON user_goals(user_id, goal_type);
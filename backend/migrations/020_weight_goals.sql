@@ -0,0 +1,14 @@
+-- Target-weight goals: progress follows the weigh-in trend, with a projected completion date
+-- Migration: 020_weight_goals.sql
+
+ALTER TABLE user_goals ADD COLUMN IF NOT EXISTS target_weight_kg NUMERIC(5,2);
+ALTER TABLE user_goals ADD COLUMN IF NOT EXISTS start_weight_kg NUMERIC(5,2);  -- trend weight when the goal was set
+ALTER TABLE user_goals ADD COLUMN IF NOT EXISTS target_date DATE;              -- optional deadline
+ALTER TABLE user_goals ADD COLUMN IF NOT EXISTS projected_date DATE;           -- last projection, to notice slips
+
+ALTER TABLE user_goals DROP CONSTRAINT IF EXISTS user_goals_goal_type_check;
+ALTER TABLE user_goals ADD CONSTRAINT user_goals_goal_type_check CHECK (
+    goal_type IN ('protein', 'calories', 'steps', 'workouts', 'cardio_minutes', 'weight', 'water', 'sleep', 'custom')
+    AND (goal_type <> 'custom' OR label IS NOT NULL)
+    AND (goal_type <> 'weight' OR (target_weight_kg IS NOT NULL AND start_weight_kg IS NOT NULL))
+);
@@ -0,0 +1,34 @@
+-- Group challenges: participants join with an invite code and are ranked on their logged data
+-- Migration: 021_challenges.sql
+
+CREATE TABLE IF NOT EXISTS challenges (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    owner_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    metric TEXT NOT NULL CHECK (metric IN ('steps', 'workouts', 'protein_days')),
+    protein_target_g INTEGER CHECK (protein_target_g BETWEEN 10 AND 500),  -- protein_days: grams a day must reach
+    start_date DATE NOT NULL,
+    end_date DATE NOT NULL,
+    invite_code TEXT NOT NULL UNIQUE,
+    finalized_at TIMESTAMPTZ,                                              -- set once winners are announced
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK (end_date >= start_date),
+    CHECK (metric <> 'protein_days' OR protein_target_g IS NOT NULL)
+);
+
+-- visibility is what other participants see: public (name and score), anonymous (score without
+-- the name) or rank_only (name and rank without the score)
+CREATE TABLE IF NOT EXISTS challenge_participants (
+    challenge_id UUID NOT NULL REFERENCES challenges(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    display_name TEXT NOT NULL,
+    visibility TEXT NOT NULL DEFAULT 'public' CHECK (visibility IN ('public', 'anonymous', 'rank_only')),
+    last_rank INTEGER,    -- rank in the last standings announcement
+    final_rank INTEGER,
+    final_score INTEGER,
+    joined_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (challenge_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_challenge_participants_user_id ON challenge_participants(user_id);
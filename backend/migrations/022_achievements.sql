@@ -0,0 +1,10 @@
+-- Achievements: badges awarded once per user when a rule's target is reached
+-- Migration: 022_achievements.sql
+
+CREATE TABLE IF NOT EXISTS user_achievements (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    achievement_key TEXT NOT NULL,     -- key in the achievement rules, see handlers/achievements.go
+    progress_value INTEGER NOT NULL,   -- metric value when it was awarded
+    awarded_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, achievement_key)
+);
@@ -0,0 +1,50 @@
+-- Body measurements: weigh-ins become one log of weight, body fat and circumferences over time,
+-- and the profile weight keeps its decimals
+-- Migration: 023_body_measurements.sql
+
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'weigh_ins' AND table_type = 'BASE TABLE') THEN
+        ALTER TABLE weigh_ins RENAME TO body_measurements;
+        ALTER TABLE body_measurements RENAME COLUMN weighed_at TO measured_at;
+        ALTER INDEX IF EXISTS idx_weigh_ins_user_time RENAME TO idx_body_measurements_user_time;
+    END IF;
+END $$;
+
+CREATE TABLE IF NOT EXISTS body_measurements (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    measured_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    weight_kg NUMERIC(5,2) CHECK (weight_kg > 0),
+    note TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_body_measurements_user_time ON body_measurements(user_id, measured_at);
+
+-- Any one value makes a measurement; weight is no longer required
+ALTER TABLE body_measurements ALTER COLUMN weight_kg DROP NOT NULL;
+ALTER TABLE body_measurements ADD COLUMN IF NOT EXISTS body_fat_pct NUMERIC(4,1);
+ALTER TABLE body_measurements ADD COLUMN IF NOT EXISTS waist_cm NUMERIC(5,1);
+ALTER TABLE body_measurements ADD COLUMN IF NOT EXISTS hips_cm NUMERIC(5,1);
+ALTER TABLE body_measurements ADD COLUMN IF NOT EXISTS chest_cm NUMERIC(5,1);
+ALTER TABLE body_measurements ADD COLUMN IF NOT EXISTS neck_cm NUMERIC(5,1);
+ALTER TABLE body_measurements ADD COLUMN IF NOT EXISTS arm_cm NUMERIC(5,1);    -- upper arm, relaxed
+ALTER TABLE body_measurements ADD COLUMN IF NOT EXISTS thigh_cm NUMERIC(5,1);
+ALTER TABLE body_measurements ADD COLUMN IF NOT EXISTS calf_cm NUMERIC(5,1);
+ALTER TABLE body_measurements ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW();
+
+ALTER TABLE body_measurements DROP CONSTRAINT IF EXISTS body_measurements_any_value_check;
+ALTER TABLE body_measurements ADD CONSTRAINT body_measurements_any_value_check CHECK (
+    num_nonnulls(weight_kg, body_fat_pct, waist_cm, hips_cm, chest_cm, neck_cm, arm_cm, thigh_cm, calf_cm) > 0
+);
+
+-- The profile mirrors the latest weight and body fat, decimals included
+ALTER TABLE users ALTER COLUMN weight TYPE NUMERIC(5,2) USING weight::numeric;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS body_fat_pct NUMERIC(4,1);
+
+UPDATE users u SET weight = m.weight_kg
+FROM (
+    SELECT DISTINCT ON (user_id) user_id, weight_kg FROM body_measurements
+    WHERE weight_kg IS NOT NULL ORDER BY user_id, measured_at DESC
+) m
+WHERE m.user_id = u.id;
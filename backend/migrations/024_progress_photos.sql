@@ -0,0 +1,15 @@
+-- Progress photos: pose-tagged body photos by date, stored privately on disk with a thumbnail
+-- Migration: 024_progress_photos.sql
+
+CREATE TABLE IF NOT EXISTS progress_photos (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    taken_on DATE NOT NULL,            -- calendar day in the user's timezone
+    pose TEXT NOT NULL CHECK (pose IN ('front', 'side', 'back')),
+    note TEXT NOT NULL DEFAULT '',
+    width INTEGER NOT NULL,            -- of the stored image, after it was turned upright
+    height INTEGER NOT NULL,
+    size_bytes INTEGER NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_progress_photos_user_day ON progress_photos(user_id, taken_on);
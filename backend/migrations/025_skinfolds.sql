@@ -0,0 +1,17 @@
+-- Skinfolds: caliper readings in the body measurement log, for the Jackson-Pollock body fat methods
+-- Migration: 025_skinfolds.sql
+
+ALTER TABLE body_measurements ADD COLUMN IF NOT EXISTS chest_skinfold_mm NUMERIC(4,1);       -- diagonal, between armpit and nipple
+ALTER TABLE body_measurements ADD COLUMN IF NOT EXISTS abdominal_skinfold_mm NUMERIC(4,1);   -- vertical, beside the navel
+ALTER TABLE body_measurements ADD COLUMN IF NOT EXISTS thigh_skinfold_mm NUMERIC(4,1);       -- vertical, front of the thigh
+ALTER TABLE body_measurements ADD COLUMN IF NOT EXISTS triceps_skinfold_mm NUMERIC(4,1);
+ALTER TABLE body_measurements ADD COLUMN IF NOT EXISTS subscapular_skinfold_mm NUMERIC(4,1);
+ALTER TABLE body_measurements ADD COLUMN IF NOT EXISTS suprailiac_skinfold_mm NUMERIC(4,1);
+ALTER TABLE body_measurements ADD COLUMN IF NOT EXISTS midaxillary_skinfold_mm NUMERIC(4,1);
+
+ALTER TABLE body_measurements DROP CONSTRAINT IF EXISTS body_measurements_any_value_check;
+ALTER TABLE body_measurements ADD CONSTRAINT body_measurements_any_value_check CHECK (
+    num_nonnulls(weight_kg, body_fat_pct, waist_cm, hips_cm, chest_cm, neck_cm, arm_cm, thigh_cm, calf_cm,
+                 chest_skinfold_mm, abdominal_skinfold_mm, thigh_skinfold_mm, triceps_skinfold_mm,
+                 subscapular_skinfold_mm, suprailiac_skinfold_mm, midaxillary_skinfold_mm) > 0
+);
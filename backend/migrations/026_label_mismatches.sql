@@ -0,0 +1,12 @@
+-- Keep label mismatches apart from diet conflicts
+-- Migration: 026_label_mismatches.sql
+
+-- Diet claims on a logged food that its name contradicts (e.g. a "gluten_free" wheat bread). They
+-- say nothing about the user's profile, so they aren't exposures.
+ALTER TABLE meal_foods ADD COLUMN IF NOT EXISTS label_mismatches TEXT[] NOT NULL DEFAULT '{}';
+
+-- Earlier rows kept them in diet_conflicts as "claim:<tag>"
+UPDATE meal_foods
+SET label_mismatches = ARRAY(SELECT substr(c, 7) FROM unnest(diet_conflicts) c WHERE c LIKE 'claim:%'),
+    diet_conflicts = ARRAY(SELECT c FROM unnest(diet_conflicts) c WHERE c NOT LIKE 'claim:%')
+WHERE EXISTS (SELECT 1 FROM unnest(diet_conflicts) c WHERE c LIKE 'claim:%');
@@ -0,0 +1,9 @@
+-- Measure weight goal slips from a fixed projection rather than the previous day's
+-- Migration: 027_goal_reference_projection.sql
+
+-- The first projection after the goal was set, moved on only when a slip is announced, so a goal
+-- drifting a day at a time is still noticed once it adds up
+ALTER TABLE user_goals ADD COLUMN IF NOT EXISTS reference_projected_date DATE;
+
+UPDATE user_goals SET reference_projected_date = projected_date
+WHERE goal_type = 'weight' AND reference_projected_date IS NULL;
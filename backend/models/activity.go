@@ -1 +1,21 @@
 package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DailyActivity is a day of device activity data. Counters are cumulative for the day, so a null
+// field is one no sample has reported yet.
+type DailyActivity struct {
+	UserID        uuid.UUID `gorm:"type:uuid;primaryKey" json:"user_id"`
+	Date          string    `gorm:"type:date;primaryKey" json:"date"`
+	Steps         *int      `gorm:"type:int" json:"steps"`
+	ActiveMinutes *int      `gorm:"type:int" json:"active_minutes"`
+	DistanceM     *float64  `gorm:"type:numeric(9,1)" json:"distance_m"`
+	Floors        *int      `gorm:"type:int" json:"floors"`
+	RestingHR     *int      `gorm:"type:smallint" json:"resting_hr"`
+	RecordedAt    time.Time `gorm:"not null" json:"recorded_at"` // newest sample merged into the day
+	UpdatedAt     time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// BodyMeasurement is one entry in the body measurement log. Only the values measured are set.
+type BodyMeasurement struct {
+	ID         uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	UserID     uuid.UUID `gorm:"type:uuid;not null" json:"user_id"`
+	MeasuredAt time.Time `gorm:"not null" json:"measured_at"`
+	Date       string    `gorm:"-" json:"date"` // calendar day of measured_at in the user's timezone
+	WeightKg   *float64  `gorm:"type:numeric(5,2)" json:"weight_kg"`
+	BodyFatPct *float64  `gorm:"type:numeric(4,1)" json:"body_fat_pct"`
+	WaistCm    *float64  `gorm:"type:numeric(5,1)" json:"waist_cm"`
+	HipsCm     *float64  `gorm:"type:numeric(5,1)" json:"hips_cm"`
+	ChestCm    *float64  `gorm:"type:numeric(5,1)" json:"chest_cm"`
+	NeckCm     *float64  `gorm:"type:numeric(5,1)" json:"neck_cm"`
+	ArmCm      *float64  `gorm:"type:numeric(5,1)" json:"arm_cm"`
+	ThighCm    *float64  `gorm:"type:numeric(5,1)" json:"thigh_cm"`
+	CalfCm     *float64  `gorm:"type:numeric(5,1)" json:"calf_cm"`
+
+	// Skinfold caliper readings
+	ChestSkinfoldMm       *float64 `gorm:"type:numeric(4,1)" json:"chest_skinfold_mm"`
+	AbdominalSkinfoldMm   *float64 `gorm:"type:numeric(4,1)" json:"abdominal_skinfold_mm"`
+	ThighSkinfoldMm       *float64 `gorm:"type:numeric(4,1)" json:"thigh_skinfold_mm"`
+	TricepsSkinfoldMm     *float64 `gorm:"type:numeric(4,1)" json:"triceps_skinfold_mm"`
+	SubscapularSkinfoldMm *float64 `gorm:"type:numeric(4,1)" json:"subscapular_skinfold_mm"`
+	SuprailiacSkinfoldMm  *float64 `gorm:"type:numeric(4,1)" json:"suprailiac_skinfold_mm"`
+	MidaxillarySkinfoldMm *float64 `gorm:"type:numeric(4,1)" json:"midaxillary_skinfold_mm"`
+
+	Note      string    `gorm:"type:text" json:"note"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
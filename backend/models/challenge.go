@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Challenge is a group competition on one metric between start_date and end_date
+type Challenge struct {
+	ID             uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	OwnerID        uuid.UUID  `gorm:"type:uuid;not null" json:"owner_id"`
+	Name           string     `gorm:"type:text;not null" json:"name"`
+	Description    string     `gorm:"type:text" json:"description"`
+	Metric         string     `gorm:"type:text;not null" json:"metric"` // steps, workouts or protein_days
+	ProteinTargetG *int       `gorm:"type:integer" json:"protein_target_g,omitempty"`
+	StartDate      string     `gorm:"type:date;not null" json:"start_date"`
+	EndDate        string     `gorm:"type:date;not null" json:"end_date"`
+	InviteCode     string     `gorm:"type:text;unique;not null" json:"invite_code"`
+	Status         string     `gorm:"-" json:"status"` // upcoming, active, ended or finalized
+	Participants   int        `gorm:"-" json:"participants"`
+	FinalizedAt    *time.Time `json:"finalized_at,omitempty"`
+	CreatedAt      time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// ChallengeStanding is one row of a leaderboard as the viewer is allowed to see it
+type ChallengeStanding struct {
+	Rank        int        `json:"rank"`
+	UserID      *uuid.UUID `json:"user_id,omitempty"` // only for the viewer's own row and public participants
+	DisplayName *string    `json:"display_name"`      // null for anonymous participants
+	Score       *int       `json:"score"`             // null for rank_only participants
+	IsYou       bool       `json:"is_you"`
+}
@@ -0,0 +1,12 @@
+package models
+
+// Exercise is an entry in the exercise catalog; each intensity variant is its own row
+type Exercise struct {
+	ID          int     `gorm:"primaryKey;autoIncrement" json:"id"`
+	Slug        string  `gorm:"type:text;not null" json:"slug"`
+	Name        string  `gorm:"type:text;not null" json:"name"`
+	Type        string  `gorm:"type:text;not null" json:"type"`                         // cardio, strength, flexibility, sports
+	Intensity   string  `gorm:"type:text;not null;default:'moderate'" json:"intensity"` // light, moderate, vigorous
+	MET         float64 `gorm:"type:numeric(4,1);not null" json:"met"`
+	MuscleGroup string  `gorm:"type:text" json:"muscle_group,omitempty"` // strength exercises: chest, back, legs, shoulders, arms, core, full_body
+}
@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// GoalPeriod is a closed daily, weekly or monthly period of a goal
+type GoalPeriod struct {
+	ID            uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	GoalID        uuid.UUID `gorm:"type:uuid;not null" json:"goal_id"`
+	PeriodStart   string    `gorm:"type:date;not null" json:"period_start"`
+	PeriodEnd     string    `gorm:"type:date;not null" json:"period_end"`
+	TargetValue   int       `gorm:"not null" json:"target_value"`
+	ProgressValue int       `gorm:"not null" json:"progress_value"`
+	Status        string    `gorm:"type:text;not null" json:"status"` // met or missed
+	ClosedAt      time.Time `gorm:"not null" json:"closed_at"`
+}
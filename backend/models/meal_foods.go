@@ -18,4 +18,9 @@ type MealFood struct {
 	Iron        float32 `db:"iron" json:"iron"`
 	Potassium   float32 `db:"potassium" json:"potassium"`
 	ServingSize string  `db:"serving_size" json:"serving_size"` // Original serving size from database
+
+	Allergens       []string `db:"allergens" json:"allergens"`               // peanuts, milk, gluten, etc.
+	DietTags        []string `db:"diet_tags" json:"diet_tags"`               // vegan, gluten_free, meat, etc.
+	DietConflicts   []string `db:"diet_conflicts" json:"diet_conflicts"`     // conflicts with the user's profile when logged
+	LabelMismatches []string `db:"label_mismatches" json:"label_mismatches"` // diet claims the food's name contradicts
 }
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PersonalRecord is a best performance on an exercise, recorded when it was first achieved
+type PersonalRecord struct {
+	ID            uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	UserID        uuid.UUID  `gorm:"type:uuid;not null" json:"user_id"`
+	WorkoutID     uuid.UUID  `gorm:"type:uuid;not null" json:"workout_id"`
+	SetID         *uuid.UUID `gorm:"type:uuid" json:"set_id,omitempty"`
+	ExerciseKey   string     `gorm:"type:text;not null" json:"exercise_key"`
+	ExerciseName  string     `gorm:"type:text;not null" json:"exercise_name"`
+	RecordType    string     `gorm:"type:text;not null" json:"record_type"` // max_weight, reps_at_weight, estimated_1rm, max_volume
+	Value         float64    `gorm:"type:numeric(9,2);not null" json:"value"`
+	PreviousValue *float64   `gorm:"type:numeric(9,2)" json:"previous_value"`
+	WeightKg      *float64   `gorm:"type:numeric(6,2)" json:"weight_kg,omitempty"`
+	Reps          *int       `gorm:"type:int" json:"reps,omitempty"`
+	Formula       string     `gorm:"type:text" json:"formula,omitempty"` // epley or brzycki
+	AchievedAt    time.Time  `gorm:"not null" json:"achieved_at"`
+	CreatedAt     time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
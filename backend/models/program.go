@@ -0,0 +1,120 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Program is a multi-week training program; built-in programs have no owner
+type Program struct {
+	ID          uuid.UUID         `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	OwnerID     *uuid.UUID        `gorm:"type:uuid" json:"owner_id"`
+	Slug        string            `gorm:"type:text;not null" json:"slug"`
+	Name        string            `gorm:"type:text;not null" json:"name"`
+	Description string            `gorm:"type:text" json:"description"`
+	Definition  ProgramDefinition `gorm:"type:jsonb;not null" json:"definition"`
+	CreatedAt   time.Time         `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// ProgramDefinition is the shareable JSON form of a program.
+// Sessions are templates used in rotation; a program week is SessionsPerWeek sessions.
+type ProgramDefinition struct {
+	Slug            string           `json:"slug"`
+	Name            string           `json:"name"`
+	Description     string           `json:"description"`
+	Weeks           int              `json:"weeks"`
+	SessionsPerWeek int              `json:"sessions_per_week"`
+	Sessions        []ProgramSession `json:"sessions"`
+}
+
+// ProgramSession is one session template
+type ProgramSession struct {
+	Name      string            `json:"name"`
+	Type      string            `json:"type"` // cardio, strength, flexibility, sports
+	Exercises []ProgramExercise `json:"exercises"`
+}
+
+// ProgramExercise prescribes either sets × reps at a working weight, or run/walk intervals
+type ProgramExercise struct {
+	Exercise      string             `json:"exercise"` // catalog slug or free-form name
+	Sets          int                `json:"sets,omitempty"`
+	Reps          int                `json:"reps,omitempty"`
+	StartWeightKg float64            `json:"start_weight_kg,omitempty"`
+	Intervals     *ProgramIntervals  `json:"intervals,omitempty"`
+	Progression   ProgramProgression `json:"progression"`
+}
+
+// ProgramIntervals describes a run/walk session in its first week
+type ProgramIntervals struct {
+	RunSeconds      int `json:"run_seconds"`
+	WalkSeconds     int `json:"walk_seconds"`
+	TotalRunSeconds int `json:"total_run_seconds"`
+	WarmupMin       int `json:"warmup_min,omitempty"`
+}
+
+// ProgramProgression holds the rules applied between sessions
+type ProgramProgression struct {
+	IncrementKg       float64 `json:"increment_kg,omitempty"`        // added after a successful session
+	DeloadAfterMisses int     `json:"deload_after_misses,omitempty"` // consecutive misses before a deload
+	DeloadPercent     float64 `json:"deload_percent,omitempty"`      // weight reduction on deload
+
+	RunIncrementSeconds   int `json:"run_increment_seconds,omitempty"`   // interval length added each week
+	TotalIncrementSeconds int `json:"total_increment_seconds,omitempty"` // total running time added each week
+	MaxRunSeconds         int `json:"max_run_seconds,omitempty"`         // at this length the session becomes one continuous run
+}
+
+// ProgramEnrollment is a user's progress through a program
+type ProgramEnrollment struct {
+	ID        uuid.UUID       `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	UserID    uuid.UUID       `gorm:"type:uuid;not null" json:"user_id"`
+	ProgramID uuid.UUID       `gorm:"type:uuid;not null" json:"program_id"`
+	StartDate string          `gorm:"type:date;not null" json:"start_date"`
+	Status    string          `gorm:"type:text;default:'active'" json:"status"` // active, completed, cancelled
+	State     EnrollmentState `gorm:"type:jsonb" json:"state"`
+	CreatedAt time.Time       `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time       `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// EnrollmentState is the progression state stored with an enrollment
+type EnrollmentState struct {
+	SessionsDone int                       `json:"sessions_done"`       // completed sessions; picks the next template and week
+	Lifts        map[string]*LiftState     `json:"lifts"`               // keyed by exercise key (catalog slug or lowercased name)
+	Intervals    map[string]*IntervalState `json:"intervals,omitempty"` // run/walk exercises, keyed like Lifts
+	Pending      *PendingSession           `json:"pending,omitempty"`
+}
+
+// IntervalState is how far a run/walk exercise has progressed: its program week, the sessions
+// completed at that week, and consecutive misses
+type IntervalState struct {
+	Week      int `json:"week"`
+	Completed int `json:"completed"`
+	Misses    int `json:"misses"`
+}
+
+// LiftState is the working weight and consecutive misses for one lift
+type LiftState struct {
+	WeightKg float64 `json:"weight_kg"`
+	Misses   int     `json:"misses"`
+}
+
+// PendingSession is the last generated session, evaluated when the next one is requested
+type PendingSession struct {
+	WorkoutID  uuid.UUID            `json:"workout_id"`
+	Template   int                  `json:"template"`
+	Week       int                  `json:"week"`
+	Date       string               `json:"date"`
+	Prescribed []PrescribedExercise `json:"prescribed"`
+}
+
+// PrescribedExercise is what a generated session asks for
+type PrescribedExercise struct {
+	Key         string  `json:"key"`
+	Name        string  `json:"name"`
+	Sets        int     `json:"sets,omitempty"`
+	Reps        int     `json:"reps,omitempty"`
+	WeightKg    float64 `json:"weight_kg,omitempty"`
+	RunSeconds  int     `json:"run_seconds,omitempty"`
+	WalkSeconds int     `json:"walk_seconds,omitempty"`
+	Repeats     int     `json:"repeats,omitempty"`
+}
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ProgressPhoto is a body photo for one pose on one day. The files live under the upload
+// directory and are only served to their owner.
+type ProgressPhoto struct {
+	ID        uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	UserID    uuid.UUID `gorm:"type:uuid;not null" json:"user_id"`
+	TakenOn   string    `gorm:"type:date;not null" json:"taken_on"`
+	Pose      string    `gorm:"type:text;not null" json:"pose"` // front, side or back
+	Note      string    `gorm:"type:text" json:"note"`
+	Width     int       `gorm:"not null" json:"width"`
+	Height    int       `gorm:"not null" json:"height"`
+	SizeBytes int       `gorm:"not null" json:"size_bytes"`
+	ImageURL  string    `gorm:"-" json:"image_url"`
+	ThumbURL  string    `gorm:"-" json:"thumbnail_url"`
+	WeighIn   *WeighIn  `gorm:"-" json:"weigh_in"` // the newest weigh-in on taken_on, if any
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
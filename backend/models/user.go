@@ -14,6 +14,14 @@ type User struct {
 	Role      string    `gorm:"type:text;default:'user';not null" json:"role"`
 	Age       int64     `gorm:"type:int8" json:"age"`
 	Height    int64     `gorm:"type:int8" json:"height"`
-	Weight    int64     `gorm:"type:int8" json:"weight"`
+	Weight    *float64  `gorm:"type:numeric(5,2)" json:"weight"` // kg; mirrors the latest body measurement
 	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+
+	DietaryRestrictions []string `gorm:"type:text[]" json:"dietary_restrictions"` // vegan, vegetarian, gluten_free, dairy_free
+	Allergens           []string `gorm:"type:text[]" json:"allergens"`            // peanuts, tree_nuts, milk, etc.
+	Timezone            string   `gorm:"type:text;default:'UTC'" json:"timezone"` // IANA name used for schedules and reminders
+	Sex                 *string  `gorm:"type:text" json:"sex"`                    // male or female; used by BMR formulas
+	MaxHR               *int     `gorm:"type:smallint" json:"max_hr"`             // bpm; heart rate zones use 220 - age when unset
+	RestingHR           *int     `gorm:"type:smallint" json:"resting_hr"`         // bpm; zones use % of max HR when unset
+	BodyFatPct          *float64 `gorm:"type:numeric(4,1)" json:"body_fat_pct"`   // mirrors the latest body measurement
 }
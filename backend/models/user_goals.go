@@ -7,14 +7,25 @@ import (
 )
 
 type UserGoal struct {
-	ID            uuid.UUID `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
-	UserID        uuid.UUID `gorm:"type:uuid;not null" json:"user_id"`
-	GoalType      string    `gorm:"type:varchar(50);not null" json:"goal_type"`
-	TargetValue   int       `gorm:"not null" json:"target_value"`
-	ProgressValue int       `gorm:"not null;default:0" json:"progress_value"`
-	TimeFrame     string    `gorm:"type:varchar(20);not null" json:"time_frame"`
-	IsCompleted   bool      `gorm:"type:boolean;not null;default:false" json:"is_completed"`
-	Archived      bool      `gorm:"type:boolean;not null;default:false" json:"archived"` // NEW
-	CreatedAt     time.Time `gorm:"not null;default:current_timestamp" json:"created_at"`
-	UpdatedAt     time.Time `gorm:"not null;default:current_timestamp" json:"updated_at"`
+	ID             uuid.UUID `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	UserID         uuid.UUID `gorm:"type:uuid;not null" json:"user_id"`
+	GoalType       string    `gorm:"type:varchar(50);not null" json:"goal_type"` // key in the goal type registry
+	Label          *string   `gorm:"type:text" json:"label"`
+	Name           string    `gorm:"-" json:"name"` // label, or the registry name
+	Unit           string    `gorm:"-" json:"unit"`
+	Direction      string    `gorm:"-" json:"direction"` // at_least or at_most
+	Source         string    `gorm:"-" json:"source"`    // manual, meals, workouts, activity or weigh_ins
+	TargetValue    int       `gorm:"not null" json:"target_value"`
+	ProgressValue  int       `gorm:"not null;default:0" json:"progress_value"`
+	TimeFrame      string    `gorm:"type:varchar(20);not null" json:"time_frame"`
+	IsCompleted    bool      `gorm:"type:boolean;not null;default:false" json:"is_completed"`
+	PeriodStart    *string   `gorm:"type:date" json:"period_start,omitempty"`             // period progress_value belongs to
+	TargetWeightKg *float64  `gorm:"type:numeric(5,2)" json:"target_weight_kg,omitempty"` // weight goals only, as are the next three
+	StartWeightKg  *float64  `gorm:"type:numeric(5,2)" json:"start_weight_kg,omitempty"`
+	TargetDate     *string   `gorm:"type:date" json:"target_date,omitempty"`
+	ProjectedDate  *string   `gorm:"type:date" json:"projected_date,omitempty"`           // when the weigh-in trend reaches the target
+	Streak         int       `gorm:"-" json:"streak"`                                     // consecutive periods met, see GET /goals/:id/history
+	Archived       bool      `gorm:"type:boolean;not null;default:false" json:"archived"` // NEW
+	CreatedAt      time.Time `gorm:"not null;default:current_timestamp" json:"created_at"`
+	UpdatedAt      time.Time `gorm:"not null;default:current_timestamp" json:"updated_at"`
 }
@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// WeighIn is a body measurement that has a weight, as the weigh-in endpoints and trends see it
+type WeighIn struct {
+	ID        uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	UserID    uuid.UUID `gorm:"type:uuid;not null" json:"user_id"`
+	WeighedAt time.Time `gorm:"not null" json:"weighed_at"`
+	Date      string    `gorm:"-" json:"date"` // calendar day of weighed_at in the user's timezone
+	WeightKg  float64   `gorm:"type:numeric(5,2);not null" json:"weight_kg"`
+	Note      string    `gorm:"type:text" json:"note"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
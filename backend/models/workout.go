@@ -17,6 +17,20 @@ type Workout struct {
 	Reps           sql.NullInt32   `gorm:"type:int" json:"reps"`            // repetitions for strength training
 	CaloriesBurned sql.NullInt32   `gorm:"type:int" json:"calories_burned"`
 	Date           sql.NullTime    `gorm:"type:date" json:"-"`
-	DateString     string          `json:"date"` // JSON visible date string
+	DateString     string          `json:"date"`                                    // JSON visible date string
+	ScheduledAt    sql.NullTime    `gorm:"type:timestamptz" json:"scheduled_at"`    // optional start time
+	Timezone       string          `gorm:"type:text;default:'UTC'" json:"timezone"` // IANA name the workout is scheduled in
 	CreatedAt      time.Time       `gorm:"autoCreateTime" json:"created_at"`
+	ExerciseID     sql.NullInt64   `gorm:"type:int" json:"exercise_id"`                       // optional exercise_catalog reference
+	CaloriesSource string          `gorm:"type:text;default:'manual'" json:"calories_source"` // manual, met_estimate, device, none
+	PlanID         uuid.NullUUID   `gorm:"type:uuid" json:"plan_id"`                          // set for occurrences of a workout plan
+	Detached       bool            `gorm:"default:false" json:"detached"`                     // occurrence edited apart from its plan
+	EnrollmentID   uuid.NullUUID   `gorm:"type:uuid" json:"enrollment_id"`                    // set for sessions generated by a program
+
+	Status            string        `gorm:"type:text;default:'planned'" json:"status"` // planned, in_progress, completed, skipped, missed
+	StartedAt         sql.NullTime  `gorm:"type:timestamptz" json:"started_at"`
+	CompletedAt       sql.NullTime  `gorm:"type:timestamptz" json:"completed_at"`
+	ActualDurationMin sql.NullInt32 `gorm:"type:int" json:"actual_duration_min"` // DurationMin is the planned duration
+	SessionRPE        sql.NullInt32 `gorm:"type:smallint" json:"session_rpe"`    // Foster's session RPE, 1-10
+	WasPlanned        bool          `gorm:"default:true" json:"was_planned"`     // false for workouts logged after the fact
 }
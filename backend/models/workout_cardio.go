@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// WorkoutCardio holds the distance and heart rate figures of a cardio workout
+type WorkoutCardio struct {
+	WorkoutID      uuid.UUID `gorm:"type:uuid;primaryKey" json:"workout_id"`
+	DistanceM      *float64  `gorm:"type:numeric(9,1)" json:"distance_m"`
+	ElevationGainM *float64  `gorm:"type:numeric(7,1)" json:"elevation_gain_m"`
+	AvgHeartRate   *int      `gorm:"type:smallint" json:"avg_heart_rate"`
+	MaxHeartRate   *int      `gorm:"type:smallint" json:"max_heart_rate"`
+	ZoneSeconds    []int64   `gorm:"type:integer[]" json:"zone_seconds"`       // zones 1-5
+	Source         string    `gorm:"type:text;default:'manual'" json:"source"` // manual or track
+	UpdatedAt      time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
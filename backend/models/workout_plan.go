@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// WorkoutPlan is a recurring workout; its occurrences are materialized as workouts rows
+type WorkoutPlan struct {
+	ID          uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	UserID      uuid.UUID `gorm:"type:uuid;not null" json:"user_id"`
+	Name        string    `gorm:"type:text;not null" json:"name"`
+	Type        string    `gorm:"type:text;not null" json:"type"`
+	ExerciseID  *int      `gorm:"type:int" json:"exercise_id"`
+	DurationMin int       `gorm:"type:int;not null" json:"duration_min"`
+	RRule       string    `gorm:"type:text;not null" json:"rrule"` // e.g. FREQ=WEEKLY;BYDAY=MO,WE,FR
+	StartDate   string    `gorm:"type:date;not null" json:"start_date"`
+	StartTime   *string   `gorm:"type:time" json:"start_time"` // "HH:MM", nil for no set time
+	Timezone    string    `gorm:"type:text;default:'UTC'" json:"timezone"`
+	Active      bool      `gorm:"default:true" json:"active"`
+	CreatedAt   time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// WorkoutExercise is one exercise in a workout session, in the order it was performed
+type WorkoutExercise struct {
+	ID         uuid.UUID    `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	WorkoutID  uuid.UUID    `gorm:"type:uuid;not null" json:"workout_id"`
+	ExerciseID *int         `gorm:"type:int" json:"exercise_id"` // optional exercise_catalog reference
+	Name       string       `gorm:"type:text;not null" json:"name"`
+	Position   int          `gorm:"type:int;not null" json:"position"`
+	Notes      string       `gorm:"type:text" json:"notes"`
+	CreatedAt  time.Time    `gorm:"autoCreateTime" json:"created_at"`
+	Sets       []WorkoutSet `gorm:"-" json:"sets"`
+	VolumeKg   float64      `gorm:"-" json:"volume_kg"` // reps × load over working sets
+}
+
+// WorkoutSet is a single logged set of a workout exercise
+type WorkoutSet struct {
+	ID                uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	WorkoutExerciseID uuid.UUID `gorm:"type:uuid;not null" json:"workout_exercise_id"`
+	SetNumber         int       `gorm:"type:int;not null" json:"set_number"`
+	Reps              int       `gorm:"type:int;not null" json:"reps"`
+	WeightKg          float64   `gorm:"type:numeric(6,2);not null" json:"weight_kg"`
+	RPE               *float64  `gorm:"type:numeric(3,1)" json:"rpe"`
+	RestSeconds       *int      `gorm:"type:int" json:"rest_seconds"`
+	IsWarmup          bool      `gorm:"default:false" json:"is_warmup"`
+	CompletedAt       time.Time `gorm:"autoCreateTime" json:"completed_at"`
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// WorkoutTrack is the recorded track behind an imported workout
+type WorkoutTrack struct {
+	ID             uuid.UUID       `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	WorkoutID      uuid.UUID       `gorm:"type:uuid;not null;unique" json:"workout_id"`
+	UserID         uuid.UUID       `gorm:"type:uuid;not null" json:"user_id"`
+	Format         string          `gorm:"type:text;not null" json:"format"` // gpx, tcx, fit
+	Sport          string          `gorm:"type:text" json:"sport"`
+	FileHash       string          `gorm:"type:text;not null" json:"file_hash"`
+	StartTime      time.Time       `gorm:"not null" json:"start_time"`
+	EndTime        time.Time       `gorm:"not null" json:"end_time"`
+	DurationSec    int             `gorm:"type:int;not null" json:"duration_sec"`
+	DistanceM      float64         `gorm:"type:numeric(9,1)" json:"distance_m"`
+	ElevationGainM float64         `gorm:"type:numeric(7,1)" json:"elevation_gain_m"`
+	AvgHeartRate   *int            `gorm:"type:int" json:"avg_heart_rate"`
+	MaxHeartRate   *int            `gorm:"type:int" json:"max_heart_rate"`
+	DeviceCalories *int            `gorm:"type:int" json:"device_calories"`
+	Splits         json.RawMessage `gorm:"type:jsonb" json:"splits"`
+	Points         json.RawMessage `gorm:"type:jsonb" json:"points,omitempty"`
+	CreatedAt      time.Time       `gorm:"autoCreateTime" json:"created_at"`
+}
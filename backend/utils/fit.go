@@ -0,0 +1,234 @@
+package utils
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"time"
+)
+
+// FIT global message and field numbers we read (from the Garmin FIT SDK profile)
+const (
+	fitMesgSport   = 12
+	fitMesgSession = 18
+	fitMesgRecord  = 20
+
+	fitFieldTimestamp = 253
+
+	fitRecordLat          = 0
+	fitRecordLon          = 1
+	fitRecordAltitude     = 2
+	fitRecordHeartRate    = 3
+	fitRecordDistance     = 5
+	fitRecordEnhancedAlt  = 78
+	fitSessionSport       = 5
+	fitSessionCalories    = 11
+	fitSportSport         = 0
+	fitSemicirclesToDeg   = 180.0 / (1 << 31)
+	fitEpochOffsetSeconds = 631065600 // 1989-12-31T00:00:00Z in Unix time
+)
+
+// fitSports maps the FIT sport enum onto our sport names
+var fitSports = map[uint64]string{1: "running", 2: "cycling", 5: "swimming", 11: "walking", 17: "hiking"}
+
+var errInvalidFIT = errors.New("invalid FIT file")
+
+type fitFieldDef struct {
+	Num  byte
+	Size int
+}
+
+type fitDefinition struct {
+	Global    uint16
+	BigEndian bool
+	Fields    []fitFieldDef
+	DevSize   int // total size of developer fields, skipped
+}
+
+// isFIT checks for the ".FIT" signature in the file header
+func isFIT(data []byte) bool {
+	return len(data) >= 12 && string(data[8:12]) == ".FIT"
+}
+
+// ParseFIT decodes the record, session and sport messages of a FIT activity file.
+// Other messages are skipped using their definitions; CRCs are not checked.
+func ParseFIT(data []byte) (*Track, error) {
+	if !isFIT(data) {
+		return nil, errInvalidFIT
+	}
+	headerSize := int(data[0])
+	if headerSize < 12 || headerSize > len(data) {
+		return nil, errInvalidFIT
+	}
+	dataSize := int(binary.LittleEndian.Uint32(data[4:8]))
+	end := headerSize + dataSize
+	if end > len(data) {
+		return nil, errors.New("FIT file is truncated")
+	}
+
+	track := &Track{Format: TrackFormatFIT}
+	definitions := map[byte]*fitDefinition{}
+	var lastTimestamp uint32
+	pos := headerSize
+
+	for pos < end {
+		header := data[pos]
+		pos++
+
+		var local byte
+		var compressedOffset = -1
+		if header&0x80 != 0 {
+			// Compressed timestamp header: local type in bits 5-6, time offset in bits 0-4
+			local = (header >> 5) & 0x03
+			compressedOffset = int(header & 0x1F)
+		} else {
+			local = header & 0x0F
+			if header&0x40 != 0 {
+				def, n, err := readFITDefinition(data[pos:end], header&0x20 != 0)
+				if err != nil {
+					return nil, err
+				}
+				definitions[local] = def
+				pos += n
+				continue
+			}
+		}
+
+		def, ok := definitions[local]
+		if !ok {
+			return nil, errors.New("FIT data message without a definition")
+		}
+		fields := map[byte]uint64{}
+		for _, f := range def.Fields {
+			if pos+f.Size > end {
+				return nil, errors.New("FIT file is truncated")
+			}
+			if v, valid := readFITValue(data[pos:pos+f.Size], def.BigEndian); valid {
+				fields[f.Num] = v
+			}
+			pos += f.Size
+		}
+		pos += def.DevSize
+		if pos > end {
+			return nil, errors.New("FIT file is truncated")
+		}
+
+		if ts, ok := fields[fitFieldTimestamp]; ok {
+			lastTimestamp = uint32(ts)
+		} else if compressedOffset >= 0 {
+			// The offset replaces the low 5 bits of the last timestamp, rolling over when smaller
+			ts := lastTimestamp&^0x1F | uint32(compressedOffset)
+			if uint32(compressedOffset) < lastTimestamp&0x1F {
+				ts += 0x20
+			}
+			lastTimestamp = ts
+			fields[fitFieldTimestamp] = uint64(ts)
+		}
+
+		switch def.Global {
+		case fitMesgRecord:
+			track.Points = append(track.Points, fitRecordPoint(fields))
+		case fitMesgSession:
+			if cal, ok := fields[fitSessionCalories]; ok {
+				track.DeviceCalories += int(cal)
+			}
+			if sport, ok := fitSports[fields[fitSessionSport]]; ok && track.Sport == "" {
+				track.Sport = sport
+			}
+		case fitMesgSport:
+			if sport, ok := fitSports[fields[fitSportSport]]; ok && track.Sport == "" {
+				track.Sport = sport
+			}
+		}
+	}
+	return track, nil
+}
+
+// readFITDefinition parses a definition message body and returns its length
+func readFITDefinition(b []byte, hasDevFields bool) (*fitDefinition, int, error) {
+	if len(b) < 5 {
+		return nil, 0, errInvalidFIT
+	}
+	def := &fitDefinition{BigEndian: b[1] == 1}
+	if def.BigEndian {
+		def.Global = binary.BigEndian.Uint16(b[2:4])
+	} else {
+		def.Global = binary.LittleEndian.Uint16(b[2:4])
+	}
+	numFields := int(b[4])
+	n := 5
+	if len(b) < n+numFields*3 {
+		return nil, 0, errInvalidFIT
+	}
+	for i := 0; i < numFields; i++ {
+		def.Fields = append(def.Fields, fitFieldDef{Num: b[n], Size: int(b[n+1])})
+		n += 3
+	}
+	if hasDevFields {
+		if len(b) < n+1 {
+			return nil, 0, errInvalidFIT
+		}
+		numDev := int(b[n])
+		n++
+		if len(b) < n+numDev*3 {
+			return nil, 0, errInvalidFIT
+		}
+		for i := 0; i < numDev; i++ {
+			def.DevSize += int(b[n+1])
+			n += 3
+		}
+	}
+	return def, n, nil
+}
+
+// readFITValue reads a 1, 2 or 4 byte unsigned value; all-ones means "invalid" in FIT.
+// Arrays and other sizes aren't needed for the fields we read and are reported invalid.
+func readFITValue(b []byte, bigEndian bool) (uint64, bool) {
+	var order binary.ByteOrder = binary.LittleEndian
+	if bigEndian {
+		order = binary.BigEndian
+	}
+	switch len(b) {
+	case 1:
+		return uint64(b[0]), b[0] != 0xFF
+	case 2:
+		v := order.Uint16(b)
+		return uint64(v), v != 0xFFFF
+	case 4:
+		v := order.Uint32(b)
+		return uint64(v), v != 0xFFFFFFFF && v != 0x7FFFFFFF
+	}
+	return 0, false
+}
+
+// fitRecordPoint converts a record message into a track point
+func fitRecordPoint(fields map[byte]uint64) TrackPoint {
+	var p TrackPoint
+	if ts, ok := fields[fitFieldTimestamp]; ok {
+		p.Time = time.Unix(int64(ts)+fitEpochOffsetSeconds, 0).UTC()
+	}
+	lat, latOK := fields[fitRecordLat]
+	lon, lonOK := fields[fitRecordLon]
+	if latOK && lonOK {
+		latDeg := float64(int32(uint32(lat))) * fitSemicirclesToDeg
+		lonDeg := float64(int32(uint32(lon))) * fitSemicirclesToDeg
+		p.Lat, p.Lon = &latDeg, &lonDeg
+	}
+	// Altitude is stored as (metres + 500) × 5
+	if alt, ok := fields[fitRecordEnhancedAlt]; ok {
+		e := math.Round((float64(alt)/5-500)*10) / 10
+		p.Elevation = &e
+	} else if alt, ok := fields[fitRecordAltitude]; ok {
+		e := math.Round((float64(alt)/5-500)*10) / 10
+		p.Elevation = &e
+	}
+	if hr, ok := fields[fitRecordHeartRate]; ok {
+		p.HeartRate = int(hr)
+	}
+	// Distance is stored in centimetres
+	if d, ok := fields[fitRecordDistance]; ok {
+		m := float64(d) / 100
+		p.DistanceM = &m
+	}
+	return p
+}
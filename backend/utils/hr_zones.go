@@ -0,0 +1,63 @@
+package utils
+
+import "math"
+
+// HRZoneCount is the number of heart rate zones (Z1 recovery to Z5 maximum)
+const HRZoneCount = 5
+
+// maxPointGapSec stops a recording pause from counting as time in the zone of the point before it
+const maxPointGapSec = 30
+
+// hrZoneFractions are the lower bounds of the zones as a fraction of heart rate reserve
+var hrZoneFractions = [HRZoneCount]float64{0.5, 0.6, 0.7, 0.8, 0.9}
+
+// HRZone is one heart rate zone in beats per minute; Max is exclusive except in the top zone
+type HRZone struct {
+	Zone int `json:"zone"`
+	Min  int `json:"min_bpm"`
+	Max  int `json:"max_bpm"`
+}
+
+// KarvonenZones computes the five zones from heart rate reserve: resting + fraction × (max − resting).
+// With a resting heart rate of 0 this reduces to percentages of max heart rate.
+func KarvonenZones(maxHR, restingHR int) [HRZoneCount]HRZone {
+	var zones [HRZoneCount]HRZone
+	reserve := float64(maxHR - restingHR)
+	for i, f := range hrZoneFractions {
+		zones[i] = HRZone{Zone: i + 1, Min: restingHR + int(math.Round(f*reserve)), Max: maxHR}
+		if i > 0 {
+			zones[i-1].Max = zones[i].Min
+		}
+	}
+	return zones
+}
+
+// ZoneFor returns the 0-based zone index for a heart rate, or -1 when it is below zone 1
+func ZoneFor(zones [HRZoneCount]HRZone, hr int) int {
+	for i := HRZoneCount - 1; i >= 0; i-- {
+		if hr >= zones[i].Min {
+			return i
+		}
+	}
+	return -1
+}
+
+// TimeInZones attributes the time between consecutive points to the zone of the earlier point's
+// heart rate. Points without heart rate and gaps longer than 30 s are left out.
+func (t *Track) TimeInZones(zones [HRZoneCount]HRZone) [HRZoneCount]int {
+	var seconds [HRZoneCount]int
+	for i := 0; i+1 < len(t.Points); i++ {
+		p := t.Points[i]
+		if p.HeartRate <= 0 {
+			continue
+		}
+		dt := int(t.Points[i+1].Time.Sub(p.Time).Seconds())
+		if dt <= 0 || dt > maxPointGapSec {
+			continue
+		}
+		if z := ZoneFor(zones, p.HeartRate); z >= 0 {
+			seconds[z] += dt
+		}
+	}
+	return seconds
+}
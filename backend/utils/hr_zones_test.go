@@ -0,0 +1,64 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestKarvonenZones(t *testing.T) {
+	tests := []struct {
+		name          string
+		maxHR, restHR int
+		want          [HRZoneCount]HRZone
+	}{
+		{
+			name: "heart rate reserve", maxHR: 190, restHR: 60,
+			want: [HRZoneCount]HRZone{{1, 125, 138}, {2, 138, 151}, {3, 151, 164}, {4, 164, 177}, {5, 177, 190}},
+		},
+		{
+			name: "percent of max without a resting heart rate", maxHR: 200, restHR: 0,
+			want: [HRZoneCount]HRZone{{1, 100, 120}, {2, 120, 140}, {3, 140, 160}, {4, 160, 180}, {5, 180, 200}},
+		},
+		{
+			name: "bounds are rounded", maxHR: 185, restHR: 52,
+			want: [HRZoneCount]HRZone{{1, 119, 132}, {2, 132, 145}, {3, 145, 158}, {4, 158, 172}, {5, 172, 185}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := KarvonenZones(tt.maxHR, tt.restHR); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestZoneFor(t *testing.T) {
+	zones := KarvonenZones(200, 0)
+	for hr, want := range map[int]int{0: -1, 99: -1, 100: 0, 119: 0, 120: 1, 159: 2, 160: 3, 180: 4, 200: 4, 230: 4} {
+		if got := ZoneFor(zones, hr); got != want {
+			t.Errorf("ZoneFor(%d) = %d, want %d", hr, got, want)
+		}
+	}
+}
+
+func TestTimeInZones(t *testing.T) {
+	start := time.Date(2024, 5, 1, 7, 0, 0, 0, time.UTC)
+	point := func(sec, hr int) TrackPoint {
+		return TrackPoint{Time: start.Add(time.Duration(sec) * time.Second), HeartRate: hr}
+	}
+	track := &Track{Points: []TrackPoint{
+		point(0, 110),   // 10 s in zone 1
+		point(10, 130),  // 20 s in zone 2
+		point(30, 0),    // no heart rate: left out
+		point(40, 90),   // below zone 1: left out
+		point(45, 185),  // 15 s in zone 5
+		point(60, 170),  // a 120 s pause: left out
+		point(180, 150), // 30 s in zone 3, the longest gap that still counts
+		point(210, 150), // the last point has no following interval
+	}}
+	want := [HRZoneCount]int{10, 20, 30, 0, 15}
+	if got := track.TimeInZones(KarvonenZones(200, 0)); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,269 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RRule is the supported subset of an RFC 5545 recurrence rule:
+// FREQ=DAILY|WEEKLY|MONTHLY, INTERVAL, BYDAY (with ordinals for MONTHLY), BYMONTHDAY, COUNT and UNTIL
+type RRule struct {
+	Freq       string
+	Interval   int
+	ByDay      []RRuleDay
+	ByMonthDay []int
+	Count      int
+	Until      *time.Time
+}
+
+// RRuleDay is a BYDAY entry; Ordinal is 0 for "every", or e.g. 1 / -1 for first / last in a month
+type RRuleDay struct {
+	Ordinal int
+	Weekday time.Weekday
+}
+
+var rruleWeekdays = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+// maxRRuleIterations bounds expansion so a malformed rule can't loop forever
+const maxRRuleIterations = 5000
+
+// ParseRRule parses an RRULE value such as "FREQ=WEEKLY;BYDAY=MO,WE,FR" (an "RRULE:" prefix is allowed)
+func ParseRRule(rule string) (*RRule, error) {
+	rule = strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(rule)), "RRULE:")
+	if rule == "" {
+		return nil, errors.New("rrule is empty")
+	}
+
+	r := &RRule{Interval: 1}
+	for _, part := range strings.Split(rule, ";") {
+		if part == "" {
+			continue
+		}
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid rrule part %q", part)
+		}
+		key, value := kv[0], kv[1]
+		switch key {
+		case "FREQ":
+			if value != "DAILY" && value != "WEEKLY" && value != "MONTHLY" {
+				return nil, errors.New("FREQ must be DAILY, WEEKLY or MONTHLY")
+			}
+			r.Freq = value
+		case "INTERVAL":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 || n > 365 {
+				return nil, errors.New("INTERVAL must be between 1 and 365")
+			}
+			r.Interval = n
+		case "COUNT":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 || n > 1000 {
+				return nil, errors.New("COUNT must be between 1 and 1000")
+			}
+			r.Count = n
+		case "UNTIL":
+			until, err := parseRRuleDate(value)
+			if err != nil {
+				return nil, err
+			}
+			r.Until = &until
+		case "BYDAY":
+			for _, d := range strings.Split(value, ",") {
+				day, err := parseRRuleDay(d)
+				if err != nil {
+					return nil, err
+				}
+				r.ByDay = append(r.ByDay, day)
+			}
+		case "BYMONTHDAY":
+			for _, d := range strings.Split(value, ",") {
+				n, err := strconv.Atoi(d)
+				if err != nil || n == 0 || n < -31 || n > 31 {
+					return nil, fmt.Errorf("invalid BYMONTHDAY %q", d)
+				}
+				r.ByMonthDay = append(r.ByMonthDay, n)
+			}
+		case "WKST":
+			if value != "MO" {
+				return nil, errors.New("only WKST=MO is supported")
+			}
+		default:
+			return nil, fmt.Errorf("unsupported rrule part %s", key)
+		}
+	}
+
+	if r.Freq == "" {
+		return nil, errors.New("FREQ is required")
+	}
+	if r.Count > 0 && r.Until != nil {
+		return nil, errors.New("COUNT and UNTIL cannot both be set")
+	}
+	if r.Freq == "DAILY" && (len(r.ByDay) > 0 || len(r.ByMonthDay) > 0) {
+		return nil, errors.New("BYDAY and BYMONTHDAY are not supported with FREQ=DAILY")
+	}
+	if r.Freq == "WEEKLY" && len(r.ByMonthDay) > 0 {
+		return nil, errors.New("BYMONTHDAY is not supported with FREQ=WEEKLY")
+	}
+	for _, d := range r.ByDay {
+		if d.Ordinal != 0 && r.Freq != "MONTHLY" {
+			return nil, errors.New("BYDAY ordinals are only supported with FREQ=MONTHLY")
+		}
+	}
+	return r, nil
+}
+
+func parseRRuleDate(value string) (time.Time, error) {
+	for _, layout := range []string{"20060102T150405Z", "20060102T150405", "20060102"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid UNTIL %q", value)
+}
+
+func parseRRuleDay(value string) (RRuleDay, error) {
+	value = strings.TrimSpace(value)
+	if len(value) < 2 {
+		return RRuleDay{}, fmt.Errorf("invalid BYDAY %q", value)
+	}
+	weekday, ok := rruleWeekdays[value[len(value)-2:]]
+	if !ok {
+		return RRuleDay{}, fmt.Errorf("invalid BYDAY %q", value)
+	}
+	day := RRuleDay{Weekday: weekday}
+	if prefix := value[:len(value)-2]; prefix != "" {
+		n, err := strconv.Atoi(prefix)
+		if err != nil || n == 0 || n < -5 || n > 5 {
+			return RRuleDay{}, fmt.Errorf("invalid BYDAY ordinal %q", value)
+		}
+		day.Ordinal = n
+	}
+	return day, nil
+}
+
+// Between expands the rule from dtstart and returns occurrence dates within [from, to], both inclusive.
+// Dates are calendar days at midnight UTC; COUNT is always counted from dtstart.
+func (r *RRule) Between(dtstart, from, to time.Time) []time.Time {
+	dtstart = truncateDay(dtstart)
+	from, to = truncateDay(from), truncateDay(to)
+	if r.Until != nil && r.Until.Before(to) {
+		to = *r.Until
+	}
+
+	var result []time.Time
+	emitted := 0
+	for i := 0; i < maxRRuleIterations; i++ {
+		if r.periodStart(dtstart, i).After(to) {
+			break
+		}
+		for _, d := range r.periodDates(dtstart, i) {
+			if d.Before(dtstart) {
+				continue
+			}
+			if d.After(to) || (r.Count > 0 && emitted >= r.Count) {
+				return result
+			}
+			emitted++
+			if !d.Before(from) {
+				result = append(result, d)
+			}
+		}
+	}
+	return result
+}
+
+// periodStart is the first day of the i-th period (day, week starting Monday, or month)
+func (r *RRule) periodStart(dtstart time.Time, i int) time.Time {
+	switch r.Freq {
+	case "DAILY":
+		return dtstart.AddDate(0, 0, i*r.Interval)
+	case "WEEKLY":
+		offset := (int(dtstart.Weekday()) + 6) % 7 // days since Monday
+		return dtstart.AddDate(0, 0, -offset+7*i*r.Interval)
+	default:
+		return time.Date(dtstart.Year(), dtstart.Month()+time.Month(i*r.Interval), 1, 0, 0, 0, 0, time.UTC)
+	}
+}
+
+// periodDates lists the candidate dates in the i-th period, sorted
+func (r *RRule) periodDates(dtstart time.Time, i int) []time.Time {
+	start := r.periodStart(dtstart, i)
+	switch r.Freq {
+	case "DAILY":
+		return []time.Time{start}
+
+	case "WEEKLY":
+		days := r.ByDay
+		if len(days) == 0 {
+			days = []RRuleDay{{Weekday: dtstart.Weekday()}}
+		}
+		var dates []time.Time
+		for _, d := range days {
+			dates = append(dates, start.AddDate(0, 0, (int(d.Weekday)+6)%7))
+		}
+		sortDates(dates)
+		return dates
+
+	default:
+		daysInMonth := time.Date(start.Year(), start.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+		// BYMONTHDAY and BYDAY each pick a set of days; given both, only days in both count
+		// (BYDAY=FR;BYMONTHDAY=13 is every Friday the 13th)
+		var monthDays, weekDays map[int]bool
+		if len(r.ByMonthDay) > 0 {
+			monthDays = map[int]bool{}
+			for _, md := range r.ByMonthDay {
+				if md < 0 {
+					md = daysInMonth + md + 1
+				}
+				monthDays[md] = true
+			}
+		}
+		if len(r.ByDay) > 0 {
+			weekDays = map[int]bool{}
+			for _, d := range r.ByDay {
+				first := 1 + (int(d.Weekday)-int(start.Weekday())+7)%7
+				switch {
+				case d.Ordinal == 0:
+					for day := first; day <= daysInMonth; day += 7 {
+						weekDays[day] = true
+					}
+				case d.Ordinal > 0:
+					weekDays[first+7*(d.Ordinal-1)] = true
+				default:
+					last := first
+					for last+7 <= daysInMonth {
+						last += 7
+					}
+					weekDays[last+7*(d.Ordinal+1)] = true
+				}
+			}
+		}
+		if monthDays == nil && weekDays == nil {
+			monthDays = map[int]bool{dtstart.Day(): true}
+		}
+
+		var dates []time.Time
+		for day := 1; day <= daysInMonth; day++ {
+			if (monthDays == nil || monthDays[day]) && (weekDays == nil || weekDays[day]) {
+				dates = append(dates, start.AddDate(0, 0, day-1))
+			}
+		}
+		return dates
+	}
+}
+
+func truncateDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func sortDates(dates []time.Time) {
+	sort.Slice(dates, func(i, j int) bool { return dates[i].Before(dates[j]) })
+}
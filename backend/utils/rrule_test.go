@@ -0,0 +1,147 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func mustDate(s string) time.Time {
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestRRuleBetween(t *testing.T) {
+	tests := []struct {
+		name     string
+		rule     string
+		dtstart  string
+		from, to string
+		want     []string
+	}{
+		{
+			name: "daily with interval", rule: "FREQ=DAILY;INTERVAL=3",
+			dtstart: "2024-01-01", from: "2024-01-01", to: "2024-01-10",
+			want: []string{"2024-01-01", "2024-01-04", "2024-01-07", "2024-01-10"},
+		},
+		{
+			name: "weekly on several days", rule: "FREQ=WEEKLY;BYDAY=MO,WE,FR",
+			dtstart: "2024-01-03", from: "2024-01-01", to: "2024-01-12",
+			want: []string{"2024-01-03", "2024-01-05", "2024-01-08", "2024-01-10", "2024-01-12"},
+		},
+		{
+			name: "weekly defaults to the start's weekday", rule: "FREQ=WEEKLY;INTERVAL=2",
+			dtstart: "2024-01-02", from: "2024-01-01", to: "2024-02-01",
+			want: []string{"2024-01-02", "2024-01-16", "2024-01-30"},
+		},
+		{
+			name: "monthly defaults to the start's day", rule: "FREQ=MONTHLY",
+			dtstart: "2024-01-15", from: "2024-01-01", to: "2024-03-31",
+			want: []string{"2024-01-15", "2024-02-15", "2024-03-15"},
+		},
+		{
+			name: "friday the 13th", rule: "FREQ=MONTHLY;BYDAY=FR;BYMONTHDAY=13",
+			dtstart: "2024-01-01", from: "2024-01-01", to: "2024-12-31",
+			want: []string{"2024-09-13", "2024-12-13"},
+		},
+		{
+			name: "first monday as an intersection", rule: "FREQ=MONTHLY;BYDAY=MO;BYMONTHDAY=1,2,3,4,5,6,7",
+			dtstart: "2024-01-01", from: "2024-01-01", to: "2024-03-31",
+			want: []string{"2024-01-01", "2024-02-05", "2024-03-04"},
+		},
+		{
+			name: "first and last weekday of the month", rule: "FREQ=MONTHLY;BYDAY=1MO,-1FR",
+			dtstart: "2024-01-01", from: "2024-01-01", to: "2024-02-29",
+			want: []string{"2024-01-01", "2024-01-26", "2024-02-05", "2024-02-23"},
+		},
+		{
+			name: "second to last weekday", rule: "FREQ=MONTHLY;BYDAY=-2TU",
+			dtstart: "2024-01-01", from: "2024-01-01", to: "2024-03-31",
+			want: []string{"2024-01-23", "2024-02-20", "2024-03-19"},
+		},
+		{
+			name: "fifth weekday skips months without one", rule: "FREQ=MONTHLY;BYDAY=5TH",
+			dtstart: "2024-01-01", from: "2024-01-01", to: "2024-06-30",
+			want: []string{"2024-02-29", "2024-05-30"},
+		},
+		{
+			name: "last day of the month", rule: "FREQ=MONTHLY;BYMONTHDAY=-1",
+			dtstart: "2024-01-01", from: "2024-01-01", to: "2024-04-30",
+			want: []string{"2024-01-31", "2024-02-29", "2024-03-31", "2024-04-30"},
+		},
+		{
+			name: "31st skips shorter months", rule: "FREQ=MONTHLY;BYMONTHDAY=31",
+			dtstart: "2024-01-01", from: "2024-01-01", to: "2024-07-31",
+			want: []string{"2024-01-31", "2024-03-31", "2024-05-31", "2024-07-31"},
+		},
+		{
+			name: "count stops expansion", rule: "FREQ=WEEKLY;BYDAY=TU,TH;COUNT=3",
+			dtstart: "2024-01-02", from: "2024-01-01", to: "2024-12-31",
+			want: []string{"2024-01-02", "2024-01-04", "2024-01-09"},
+		},
+		{
+			name: "count is counted from dtstart, not from", rule: "FREQ=DAILY;COUNT=5",
+			dtstart: "2024-01-01", from: "2024-01-04", to: "2024-12-31",
+			want: []string{"2024-01-04", "2024-01-05"},
+		},
+		{
+			name: "until is inclusive", rule: "FREQ=DAILY;INTERVAL=2;UNTIL=20240105",
+			dtstart: "2024-01-01", from: "2024-01-01", to: "2024-12-31",
+			want: []string{"2024-01-01", "2024-01-03", "2024-01-05"},
+		},
+		{
+			name: "until with a time", rule: "RRULE:FREQ=MONTHLY;BYMONTHDAY=10;UNTIL=20240310T235959Z",
+			dtstart: "2024-01-01", from: "2024-01-01", to: "2024-12-31",
+			want: []string{"2024-01-10", "2024-02-10", "2024-03-10"},
+		},
+		{
+			name: "nothing before dtstart", rule: "FREQ=MONTHLY;BYMONTHDAY=1,20",
+			dtstart: "2024-01-10", from: "2024-01-01", to: "2024-02-15",
+			want: []string{"2024-01-20", "2024-02-01"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := ParseRRule(tt.rule)
+			if err != nil {
+				t.Fatalf("ParseRRule(%q): %v", tt.rule, err)
+			}
+			var got []string
+			for _, d := range r.Between(mustDate(tt.dtstart), mustDate(tt.from), mustDate(tt.to)) {
+				got = append(got, d.Format("2006-01-02"))
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRRuleErrors(t *testing.T) {
+	for _, rule := range []string{
+		"",
+		"INTERVAL=2",
+		"FREQ=YEARLY",
+		"FREQ=DAILY;INTERVAL=0",
+		"FREQ=DAILY;COUNT=3;UNTIL=20240101",
+		"FREQ=DAILY;BYDAY=MO",
+		"FREQ=WEEKLY;BYMONTHDAY=1",
+		"FREQ=WEEKLY;BYDAY=1MO",
+		"FREQ=MONTHLY;BYDAY=6MO",
+		"FREQ=MONTHLY;BYDAY=XX",
+		"FREQ=MONTHLY;BYMONTHDAY=0",
+		"FREQ=MONTHLY;BYMONTHDAY=32",
+		"FREQ=MONTHLY;UNTIL=tomorrow",
+		"FREQ=MONTHLY;WKST=SU",
+		"FREQ=MONTHLY;BYSETPOS=1",
+		"FREQ",
+	} {
+		if _, err := ParseRRule(rule); err == nil {
+			t.Errorf("ParseRRule(%q) should fail", rule)
+		}
+	}
+}
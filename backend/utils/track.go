@@ -0,0 +1,248 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Supported activity file formats
+const (
+	TrackFormatGPX = "gpx"
+	TrackFormatTCX = "tcx"
+	TrackFormatFIT = "fit"
+)
+
+// earthRadiusM is the mean Earth radius used by Haversine
+const earthRadiusM = 6371008.8
+
+// elevationThresholdM filters GPS altitude noise: a climb only counts once it exceeds this
+const elevationThresholdM = 3.0
+
+var errEmptyTrack = errors.New("the file contains no timestamped track points")
+
+// TrackPoint is one recorded sample; any of position, elevation, heart rate and distance may be missing
+type TrackPoint struct {
+	Time      time.Time `json:"t"`
+	Lat       *float64  `json:"lat,omitempty"`
+	Lon       *float64  `json:"lon,omitempty"`
+	Elevation *float64  `json:"ele,omitempty"`
+	HeartRate int       `json:"hr,omitempty"`
+	DistanceM *float64  `json:"dist,omitempty"` // cumulative distance reported by the device
+}
+
+// Track is a parsed activity file
+type Track struct {
+	Format         string
+	Sport          string // running, cycling, walking, hiking, swimming, or "" when the file doesn't say
+	Points         []TrackPoint
+	DeviceCalories int // calories reported by the device, 0 when absent
+}
+
+// TrackSplit covers one kilometre of a track (the last split may be shorter)
+type TrackSplit struct {
+	Km             int     `json:"km"`
+	DistanceM      float64 `json:"distance_m"`
+	DurationSec    int     `json:"duration_sec"`
+	PaceSecPerKm   int     `json:"pace_sec_per_km"`
+	ElevationGainM float64 `json:"elevation_gain_m"`
+	AvgHeartRate   int     `json:"avg_heart_rate,omitempty"`
+}
+
+// TrackSummary holds the figures derived from a track
+type TrackSummary struct {
+	StartTime      time.Time    `json:"start_time"`
+	EndTime        time.Time    `json:"end_time"`
+	DurationSec    int          `json:"duration_sec"`
+	DistanceM      float64      `json:"distance_m"`
+	ElevationGainM float64      `json:"elevation_gain_m"`
+	AvgHeartRate   int          `json:"avg_heart_rate,omitempty"`
+	MaxHeartRate   int          `json:"max_heart_rate,omitempty"`
+	AvgSpeedKmh    float64      `json:"avg_speed_kmh"`
+	Splits         []TrackSplit `json:"splits"`
+}
+
+// ParseTrack detects the file format from its content and parses it
+func ParseTrack(data []byte) (*Track, error) {
+	var track *Track
+	var err error
+	switch {
+	case isFIT(data):
+		track, err = ParseFIT(data)
+	case bytes.Contains(firstBytes(data, 1024), []byte("<gpx")):
+		track, err = ParseGPX(data)
+	case bytes.Contains(firstBytes(data, 1024), []byte("<TrainingCenterDatabase")):
+		track, err = ParseTCX(data)
+	default:
+		return nil, errors.New("unrecognized file: expected GPX, TCX or FIT")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep only timestamped points, in time order
+	points := track.Points[:0]
+	for _, p := range track.Points {
+		if !p.Time.IsZero() {
+			points = append(points, p)
+		}
+	}
+	sort.SliceStable(points, func(i, j int) bool { return points[i].Time.Before(points[j].Time) })
+	if len(points) < 2 {
+		return nil, errEmptyTrack
+	}
+	track.Points = points
+	return track, nil
+}
+
+func firstBytes(data []byte, n int) []byte {
+	if len(data) < n {
+		return data
+	}
+	return data[:n]
+}
+
+// normalizeSport maps the sport names used by GPX, TCX and FIT files onto our own
+func normalizeSport(sport string) string {
+	s := strings.ToLower(strings.TrimSpace(sport))
+	switch {
+	case strings.Contains(s, "run"):
+		return "running"
+	case strings.Contains(s, "bik"), strings.Contains(s, "cycl"), strings.Contains(s, "ride"):
+		return "cycling"
+	case strings.Contains(s, "hik"):
+		return "hiking"
+	case strings.Contains(s, "walk"):
+		return "walking"
+	case strings.Contains(s, "swim"):
+		return "swimming"
+	}
+	return ""
+}
+
+// Haversine returns the great-circle distance in metres between two coordinates
+func Haversine(lat1, lon1, lat2, lon2 float64) float64 {
+	toRad := math.Pi / 180
+	dLat := (lat2 - lat1) * toRad
+	dLon := (lon2 - lon1) * toRad
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*toRad)*math.Cos(lat2*toRad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusM * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+// Summarize derives distance, duration, elevation gain, heart rate and per-km splits.
+// Distance comes from GPS positions when the track has them, otherwise from the device's
+// own distance field (e.g. a treadmill run).
+func (t *Track) Summarize() TrackSummary {
+	pts := t.Points
+	s := TrackSummary{
+		StartTime: pts[0].Time,
+		EndTime:   pts[len(pts)-1].Time,
+		Splits:    []TrackSplit{},
+	}
+	s.DurationSec = int(s.EndTime.Sub(s.StartTime).Seconds())
+
+	positioned := 0
+	for _, p := range pts {
+		if p.Lat != nil && p.Lon != nil {
+			positioned++
+		}
+	}
+	useGPS := positioned >= 2
+
+	// Cumulative distance at each point
+	cumulative := make([]float64, len(pts))
+	var lastLat, lastLon *float64
+	for i, p := range pts {
+		if i > 0 {
+			cumulative[i] = cumulative[i-1]
+		}
+		if useGPS {
+			if p.Lat != nil && p.Lon != nil {
+				if lastLat != nil {
+					cumulative[i] += Haversine(*lastLat, *lastLon, *p.Lat, *p.Lon)
+				}
+				lastLat, lastLon = p.Lat, p.Lon
+			}
+		} else if p.DistanceM != nil && *p.DistanceM > cumulative[i] {
+			cumulative[i] = *p.DistanceM
+		}
+	}
+	s.DistanceM = round1(cumulative[len(cumulative)-1])
+	if s.DurationSec > 0 {
+		s.AvgSpeedKmh = round1(s.DistanceM / float64(s.DurationSec) * 3.6)
+	}
+
+	hrSum, hrCount := 0, 0
+	for _, p := range pts {
+		if p.HeartRate > 0 {
+			hrSum += p.HeartRate
+			hrCount++
+			if p.HeartRate > s.MaxHeartRate {
+				s.MaxHeartRate = p.HeartRate
+			}
+		}
+	}
+	if hrCount > 0 {
+		s.AvgHeartRate = int(math.Round(float64(hrSum) / float64(hrCount)))
+	}
+
+	// Elevation gain with a hysteresis threshold, tracked per split as well
+	var ref *float64
+	gains := make([]float64, len(pts))
+	for i, p := range pts {
+		if p.Elevation == nil {
+			continue
+		}
+		e := *p.Elevation
+		switch {
+		case ref == nil || e < *ref:
+			ref = &e
+		case e-*ref >= elevationThresholdM:
+			gains[i] = e - *ref
+			s.ElevationGainM += e - *ref
+			ref = &e
+		}
+	}
+	s.ElevationGainM = round1(s.ElevationGainM)
+
+	// Per-km splits
+	splitStart := 0
+	for i := 1; i < len(pts); i++ {
+		last := i == len(pts)-1
+		km := len(s.Splits) + 1
+		if cumulative[i] < float64(km)*1000 && !last {
+			continue
+		}
+		split := TrackSplit{Km: km}
+		split.DistanceM = round1(cumulative[i] - cumulative[splitStart])
+		split.DurationSec = int(pts[i].Time.Sub(pts[splitStart].Time).Seconds())
+		if split.DistanceM > 0 {
+			split.PaceSecPerKm = int(math.Round(float64(split.DurationSec) / split.DistanceM * 1000))
+		}
+		sum, n := 0, 0
+		for j := splitStart + 1; j <= i; j++ {
+			split.ElevationGainM += gains[j]
+			if pts[j].HeartRate > 0 {
+				sum += pts[j].HeartRate
+				n++
+			}
+		}
+		split.ElevationGainM = round1(split.ElevationGainM)
+		if n > 0 {
+			split.AvgHeartRate = int(math.Round(float64(sum) / float64(n)))
+		}
+		if split.DistanceM > 0 {
+			s.Splits = append(s.Splits, split)
+		}
+		splitStart = i
+	}
+	return s
+}
+
+func round1(v float64) float64 {
+	return math.Round(v*10) / 10
+}
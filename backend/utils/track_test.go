@@ -0,0 +1,282 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fitBuilder assembles a FIT file from raw messages for tests
+type fitBuilder struct {
+	body bytes.Buffer
+}
+
+// define writes a little-endian definition message; fields are (number, size) pairs
+func (b *fitBuilder) define(local byte, global uint16, fields ...[2]byte) {
+	b.body.WriteByte(0x40 | local)
+	b.body.Write([]byte{0, 0})
+	binary.Write(&b.body, binary.LittleEndian, global)
+	b.body.WriteByte(byte(len(fields)))
+	for _, f := range fields {
+		b.body.Write([]byte{f[0], f[1], 0})
+	}
+}
+
+// data writes a data message with a normal or compressed timestamp header
+func (b *fitBuilder) data(header byte, values ...interface{}) {
+	b.body.WriteByte(header)
+	for _, v := range values {
+		binary.Write(&b.body, binary.LittleEndian, v)
+	}
+}
+
+func (b *fitBuilder) bytes() []byte {
+	var out bytes.Buffer
+	out.Write([]byte{12, 0x10, 0x08, 0x08})
+	binary.Write(&out, binary.LittleEndian, uint32(b.body.Len()))
+	out.WriteString(".FIT")
+	out.Write(b.body.Bytes())
+	out.Write([]byte{0, 0}) // CRC, not checked
+	return out.Bytes()
+}
+
+func semicircles(deg float64) int32 {
+	return int32(math.Round(deg / fitSemicirclesToDeg))
+}
+
+// sampleFIT is a running activity: two full records, one with a compressed timestamp, and a session
+func sampleFIT() []byte {
+	var b fitBuilder
+	b.define(0, fitMesgRecord, [2]byte{253, 4}, [2]byte{0, 4}, [2]byte{1, 4}, [2]byte{2, 2}, [2]byte{3, 1}, [2]byte{5, 4})
+	b.data(0x00, uint32(1000000000), semicircles(45), semicircles(7), uint16(3000), uint8(120), uint32(0))
+	b.data(0x00, uint32(1000000005), semicircles(45.001), semicircles(7), uint16(3010), uint8(130), uint32(12345))
+	b.define(1, fitMesgRecord, [2]byte{3, 1}, [2]byte{5, 4})
+	b.data(0x80|1<<5|3, uint8(140), uint32(0xFFFFFFFF))
+	b.define(2, fitMesgSession, [2]byte{fitSessionSport, 1}, [2]byte{fitSessionCalories, 2})
+	b.data(0x02, uint8(1), uint16(321))
+	return b.bytes()
+}
+
+func fitTime(ts int64) time.Time {
+	return time.Unix(ts+fitEpochOffsetSeconds, 0).UTC()
+}
+
+func TestParseFIT(t *testing.T) {
+	track, err := ParseTrack(sampleFIT())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if track.Format != TrackFormatFIT || track.Sport != "running" || track.DeviceCalories != 321 {
+		t.Errorf("got format %q, sport %q, calories %d", track.Format, track.Sport, track.DeviceCalories)
+	}
+	if len(track.Points) != 3 {
+		t.Fatalf("got %d points, want 3", len(track.Points))
+	}
+
+	p := track.Points[1]
+	if !p.Time.Equal(fitTime(1000000005)) {
+		t.Errorf("time %v", p.Time)
+	}
+	if p.Lat == nil || math.Abs(*p.Lat-45.001) > 1e-6 || p.Lon == nil || math.Abs(*p.Lon-7) > 1e-6 {
+		t.Errorf("position %v, %v", p.Lat, p.Lon)
+	}
+	if p.Elevation == nil || *p.Elevation != 102 {
+		t.Errorf("elevation %v, want 102", p.Elevation)
+	}
+	if p.DistanceM == nil || *p.DistanceM != 123.45 || p.HeartRate != 130 {
+		t.Errorf("distance %v, heart rate %d", p.DistanceM, p.HeartRate)
+	}
+
+	// The compressed offset 3 is below the last timestamp's low bits (5), so it rolls over
+	last := track.Points[2]
+	if !last.Time.Equal(fitTime(1000000035)) {
+		t.Errorf("compressed timestamp %v, want %v", last.Time, fitTime(1000000035))
+	}
+	if last.DistanceM != nil || last.Lat != nil || last.HeartRate != 140 {
+		t.Errorf("invalid fields should be left out: %+v", last)
+	}
+}
+
+func TestParseFITMalformed(t *testing.T) {
+	valid := sampleFIT()
+
+	var undefined fitBuilder
+	undefined.data(0x03, uint8(1))
+
+	badHeader := append([]byte{}, valid...)
+	badHeader[0] = 200
+
+	oversized := append([]byte{}, valid...)
+	binary.LittleEndian.PutUint32(oversized[4:8], uint32(len(valid)))
+
+	for name, data := range map[string][]byte{
+		"no signature":          []byte("not a fit file at all"),
+		"header size too large": badHeader,
+		"data size past end":    oversized,
+		"undefined local type":  undefined.bytes(),
+	} {
+		if _, err := ParseFIT(data); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+
+	// Every truncation and every corrupted byte must fail cleanly or parse, never panic
+	for n := 0; n < len(valid); n++ {
+		truncated := append([]byte{}, valid[:n]...)
+		if n >= 8 {
+			binary.LittleEndian.PutUint32(truncated[4:8], uint32(max(0, n-12)))
+		}
+		ParseTrack(truncated)
+	}
+	for i := range valid {
+		for _, v := range []byte{0x00, 0x7F, 0xFF} {
+			corrupted := append([]byte{}, valid...)
+			corrupted[i] = v
+			ParseTrack(corrupted)
+		}
+	}
+}
+
+const sampleGPX = `<?xml version="1.0" encoding="UTF-8"?>
+<gpx version="1.1" creator="test" xmlns="http://www.topografix.com/GPX/1/1"
+     xmlns:gpxtpx="http://www.garmin.com/xmlschemas/TrackPointExtension/v1">
+  <trk>
+    <type>Run</type>
+    <trkseg>
+      <trkpt lat="51.5000" lon="-0.1200"><ele>10</ele><time>2024-05-01T07:00:10Z</time>
+        <extensions><gpxtpx:TrackPointExtension><gpxtpx:hr>150</gpxtpx:hr></gpxtpx:TrackPointExtension></extensions>
+      </trkpt>
+      <trkpt lat="51.4990" lon="-0.1200"><ele>9</ele><time>2024-05-01T07:00:00Z</time>
+        <extensions><gpxtpx:TrackPointExtension><gpxtpx:hr>140</gpxtpx:hr></gpxtpx:TrackPointExtension></extensions>
+      </trkpt>
+      <trkpt lat="51.5010" lon="-0.1200"><ele>15</ele></trkpt>
+    </trkseg>
+  </trk>
+</gpx>`
+
+func TestParseGPX(t *testing.T) {
+	track, err := ParseTrack([]byte(sampleGPX))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if track.Format != TrackFormatGPX || track.Sport != "running" {
+		t.Errorf("got format %q, sport %q", track.Format, track.Sport)
+	}
+	// The untimestamped point is dropped and the others are put in time order
+	if len(track.Points) != 2 {
+		t.Fatalf("got %d points, want 2", len(track.Points))
+	}
+	first, second := track.Points[0], track.Points[1]
+	if first.HeartRate != 140 || *first.Lat != 51.499 || *first.Elevation != 9 || second.HeartRate != 150 {
+		t.Errorf("points out of order or misread: %+v %+v", first, second)
+	}
+}
+
+const sampleTCX = `<?xml version="1.0" encoding="UTF-8"?>
+<TrainingCenterDatabase xmlns="http://www.garmin.com/xmlschemas/TrainingCenterDatabase/v2">
+  <Activities>
+    <Activity Sport="Biking">
+      <Lap><Calories>100</Calories><Track>
+        <Trackpoint><Time>2024-05-01T07:00:00Z</Time><DistanceMeters>0</DistanceMeters><HeartRateBpm><Value>120</Value></HeartRateBpm></Trackpoint>
+        <Trackpoint><Time>2024-05-01T07:02:00Z</Time><DistanceMeters>1000</DistanceMeters></Trackpoint>
+      </Track></Lap>
+      <Lap><Calories>50</Calories><Track>
+        <Trackpoint><Time>2024-05-01T07:03:00Z</Time><DistanceMeters>1500</DistanceMeters>
+          <Position><LatitudeDegrees>48.1</LatitudeDegrees><LongitudeDegrees>11.5</LongitudeDegrees></Position>
+          <AltitudeMeters>520.5</AltitudeMeters></Trackpoint>
+      </Track></Lap>
+    </Activity>
+  </Activities>
+</TrainingCenterDatabase>`
+
+func TestParseTCX(t *testing.T) {
+	track, err := ParseTrack([]byte(sampleTCX))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if track.Format != TrackFormatTCX || track.Sport != "cycling" || track.DeviceCalories != 150 {
+		t.Errorf("got format %q, sport %q, calories %d", track.Format, track.Sport, track.DeviceCalories)
+	}
+	if len(track.Points) != 3 {
+		t.Fatalf("got %d points, want 3", len(track.Points))
+	}
+	if track.Points[0].HeartRate != 120 || track.Points[0].Lat != nil {
+		t.Errorf("first point %+v", track.Points[0])
+	}
+	last := track.Points[2]
+	if *last.DistanceM != 1500 || *last.Lat != 48.1 || *last.Elevation != 520.5 {
+		t.Errorf("last point %+v", last)
+	}
+}
+
+func TestParseTrackRejects(t *testing.T) {
+	for name, data := range map[string]string{
+		"empty":           "",
+		"unknown format":  "lat,lon\n1,2\n",
+		"broken gpx":      `<gpx><trk><trkseg><trkpt lat="1" lon="2"><time>`,
+		"broken tcx":      `<TrainingCenterDatabase><Activities><Activity>`,
+		"one point":       `<gpx><trk><trkseg><trkpt lat="1" lon="2"><time>2024-05-01T07:00:00Z</time></trkpt></trkseg></trk></gpx>`,
+		"no timestamps":   `<gpx><trk><trkseg><trkpt lat="1" lon="2"/><trkpt lat="1" lon="3"/></trkseg></trk></gpx>`,
+		"bad coordinates": `<gpx><trk><trkseg><trkpt lat="north" lon="2"><time>2024-05-01T07:00:00Z</time></trkpt></trkseg></trk></gpx>`,
+	} {
+		if _, err := ParseTrack([]byte(data)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	start := time.Date(2024, 5, 1, 7, 0, 0, 0, time.UTC)
+	point := func(sec int, distance, elevation float64, hr int) TrackPoint {
+		return TrackPoint{Time: start.Add(time.Duration(sec) * time.Second), DistanceM: &distance, Elevation: &elevation, HeartRate: hr}
+	}
+	// A treadmill-style track: device distance only, with 2 m of elevation noise and a 5 m climb
+	track := &Track{Points: []TrackPoint{
+		point(0, 0, 100, 140),
+		point(150, 500, 102, 150),
+		point(300, 1000, 100, 160),
+		point(450, 1500, 105, 170),
+	}}
+	s := track.Summarize()
+	if s.DistanceM != 1500 || s.DurationSec != 450 || s.AvgSpeedKmh != 12 {
+		t.Errorf("distance %v, duration %d, speed %v", s.DistanceM, s.DurationSec, s.AvgSpeedKmh)
+	}
+	if s.ElevationGainM != 5 || s.AvgHeartRate != 155 || s.MaxHeartRate != 170 {
+		t.Errorf("gain %v, avg hr %d, max hr %d", s.ElevationGainM, s.AvgHeartRate, s.MaxHeartRate)
+	}
+	if len(s.Splits) != 2 {
+		t.Fatalf("got %d splits, want 2", len(s.Splits))
+	}
+	if sp := s.Splits[0]; sp.DistanceM != 1000 || sp.DurationSec != 300 || sp.PaceSecPerKm != 300 || sp.AvgHeartRate != 155 {
+		t.Errorf("first split %+v", sp)
+	}
+	if sp := s.Splits[1]; sp.Km != 2 || sp.DistanceM != 500 || sp.PaceSecPerKm != 300 || sp.ElevationGainM != 5 {
+		t.Errorf("last split %+v", sp)
+	}
+}
+
+func TestHaversine(t *testing.T) {
+	// One degree of latitude is about 111.2 km; London to Paris about 343.5 km
+	if d := Haversine(0, 0, 1, 0); math.Abs(d-111195) > 1 {
+		t.Errorf("one degree: %v m", d)
+	}
+	if d := Haversine(51.5074, -0.1278, 48.8566, 2.3522); math.Abs(d-343556) > 500 {
+		t.Errorf("London to Paris: %v m", d)
+	}
+}
+
+func FuzzParseTrack(f *testing.F) {
+	f.Add(sampleFIT())
+	f.Add([]byte(sampleGPX))
+	f.Add([]byte(sampleTCX))
+	f.Add([]byte(strings.Repeat("<gpx>", 10)))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		track, err := ParseTrack(data)
+		if err == nil {
+			track.Summarize()
+		}
+	})
+}
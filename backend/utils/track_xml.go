@@ -0,0 +1,132 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// gpxFile covers the parts of GPX 1.1 we read, including the Garmin TrackPointExtension heart rate
+type gpxFile struct {
+	Tracks []struct {
+		Type     string `xml:"type"`
+		Segments []struct {
+			Points []struct {
+				Lat        float64  `xml:"lat,attr"`
+				Lon        float64  `xml:"lon,attr"`
+				Elevation  *float64 `xml:"ele"`
+				Time       string   `xml:"time"`
+				Extensions struct {
+					InnerXML string `xml:",innerxml"`
+				} `xml:"extensions"`
+			} `xml:"trkpt"`
+		} `xml:"trkseg"`
+	} `xml:"trk"`
+}
+
+// tcxFile covers the parts of a Garmin Training Center file we read
+type tcxFile struct {
+	Activities []struct {
+		Sport string `xml:"Sport,attr"`
+		Laps  []struct {
+			Calories int `xml:"Calories"`
+			Points   []struct {
+				Time     string `xml:"Time"`
+				Position *struct {
+					Lat float64 `xml:"LatitudeDegrees"`
+					Lon float64 `xml:"LongitudeDegrees"`
+				} `xml:"Position"`
+				Altitude  *float64 `xml:"AltitudeMeters"`
+				Distance  *float64 `xml:"DistanceMeters"`
+				HeartRate *struct {
+					Value int `xml:"Value"`
+				} `xml:"HeartRateBpm"`
+			} `xml:"Track>Trackpoint"`
+		} `xml:"Lap"`
+	} `xml:"Activities>Activity"`
+}
+
+// ParseGPX parses a GPX track
+func ParseGPX(data []byte) (*Track, error) {
+	var f gpxFile
+	if err := xml.NewDecoder(bytes.NewReader(data)).Decode(&f); err != nil {
+		return nil, fmt.Errorf("invalid GPX file: %v", err)
+	}
+
+	track := &Track{Format: TrackFormatGPX}
+	for _, trk := range f.Tracks {
+		if track.Sport == "" {
+			track.Sport = normalizeSport(trk.Type)
+		}
+		for _, seg := range trk.Segments {
+			for _, pt := range seg.Points {
+				lat, lon := pt.Lat, pt.Lon
+				p := TrackPoint{Lat: &lat, Lon: &lon, Elevation: pt.Elevation}
+				p.Time, _ = time.Parse(time.RFC3339, strings.TrimSpace(pt.Time))
+				p.HeartRate = gpxHeartRate(pt.Extensions.InnerXML)
+				track.Points = append(track.Points, p)
+			}
+		}
+	}
+	return track, nil
+}
+
+// gpxHeartRate reads <gpxtpx:hr> (or any <hr> element) from a trackpoint's extensions
+func gpxHeartRate(extensions string) int {
+	if extensions == "" {
+		return 0
+	}
+	decoder := xml.NewDecoder(strings.NewReader("<x>" + extensions + "</x>"))
+	inHR := false
+	for {
+		tok, err := decoder.Token()
+		if err != nil {
+			return 0
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			inHR = t.Name.Local == "hr"
+		case xml.CharData:
+			if inHR {
+				var hr int
+				fmt.Sscanf(strings.TrimSpace(string(t)), "%d", &hr)
+				return hr
+			}
+		case xml.EndElement:
+			inHR = false
+		}
+	}
+}
+
+// ParseTCX parses a Garmin Training Center (TCX) activity
+func ParseTCX(data []byte) (*Track, error) {
+	var f tcxFile
+	if err := xml.NewDecoder(bytes.NewReader(data)).Decode(&f); err != nil {
+		return nil, fmt.Errorf("invalid TCX file: %v", err)
+	}
+
+	track := &Track{Format: TrackFormatTCX}
+	for _, activity := range f.Activities {
+		if track.Sport == "" {
+			track.Sport = normalizeSport(activity.Sport)
+		}
+		for _, lap := range activity.Laps {
+			track.DeviceCalories += lap.Calories
+			for _, pt := range lap.Points {
+				p := TrackPoint{Elevation: pt.Altitude, DistanceM: pt.Distance}
+				p.Time, _ = time.Parse(time.RFC3339, strings.TrimSpace(pt.Time))
+				if pt.Position != nil {
+					lat, lon := pt.Position.Lat, pt.Position.Lon
+					p.Lat, p.Lon = &lat, &lon
+				}
+				if pt.HeartRate != nil {
+					p.HeartRate = pt.HeartRate.Value
+				}
+				track.Points = append(track.Points, p)
+			}
+		}
+	}
+	return track, nil
+}
@@ -0,0 +1,56 @@
+package utils
+
+import "math"
+
+// DayValue is a reading on a calendar day, with days counted from any fixed origin
+type DayValue struct {
+	Day   int
+	Value float64
+}
+
+// EMAPoint is the smoothed value on one day. Observed is false on days without a reading.
+type EMAPoint struct {
+	Day      int
+	Trend    float64
+	Observed bool
+}
+
+// DailyEMA smooths readings (sorted by day, at most one per day) into an exponentially weighted
+// moving average with one point per day, from the first reading through lastDay.
+//
+// alpha is the weight of a reading taken the day after the previous one. After a gap of n days a
+// reading weighs 1-(1-alpha)^n, as if the trend had been updated on each missed day, so a reading
+// after a week away moves the trend more than one of a daily series. Days inside a gap are
+// interpolated between the trends either side of it. A gap longer than maxGap days restarts the
+// trend at the next reading, since the old one says little about it. Days after the last reading
+// keep its trend.
+func DailyEMA(readings []DayValue, alpha float64, maxGap, lastDay int) []EMAPoint {
+	if len(readings) == 0 {
+		return nil
+	}
+	first := readings[0].Day
+	end := max(lastDay, readings[len(readings)-1].Day)
+	points := make([]EMAPoint, end-first+1)
+
+	trend := readings[0].Value
+	points[0] = EMAPoint{Day: first, Trend: trend, Observed: true}
+	prevDay := first
+	for _, r := range readings[1:] {
+		gap := r.Day - prevDay
+		prev := trend
+		if gap > maxGap {
+			trend = r.Value
+		} else {
+			trend += (1 - math.Pow(1-alpha, float64(gap))) * (r.Value - trend)
+		}
+		for d := 1; d < gap; d++ {
+			points[prevDay+d-first] = EMAPoint{Day: prevDay + d, Trend: prev + (trend-prev)*float64(d)/float64(gap)}
+		}
+		points[r.Day-first] = EMAPoint{Day: r.Day, Trend: trend, Observed: true}
+		prevDay = r.Day
+	}
+	for d := prevDay + 1; d <= end; d++ {
+		points[d-first] = EMAPoint{Day: d, Trend: trend}
+	}
+	return points
+}
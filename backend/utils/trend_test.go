@@ -0,0 +1,79 @@
+package utils
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDailyEMA(t *testing.T) {
+	tests := []struct {
+		name     string
+		readings []DayValue
+		maxGap   int
+		lastDay  int
+		trend    []float64 // from the first reading's day
+		observed []bool
+	}{
+		{
+			name:     "daily readings",
+			readings: []DayValue{{10, 100}, {11, 98}, {12, 99}},
+			maxGap:   7, lastDay: 12,
+			trend:    []float64{100, 99, 99},
+			observed: []bool{true, true, true},
+		},
+		{
+			name:     "a gap weighs the next reading as if each missed day had been updated",
+			readings: []DayValue{{0, 100}, {2, 96}},
+			maxGap:   7, lastDay: 2,
+			trend:    []float64{100, 98.5, 97},
+			observed: []bool{true, false, true},
+		},
+		{
+			name:     "a gap of exactly maxGap still smooths",
+			readings: []DayValue{{0, 100}, {3, 92}},
+			maxGap:   3, lastDay: 3,
+			trend:    []float64{100, 100 - 7.0/3, 100 - 14.0/3, 93},
+			observed: []bool{true, false, false, true},
+		},
+		{
+			name:     "a longer gap restarts the trend at the next reading",
+			readings: []DayValue{{0, 100}, {5, 90}, {6, 92}},
+			maxGap:   3, lastDay: 6,
+			trend:    []float64{100, 98, 96, 94, 92, 90, 91},
+			observed: []bool{true, false, false, false, false, true, true},
+		},
+		{
+			name:     "days after the last reading keep its trend",
+			readings: []DayValue{{0, 80}},
+			maxGap:   7, lastDay: 2,
+			trend:    []float64{80, 80, 80},
+			observed: []bool{true, false, false},
+		},
+		{
+			name:     "lastDay before the last reading is ignored",
+			readings: []DayValue{{0, 80}, {1, 82}},
+			maxGap:   7, lastDay: -5,
+			trend:    []float64{80, 81},
+			observed: []bool{true, true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			points := DailyEMA(tt.readings, 0.5, tt.maxGap, tt.lastDay)
+			if len(points) != len(tt.trend) {
+				t.Fatalf("got %d points, want %d", len(points), len(tt.trend))
+			}
+			for i, p := range points {
+				if p.Day != tt.readings[0].Day+i || math.Abs(p.Trend-tt.trend[i]) > 1e-9 || p.Observed != tt.observed[i] {
+					t.Errorf("point %d = %+v, want day %d trend %v observed %v",
+						i, p, tt.readings[0].Day+i, tt.trend[i], tt.observed[i])
+				}
+			}
+		})
+	}
+
+	if points := DailyEMA(nil, 0.5, 7, 10); points != nil {
+		t.Errorf("no readings should give no points, got %v", points)
+	}
+}